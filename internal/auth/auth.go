@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"car_catalog/internal/config"
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type Identity struct {
+	Name string
+	Role string
+}
+
+type contextKey struct{}
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
+
+// Middleware authenticates requests by API key, taken from the X-API-Key
+// header or an "Authorization: Bearer" header, and stores the caller's
+// identity in the request context. When auth is disabled every request passes
+// through untouched. Paths in public are never authenticated.
+func Middleware(cfg config.AuthConfig, public []string, next http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range public {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+
+		identity, ok := lookup(cfg.Keys, key)
+		if !ok {
+			log.Printf("[INFO] Auth - Rejected request to %s: missing or unknown API key", r.URL.Path)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("[DEBUG] Auth - Authenticated %q with role %q", identity.Name, identity.Role)
+
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
+
+// Authenticate resolves an API key the way Middleware does, for transports
+// other than HTTP.
+func Authenticate(cfg config.AuthConfig, key string) (Identity, bool) {
+	return lookup(cfg.Keys, key)
+}
+
+// lookup compares against every configured key in constant time so response
+// timing does not reveal how much of a key matched.
+func lookup(keys []config.APIKey, key string) (Identity, bool) {
+	var (
+		found    Identity
+		matched  bool
+		provided = []byte(key)
+	)
+	if key == "" {
+		return Identity{}, false
+	}
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare(provided, []byte(k.Key)) == 1 {
+			found = Identity{Name: k.Name, Role: k.Role}
+			matched = true
+		}
+	}
+	return found, matched
+}
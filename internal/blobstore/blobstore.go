@@ -0,0 +1,56 @@
+// Package blobstore keeps the contents of car attachments. Blobs are
+// addressed by the hex SHA-256 of their content, so equal files are stored
+// once however many attachments refer to them.
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("blob key is not a hex SHA-256")
+)
+
+// Blob describes stored content. ModTime is when the content was last put.
+type Blob struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store keeps blobs by the SHA-256 of their content.
+type Store interface {
+	// Put reads r to the end and stores it under the hex SHA-256 of what was
+	// read. Putting content that is already stored keeps one copy and only
+	// refreshes its ModTime. Nothing is stored when reading r fails.
+	Put(ctx context.Context, r io.Reader) (Blob, error)
+	// Open returns the content of a blob; the caller closes it.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, Blob, error)
+	// Delete removes a blob; deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// DeleteIfOlder removes a blob unless it was put after cutoff, and
+	// reports whether it did. A concurrent Put of the same content either
+	// keeps the blob or stores it again, so it is never lost to a sweep.
+	DeleteIfOlder(ctx context.Context, key string, cutoff time.Time) (bool, error)
+	// List returns every stored blob.
+	List(ctx context.Context) ([]Blob, error)
+}
+
+// validKey reports whether key can be a blob key: 64 lower-case hex digits.
+// Keys become file paths, so nothing else may reach the disk.
+func validKey(key string) bool {
+	if len(key) != 2*sha256.Size {
+		return false
+	}
+	for _, c := range key {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// tmpDir holds uploads until their key is known; it lives inside the store
+// so the final rename never crosses file systems.
+const tmpDir = "tmp"
+
+// LocalStore keeps blobs as files under Dir, at <Dir>/<first two digits of
+// the key>/<key>.
+type LocalStore struct {
+	Dir string
+
+	// mu orders the store step of Put against DeleteIfOlder.
+	mu sync.Mutex
+}
+
+// NewLocalStore opens the store at dir, creating the directory if needed.
+func NewLocalStore(dir string) (Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, tmpDir), 0o755); err != nil {
+		log.Printf("[ERROR] BlobStore - Unable to create %s: %v", dir, err)
+		return nil, err
+	}
+	log.Printf("[INFO] BlobStore - Storing blobs in %s", dir)
+	return &LocalStore{Dir: dir}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.Dir, key[:2], key)
+}
+
+func (s *LocalStore) Put(ctx context.Context, r io.Reader) (Blob, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.Dir, tmpDir), "upload-*")
+	if err != nil {
+		log.Printf("[ERROR] BlobStore - Put - Unable to create a temporary file: %v", err)
+		return Blob{}, err
+	}
+	// Once renamed the temporary file is gone and Remove fails harmlessly.
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Blob{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob := Blob{Key: hex.EncodeToString(hash.Sum(nil)), Size: size, ModTime: time.Now()}
+	path := s.path(blob.Key)
+	if _, err := os.Stat(path); err == nil {
+		// Already stored: refresh ModTime so a sweep does not take the blob
+		// away from the attachment about to refer to it.
+		if err := os.Chtimes(path, blob.ModTime, blob.ModTime); err != nil {
+			log.Printf("[ERROR] BlobStore - Put - Unable to touch %s: %v", blob.Key, err)
+			return Blob{}, err
+		}
+		log.Printf("[DEBUG] BlobStore - Put - Blob %s is already stored", blob.Key)
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("[ERROR] BlobStore - Put - Unable to create the directory of %s: %v", blob.Key, err)
+		return Blob{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("[ERROR] BlobStore - Put - Unable to store %s: %v", blob.Key, err)
+		return Blob{}, err
+	}
+
+	log.Printf("[INFO] BlobStore - Put - Stored blob %s of %d bytes", blob.Key, blob.Size)
+	return blob, nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, Blob, error) {
+	if !validKey(key) {
+		return nil, Blob{}, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Blob{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		log.Printf("[ERROR] BlobStore - Open - Unable to open %s: %v", key, err)
+		return nil, Blob{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Printf("[ERROR] BlobStore - Open - Unable to stat %s: %v", key, err)
+		return nil, Blob{}, err
+	}
+	return f, Blob{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[ERROR] BlobStore - Delete - Unable to delete %s: %v", key, err)
+		return err
+	}
+	log.Printf("[INFO] BlobStore - Delete - Deleted blob %s", key)
+	return nil
+}
+
+func (s *LocalStore) DeleteIfOlder(ctx context.Context, key string, cutoff time.Time) (bool, error) {
+	if !validKey(key) {
+		return false, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] BlobStore - DeleteIfOlder - Unable to stat %s: %v", key, err)
+		return false, err
+	}
+	if info.ModTime().After(cutoff) {
+		log.Printf("[DEBUG] BlobStore - DeleteIfOlder - Blob %s was put again, keeping it", key)
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[ERROR] BlobStore - DeleteIfOlder - Unable to delete %s: %v", key, err)
+		return false, err
+	}
+	log.Printf("[INFO] BlobStore - DeleteIfOlder - Deleted blob %s", key)
+	return true, nil
+}
+
+func (s *LocalStore) List(ctx context.Context) ([]Blob, error) {
+	var blobs []Blob
+	err := filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == tmpDir && filepath.Dir(path) == filepath.Clean(s.Dir) {
+				return filepath.SkipDir
+			}
+			return ctx.Err()
+		}
+		if !validKey(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, Blob{Key: d.Name(), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] BlobStore - List - Unable to walk %s: %v", s.Dir, err)
+		return nil, err
+	}
+	return blobs, nil
+}
@@ -0,0 +1,137 @@
+package blobstore_test
+
+import (
+	"car_catalog/internal/blobstore"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+var ctx = context.Background()
+
+func TestLocalStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blobs")
+	store, err := blobstore.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("front photo"))
+	key := hex.EncodeToString(sum[:])
+	blob, err := store.Put(ctx, strings.NewReader("front photo"))
+	if err != nil || blob.Key != key || blob.Size != 11 {
+		t.Fatalf("Put = %+v, %v, want key %s", blob, err, key)
+	}
+
+	// Storing the same content again keeps one copy with a fresh ModTime.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, key[:2], key), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := store.Put(ctx, strings.NewReader("front photo")); err != nil || again.Key != key {
+		t.Fatalf("second Put = %+v, %v", again, err)
+	}
+	if _, err := store.Put(ctx, strings.NewReader("registration scan")); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := store.List(ctx)
+	if err != nil || len(blobs) != 2 {
+		t.Fatalf("List = %+v, %v, want 2 blobs", blobs, err)
+	}
+	for _, b := range blobs {
+		if b.Key == key && !b.ModTime.After(old) {
+			t.Fatalf("ModTime of the blob put again = %s, want after %s", b.ModTime, old)
+		}
+	}
+
+	f, opened, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if string(content) != "front photo" || opened.Size != 11 {
+		t.Fatalf("Open = %q, %+v", content, opened)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Open(ctx, key); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Fatalf("Open after Delete: %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+
+	for _, bad := range []string{"../../etc/passwd", strings.ToUpper(key), key[:63]} {
+		if _, _, err := store.Open(ctx, bad); !errors.Is(err, blobstore.ErrInvalidKey) {
+			t.Fatalf("Open(%q): %v, want ErrInvalidKey", bad, err)
+		}
+	}
+}
+
+func TestLocalStoreDeleteIfOlder(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blobstore.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := store.Put(ctx, strings.NewReader("rear photo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, blob.Key[:2], blob.Key), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sweep listed the blob as old, then an upload put the same content.
+	cutoff := time.Now().Add(-time.Minute)
+	if _, err := store.Put(ctx, strings.NewReader("rear photo")); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := store.DeleteIfOlder(ctx, blob.Key, cutoff); err != nil || deleted {
+		t.Fatalf("DeleteIfOlder of a blob put again = %t, %v, want it kept", deleted, err)
+	}
+	if _, _, err := store.Open(ctx, blob.Key); err != nil {
+		t.Fatalf("Open after a refused delete: %v", err)
+	}
+
+	if deleted, err := store.DeleteIfOlder(ctx, blob.Key, time.Now().Add(time.Minute)); err != nil || !deleted {
+		t.Fatalf("DeleteIfOlder = %t, %v, want deleted", deleted, err)
+	}
+	if deleted, err := store.DeleteIfOlder(ctx, blob.Key, time.Now()); err != nil || deleted {
+		t.Fatalf("DeleteIfOlder of a missing blob = %t, %v", deleted, err)
+	}
+	if _, err := store.DeleteIfOlder(ctx, "../../etc/passwd", time.Now()); !errors.Is(err, blobstore.ErrInvalidKey) {
+		t.Fatalf("DeleteIfOlder of a bad key: %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestLocalStoreFailedRead(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blobstore.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if _, err := store.Put(ctx, failing); err == nil {
+		t.Fatal("Put of a failing reader succeeded")
+	}
+	if blobs, err := store.List(ctx); err != nil || len(blobs) != 0 {
+		t.Fatalf("List after a failed Put = %+v, %v", blobs, err)
+	}
+	if left, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(left) != 0 {
+		t.Fatalf("temporary files left behind: %v", left)
+	}
+}
@@ -0,0 +1,206 @@
+package carinfo
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/regnum"
+	"container/list"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheCounters add up what every CacheProvider of the process does; see
+// ReadCacheStats.
+var cacheCounters struct {
+	hits, negativeHits, misses, storeHits, coalesced, evictions, upstreamErrors atomic.Int64
+}
+
+// CacheStats counts the lookups of the caches since the process started.
+type CacheStats struct {
+	Hits           int64 `json:"hits"`
+	NegativeHits   int64 `json:"negative_hits"`
+	Misses         int64 `json:"misses"`
+	StoreHits      int64 `json:"store_hits"`
+	Coalesced      int64 `json:"coalesced"`
+	Evictions      int64 `json:"evictions"`
+	UpstreamErrors int64 `json:"upstream_errors"`
+}
+
+// ReadCacheStats returns the current counters.
+func ReadCacheStats() CacheStats {
+	return CacheStats{
+		Hits:           cacheCounters.hits.Load(),
+		NegativeHits:   cacheCounters.negativeHits.Load(),
+		Misses:         cacheCounters.misses.Load(),
+		StoreHits:      cacheCounters.storeHits.Load(),
+		Coalesced:      cacheCounters.coalesced.Load(),
+		Evictions:      cacheCounters.evictions.Load(),
+		UpstreamErrors: cacheCounters.upstreamErrors.Load(),
+	}
+}
+
+// CacheEntry is one cached answer. NotFound entries record that the plate is
+// unknown upstream, so repeated lookups of a bad plate stay local too.
+type CacheEntry struct {
+	Car      dto.AddCarsDto
+	NotFound bool
+	Expires  time.Time
+}
+
+// CacheStore is an optional second cache level that outlives the process.
+type CacheStore interface {
+	// Get returns an unexpired entry, if any.
+	Get(ctx context.Context, regNum string) (CacheEntry, bool, error)
+	Put(ctx context.Context, regNum string, entry CacheEntry) error
+}
+
+type CacheOptions struct {
+	// TTL applies to found cars, NegativeTTL to not-found plates; a zero
+	// NegativeTTL disables negative caching.
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	// Size bounds the in-process LRU.
+	Size int
+	// Store, when set, is consulted on an LRU miss before going upstream.
+	Store CacheStore
+}
+
+// CacheProvider caches answers of the provider it wraps in a bounded LRU with
+// TTL. Plates are keyed by their normalized spelling, so "а 123 вс 77" and
+// A123BC77 share an entry. Concurrent misses of one plate are coalesced into
+// a single upstream lookup. Upstream failures other than ErrNotFound are
+// never cached.
+type CacheProvider struct {
+	next  CarInfoProvider
+	opts  CacheOptions
+	now   func() time.Time
+	group singleflight.Group
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	regNum string
+	entry  CacheEntry
+}
+
+func NewCacheProvider(next CarInfoProvider, opts CacheOptions) CarInfoProvider {
+	return &CacheProvider{
+		next:    next,
+		opts:    opts,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (p *CacheProvider) Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error) {
+	regNum = regnum.Normalize(regNum)
+	if entry, ok := p.get(regNum); ok {
+		if entry.NotFound {
+			cacheCounters.negativeHits.Add(1)
+			return dto.AddCarsDto{}, ErrNotFound
+		}
+		cacheCounters.hits.Add(1)
+		log.Printf("[DEBUG] CarInfo - Cache - Hit for %s", regNum)
+		return entry.Car, nil
+	}
+	cacheCounters.misses.Add(1)
+
+	// The shared lookup must not fail for every waiter just because the
+	// request that started it went away.
+	lookupCtx := context.WithoutCancel(ctx)
+	result, err, shared := p.group.Do(regNum, func() (any, error) {
+		return p.load(lookupCtx, regNum)
+	})
+	if shared {
+		cacheCounters.coalesced.Add(1)
+	}
+	if err != nil {
+		return dto.AddCarsDto{}, err
+	}
+
+	entry := result.(CacheEntry)
+	if entry.NotFound {
+		return dto.AddCarsDto{}, ErrNotFound
+	}
+	return entry.Car, nil
+}
+
+// load resolves a miss from the store or upstream and caches the outcome.
+func (p *CacheProvider) load(ctx context.Context, regNum string) (CacheEntry, error) {
+	if p.opts.Store != nil {
+		entry, ok, err := p.opts.Store.Get(ctx, regNum)
+		if err != nil {
+			log.Printf("[ERROR] CarInfo - Cache - Store lookup of %s failed: %v", regNum, err)
+		} else if ok {
+			cacheCounters.storeHits.Add(1)
+			p.put(regNum, entry)
+			return entry, nil
+		}
+	}
+
+	car, err := p.next.Lookup(ctx, regNum)
+	var entry CacheEntry
+	switch {
+	case err == nil:
+		entry = CacheEntry{Car: car, Expires: p.now().Add(p.opts.TTL)}
+	case errors.Is(err, ErrNotFound) && p.opts.NegativeTTL > 0:
+		entry = CacheEntry{NotFound: true, Expires: p.now().Add(p.opts.NegativeTTL)}
+	default:
+		cacheCounters.upstreamErrors.Add(1)
+		return CacheEntry{}, err
+	}
+
+	p.put(regNum, entry)
+	if p.opts.Store != nil {
+		if err := p.opts.Store.Put(ctx, regNum, entry); err != nil {
+			log.Printf("[ERROR] CarInfo - Cache - Store write of %s failed: %v", regNum, err)
+		}
+	}
+	return entry, nil
+}
+
+func (p *CacheProvider) get(regNum string) (CacheEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.entries[regNum]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	item := element.Value.(*lruItem)
+	if !p.now().Before(item.entry.Expires) {
+		p.order.Remove(element)
+		delete(p.entries, regNum)
+		return CacheEntry{}, false
+	}
+	p.order.MoveToFront(element)
+	return item.entry, true
+}
+
+func (p *CacheProvider) put(regNum string, entry CacheEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.entries[regNum]; ok {
+		element.Value.(*lruItem).entry = entry
+		p.order.MoveToFront(element)
+		return
+	}
+	p.entries[regNum] = p.order.PushFront(&lruItem{regNum: regNum, entry: entry})
+
+	for p.opts.Size > 0 && p.order.Len() > p.opts.Size {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(*lruItem).regNum)
+		cacheCounters.evictions.Add(1)
+	}
+}
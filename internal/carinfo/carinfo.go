@@ -0,0 +1,67 @@
+// Package carinfo looks up the registry record of a registration number.
+// Providers share one interface so they can be stacked into a fallback chain.
+package carinfo
+
+import (
+	"car_catalog/internal/dto"
+	"context"
+	"errors"
+	"log"
+)
+
+var (
+	// ErrNotFound means the provider has no record of the plate.
+	ErrNotFound = errors.New("car not found")
+	// ErrRejected means the provider refused the request, e.g. a registry 400.
+	ErrRejected = errors.New("lookup rejected")
+	// ErrUnavailable means the provider could not answer: transport errors,
+	// timeouts and 5xx responses.
+	ErrUnavailable = errors.New("provider unavailable")
+	// ErrInvalidResponse means the provider answered with a body that could
+	// not be decoded.
+	ErrInvalidResponse = errors.New("invalid provider response")
+)
+
+type CarInfoProvider interface {
+	Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error)
+}
+
+// Chain tries its providers in order and returns the first answer. When all
+// of them fail, the error of the first (primary) provider is returned.
+type Chain struct {
+	names     []string
+	providers []CarInfoProvider
+}
+
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// Add appends a provider; name only appears in logs.
+func (c *Chain) Add(name string, provider CarInfoProvider) *Chain {
+	c.names = append(c.names, name)
+	c.providers = append(c.providers, provider)
+	return c
+}
+
+func (c *Chain) Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error) {
+	var primaryErr error
+	for i, provider := range c.providers {
+		car, err := provider.Lookup(ctx, regNum)
+		if err == nil {
+			log.Printf("[DEBUG] CarInfo - Chain - %s answered for %s", c.names[i], regNum)
+			return car, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return dto.AddCarsDto{}, ctxErr
+		}
+		log.Printf("[INFO] CarInfo - Chain - %s failed for %s: %v", c.names[i], regNum, err)
+		if primaryErr == nil {
+			primaryErr = err
+		}
+	}
+	if primaryErr == nil {
+		primaryErr = ErrNotFound
+	}
+	return dto.AddCarsDto{}, primaryErr
+}
@@ -0,0 +1,195 @@
+package carinfo_test
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/dto"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stubProvider struct {
+	car   dto.AddCarsDto
+	err   error
+	calls int
+}
+
+func (s *stubProvider) Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error) {
+	s.calls++
+	return s.car, s.err
+}
+
+func TestChainFallsBack(t *testing.T) {
+	primary := &stubProvider{err: fmt.Errorf("%w: registry answered 503", carinfo.ErrUnavailable)}
+	fallback := &stubProvider{car: dto.AddCarsDto{RegNum: "A123BC77", Mark: "Lada"}}
+
+	car, err := carinfo.NewChain().Add("http", primary).Add("fixture", fallback).Lookup(context.Background(), "A123BC77")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car.Mark != "Lada" || primary.calls != 1 || fallback.calls != 1 {
+		t.Fatalf("car = %+v, calls = %d/%d", car, primary.calls, fallback.calls)
+	}
+}
+
+func TestChainReturnsPrimaryError(t *testing.T) {
+	primary := &stubProvider{err: carinfo.ErrUnavailable}
+	fallback := &stubProvider{err: carinfo.ErrNotFound}
+
+	_, err := carinfo.NewChain().Add("http", primary).Add("fixture", fallback).Lookup(context.Background(), "A123BC77")
+	if !errors.Is(err, carinfo.ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCacheProvider(t *testing.T) {
+	next := &stubProvider{car: dto.AddCarsDto{RegNum: "A123BC77"}}
+	cache := carinfo.NewCacheProvider(next, carinfo.CacheOptions{TTL: time.Minute, Size: 10})
+	before := carinfo.ReadCacheStats()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Lookup(context.Background(), "A123BC77"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if next.calls != 1 {
+		t.Fatalf("next called %d times, want 1", next.calls)
+	}
+
+	next.err = carinfo.ErrUnavailable
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Lookup(context.Background(), "B456CD77"); !errors.Is(err, carinfo.ErrUnavailable) {
+			t.Fatalf("err = %v, want ErrUnavailable", err)
+		}
+	}
+	if next.calls != 3 {
+		t.Fatalf("failures must not be cached: next called %d times, want 3", next.calls)
+	}
+
+	stats := carinfo.ReadCacheStats()
+	if stats.Hits-before.Hits != 2 || stats.Misses-before.Misses != 3 || stats.UpstreamErrors-before.UpstreamErrors != 2 {
+		t.Fatalf("stats = %+v, was %+v: want 2 hits, 3 misses, 2 upstream errors more", stats, before)
+	}
+}
+
+func TestCacheProviderNormalizesPlates(t *testing.T) {
+	next := &stubProvider{car: dto.AddCarsDto{RegNum: "A123BC77"}}
+	cache := carinfo.NewCacheProvider(next, carinfo.CacheOptions{TTL: time.Minute, Size: 10})
+
+	// Cyrillic look-alikes, spaces and case all name the same plate.
+	for _, regNum := range []string{"A123BC77", "а 123 вс 77", "a123-bc77"} {
+		if _, err := cache.Lookup(context.Background(), regNum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if next.calls != 1 {
+		t.Fatalf("next called %d times, want 1", next.calls)
+	}
+}
+
+func TestCacheProviderNegative(t *testing.T) {
+	next := &stubProvider{err: carinfo.ErrNotFound}
+	cache := carinfo.NewCacheProvider(next, carinfo.CacheOptions{TTL: time.Minute, NegativeTTL: time.Minute, Size: 10})
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Lookup(context.Background(), "A123BC77"); !errors.Is(err, carinfo.ErrNotFound) {
+			t.Fatalf("err = %v, want ErrNotFound", err)
+		}
+	}
+	if next.calls != 1 {
+		t.Fatalf("next called %d times, want 1", next.calls)
+	}
+}
+
+func TestCacheProviderEvictsLeastRecentlyUsed(t *testing.T) {
+	next := &stubProvider{}
+	cache := carinfo.NewCacheProvider(next, carinfo.CacheOptions{TTL: time.Minute, Size: 2})
+	ctx := context.Background()
+
+	for _, regNum := range []string{"A111AA77", "B222BB77", "A111AA77", "C333CC77", "A111AA77"} {
+		if _, err := cache.Lookup(ctx, regNum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// B222BB77 was the least recently used when C333CC77 arrived.
+	if next.calls != 3 {
+		t.Fatalf("next called %d times, want 3", next.calls)
+	}
+	if _, err := cache.Lookup(ctx, "B222BB77"); err != nil || next.calls != 4 {
+		t.Fatalf("B222BB77 should have been evicted: err = %v, calls = %d", err, next.calls)
+	}
+}
+
+type blockingProvider struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (b *blockingProvider) Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error) {
+	b.calls.Add(1)
+	<-b.release
+	return dto.AddCarsDto{RegNum: regNum}, nil
+}
+
+func TestCacheProviderCoalescesConcurrentMisses(t *testing.T) {
+	next := &blockingProvider{release: make(chan struct{})}
+	cache := carinfo.NewCacheProvider(next, carinfo.CacheOptions{TTL: time.Minute, Size: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Lookup(context.Background(), "A123BC77"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	if calls := next.calls.Load(); calls != 1 {
+		t.Fatalf("next called %d times, want 1", calls)
+	}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]carinfo.CacheEntry
+}
+
+func (m *memoryStore) Get(ctx context.Context, regNum string) (carinfo.CacheEntry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[regNum]
+	return entry, ok && time.Now().Before(entry.Expires), nil
+}
+
+func (m *memoryStore) Put(ctx context.Context, regNum string, entry carinfo.CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[regNum] = entry
+	return nil
+}
+
+func TestCacheProviderStoreSurvivesRestart(t *testing.T) {
+	store := &memoryStore{entries: make(map[string]carinfo.CacheEntry)}
+	next := &stubProvider{car: dto.AddCarsDto{RegNum: "A123BC77", Mark: "Lada"}}
+	opts := carinfo.CacheOptions{TTL: time.Minute, Size: 10, Store: store}
+
+	if _, err := carinfo.NewCacheProvider(next, opts).Lookup(context.Background(), "A123BC77"); err != nil {
+		t.Fatal(err)
+	}
+	car, err := carinfo.NewCacheProvider(next, opts).Lookup(context.Background(), "A123BC77")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car.Mark != "Lada" || next.calls != 1 {
+		t.Fatalf("car = %+v, next called %d times, want 1", car, next.calls)
+	}
+}
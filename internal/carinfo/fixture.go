@@ -0,0 +1,72 @@
+package carinfo
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/export"
+	"car_catalog/internal/importer"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FixtureProvider answers from a file loaded once at start-up.
+type FixtureProvider struct {
+	cars map[string]dto.AddCarsDto
+}
+
+// NewFixtureProvider reads a JSON array of AddCarsDto records (the fake
+// registry fixture format) or, by extension, a .csv or .ndjson file in the
+// import format.
+func NewFixtureProvider(path string) (CarInfoProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cars []dto.AddCarsDto
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		if err := json.NewDecoder(file).Decode(&cars); err != nil {
+			return nil, fmt.Errorf("invalid fixture file %s: %w", path, err)
+		}
+	case ".csv", ".ndjson":
+		rows, err := importer.Parse(export.Format(strings.TrimPrefix(ext, ".")), file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture file %s: %w", path, err)
+		}
+		for _, row := range rows {
+			if row.Error != "" {
+				return nil, fmt.Errorf("invalid fixture file %s: line %d: %s", path, row.Line, row.Error)
+			}
+			cars = append(cars, dto.AddCarsDto{
+				Mark:   row.Car.Mark,
+				Model:  row.Car.Model,
+				Year:   row.Car.Year,
+				RegNum: row.Car.RegNum,
+				Owner:  row.Car.Owner,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("fixture file %s: unsupported extension %q, want .json, .csv or .ndjson", path, ext)
+	}
+
+	p := &FixtureProvider{cars: make(map[string]dto.AddCarsDto, len(cars))}
+	for _, car := range cars {
+		p.cars[car.RegNum] = car
+	}
+	log.Printf("[INFO] CarInfo - NewFixtureProvider - Loaded %d cars from %s", len(p.cars), path)
+	return p, nil
+}
+
+func (p *FixtureProvider) Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error) {
+	car, ok := p.cars[regNum]
+	if !ok {
+		return dto.AddCarsDto{}, fmt.Errorf("%w: %s", ErrNotFound, regNum)
+	}
+	return car, nil
+}
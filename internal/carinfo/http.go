@@ -0,0 +1,53 @@
+package carinfo
+
+import (
+	"car_catalog/internal/dto"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// HTTPProvider queries the external registry: GET <url>?regNum=... answering
+// with an AddCarsDto.
+type HTTPProvider struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPProvider(url string, client *http.Client) CarInfoProvider {
+	return &HTTPProvider{
+		url:    url,
+		client: client,
+	}
+}
+
+func (p *HTTPProvider) Lookup(ctx context.Context, regNum string) (dto.AddCarsDto, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?regNum="+url.QueryEscape(regNum), nil)
+	if err != nil {
+		return dto.AddCarsDto{}, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return dto.AddCarsDto{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return dto.AddCarsDto{}, fmt.Errorf("%w: %s", ErrNotFound, regNum)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return dto.AddCarsDto{}, fmt.Errorf("%w: registry answered %s", ErrUnavailable, resp.Status)
+	default:
+		return dto.AddCarsDto{}, fmt.Errorf("%w: registry answered %s", ErrRejected, resp.Status)
+	}
+
+	var car dto.AddCarsDto
+	if err := json.NewDecoder(resp.Body).Decode(&car); err != nil {
+		return dto.AddCarsDto{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return car, nil
+}
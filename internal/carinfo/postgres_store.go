@@ -0,0 +1,62 @@
+package carinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresCacheStore keeps cache entries in cars.registry_cache, so a restart
+// or a second instance does not start with a cold cache.
+type PostgresCacheStore struct {
+	conn *pgxpool.Pool
+}
+
+func NewPostgresCacheStore(conn *pgxpool.Pool) CacheStore {
+	return &PostgresCacheStore{conn: conn}
+}
+
+func (s *PostgresCacheStore) Get(ctx context.Context, regNum string) (CacheEntry, bool, error) {
+	query := `SELECT car, not_found, expires_at
+	FROM cars.registry_cache
+	WHERE reg_num = $1 AND expires_at > now()`
+
+	var (
+		car   []byte
+		entry CacheEntry
+	)
+	err := s.conn.QueryRow(ctx, query, regNum).Scan(&car, &entry.NotFound, &entry.Expires)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	if !entry.NotFound {
+		if err := json.Unmarshal(car, &entry.Car); err != nil {
+			return CacheEntry{}, false, err
+		}
+	}
+	return entry, true, nil
+}
+
+func (s *PostgresCacheStore) Put(ctx context.Context, regNum string, entry CacheEntry) error {
+	var car []byte
+	if !entry.NotFound {
+		var err error
+		if car, err = json.Marshal(entry.Car); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO cars.registry_cache (reg_num, car, not_found, expires_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (reg_num) DO UPDATE
+	SET car = EXCLUDED.car, not_found = EXCLUDED.not_found, expires_at = EXCLUDED.expires_at`
+
+	_, err := s.conn.Exec(ctx, query, regNum, car, entry.NotFound, entry.Expires)
+	return err
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/logging"
+	"car_catalog/internal/pkg/app"
+	"car_catalog/internal/service"
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: car_catalog <command> [arguments]
+
+Commands:
+  serve      start the HTTP server (default)
+  migrate    manage the database schema: up, down, goto, version, force
+  seed       insert synthetic cars
+  import     load cars from a CSV or NDJSON file
+  export     write the catalog as CSV or NDJSON
+  dictionary map existing marks and models onto the reference dictionary
+  config     print the effective configuration
+  fake-registry
+             serve a stub of the external car registry for local development
+
+Every command accepts -config <file.yaml> and per-key flags such as -db-host;
+flags override environment variables, which override the config file.
+Run "car_catalog <command> -h" for command flags.
+`
+
+// Run dispatches to a subcommand. Without arguments it starts the server, so
+// existing deployments running the bare binary keep working.
+func Run(args []string) error {
+	if len(args) == 0 {
+		return Serve(nil)
+	}
+
+	switch args[0] {
+	case "serve":
+		return Serve(args[1:])
+	case "migrate":
+		return Migrate(args[1:])
+	case "seed":
+		return Seed(args[1:])
+	case "import":
+		return Import(args[1:])
+	case "export":
+		return Export(args[1:])
+	case "dictionary":
+		return Dictionary(args[1:])
+	case "config":
+		return Config(args[1:])
+	case "fake-registry":
+		return FakeRegistry(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// loadConfig resolves and validates the configuration once the command's
+// flags are parsed, and applies the logging settings.
+func loadConfig(loader *config.Loader) (*config.Config, error) {
+	cfg, err := loader.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newCarService wires the service the same way the server does. The returned
+// function releases the storage.
+func newCarService(cfg *config.Config) (service.CarService, func(), error) {
+	storage, err := app.OpenStorage(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.NewCarService(storage.Cars, storage.Dictionary, nil), storage.Close, nil
+}
@@ -0,0 +1,160 @@
+package cli_test
+
+import (
+	"car_catalog/internal/cli"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sqliteFlags points a command at a fresh SQLite database in a temporary
+// directory.
+func sqliteFlags(t *testing.T) []string {
+	t.Helper()
+	return []string{"-storage", "sqlite", "-sqlite-path", filepath.Join(t.TempDir(), "cars.db")}
+}
+
+func run(t *testing.T, command string, flags []string, args ...string) error {
+	t.Helper()
+	return cli.Run(append(append([]string{command}, flags...), args...))
+}
+
+func TestRunRejectsUnknownCommand(t *testing.T) {
+	err := cli.Run([]string{"deploy"})
+	if err == nil || !strings.Contains(err.Error(), `unknown command "deploy"`) {
+		t.Fatalf("err = %v, want unknown command", err)
+	}
+	if err := cli.Run([]string{"help"}); err != nil {
+		t.Fatalf("help: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	flags := sqliteFlags(t)
+
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{nil, "usage: migrate"},
+		{[]string{"sideways"}, `unknown migrate command "sideways"`},
+		{[]string{"goto"}, "migrate goto: version argument is required"},
+		{[]string{"goto", "-1"}, "migrate goto: -1 must not be negative"},
+		{[]string{"force", "-1"}, "migrate force: -1 must not be negative"},
+		{[]string{"down", "-2"}, "migrate down: -2 must not be negative"},
+		{[]string{"up", "many"}, `migrate up: invalid number "many"`},
+		{[]string{"up"}, ""},
+		{[]string{"up"}, ""}, // nothing left to apply is not an error
+		{[]string{"down", "2"}, ""},
+		{[]string{"goto", "3"}, ""},
+		{[]string{"version"}, ""},
+	}
+	for _, tt := range tests {
+		err := run(t, "migrate", flags, tt.args...)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Fatalf("migrate %v: %v", tt.args, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Fatalf("migrate %v: err = %v, want %q", tt.args, err, tt.wantErr)
+		}
+	}
+
+	memory := []string{"-storage", "memory"}
+	if err := run(t, "migrate", memory, "up"); err == nil || !strings.Contains(err.Error(), "no schema to migrate") {
+		t.Fatalf("memory storage: err = %v, want no schema", err)
+	}
+}
+
+func TestSeedImportExport(t *testing.T) {
+	flags := sqliteFlags(t)
+	if err := run(t, "migrate", flags, "up"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	if err := run(t, "seed", flags, "-count", "0"); err == nil {
+		t.Fatal("seed -count 0: want an error")
+	}
+	if err := run(t, "seed", flags, "-count", "3", "-seed", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	input := filepath.Join(dir, "cars.csv")
+	csv := "mark,model,year,reg_num,owner_name,owner_surname\n" +
+		"Lada,Vesta,2020,A123BC77,Иван,Иванов\n" +
+		"Kia,Rio,2019,B456CE77,,\n"
+	if err := os.WriteFile(input, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(t, "import", flags, "-dry-run", input); err != nil {
+		t.Fatal(err)
+	}
+	if got := exportCSV(t, flags, "-mark", "Lada", "-model", "Vesta", "-year", "2020"); strings.Contains(got, "A123BC77") {
+		t.Fatalf("dry run imported rows:\n%s", got)
+	}
+	if err := run(t, "import", flags, input); err != nil {
+		t.Fatal(err)
+	}
+
+	// The seeded cars come first, as ids 1 to 3, and may match the filters
+	// too.
+	tests := []struct {
+		name   string
+		args   []string
+		header string
+		row    string
+	}{
+		{"filtered", []string{"-mark", "Kia", "-model", "Rio", "-year", "2019"},
+			"id,mark,model,year,reg_num,vin", "5,Kia,Rio,2019,B456CE77,"},
+		{"with owner", []string{"-mark", "Lada", "-model", "Vesta", "-year", "2020", "-owner"},
+			"id,mark,model,year,reg_num,vin,owner_name,owner_surname,owner_patronymic", "4,Lada,Vesta,2020,A123BC77,,Иван,Иванов,"},
+	}
+	for _, tt := range tests {
+		got := exportCSV(t, flags, tt.args...)
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		if lines[0] != tt.header || !slices.Contains(lines[1:], tt.row) {
+			t.Errorf("%s: export =\n%s\nwant header %q and row %q", tt.name, got, tt.header, tt.row)
+		}
+		filtered := "," + strings.Join([]string{tt.args[1], tt.args[3], tt.args[5]}, ",") + ","
+		for _, line := range lines[1:] {
+			if !strings.Contains(line, filtered) {
+				t.Errorf("%s: row %q does not match the filters", tt.name, line)
+			}
+		}
+	}
+
+	ndjson := filepath.Join(dir, "cars.ndjson")
+	if err := run(t, "export", flags, "-format", "ndjson", "-mark", "Kia", "-model", "Rio", "-year", "2019", "-o", ndjson); err != nil {
+		t.Fatal(err)
+	}
+	// The format comes from the file extension; every plate is already there.
+	if err := run(t, "import", flags, ndjson); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(t, "export", flags, "-format", "xlsx"); err == nil {
+		t.Fatal("export -format xlsx: want an error")
+	}
+	if err := run(t, "import", flags, filepath.Join(dir, "cars.xlsx")); err == nil {
+		t.Fatal("import of an .xlsx file: want an error")
+	}
+	if err := run(t, "import", flags); err == nil || !strings.Contains(err.Error(), "usage: import") {
+		t.Fatalf("import without a file: err = %v, want usage", err)
+	}
+}
+
+// exportCSV runs the export command into a temporary file and returns it.
+func exportCSV(t *testing.T, flags []string, args ...string) string {
+	t.Helper()
+	output := filepath.Join(t.TempDir(), "export.csv")
+	if err := run(t, "export", flags, append(args, "-o", output)...); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
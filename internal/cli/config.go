@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config prints the effective configuration after all layers are merged.
+// Validation problems are reported after the dump, so a broken setup can
+// still be inspected.
+//
+//	car_catalog config print [-redacted] [config flags]
+func Config(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: config print [-redacted] [config flags]")
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "mask passwords and API keys")
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loader.Resolve()
+	if err != nil {
+		return err
+	}
+	if *redacted {
+		cfg = cfg.Redacted()
+	}
+
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(out))
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/pkg/app"
+	"car_catalog/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+)
+
+// Dictionary maps the marks and models already in the catalog onto the
+// reference dictionary, printing the cars it changed and the values it could
+// not match as JSON to stdout.
+//
+//	car_catalog dictionary map [-dry-run]
+func Dictionary(args []string) error {
+	if len(args) == 0 || args[0] != "map" {
+		return errors.New("usage: dictionary map [-dry-run]")
+	}
+	fs := flag.NewFlagSet("dictionary map", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report the mapping without writing it")
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: dictionary map [-dry-run]")
+	}
+
+	cfg, err := loadConfig(loader)
+	if err != nil {
+		return err
+	}
+	storage, err := app.OpenStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	report, err := service.NewDictionaryService(storage.Dictionary, storage.Cars).MapCars(context.Background(), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/export"
+	"context"
+	"flag"
+	"io"
+	"os"
+)
+
+// Export writes the filtered catalog to a file or stdout.
+//
+//	car_catalog export [-format csv|ndjson] [-mark M] [-model M] [-year Y] [-owner] [-o file]
+func Export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "csv", "output format: csv or ndjson")
+	output := fs.String("o", "-", "output file, - for stdout")
+	includeOwner := fs.Bool("owner", false, "include owner columns")
+	var filters dto.Filters
+	fs.StringVar(&filters.Mark, "mark", "", "filter by mark")
+	fs.StringVar(&filters.Model, "model", "", "filter by model")
+	fs.StringVar(&filters.Year, "year", "", "filter by year")
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	writer, err := export.NewWriter(format, out, *includeOwner)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(loader)
+	if err != nil {
+		return err
+	}
+	carService, closeStorage, err := newCarService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	err = carService.ExportCars(context.Background(), filters, *includeOwner, writer.Write)
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"car_catalog/internal/registrystub"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+// FakeRegistry serves a stub of the external registry for local development;
+// point EXTERNAL_API_URL at it.
+//
+//	car_catalog fake-registry [-addr host:port] [-fixtures file.json] [-generate=false]
+//	    [-latency D] [-jitter D] [-bad-request-rate P] [-server-error-rate P] [-malformed-rate P] [-seed S]
+func FakeRegistry(args []string) error {
+	fs := flag.NewFlagSet("fake-registry", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8081", "listen address")
+	fixtures := fs.String("fixtures", "", "JSON array of cars (with an optional fault) to answer with")
+	var opts registrystub.Options
+	fs.BoolVar(&opts.Generate, "generate", true, "answer unknown plates with a car generated from the plate instead of 404")
+	fs.DurationVar(&opts.Latency, "latency", 0, "delay added to every response")
+	fs.DurationVar(&opts.Jitter, "jitter", 0, "random extra delay, up to this value")
+	fs.Float64Var(&opts.BadRequestRate, "bad-request-rate", 0, "probability of a 400 response")
+	fs.Float64Var(&opts.ServerErrorRate, "server-error-rate", 0, "probability of a 500 response")
+	fs.Float64Var(&opts.MalformedRate, "malformed-rate", 0, "probability of a truncated JSON body")
+	fs.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "random seed for jitter and faults")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	for _, rate := range []float64{opts.BadRequestRate, opts.ServerErrorRate, opts.MalformedRate} {
+		if rate < 0 || rate > 1 {
+			return errors.New("fake-registry: rates must be between 0 and 1")
+		}
+	}
+	if opts.BadRequestRate+opts.ServerErrorRate+opts.MalformedRate > 1 {
+		return errors.New("fake-registry: rates must add up to at most 1")
+	}
+
+	if *fixtures != "" {
+		loaded, err := registrystub.LoadFixtures(*fixtures)
+		if err != nil {
+			return err
+		}
+		opts.Fixtures = loaded
+		log.Printf("[INFO] FakeRegistry - Loaded %d fixtures from %s", len(loaded), *fixtures)
+	}
+
+	log.Printf("[INFO] FakeRegistry - Listening on http://%s", *addr)
+	return http.ListenAndServe(*addr, registrystub.New(opts))
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/export"
+	"car_catalog/internal/importer"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Import loads a CSV or NDJSON file straight into the database, printing the
+// import report as JSON to stdout.
+//
+//	car_catalog import [-format csv|ndjson] [-dry-run] <file|->
+func Import(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := fs.String("format", "", "input format: csv or ndjson (default: from file extension)")
+	dryRun := fs.Bool("dry-run", false, "validate rows without writing them")
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import [-format csv|ndjson] [-dry-run] <file|->")
+	}
+	path := fs.Arg(0)
+
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	rows, err := importer.Parse(format, input)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	cfg, err := loadConfig(loader)
+	if err != nil {
+		return err
+	}
+	carService, closeStorage, err := newCarService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	report, err := carService.ImportCars(context.Background(), rows, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/database"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+const migrateUsage = `usage: migrate [config flags] <command>
+  up [N]       apply all or N pending migrations
+  down [N]     roll back N migrations (default 1)
+  goto V       migrate up or down to version V
+  version      print the current version
+  force V      set version V without running migrations (clears dirty state)`
+
+func Migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	command, args := args[0], args[1:]
+	number := func(def int) (int, error) {
+		if len(args) == 0 {
+			if def < 0 {
+				return 0, fmt.Errorf("migrate %s: version argument is required", command)
+			}
+			return def, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return 0, fmt.Errorf("migrate %s: invalid number %q", command, args[0])
+		}
+		// Versions are unsigned and a negative step count would run the
+		// opposite command.
+		if n < 0 {
+			return 0, fmt.Errorf("migrate %s: %d must not be negative", command, n)
+		}
+		return n, nil
+	}
+
+	cfg, err := loadConfig(loader)
+	if err != nil {
+		return err
+	}
+	if cfg.Storage.Driver == "memory" {
+		return fmt.Errorf("migrate: storage driver %q has no schema to migrate", cfg.Storage.Driver)
+	}
+	m, err := database.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch command {
+	case "up":
+		n, err := number(0)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			err = m.Steps(n)
+		} else {
+			err = m.Up()
+		}
+		if err := ignoreNoChange(err); err != nil {
+			return err
+		}
+	case "down":
+		n, err := number(1)
+		if err != nil {
+			return err
+		}
+		if err := ignoreNoChange(m.Steps(-n)); err != nil {
+			return err
+		}
+	case "goto":
+		v, err := number(-1)
+		if err != nil {
+			return err
+		}
+		if err := ignoreNoChange(m.Migrate(uint(v))); err != nil {
+			return err
+		}
+	case "force":
+		v, err := number(-1)
+		if err != nil {
+			return err
+		}
+		if err := m.Force(v); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Migrate - %s finished", command)
+	fmt.Printf("version %d, dirty: %t\n", version, dirty)
+	return nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return database.ExplainDirty(err)
+}
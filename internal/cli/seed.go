@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/dto"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// seedBatchSize bounds how many rows go through one ImportCars call.
+const seedBatchSize = 5000
+
+const plateLetters = "ABEKMHOPCTYX"
+
+var (
+	plateRegions = []string{
+		"77", "97", "99", "177", "197", "199", "777", "797", "799",
+		"50", "90", "150", "190", "750", "78", "98", "178", "198",
+		"16", "116", "716", "66", "96", "196", "54", "154", "23", "93",
+		"123", "193", "02", "102", "702", "61", "161", "761", "52", "152",
+	}
+
+	seedModels = map[string][]string{
+		"Lada":          {"Vesta", "Granta", "Niva", "Largus"},
+		"Toyota":        {"Camry", "Corolla", "RAV4", "Land Cruiser"},
+		"Kia":           {"Rio", "Sportage", "Ceed"},
+		"Hyundai":       {"Solaris", "Creta", "Tucson"},
+		"Volkswagen":    {"Polo", "Tiguan", "Passat"},
+		"Skoda":         {"Octavia", "Rapid", "Kodiaq"},
+		"BMW":           {"X5", "3 Series", "5 Series"},
+		"Mercedes-Benz": {"E-Class", "C-Class", "GLE"},
+		"Renault":       {"Logan", "Duster", "Sandero"},
+		"Nissan":        {"Qashqai", "X-Trail", "Almera"},
+	}
+
+	maleNames         = []string{"Иван", "Алексей", "Дмитрий", "Сергей", "Андрей", "Михаил", "Николай"}
+	femaleNames       = []string{"Мария", "Анна", "Елена", "Ольга", "Наталья", "Татьяна", "Ирина"}
+	surnames          = []string{"Иванов", "Петров", "Смирнов", "Кузнецов", "Попов", "Соколов", "Волков"}
+	malePatronymics   = []string{"Иванович", "Петрович", "Сергеевич", "Андреевич", "Николаевич"}
+	femalePatronymics = []string{"Ивановна", "Петровна", "Сергеевна", "Андреевна", "Николаевна"}
+)
+
+// Seed inserts synthetic cars with realistic plates and owners through the
+// regular import path, so the generated rows pass the same validation.
+//
+//	car_catalog seed -count N [-seed S]
+func Seed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	count := fs.Int("count", 100, "number of cars to generate")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "random seed, for reproducible data sets")
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *count <= 0 {
+		return errors.New("seed: -count must be positive")
+	}
+
+	cfg, err := loadConfig(loader)
+	if err != nil {
+		return err
+	}
+	carService, closeStorage, err := newCarService(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	gen := newSeedGenerator(*seed)
+	imported, rejected := 0, 0
+	for done := 0; done < *count; {
+		batch := min(seedBatchSize, *count-done)
+		rows := make([]dto.ImportRow, 0, batch)
+		for i := 0; i < batch; i++ {
+			rows = append(rows, dto.ImportRow{Line: done + i + 1, Car: gen.car()})
+		}
+
+		report, err := carService.ImportCars(context.Background(), rows, false)
+		if err != nil {
+			return err
+		}
+		imported += report.Imported
+		rejected += report.Rejected
+		done += batch
+	}
+
+	log.Printf("[INFO] Seed - Generated %d cars with seed %d", *count, *seed)
+	fmt.Printf("imported %d cars, %d rejected as duplicates\n", imported, rejected)
+	return nil
+}
+
+type seedGenerator struct {
+	rnd    *rand.Rand
+	marks  []string
+	plates map[string]bool
+}
+
+func newSeedGenerator(seed int64) *seedGenerator {
+	marks := make([]string, 0, len(seedModels))
+	for mark := range seedModels {
+		marks = append(marks, mark)
+	}
+	// Map iteration order is random; sort so a seed always yields the same data.
+	sort.Strings(marks)
+
+	return &seedGenerator{
+		rnd:    rand.New(rand.NewSource(seed)),
+		marks:  marks,
+		plates: make(map[string]bool),
+	}
+}
+
+func (g *seedGenerator) car() dto.ImportCarDto {
+	mark := g.pick(g.marks)
+	car := dto.ImportCarDto{
+		Mark:   mark,
+		Model:  g.pick(seedModels[mark]),
+		Year:   time.Now().Year() - g.rnd.Intn(25),
+		RegNum: g.plate(),
+	}
+
+	if g.rnd.Intn(2) == 0 {
+		car.Owner = dto.People{
+			Name:       g.pick(maleNames),
+			Surname:    g.pick(surnames),
+			Patronymic: g.pick(malePatronymics),
+		}
+	} else {
+		car.Owner = dto.People{
+			Name:       g.pick(femaleNames),
+			Surname:    g.pick(surnames) + "а",
+			Patronymic: g.pick(femalePatronymics),
+		}
+	}
+	return car
+}
+
+// plate returns a plate not produced before by this generator, shaped like
+// "A123BC77": letter, three digits (000 is never issued), two letters, region.
+func (g *seedGenerator) plate() string {
+	for {
+		var b strings.Builder
+		b.WriteByte(plateLetters[g.rnd.Intn(len(plateLetters))])
+		fmt.Fprintf(&b, "%03d", 1+g.rnd.Intn(999))
+		b.WriteByte(plateLetters[g.rnd.Intn(len(plateLetters))])
+		b.WriteByte(plateLetters[g.rnd.Intn(len(plateLetters))])
+		b.WriteString(g.pick(plateRegions))
+
+		if plate := b.String(); !g.plates[plate] {
+			g.plates[plate] = true
+			return plate
+		}
+	}
+}
+
+func (g *seedGenerator) pick(values []string) string {
+	return values[g.rnd.Intn(len(values))]
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/database"
+	"car_catalog/internal/pkg/app"
+	"flag"
+	"log"
+)
+
+// Serve starts the HTTP server, applying pending migrations first unless
+// -migrate=false is given.
+func Serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	runMigrations := fs.Bool("migrate", true, "apply pending migrations before starting")
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(loader)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ValidateServer(); err != nil {
+		return err
+	}
+
+	if *runMigrations && cfg.Storage.Driver != "memory" {
+		if err := database.MigrateDatabase(cfg); err != nil {
+			log.Printf("[ERROR] Failed to migrate database: %v", err)
+			return err
+		}
+	}
+
+	a, err := app.New(cfg)
+	if err != nil {
+		return err
+	}
+	return a.Run()
+}
@@ -1,63 +1,672 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"mime"
+	"net/url"
 	"os"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is assembled in layers: built-in defaults, then the YAML file, then
+// environment variables (including .env), then command-line flags.
 type Config struct {
-	Database       DatabaseConfig
-	HTTPHost       string
-	HTTPPort       int
-	ExternalAPIURL string
+	Storage     StorageConfig     `yaml:"storage"`
+	Database    DatabaseConfig    `yaml:"database"`
+	HTTP        HTTPConfig        `yaml:"http"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	GraphQL     GraphQLConfig     `yaml:"graphql"`
+	External    ExternalConfig    `yaml:"external"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Resync      ResyncConfig      `yaml:"resync"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Limits      LimitsConfig      `yaml:"limits"`
+	Suggest     SuggestConfig     `yaml:"suggest"`
+	Stats       StatsConfig       `yaml:"stats"`
+	Events      EventsConfig      `yaml:"events"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Attachments AttachmentsConfig `yaml:"attachments"`
+}
+
+type StorageConfig struct {
+	// Driver selects the CarRepository implementation: postgres, sqlite or
+	// memory.
+	Driver string       `yaml:"driver"`
+	SQLite SQLiteConfig `yaml:"sqlite"`
+}
+
+type SQLiteConfig struct {
+	Path string `yaml:"path"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
+	Host            string `yaml:"host"`
+	Port            int    `yaml:"port"`
+	User            string `yaml:"user"`
+	Password        string `yaml:"password"`
+	DBName          string `yaml:"name"`
+	ApplicationName string `yaml:"application_name"`
+	// StatementTimeout is enforced by the server for every statement.
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+	// QueryTimeout bounds a single repository call and is derived from the
+	// request context, so an aborted request also cancels its query.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+	SSL          SSLConfig     `yaml:"ssl"`
+	Pool         PoolConfig    `yaml:"pool"`
+}
+
+type SSLConfig struct {
+	Mode     string `yaml:"mode"`
+	RootCert string `yaml:"root_cert"`
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+}
+
+type PoolConfig struct {
+	MaxConns          int32         `yaml:"max_conns"`
+	MinConns          int32         `yaml:"min_conns"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period"`
+}
+
+type HTTPConfig struct {
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	TLS               TLSConfig     `yaml:"tls"`
+}
+
+// GRPCConfig controls the gRPC API, served on a port of its own with the
+// certificate of http.tls.
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    int    `yaml:"port"`
+	// Reflection lets tools like grpcurl discover the services without the
+	// proto files.
+	Reflection bool `yaml:"reflection"`
+}
+
+// GraphQLConfig controls the /graphql endpoint of the HTTP server.
+type GraphQLConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxDepth caps how deeply an operation nests fields and MaxComplexity
+	// how many fields it may resolve, counting each field under a page of
+	// cars once per car. Both are checked before anything runs.
+	MaxDepth      int `yaml:"max_depth"`
+	MaxComplexity int `yaml:"max_complexity"`
+}
+
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+type ExternalConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Providers is the ordered fallback chain used to look up cars: http,
+	// fixture and cache. A cache entry caches the providers listed after it.
+	Providers []string              `yaml:"providers"`
+	Fixture   FixtureProviderConfig `yaml:"fixture"`
+	Cache     CacheProviderConfig   `yaml:"cache"`
 }
 
+type FixtureProviderConfig struct {
+	// Path is a .json, .csv or .ndjson file of known cars.
+	Path string `yaml:"path"`
+}
+
+type CacheProviderConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+	// NegativeTTL keeps "not found" answers; zero disables negative caching.
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+	// Size bounds the number of plates kept in process memory.
+	Size int `yaml:"size"`
+	// Persistent adds the cars.registry_cache table as a second level. It
+	// needs the postgres storage driver.
+	Persistent bool `yaml:"persistent"`
+}
+
+// ResyncConfig drives the background re-synchronization with the registry.
+type ResyncConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the pause between runs; each run resyncs at most BatchSize
+	// cars not synced within StaleAfter.
+	Interval   time.Duration `yaml:"interval"`
+	StaleAfter time.Duration `yaml:"stale_after"`
+	BatchSize  int           `yaml:"batch_size"`
+}
+
+// IdempotencyConfig controls Idempotency-Key handling of mutating requests.
+type IdempotencyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long a response is replayed for retries with the same key.
+	TTL time.Duration `yaml:"ttl"`
+	// LockTimeout releases the key of a request that never completed, e.g.
+	// after a crash; running requests keep renewing it.
+	LockTimeout time.Duration `yaml:"lock_timeout"`
+}
+
+// LimitsConfig bounds what a single request or client can cost.
+type LimitsConfig struct {
+	// MaxBodyBytes caps every request body; zero disables the cap.
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+	// MaxRegNums caps the regNums array of /api/addCars.
+	MaxRegNums int `yaml:"max_reg_nums"`
+	// MaxBatchSize caps how many cars one batch update or delete may touch.
+	MaxBatchSize int             `yaml:"max_batch_size"`
+	RateLimit    RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig holds per-client token buckets. Import covers requests that
+// call the registry or write in bulk; Read covers everything else.
+type RateLimitConfig struct {
+	Enabled bool         `yaml:"enabled"`
+	Read    BucketConfig `yaml:"read"`
+	Import  BucketConfig `yaml:"import"`
+}
+
+// SuggestConfig bounds the typeahead endpoints under /api/suggest.
+type SuggestConfig struct {
+	// Timeout is the latency budget of one suggest request; it replaces
+	// database.query_timeout when shorter.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxLimit caps the limit parameter.
+	MaxLimit int `yaml:"max_limit"`
+}
+
+// StatsConfig controls where /api/stats reads its figures from.
+type StatsConfig struct {
+	// Materialized serves the queries the cars.car_stats view covers from
+	// it, refreshed every RefreshInterval. It needs the postgres storage
+	// driver.
+	Materialized    bool          `yaml:"materialized"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// EventsConfig controls the change feed of /api/cars/events.
+type EventsConfig struct {
+	// Retention is how long events stay in the log for resuming streams.
+	Retention time.Duration `yaml:"retention"`
+	// Heartbeat is the pause after which an idle stream gets a comment line,
+	// so proxies do not close it.
+	Heartbeat time.Duration `yaml:"heartbeat"`
+}
+
+// WebhooksConfig controls the delivery of change feed events to webhook
+// subscribers.
+type WebhooksConfig struct {
+	// Timeout bounds one delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is how many times a delivery is tried before it is dead.
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff is the pause after the first failed attempt; it doubles after
+	// every further one, up to MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Workers is how many deliveries are attempted at once.
+	Workers int `yaml:"workers"`
+	// AllowPrivate lets subscriptions target loopback, private and
+	// link-local addresses, for development.
+	AllowPrivate bool `yaml:"allow_private"`
+}
+
+// AttachmentsConfig controls the files attached to cars.
+type AttachmentsConfig struct {
+	// Dir is where the local blob store keeps attachment contents.
+	Dir string `yaml:"dir"`
+	// MaxSize caps one attachment, in bytes. The whole upload is also
+	// capped by limits.max_body_bytes, so it must stay below that.
+	MaxSize int `yaml:"max_size"`
+	// AllowedTypes lists the media types accepted, as detected from the
+	// content.
+	AllowedTypes []string `yaml:"allowed_types"`
+}
+
+type BucketConfig struct {
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+}
+
+type LoggingConfig struct {
+	// Level is the lowest level written: debug, info or error.
+	Level string `yaml:"level"`
+	// Output is stderr, stdout or a file path.
+	Output string `yaml:"output"`
+}
+
+type AuthConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Keys    []APIKey `yaml:"keys"`
+}
+
+type APIKey struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+	Role string `yaml:"role"`
+}
+
+const redactedValue = "******"
+
 func init() {
 	if err := godotenv.Load(); err != nil {
 		log.Print("No .env file found")
 	}
 }
 
-func New() *Config {
-	log.Println("[INFO] Initializing env variables")
+func defaults() *Config {
 	return &Config{
+		Storage: StorageConfig{
+			Driver: "postgres",
+			SQLite: SQLiteConfig{Path: "car_catalog.db"},
+		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", ""),
-			Port:     getEnvAsInt("DB_PORT", 0),
-			User:     getEnv("DB_USER", ""),
-			Password: getEnv("DB_PASSWORD", ""),
-			DBName:   getEnv("DB_NAME", ""),
+			Port:             5432,
+			ApplicationName:  "car_catalog",
+			StatementTimeout: 30 * time.Second,
+			QueryTimeout:     10 * time.Second,
+			SSL:              SSLConfig{Mode: "disable"},
+			Pool: PoolConfig{
+				MaxConns:          10,
+				MaxConnLifetime:   time.Hour,
+				MaxConnIdleTime:   30 * time.Minute,
+				HealthCheckPeriod: time.Minute,
+			},
+		},
+		HTTP: HTTPConfig{
+			Port:              8080,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		GRPC: GRPCConfig{
+			Enabled:    true,
+			Port:       9090,
+			Reflection: true,
 		},
-		HTTPHost:       getEnv("HTTP_HOST", ""),
-		HTTPPort:       getEnvAsInt("HTTP_PORT", 0),
-		ExternalAPIURL: getEnv("EXTERNAL_API_URL", ""),
+		GraphQL: GraphQLConfig{Enabled: true, MaxDepth: 10, MaxComplexity: 2000},
+		External: ExternalConfig{
+			Timeout:   10 * time.Second,
+			Providers: []string{"http"},
+			Cache: CacheProviderConfig{
+				TTL:         10 * time.Minute,
+				NegativeTTL: time.Minute,
+				Size:        10000,
+			},
+		},
+		Logging: LoggingConfig{Level: "debug", Output: "stderr"},
+		Resync: ResyncConfig{
+			Interval:   time.Hour,
+			StaleAfter: 7 * 24 * time.Hour,
+			BatchSize:  100,
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled:     true,
+			TTL:         24 * time.Hour,
+			LockTimeout: time.Minute,
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes: 10 << 20,
+			MaxRegNums:   100,
+			MaxBatchSize: 10000,
+			RateLimit: RateLimitConfig{
+				Read:   BucketConfig{PerMinute: 600, Burst: 60},
+				Import: BucketConfig{PerMinute: 10, Burst: 5},
+			},
+		},
+		Suggest: SuggestConfig{
+			Timeout:  200 * time.Millisecond,
+			MaxLimit: 50,
+		},
+		Stats: StatsConfig{
+			RefreshInterval: 15 * time.Minute,
+		},
+		Events: EventsConfig{
+			Retention: 7 * 24 * time.Hour,
+			Heartbeat: 15 * time.Second,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 8,
+			Backoff:     30 * time.Second,
+			MaxBackoff:  time.Hour,
+			Workers:     4,
+		},
+		Attachments: AttachmentsConfig{
+			Dir:          "attachments",
+			MaxSize:      8 << 20,
+			AllowedTypes: []string{"image/jpeg", "image/png", "image/webp", "image/gif", "application/pdf"},
+		},
+	}
+}
+
+// Loader holds the command-line layer of the configuration. Create it before
+// parsing the flag set, then call Load once flags are parsed.
+type Loader struct {
+	fs   *flag.FlagSet
+	path *string
+	vals map[string]*string
+}
+
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:   fs,
+		path: fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file (default config.yaml when present)"),
+		vals: make(map[string]*string),
+	}
+	for _, f := range defaults().fields() {
+		l.vals[f.flag] = fs.String(f.flag, "", fmt.Sprintf("%s (env %s)", f.key, f.env))
+	}
+	return l
+}
+
+// Load builds and validates the effective configuration.
+func (l *Loader) Load() (*Config, error) {
+	cfg, err := l.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Resolve merges all layers without validating the result.
+func (l *Loader) Resolve() (*Config, error) {
+	log.Println("[INFO] Loading configuration")
+	cfg := defaults()
+
+	path := *l.path
+	if path == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			path = "config.yaml"
+		}
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		log.Printf("[DEBUG] Config - Loaded file %s", path)
+	}
+
+	setFlags := make(map[string]bool)
+	l.fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	for _, f := range cfg.fields() {
+		if value, ok := os.LookupEnv(f.env); ok && value != "" {
+			if err := f.set(value); err != nil {
+				return nil, fmt.Errorf("env %s: %w", f.env, err)
+			}
+		}
+		if setFlags[f.flag] {
+			if err := f.set(*l.vals[f.flag]); err != nil {
+				return nil, fmt.Errorf("flag -%s: %w", f.flag, err)
+			}
+		}
 	}
+
+	return cfg, nil
 }
 
-func getEnv(key string, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
 	}
 
-	return defaultVal
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	return nil
 }
 
-func getEnvAsInt(name string, defaultVal int) int {
-	valueStr := getEnv(name, "")
-	if value, err := strconv.Atoi(valueStr); err == nil {
-		return value
+// Validate checks the settings every command needs. Problems are reported
+// together, naming both the config key and its environment variable.
+func (c *Config) Validate() error {
+	var problems []string
+	usesPostgres := c.Storage.Driver == "postgres"
+	for _, f := range c.fields() {
+		if strings.HasPrefix(f.key, "database.") && !usesPostgres {
+			continue
+		}
+		if f.required && f.isZero() {
+			problems = append(problems, fmt.Sprintf("%s (env %s) is required", f.key, f.env))
+		}
+	}
+
+	switch c.Storage.Driver {
+	case "postgres", "memory":
+	case "sqlite":
+		if c.Storage.SQLite.Path == "" {
+			problems = append(problems, "storage.sqlite.path (env SQLITE_PATH) is required for the sqlite driver")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("storage.driver %q must be postgres, sqlite or memory", c.Storage.Driver))
+	}
+
+	pool := c.Database.Pool
+	if pool.MaxConns <= 0 {
+		problems = append(problems, "database.pool.max_conns must be positive")
+	}
+	if pool.MinConns < 0 || pool.MinConns > pool.MaxConns {
+		problems = append(problems, "database.pool.min_conns must be between 0 and database.pool.max_conns")
+	}
+
+	switch c.Database.SSL.Mode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		problems = append(problems, fmt.Sprintf("database.ssl.mode %q is not a libpq sslmode", c.Database.SSL.Mode))
+	}
+	if (c.Database.SSL.Cert == "") != (c.Database.SSL.Key == "") {
+		problems = append(problems, "database.ssl.cert and database.ssl.key must be set together")
+	}
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"database.statement_timeout", c.Database.StatementTimeout},
+		{"database.query_timeout", c.Database.QueryTimeout},
+		{"database.pool.max_conn_lifetime", pool.MaxConnLifetime},
+		{"database.pool.max_conn_idle_time", pool.MaxConnIdleTime},
+		{"database.pool.health_check_period", pool.HealthCheckPeriod},
+	} {
+		if d.value < 0 {
+			problems = append(problems, fmt.Sprintf("%s must not be negative", d.key))
+		}
+	}
+
+	if c.External.URL != "" {
+		if u, err := url.Parse(c.External.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("external.url %q is not an absolute http(s) URL", c.External.URL))
+		}
+	}
+
+	problems = append(problems, c.External.validateProviders()...)
+	if c.External.Cache.Persistent && c.Storage.Driver != "postgres" {
+		problems = append(problems, "external.cache.persistent needs storage.driver postgres")
+	}
+
+	if c.GRPC.Enabled && (c.GRPC.Port <= 0 || c.GRPC.Port > 65535 || (c.GRPC.Port == c.HTTP.Port && c.GRPC.Host == c.HTTP.Host)) {
+		problems = append(problems, fmt.Sprintf("grpc.port %d must be a valid port other than http.port", c.GRPC.Port))
+	}
+
+	tls := c.HTTP.TLS
+	if tls.Enabled && (tls.CertFile == "" || tls.KeyFile == "") {
+		problems = append(problems, "http.tls.cert_file and http.tls.key_file are required when TLS is enabled")
+	}
+
+	switch c.Logging.Level {
+	case "debug", "info", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("logging.level %q must be debug, info or error", c.Logging.Level))
+	}
+
+	if c.Resync.Enabled {
+		if c.Resync.Interval <= 0 || c.Resync.StaleAfter <= 0 {
+			problems = append(problems, "resync.interval and resync.stale_after must be positive")
+		}
+		if c.Resync.BatchSize <= 0 {
+			problems = append(problems, "resync.batch_size must be positive")
+		}
+	}
+
+	if c.Idempotency.Enabled && (c.Idempotency.TTL <= 0 || c.Idempotency.LockTimeout <= 0) {
+		problems = append(problems, "idempotency.ttl and idempotency.lock_timeout must be positive")
 	}
 
-	return defaultVal
+	if c.GraphQL.Enabled && (c.GraphQL.MaxDepth <= 0 || c.GraphQL.MaxComplexity <= 0) {
+		problems = append(problems, "graphql.max_depth and graphql.max_complexity must be positive")
+	}
+
+	if c.Limits.MaxBodyBytes < 0 {
+		problems = append(problems, "limits.max_body_bytes must not be negative")
+	}
+	if c.Limits.MaxRegNums <= 0 || c.Limits.MaxBatchSize <= 0 {
+		problems = append(problems, "limits.max_reg_nums and limits.max_batch_size must be positive")
+	}
+	if rl := c.Limits.RateLimit; rl.Enabled {
+		for _, b := range []struct {
+			name   string
+			bucket BucketConfig
+		}{{"read", rl.Read}, {"import", rl.Import}} {
+			if b.bucket.PerMinute <= 0 || b.bucket.Burst <= 0 {
+				problems = append(problems, fmt.Sprintf("limits.rate_limit.%s.per_minute and burst must be positive", b.name))
+			}
+		}
+	}
+
+	if c.Suggest.Timeout <= 0 || c.Suggest.MaxLimit <= 0 {
+		problems = append(problems, "suggest.timeout and suggest.max_limit must be positive")
+	}
+	if c.Stats.Materialized {
+		if c.Storage.Driver != "postgres" {
+			problems = append(problems, "stats.materialized needs storage.driver postgres")
+		}
+		if c.Stats.RefreshInterval <= 0 {
+			problems = append(problems, "stats.refresh_interval must be positive")
+		}
+	}
+	if c.Events.Retention <= 0 || c.Events.Heartbeat <= 0 {
+		problems = append(problems, "events.retention and events.heartbeat must be positive")
+	}
+	if wh := c.Webhooks; wh.Timeout <= 0 || wh.MaxAttempts <= 0 || wh.Workers <= 0 {
+		problems = append(problems, "webhooks.timeout, webhooks.max_attempts and webhooks.workers must be positive")
+	}
+	if wh := c.Webhooks; wh.Backoff <= 0 || wh.MaxBackoff < wh.Backoff {
+		problems = append(problems, "webhooks.backoff must be positive and not above webhooks.max_backoff")
+	}
+
+	if c.Attachments.Dir == "" {
+		problems = append(problems, "attachments.dir (env ATTACHMENTS_DIR) is required")
+	}
+	if c.Attachments.MaxSize <= 0 {
+		problems = append(problems, "attachments.max_size must be positive")
+	} else if c.Limits.MaxBodyBytes > 0 && c.Attachments.MaxSize >= c.Limits.MaxBodyBytes {
+		problems = append(problems, "attachments.max_size must be below limits.max_body_bytes, which caps the whole upload")
+	}
+	if len(c.Attachments.AllowedTypes) == 0 {
+		problems = append(problems, "attachments.allowed_types must not be empty")
+	}
+	for _, t := range c.Attachments.AllowedTypes {
+		if mediaType, params, err := mime.ParseMediaType(t); err != nil || len(params) > 0 || mediaType != strings.TrimSpace(strings.ToLower(t)) {
+			problems = append(problems, fmt.Sprintf("attachments.allowed_types: %q is not a media type like image/jpeg", t))
+		}
+	}
+
+	if c.Auth.Enabled && len(c.Auth.Keys) == 0 {
+		problems = append(problems, "auth.keys must not be empty when auth is enabled")
+	}
+	for i, key := range c.Auth.Keys {
+		if key.Key == "" || key.Role == "" {
+			problems = append(problems, fmt.Sprintf("auth.keys[%d] needs both key and role", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (e ExternalConfig) validateProviders() []string {
+	var problems []string
+	if len(e.Providers) == 0 {
+		problems = append(problems, "external.providers (env EXTERNAL_PROVIDERS) must not be empty")
+	}
+	seen := make(map[string]bool)
+	for i, name := range e.Providers {
+		switch name {
+		case "http", "fixture", "cache":
+		default:
+			problems = append(problems, fmt.Sprintf("external.providers: unknown provider %q, want http, fixture or cache", name))
+		}
+		if seen[name] {
+			problems = append(problems, fmt.Sprintf("external.providers: %q is listed twice", name))
+		}
+		seen[name] = true
+		if name == "cache" && i == len(e.Providers)-1 {
+			problems = append(problems, "external.providers: cache must be followed by the providers it caches")
+		}
+	}
+	if seen["fixture"] && e.Fixture.Path == "" {
+		problems = append(problems, "external.fixture.path (env EXTERNAL_FIXTURE_PATH) is required for the fixture provider")
+	}
+	if seen["cache"] {
+		if e.Cache.TTL <= 0 {
+			problems = append(problems, "external.cache.ttl must be positive")
+		}
+		if e.Cache.NegativeTTL < 0 {
+			problems = append(problems, "external.cache.negative_ttl must not be negative")
+		}
+		if e.Cache.Size <= 0 {
+			problems = append(problems, "external.cache.size must be positive")
+		}
+	}
+	return problems
+}
+
+// ValidateServer adds the checks that only matter when serving HTTP traffic.
+func (c *Config) ValidateServer() error {
+	for _, name := range c.External.Providers {
+		if name == "http" && c.External.URL == "" {
+			return errors.New("invalid configuration: external.url (env EXTERNAL_API_URL) is required by the http provider to serve /api/addCars")
+		}
+	}
+	return nil
+}
+
+// Redacted returns a copy that is safe to print: passwords and API keys are
+// masked.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Database.Password != "" {
+		redacted.Database.Password = redactedValue
+	}
+	redacted.Auth.Keys = make([]APIKey, len(c.Auth.Keys))
+	for i, key := range c.Auth.Keys {
+		key.Key = redactedValue
+		redacted.Auth.Keys[i] = key
+	}
+	return &redacted
 }
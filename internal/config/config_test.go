@@ -0,0 +1,182 @@
+package config_test
+
+import (
+	"car_catalog/internal/config"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resolve loads the configuration from a YAML file holding yaml and the
+// given command-line arguments; the caller sets the environment.
+func resolve(t *testing.T, yaml string, args ...string) (*config.Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(append([]string{"-config", path}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	return loader.Resolve()
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+	yaml := `
+storage:
+  driver: memory
+http:
+  host: yaml-host
+  port: 1000
+suggest:
+  timeout: 1s
+  max_limit: 20
+`
+	t.Setenv("HTTP_PORT", "2000")
+	t.Setenv("SUGGEST_MAX_LIMIT", "30")
+	t.Setenv("HTTP_HOST", "") // empty variables are ignored
+	t.Setenv("ATTACHMENTS_ALLOWED_TYPES", "image/png, ,application/pdf")
+
+	cfg, err := resolve(t, yaml, "-http-port", "3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"flag over env and file", cfg.HTTP.Port, 3000},
+		{"env over file", cfg.Suggest.MaxLimit, 30},
+		{"file over default", cfg.HTTP.Host, "yaml-host"},
+		{"file duration", cfg.Suggest.Timeout, time.Second},
+		{"default", cfg.Webhooks.Workers, 4},
+		{"env list", cfg.Attachments.AllowedTypes, []string{"image/png", "application/pdf"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoaderRejectsBadInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown file key", yaml: "http:\n  prot: 8080\n", wantErr: "field prot not found"},
+		{name: "wrong file type", yaml: "http:\n  port: eighty\n", wantErr: "unable to parse config file"},
+		{name: "bad env value", env: "eighty", wantErr: `env HTTP_PORT: http.port: "eighty" is not an integer`},
+		{name: "bad flag value", args: []string{"-suggest-timeout", "soon"}, wantErr: `flag -suggest-timeout: suggest.timeout: "soon" is not a duration`},
+	}
+	for _, tt := range tests {
+		t.Setenv("HTTP_PORT", tt.env)
+		_, err := resolve(t, tt.yaml, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// An empty file only keeps the defaults.
+	t.Setenv("HTTP_PORT", "")
+	cfg, err := resolve(t, "")
+	if err != nil {
+		t.Fatalf("empty file: %v", err)
+	}
+	if cfg.HTTP.Port != 8080 {
+		t.Fatalf("empty file: port = %d, want the default 8080", cfg.HTTP.Port)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, name := range []string{"DB_HOST", "DB_USER", "DB_NAME", "STORAGE_DRIVER"} {
+		t.Setenv(name, "")
+	}
+	valid := func(t *testing.T) *config.Config {
+		cfg, err := resolve(t, "storage:\n  driver: memory\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	if err := valid(t).Validate(); err != nil {
+		t.Fatalf("defaults with memory storage: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+		want   []string
+	}{
+		{"postgres needs connection settings", func(c *config.Config) { c.Storage.Driver = "postgres" },
+			[]string{"database.host (env DB_HOST) is required", "database.user (env DB_USER) is required", "database.name (env DB_NAME) is required"}},
+		{"unknown driver", func(c *config.Config) { c.Storage.Driver = "mysql" },
+			[]string{`storage.driver "mysql" must be postgres, sqlite or memory`}},
+		{"sqlite needs a path", func(c *config.Config) { c.Storage.Driver, c.Storage.SQLite.Path = "sqlite", "" },
+			[]string{"storage.sqlite.path (env SQLITE_PATH) is required"}},
+		{"problems are reported together", func(c *config.Config) {
+			c.Logging.Level = "trace"
+			c.Idempotency.LockTimeout = 0
+			c.GRPC.Port = c.HTTP.Port
+		}, []string{`logging.level "trace"`, "idempotency.ttl and idempotency.lock_timeout must be positive", "grpc.port 8080 must be a valid port"}},
+		{"auth without keys", func(c *config.Config) { c.Auth.Enabled = true },
+			[]string{"auth.keys must not be empty"}},
+		{"key without role", func(c *config.Config) { c.Auth.Keys = []config.APIKey{{Name: "crm", Key: "secret"}} },
+			[]string{"auth.keys[0] needs both key and role"}},
+		{"attachments above the body limit", func(c *config.Config) { c.Attachments.MaxSize = c.Limits.MaxBodyBytes },
+			[]string{"attachments.max_size must be below limits.max_body_bytes"}},
+	}
+	for _, tt := range tests {
+		cfg := valid(t)
+		tt.modify(cfg)
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("%s: want an error", tt.name)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not mention %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "")
+	cfg, err := resolve(t, `
+database:
+  password: hunter2
+auth:
+  keys:
+    - name: crm
+      key: secret
+      role: admin
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.Database.Password != "******" || redacted.Auth.Keys[0].Key != "******" {
+		t.Fatalf("secrets not masked: %+v, %+v", redacted.Database, redacted.Auth.Keys)
+	}
+	if redacted.Auth.Keys[0].Name != "crm" || redacted.Auth.Keys[0].Role != "admin" {
+		t.Fatalf("key names and roles must stay readable: %+v", redacted.Auth.Keys)
+	}
+	if cfg.Database.Password != "hunter2" || cfg.Auth.Keys[0].Key != "secret" {
+		t.Fatal("Redacted changed the original configuration")
+	}
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field binds one setting to its config key, environment variable and
+// command-line flag. String lists are written comma-separated; lists of
+// structs such as auth.keys are only settable from the file.
+type field struct {
+	key      string
+	env      string
+	flag     string
+	required bool
+	ptr      any
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{key: "storage.driver", env: "STORAGE_DRIVER", flag: "storage", required: true, ptr: &c.Storage.Driver},
+		{key: "storage.sqlite.path", env: "SQLITE_PATH", flag: "sqlite-path", ptr: &c.Storage.SQLite.Path},
+
+		{key: "database.host", env: "DB_HOST", flag: "db-host", required: true, ptr: &c.Database.Host},
+		{key: "database.port", env: "DB_PORT", flag: "db-port", required: true, ptr: &c.Database.Port},
+		{key: "database.user", env: "DB_USER", flag: "db-user", required: true, ptr: &c.Database.User},
+		{key: "database.password", env: "DB_PASSWORD", flag: "db-password", ptr: &c.Database.Password},
+		{key: "database.name", env: "DB_NAME", flag: "db-name", required: true, ptr: &c.Database.DBName},
+		{key: "database.application_name", env: "DB_APPLICATION_NAME", flag: "db-application-name", ptr: &c.Database.ApplicationName},
+		{key: "database.statement_timeout", env: "DB_STATEMENT_TIMEOUT", flag: "db-statement-timeout", ptr: &c.Database.StatementTimeout},
+		{key: "database.query_timeout", env: "DB_QUERY_TIMEOUT", flag: "db-query-timeout", ptr: &c.Database.QueryTimeout},
+		{key: "database.ssl.mode", env: "DB_SSL_MODE", flag: "db-ssl-mode", ptr: &c.Database.SSL.Mode},
+		{key: "database.ssl.root_cert", env: "DB_SSL_ROOT_CERT", flag: "db-ssl-root-cert", ptr: &c.Database.SSL.RootCert},
+		{key: "database.ssl.cert", env: "DB_SSL_CERT", flag: "db-ssl-cert", ptr: &c.Database.SSL.Cert},
+		{key: "database.ssl.key", env: "DB_SSL_KEY", flag: "db-ssl-key", ptr: &c.Database.SSL.Key},
+		{key: "database.pool.max_conns", env: "DB_POOL_MAX_CONNS", flag: "db-pool-max-conns", ptr: &c.Database.Pool.MaxConns},
+		{key: "database.pool.min_conns", env: "DB_POOL_MIN_CONNS", flag: "db-pool-min-conns", ptr: &c.Database.Pool.MinConns},
+		{key: "database.pool.max_conn_lifetime", env: "DB_POOL_MAX_CONN_LIFETIME", flag: "db-pool-max-conn-lifetime", ptr: &c.Database.Pool.MaxConnLifetime},
+		{key: "database.pool.max_conn_idle_time", env: "DB_POOL_MAX_CONN_IDLE_TIME", flag: "db-pool-max-conn-idle-time", ptr: &c.Database.Pool.MaxConnIdleTime},
+		{key: "database.pool.health_check_period", env: "DB_POOL_HEALTH_CHECK_PERIOD", flag: "db-pool-health-check-period", ptr: &c.Database.Pool.HealthCheckPeriod},
+
+		{key: "http.host", env: "HTTP_HOST", flag: "http-host", ptr: &c.HTTP.Host},
+		{key: "http.port", env: "HTTP_PORT", flag: "http-port", required: true, ptr: &c.HTTP.Port},
+		{key: "http.read_timeout", env: "HTTP_READ_TIMEOUT", flag: "http-read-timeout", ptr: &c.HTTP.ReadTimeout},
+		{key: "http.read_header_timeout", env: "HTTP_READ_HEADER_TIMEOUT", flag: "http-read-header-timeout", ptr: &c.HTTP.ReadHeaderTimeout},
+		{key: "http.write_timeout", env: "HTTP_WRITE_TIMEOUT", flag: "http-write-timeout", ptr: &c.HTTP.WriteTimeout},
+		{key: "http.idle_timeout", env: "HTTP_IDLE_TIMEOUT", flag: "http-idle-timeout", ptr: &c.HTTP.IdleTimeout},
+		{key: "http.shutdown_timeout", env: "HTTP_SHUTDOWN_TIMEOUT", flag: "http-shutdown-timeout", ptr: &c.HTTP.ShutdownTimeout},
+		{key: "http.tls.enabled", env: "HTTP_TLS_ENABLED", flag: "http-tls-enabled", ptr: &c.HTTP.TLS.Enabled},
+		{key: "http.tls.cert_file", env: "HTTP_TLS_CERT_FILE", flag: "http-tls-cert-file", ptr: &c.HTTP.TLS.CertFile},
+		{key: "http.tls.key_file", env: "HTTP_TLS_KEY_FILE", flag: "http-tls-key-file", ptr: &c.HTTP.TLS.KeyFile},
+		{key: "grpc.enabled", env: "GRPC_ENABLED", flag: "grpc-enabled", ptr: &c.GRPC.Enabled},
+		{key: "grpc.host", env: "GRPC_HOST", flag: "grpc-host", ptr: &c.GRPC.Host},
+		{key: "grpc.port", env: "GRPC_PORT", flag: "grpc-port", ptr: &c.GRPC.Port},
+		{key: "grpc.reflection", env: "GRPC_REFLECTION", flag: "grpc-reflection", ptr: &c.GRPC.Reflection},
+		{key: "graphql.enabled", env: "GRAPHQL_ENABLED", flag: "graphql-enabled", ptr: &c.GraphQL.Enabled},
+		{key: "graphql.max_depth", env: "GRAPHQL_MAX_DEPTH", flag: "graphql-max-depth", ptr: &c.GraphQL.MaxDepth},
+		{key: "graphql.max_complexity", env: "GRAPHQL_MAX_COMPLEXITY", flag: "graphql-max-complexity", ptr: &c.GraphQL.MaxComplexity},
+
+		{key: "external.url", env: "EXTERNAL_API_URL", flag: "external-url", ptr: &c.External.URL},
+		{key: "external.timeout", env: "EXTERNAL_API_TIMEOUT", flag: "external-timeout", ptr: &c.External.Timeout},
+		{key: "external.providers", env: "EXTERNAL_PROVIDERS", flag: "external-providers", ptr: &c.External.Providers},
+		{key: "external.fixture.path", env: "EXTERNAL_FIXTURE_PATH", flag: "external-fixture-path", ptr: &c.External.Fixture.Path},
+		{key: "external.cache.ttl", env: "EXTERNAL_CACHE_TTL", flag: "external-cache-ttl", ptr: &c.External.Cache.TTL},
+		{key: "external.cache.negative_ttl", env: "EXTERNAL_CACHE_NEGATIVE_TTL", flag: "external-cache-negative-ttl", ptr: &c.External.Cache.NegativeTTL},
+		{key: "external.cache.size", env: "EXTERNAL_CACHE_SIZE", flag: "external-cache-size", ptr: &c.External.Cache.Size},
+		{key: "external.cache.persistent", env: "EXTERNAL_CACHE_PERSISTENT", flag: "external-cache-persistent", ptr: &c.External.Cache.Persistent},
+
+		{key: "logging.level", env: "LOG_LEVEL", flag: "log-level", ptr: &c.Logging.Level},
+		{key: "logging.output", env: "LOG_OUTPUT", flag: "log-output", ptr: &c.Logging.Output},
+
+		{key: "auth.enabled", env: "AUTH_ENABLED", flag: "auth-enabled", ptr: &c.Auth.Enabled},
+
+		{key: "resync.enabled", env: "RESYNC_ENABLED", flag: "resync-enabled", ptr: &c.Resync.Enabled},
+		{key: "resync.interval", env: "RESYNC_INTERVAL", flag: "resync-interval", ptr: &c.Resync.Interval},
+		{key: "resync.stale_after", env: "RESYNC_STALE_AFTER", flag: "resync-stale-after", ptr: &c.Resync.StaleAfter},
+		{key: "resync.batch_size", env: "RESYNC_BATCH_SIZE", flag: "resync-batch-size", ptr: &c.Resync.BatchSize},
+
+		{key: "idempotency.enabled", env: "IDEMPOTENCY_ENABLED", flag: "idempotency-enabled", ptr: &c.Idempotency.Enabled},
+		{key: "idempotency.ttl", env: "IDEMPOTENCY_TTL", flag: "idempotency-ttl", ptr: &c.Idempotency.TTL},
+		{key: "idempotency.lock_timeout", env: "IDEMPOTENCY_LOCK_TIMEOUT", flag: "idempotency-lock-timeout", ptr: &c.Idempotency.LockTimeout},
+
+		{key: "limits.max_body_bytes", env: "LIMITS_MAX_BODY_BYTES", flag: "limits-max-body-bytes", ptr: &c.Limits.MaxBodyBytes},
+		{key: "limits.max_reg_nums", env: "LIMITS_MAX_REG_NUMS", flag: "limits-max-reg-nums", ptr: &c.Limits.MaxRegNums},
+		{key: "limits.max_batch_size", env: "LIMITS_MAX_BATCH_SIZE", flag: "limits-max-batch-size", ptr: &c.Limits.MaxBatchSize},
+		{key: "limits.rate_limit.enabled", env: "RATE_LIMIT_ENABLED", flag: "rate-limit-enabled", ptr: &c.Limits.RateLimit.Enabled},
+		{key: "limits.rate_limit.read.per_minute", env: "RATE_LIMIT_READ_PER_MINUTE", flag: "rate-limit-read-per-minute", ptr: &c.Limits.RateLimit.Read.PerMinute},
+		{key: "limits.rate_limit.read.burst", env: "RATE_LIMIT_READ_BURST", flag: "rate-limit-read-burst", ptr: &c.Limits.RateLimit.Read.Burst},
+		{key: "limits.rate_limit.import.per_minute", env: "RATE_LIMIT_IMPORT_PER_MINUTE", flag: "rate-limit-import-per-minute", ptr: &c.Limits.RateLimit.Import.PerMinute},
+		{key: "limits.rate_limit.import.burst", env: "RATE_LIMIT_IMPORT_BURST", flag: "rate-limit-import-burst", ptr: &c.Limits.RateLimit.Import.Burst},
+
+		{key: "suggest.timeout", env: "SUGGEST_TIMEOUT", flag: "suggest-timeout", ptr: &c.Suggest.Timeout},
+		{key: "suggest.max_limit", env: "SUGGEST_MAX_LIMIT", flag: "suggest-max-limit", ptr: &c.Suggest.MaxLimit},
+		{key: "stats.materialized", env: "STATS_MATERIALIZED", flag: "stats-materialized", ptr: &c.Stats.Materialized},
+		{key: "stats.refresh_interval", env: "STATS_REFRESH_INTERVAL", flag: "stats-refresh-interval", ptr: &c.Stats.RefreshInterval},
+		{key: "events.retention", env: "EVENTS_RETENTION", flag: "events-retention", ptr: &c.Events.Retention},
+		{key: "events.heartbeat", env: "EVENTS_HEARTBEAT", flag: "events-heartbeat", ptr: &c.Events.Heartbeat},
+		{key: "webhooks.timeout", env: "WEBHOOKS_TIMEOUT", flag: "webhooks-timeout", ptr: &c.Webhooks.Timeout},
+		{key: "webhooks.max_attempts", env: "WEBHOOKS_MAX_ATTEMPTS", flag: "webhooks-max-attempts", ptr: &c.Webhooks.MaxAttempts},
+		{key: "webhooks.backoff", env: "WEBHOOKS_BACKOFF", flag: "webhooks-backoff", ptr: &c.Webhooks.Backoff},
+		{key: "webhooks.max_backoff", env: "WEBHOOKS_MAX_BACKOFF", flag: "webhooks-max-backoff", ptr: &c.Webhooks.MaxBackoff},
+		{key: "webhooks.workers", env: "WEBHOOKS_WORKERS", flag: "webhooks-workers", ptr: &c.Webhooks.Workers},
+		{key: "webhooks.allow_private", env: "WEBHOOKS_ALLOW_PRIVATE", flag: "webhooks-allow-private", ptr: &c.Webhooks.AllowPrivate},
+		{key: "attachments.dir", env: "ATTACHMENTS_DIR", flag: "attachments-dir", ptr: &c.Attachments.Dir},
+		{key: "attachments.max_size", env: "ATTACHMENTS_MAX_SIZE", flag: "attachments-max-size", ptr: &c.Attachments.MaxSize},
+		{key: "attachments.allowed_types", env: "ATTACHMENTS_ALLOWED_TYPES", flag: "attachments-allowed-types", ptr: &c.Attachments.AllowedTypes},
+	}
+}
+
+func (f field) set(value string) error {
+	switch ptr := f.ptr.(type) {
+	case *string:
+		*ptr = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", f.key, value)
+		}
+		*ptr = n
+	case *int32:
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", f.key, value)
+		}
+		*ptr = int32(n)
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", f.key, value)
+		}
+		*ptr = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration", f.key, value)
+		}
+		*ptr = d
+	case *[]string:
+		*ptr = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*ptr = append(*ptr, item)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported field type %T", f.key, f.ptr)
+	}
+	return nil
+}
+
+func (f field) isZero() bool {
+	switch ptr := f.ptr.(type) {
+	case *string:
+		return *ptr == ""
+	case *int:
+		return *ptr == 0
+	case *int32:
+		return *ptr == 0
+	case *bool:
+		return !*ptr
+	case *time.Duration:
+		return *ptr == 0
+	case *[]string:
+		return len(*ptr) == 0
+	}
+	return false
+}
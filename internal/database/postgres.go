@@ -2,14 +2,19 @@ package database
 
 import (
 	"car_catalog/internal/config"
+	"car_catalog/migrations"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -18,44 +23,93 @@ import (
 func MigrateDatabase(cfg *config.Config) error {
 	log.Println("[INFO] MigrateDatabase - Starting database migration...")
 
-	databaseUrl := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s?sslmode=disable",
-		cfg.Database.User, cfg.Database.Password,
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName)
-	db, err := sql.Open("pgx", databaseUrl)
+	m, err := NewMigrator(cfg)
 	if err != nil {
-		log.Fatalf("error open connection to apply migration: %s", err)
+		return err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("could not read the schema version: %w", err)
+	}
+	if dirty {
+		return ExplainDirty(migrate.ErrDirty{Version: int(version)})
+	}
+	log.Printf("[DEBUG] MigrateDatabase - Current schema version: %d", version)
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not apply the migration: %w", ExplainDirty(err))
+	}
+
+	log.Println("[INFO] MigrateDatabase - Database migration completed successfully")
+	return nil
+}
+
+// ExplainDirty turns migrate.ErrDirty into an actionable message. A dirty
+// version means a migration failed halfway and the schema must be repaired by
+// hand before the version is forced.
+func ExplainDirty(err error) error {
+	var dirty migrate.ErrDirty
+	if errors.As(err, &dirty) {
+		return fmt.Errorf("database schema is dirty at version %d: repair it manually, then run \"migrate force <version>\"", dirty.Version)
+	}
+	return err
+}
+
+// NewMigrator opens a dedicated connection for schema migrations, reading the
+// migrations embedded in the binary for the configured storage driver. The
+// caller owns the returned instance and must Close it.
+func NewMigrator(cfg *config.Config) (*migrate.Migrate, error) {
+	if cfg.Storage.Driver == "sqlite" {
+		return newSQLiteMigrator(cfg)
+	}
+
+	db, err := sql.Open("pgx", ConnString(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("error open connection to apply migration: %w", err)
 	}
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		log.Fatalf("could not init driver: %s", err)
+		db.Close()
+		return nil, fmt.Errorf("could not init driver: %w", err)
 	}
 
-	migrate, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"pgx", driver)
+	source, err := iofs.New(migrations.FS, ".")
 	if err != nil {
-		log.Fatalf("could not apply the migration: %s", err)
+		db.Close()
+		return nil, fmt.Errorf("could not read embedded migrations: %w", err)
 	}
 
-	migrate.Up()
+	m, err := migrate.NewWithInstance("iofs", source, "pgx", driver)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create migrator: %w", err)
+	}
 
-	log.Println("[INFO] MigrateDatabase - Database migration completed successfully")
-	return nil
+	return m, nil
 }
 
 func DatabaseConnection(cfg *config.Config) *pgxpool.Pool {
 	log.Println("[INFO] DatabaseConnection - Connecting to database...")
 
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s?sslmode=disable",
-		cfg.Database.User, cfg.Database.Password,
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName)
-
-	config, err := pgxpool.ParseConfig(dsn)
+	config, err := pgxpool.ParseConfig(ConnString(cfg))
 	if err != nil {
 		log.Fatalf("Unable to create connection pool: %v\n", err)
 	}
 
+	pool := cfg.Database.Pool
+	config.MaxConns = pool.MaxConns
+	config.MinConns = pool.MinConns
+	config.MaxConnLifetime = pool.MaxConnLifetime
+	config.MaxConnIdleTime = pool.MaxConnIdleTime
+	config.HealthCheckPeriod = pool.HealthCheckPeriod
+	if timeout := cfg.Database.StatementTimeout; timeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	log.Printf("[DEBUG] DatabaseConnection - Pool: %+v, statement_timeout: %s", pool, cfg.Database.StatementTimeout)
+
 	conn, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		log.Fatalf("Unable to create connection pool: %v\n", err)
@@ -65,3 +119,31 @@ func DatabaseConnection(cfg *config.Config) *pgxpool.Pool {
 
 	return conn
 }
+
+// ConnString builds the connection URL shared by the pool and the migrator,
+// escaping credentials and carrying the SSL settings and application_name.
+func ConnString(cfg *config.Config) string {
+	db := cfg.Database
+
+	params := url.Values{}
+	params.Set("sslmode", db.SSL.Mode)
+	if db.SSL.RootCert != "" {
+		params.Set("sslrootcert", db.SSL.RootCert)
+	}
+	if db.SSL.Cert != "" {
+		params.Set("sslcert", db.SSL.Cert)
+		params.Set("sslkey", db.SSL.Key)
+	}
+	if db.ApplicationName != "" {
+		params.Set("application_name", db.ApplicationName)
+	}
+
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(db.User, db.Password),
+		Host:     net.JoinHostPort(db.Host, strconv.Itoa(db.Port)),
+		Path:     "/" + db.DBName,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
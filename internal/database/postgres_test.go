@@ -0,0 +1,86 @@
+package database
+
+import (
+	"car_catalog/internal/config"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+// TestMigrationsUpDown walks every migration up, back down and up again, then
+// rolls the whole schema back. It needs a disposable database described by
+// the usual DB_* variables and is skipped unless TEST_POSTGRES is set.
+func TestMigrationsUpDown(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("set TEST_POSTGRES=1 and DB_* variables to run against a disposable database")
+	}
+
+	cfg, err := config.NewLoader(flag.NewFlagSet("test", flag.ContinueOnError)).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMigrator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("reset: %v", err)
+	}
+
+	for step := 1; ; step++ {
+		err := m.Steps(1)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("step %d up: %v", step, err)
+		}
+		if err := m.Steps(-1); err != nil {
+			t.Fatalf("step %d down: %v", step, err)
+		}
+		if err := m.Steps(1); err != nil {
+			t.Fatalf("step %d up again: %v", step, err)
+		}
+		if _, dirty, _ := m.Version(); dirty {
+			t.Fatalf("step %d left the schema dirty", step)
+		}
+	}
+
+	if !tableExists(t, cfg, "cars.car") {
+		t.Fatal("cars.car does not exist after migrating up")
+	}
+
+	if err := m.Down(); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if _, _, err := m.Version(); !errors.Is(err, migrate.ErrNilVersion) {
+		t.Fatalf("expected no version after down, got %v", err)
+	}
+	if tableExists(t, cfg, "cars.car") || tableExists(t, cfg, "public.car") {
+		t.Fatal("car table survived migrating down")
+	}
+}
+
+func tableExists(t *testing.T, cfg *config.Config, name string) bool {
+	t.Helper()
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, ConnString(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/migrations"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// sqliteDSN enables WAL so readers do not block the writer, waits on locks
+// instead of failing with SQLITE_BUSY, and turns on foreign keys, which
+// SQLite leaves off by default.
+func sqliteDSN(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// SQLiteConnection opens the database file of the sqlite storage driver using
+// the pure-Go modernc.org/sqlite driver.
+func SQLiteConnection(cfg *config.Config) (*sql.DB, error) {
+	log.Printf("[INFO] SQLiteConnection - Opening %s...", cfg.Storage.SQLite.Path)
+
+	db, err := sql.Open("sqlite", sqliteDSN(cfg.Storage.SQLite.Path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+
+	log.Println("[INFO] SQLiteConnection - Successfully opened database")
+	return db, nil
+}
+
+// newSQLiteMigrator applies the SQLite migration set over its own handle;
+// closing the migrator closes that handle.
+func newSQLiteMigrator(cfg *config.Config) (*migrate.Migrate, error) {
+	db, err := SQLiteConnection(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not init driver: %w", err)
+	}
+
+	source, err := iofs.New(migrations.SQLite, "sqlite")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not read embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite", driver)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create migrator: %w", err)
+	}
+	return m, nil
+}
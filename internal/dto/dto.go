@@ -1,11 +1,20 @@
 package dto
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Filters struct {
 	Mark  string
 	Model string
 	Year  string
+	// Vin looks up the one car with this VIN; the other filters still apply.
+	Vin   string
 	Page  string
 	Limit string
+	// Query is the q= fuzzy search; when set, results are ranked by Score.
+	Query string
 }
 
 type AddCarsDto struct {
@@ -13,14 +22,25 @@ type AddCarsDto struct {
 	Model  string `json:"model"`
 	Year   int    `json:"year,omitempty"`
 	RegNum string `json:"regNum"`
+	Vin    string `json:"vin,omitempty"`
 	Owner  People
 }
 
+// AddCarsResult reports what AddCarsByRegNum did: how many cars were added
+// and the registration numbers the registry does not know, which are skipped.
+type AddCarsResult struct {
+	Added    int      `json:"added"`
+	NotFound []string `json:"notFound"`
+}
+
 type UpdateCarDto struct {
 	Mark   string `json:"mark,omitempty"`
 	Model  string `json:"model,omitempty"`
 	Year   string `json:"year,omitempty"`
 	RegNum string `json:"regNum,omitempty"`
+	Vin    string `json:"vin,omitempty"`
+	// Owner fields follow the same rule: empty values are left unchanged.
+	Owner *People `json:"owner,omitempty"`
 }
 
 type GetFilteredCarsDto struct {
@@ -28,6 +48,58 @@ type GetFilteredCarsDto struct {
 	Mark  string
 	Model string
 	Year  string
+	Vin   string  `json:",omitempty"`
+	Score float64 `json:",omitempty"`
+	// Cursor is the position of the car, encoded like the page cursors, for
+	// APIs that hand out a cursor per row.
+	Cursor string `json:"-"`
+}
+
+type SuggestionDto struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type ExportCarDto struct {
+	CarId  int     `json:"id"`
+	Mark   string  `json:"mark"`
+	Model  string  `json:"model"`
+	Year   int     `json:"year"`
+	RegNum string  `json:"regNum"`
+	Vin    string  `json:"vin,omitempty"`
+	Owner  *People `json:"owner,omitempty"`
+}
+
+type ImportCarDto struct {
+	Mark   string `json:"mark"`
+	Model  string `json:"model"`
+	Year   int    `json:"year"`
+	RegNum string `json:"regNum"`
+	Vin    string `json:"vin,omitempty"`
+	Owner  People `json:"owner"`
+}
+
+// ImportRow is one parsed record of an import file. Error is set when the
+// record could not be parsed at all.
+type ImportRow struct {
+	Line  int
+	Car   ImportCarDto
+	Error string
+}
+
+type ImportRowError struct {
+	Line   int    `json:"line"`
+	RegNum string `json:"regNum,omitempty"`
+	Error  string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun   bool             `json:"dryRun"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Rejected int              `json:"rejected"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
 }
 
 type People struct {
@@ -44,3 +116,245 @@ type Cursors struct {
 	Prev string `json:"prev,omitempty"`
 	Next string `json:"next,omitempty"`
 }
+
+type DivergenceDto struct {
+	Id            int       `json:"id"`
+	CarId         int       `json:"carId"`
+	Field         string    `json:"field"`
+	LocalValue    string    `json:"localValue"`
+	RegistryValue string    `json:"registryValue"`
+	DetectedAt    time.Time `json:"detectedAt"`
+	// Status is applied, failed or skipped; Error says why one failed.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ResyncResult describes one car compared with the registry. Divergences
+// lists what differed and whether each was applied.
+type ResyncResult struct {
+	CarId       int             `json:"carId"`
+	SyncedAt    time.Time       `json:"syncedAt"`
+	NotFound    bool            `json:"notFound,omitempty"`
+	Divergences []DivergenceDto `json:"divergences"`
+}
+
+type ResyncSummary struct {
+	Checked   int `json:"checked"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	NotFound  int `json:"notFound"`
+	Failed    int `json:"failed"`
+}
+
+// BatchFilter selects cars like the filters of /api/getCars; at least one
+// field must be set.
+type BatchFilter struct {
+	Mark  string `json:"mark,omitempty"`
+	Model string `json:"model,omitempty"`
+	Year  string `json:"year,omitempty"`
+}
+
+type BatchUpdateItem struct {
+	Id      int          `json:"id"`
+	Changes UpdateCarDto `json:"changes"`
+}
+
+// BatchUpdateRequest takes either Items, each with its own changes, or a
+// Filter with one set of Changes for every matching car.
+type BatchUpdateRequest struct {
+	Items   []BatchUpdateItem `json:"items,omitempty"`
+	Filter  *BatchFilter      `json:"filter,omitempty"`
+	Changes *UpdateCarDto     `json:"changes,omitempty"`
+	// Mode is "transaction" (default, all or nothing) or "per_item".
+	Mode   string `json:"mode,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+}
+
+// BatchDeleteRequest takes either Ids or a Filter.
+type BatchDeleteRequest struct {
+	Ids    []int        `json:"ids,omitempty"`
+	Filter *BatchFilter `json:"filter,omitempty"`
+	Mode   string       `json:"mode,omitempty"`
+	DryRun bool         `json:"dryRun,omitempty"`
+}
+
+type BatchItemError struct {
+	Id    int    `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// BatchResult reports a batch operation. Matched counts the cars the request
+// selected; Affected those actually changed, always 0 for a dry run or an
+// aborted transaction.
+type BatchResult struct {
+	Mode     string           `json:"mode"`
+	DryRun   bool             `json:"dryRun"`
+	Matched  int              `json:"matched"`
+	Affected int              `json:"affected"`
+	Failed   int              `json:"failed"`
+	Errors   []BatchItemError `json:"errors,omitempty"`
+}
+
+type MakeDto struct {
+	Id      int           `json:"id"`
+	Name    string        `json:"name"`
+	Aliases []string      `json:"aliases"`
+	Models  []ModelRefDto `json:"models"`
+}
+
+type ModelRefDto struct {
+	Id      int      `json:"id"`
+	MakeId  int      `json:"makeId"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// DictionaryEntryRequest creates a make or a model. The canonical name is
+// always an alias of the entry, so Aliases lists the other spellings only.
+type DictionaryEntryRequest struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+type AliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// UnmatchedValue is a mark, or a model of a known mark, missing from the
+// dictionary, with the number of cars using it.
+type UnmatchedValue struct {
+	Mark  string `json:"mark"`
+	Model string `json:"model,omitempty"`
+	Count int    `json:"count"`
+}
+
+// DictionaryMapReport is the outcome of mapping the catalog onto the
+// dictionary: Changed cars have a non-canonical mark or model, Updated of
+// them were rewritten (none on a dry run).
+type DictionaryMapReport struct {
+	DryRun          bool             `json:"dryRun"`
+	Scanned         int              `json:"scanned"`
+	Changed         int              `json:"changed"`
+	Updated         int              `json:"updated"`
+	UnmatchedMarks  []UnmatchedValue `json:"unmatchedMarks"`
+	UnmatchedModels []UnmatchedValue `json:"unmatchedModels"`
+}
+
+// StatsGroupDto is one group of /api/stats; only the grouped fields are set.
+type StatsGroupDto struct {
+	Mark   string `json:"mark,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Year   int    `json:"year,omitempty"`
+	Region string `json:"region,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+	Count  int    `json:"count"`
+}
+
+// StatsDto holds the largest groups, most cars first, and totals over all
+// groups. Source is "live" or "materialized"; the latter comes with the time
+// the snapshot was taken.
+type StatsDto struct {
+	GroupBy     []string        `json:"groupBy"`
+	Source      string          `json:"source"`
+	RefreshedAt *time.Time      `json:"refreshedAt,omitempty"`
+	TotalCars   int             `json:"totalCars"`
+	TotalGroups int             `json:"totalGroups"`
+	Groups      []StatsGroupDto `json:"groups"`
+}
+
+// EventFilter narrows /api/cars/events; empty fields match everything.
+type EventFilter struct {
+	Mark   string
+	Tenant string
+}
+
+// CarEventDto is the data of one change feed event. Car is the state after
+// the change, for deletions the last one; Owner is set for the roles that
+// may see it.
+type CarEventDto struct {
+	Id        int64        `json:"id"`
+	Type      string       `json:"type"`
+	CarId     int          `json:"carId"`
+	Tenant    string       `json:"tenant,omitempty"`
+	Car       ExportCarDto `json:"car"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// OwnershipDto is one owner in the history of a car: from Since, when the
+// change feed first recorded them, until the next owner took over. Until is
+// nil for the current owner.
+type OwnershipDto struct {
+	Owner People     `json:"owner"`
+	Since time.Time  `json:"since"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// GraphQLRequest is a GraphQL operation, sent as the JSON body of a POST or
+// as the query, operationName and variables (JSON) parameters of a GET.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// WebhookSubscriptionRequest subscribes Url to the change feed. Events lists
+// the event types to deliver (created, updated, deleted), every type when
+// empty; Mark limits the subscription to one mark. A secret is generated
+// when none is given.
+type WebhookSubscriptionRequest struct {
+	Url    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+	Mark   string   `json:"mark,omitempty"`
+}
+
+// WebhookSubscriptionDto describes a subscription. Secret is only returned
+// when the subscription is created.
+type WebhookSubscriptionDto struct {
+	Id           int       `json:"id"`
+	Url          string    `json:"url"`
+	Secret       string    `json:"secret,omitempty"`
+	Events       []string  `json:"events"`
+	Mark         string    `json:"mark,omitempty"`
+	AfterEventId int64     `json:"afterEventId"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// WebhookDeliveryDto is one event on its way to a subscription; Payload is
+// the body POSTed on every attempt.
+type WebhookDeliveryDto struct {
+	Id             int64           `json:"id"`
+	EventId        int64           `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+}
+
+// WebhookReplayRequest redelivers the dead deliveries of a subscription, or
+// with FromEventId every retained event after it that the subscription
+// matches.
+type WebhookReplayRequest struct {
+	FromEventId *int64 `json:"fromEventId,omitempty"`
+}
+
+type WebhookReplayResult struct {
+	Queued int64 `json:"queued"`
+}
+
+// AttachmentDto describes a file attached to a car; the content is served by
+// GET /api/cars/{id}/attachments/{attachmentId}.
+type AttachmentDto struct {
+	Id          int       `json:"id"`
+	CarId       int       `json:"carId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Sha256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"car_catalog/internal/dto"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatCSV, FormatNDJSON:
+		return Format(s), nil
+	case "":
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q", s)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Writer encodes exported cars one at a time, so callers can stream rows
+// without holding the whole result set in memory.
+type Writer interface {
+	Write(car dto.ExportCarDto) error
+	Flush() error
+}
+
+func NewWriter(format Format, w io.Writer, includeOwner bool) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), includeOwner: includeOwner}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w), includeOwner: includeOwner}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+var (
+	csvColumns      = []string{"id", "mark", "model", "year", "reg_num", "vin"}
+	csvOwnerColumns = []string{"owner_name", "owner_surname", "owner_patronymic"}
+)
+
+type csvWriter struct {
+	w             *csv.Writer
+	includeOwner  bool
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(car dto.ExportCarDto) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	record := []string{
+		strconv.Itoa(car.CarId), car.Mark, car.Model, strconv.Itoa(car.Year), car.RegNum, car.Vin,
+	}
+	if c.includeOwner {
+		var owner dto.People
+		if car.Owner != nil {
+			owner = *car.Owner
+		}
+		record = append(record, owner.Name, owner.Surname, owner.Patronymic)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+
+	header := csvColumns
+	if c.includeOwner {
+		header = append(append([]string{}, csvColumns...), csvOwnerColumns...)
+	}
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) Flush() error {
+	// An empty export still gets a header so spreadsheets know the columns.
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc          *json.Encoder
+	includeOwner bool
+}
+
+func (n *ndjsonWriter) Write(car dto.ExportCarDto) error {
+	if !n.includeOwner {
+		car.Owner = nil
+	}
+	return n.enc.Encode(car)
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,161 @@
+package export_test
+
+import (
+	"bytes"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/export"
+	"errors"
+	"testing"
+)
+
+var owned = dto.ExportCarDto{
+	CarId: 1, Mark: "Lada", Model: `Vesta "Cross", SW`, Year: 2020, RegNum: "A123BC77",
+	Owner: &dto.People{Name: "Иван", Surname: "Иванов\nмл."},
+}
+
+var unowned = dto.ExportCarDto{CarId: 2, Mark: "Kia", Model: "Rio", Year: 2019, RegNum: "B456CD77", Vin: "XTA21700000000001"}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    export.Format
+		wantErr bool
+	}{
+		{"", export.FormatCSV, false},
+		{"csv", export.FormatCSV, false},
+		{"ndjson", export.FormatNDJSON, false},
+		{"CSV", "", true},
+		{"xlsx", "", true},
+	}
+	for _, tt := range tests {
+		got, err := export.ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       export.Format
+		includeOwner bool
+		cars         []dto.ExportCarDto
+		want         string
+	}{
+		{
+			name:   "csv quotes commas, quotes and newlines",
+			format: export.FormatCSV,
+			cars:   []dto.ExportCarDto{owned, unowned},
+			want: "id,mark,model,year,reg_num,vin\n" +
+				"1,Lada,\"Vesta \"\"Cross\"\", SW\",2020,A123BC77,\n" +
+				"2,Kia,Rio,2019,B456CD77,XTA21700000000001\n",
+		},
+		{
+			name:         "csv owner columns",
+			format:       export.FormatCSV,
+			includeOwner: true,
+			cars:         []dto.ExportCarDto{owned, unowned},
+			want: "id,mark,model,year,reg_num,vin,owner_name,owner_surname,owner_patronymic\n" +
+				"1,Lada,\"Vesta \"\"Cross\"\", SW\",2020,A123BC77,,Иван,\"Иванов\nмл.\",\n" +
+				"2,Kia,Rio,2019,B456CD77,XTA21700000000001,,,\n",
+		},
+		{
+			name:   "empty csv keeps the header",
+			format: export.FormatCSV,
+			want:   "id,mark,model,year,reg_num,vin\n",
+		},
+		{
+			name:   "ndjson drops the owner",
+			format: export.FormatNDJSON,
+			cars:   []dto.ExportCarDto{owned, unowned},
+			want: `{"id":1,"mark":"Lada","model":"Vesta \"Cross\", SW","year":2020,"regNum":"A123BC77"}` + "\n" +
+				`{"id":2,"mark":"Kia","model":"Rio","year":2019,"regNum":"B456CD77","vin":"XTA21700000000001"}` + "\n",
+		},
+		{
+			name:         "ndjson owner",
+			format:       export.FormatNDJSON,
+			includeOwner: true,
+			cars:         []dto.ExportCarDto{owned},
+			want:         `{"id":1,"mark":"Lada","model":"Vesta \"Cross\", SW","year":2020,"regNum":"A123BC77","owner":{"name":"Иван","surname":"Иванов\nмл."}}` + "\n",
+		},
+		{
+			name:   "empty ndjson",
+			format: export.FormatNDJSON,
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := export.NewWriter(tt.format, &buf, tt.includeOwner)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, car := range tt.cars {
+			if err := w.Write(car); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWriterKeepsCallerCar(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := export.NewWriter(export.FormatNDJSON, &buf, false)
+	car := owned
+	if err := w.Write(car); err != nil {
+		t.Fatal(err)
+	}
+	if car.Owner == nil {
+		t.Fatal("Write cleared the owner of the caller's car")
+	}
+}
+
+func TestCSVWriterBuffersUntilFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := export.NewWriter(export.FormatCSV, &buf, false)
+	if err := w.Write(unowned); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("wrote %q before Flush", buf.String())
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("Flush wrote nothing")
+	}
+}
+
+type failingWriter struct{}
+
+var errDisk = errors.New("disk full")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errDisk
+}
+
+func TestWriterReportsWriteErrors(t *testing.T) {
+	csvWriter, _ := export.NewWriter(export.FormatCSV, failingWriter{}, false)
+	csvWriter.Write(unowned)
+	if err := csvWriter.Flush(); !errors.Is(err, errDisk) {
+		t.Fatalf("csv Flush error = %v, want %v", err, errDisk)
+	}
+
+	ndjsonWriter, _ := export.NewWriter(export.FormatNDJSON, failingWriter{}, false)
+	if err := ndjsonWriter.Write(unowned); !errors.Is(err, errDisk) {
+		t.Fatalf("ndjson Write error = %v, want %v", err, errDisk)
+	}
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := export.NewWriter("xlsx", &bytes.Buffer{}, false); err == nil {
+		t.Fatal("want an error for an unknown format")
+	}
+}
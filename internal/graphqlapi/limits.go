@@ -0,0 +1,171 @@
+package graphqlapi
+
+import (
+	"car_catalog/internal/dto"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// maxCost saturates complexity, so that nested page sizes cannot overflow.
+const maxCost = 1 << 31
+
+// singleUseMutations may appear once per operation: each is an import, and
+// aliases would otherwise multiply what one request may import.
+var singleUseMutations = map[string]bool{"createCars": true, "importCars": true}
+
+// checkLimits refuses the operation of req selected from doc when it nests
+// fields deeper than opts.MaxDepth, resolves more than opts.MaxComplexity
+// fields or repeats a single-use mutation. Requests that do not select
+// exactly one operation are left for the executor to report.
+func checkLimits(doc *ast.Document, req dto.GraphQLRequest, opts Options) error {
+	op, fragments := selectOperation(doc, req.OperationName)
+	if op == nil {
+		return nil
+	}
+	m := measurer{fragments: fragments, variables: req.Variables, visiting: make(map[string]bool)}
+
+	if op.Operation == ast.OperationTypeMutation {
+		seen := make(map[string]bool)
+		for _, name := range m.rootFields(op.SelectionSet) {
+			if singleUseMutations[name] && seen[name] {
+				return badInput(fmt.Sprintf("%s may appear once per operation", name))
+			}
+			seen[name] = true
+		}
+	}
+
+	depth, cost := m.measure(op.SelectionSet)
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return badInput(fmt.Sprintf("operation is %d fields deep, the limit is %d", depth, opts.MaxDepth))
+	}
+	if opts.MaxComplexity > 0 && cost > opts.MaxComplexity {
+		return badInput(fmt.Sprintf("operation may resolve %d fields, the limit is %d", cost, opts.MaxComplexity))
+	}
+	return nil
+}
+
+// selectOperation returns the operation named name, or the only one of doc
+// when name is empty, with the fragments of doc by name.
+func selectOperation(doc *ast.Document, name string) (*ast.OperationDefinition, map[string]*ast.FragmentDefinition) {
+	var (
+		selected  *ast.OperationDefinition
+		count     int
+		fragments = make(map[string]*ast.FragmentDefinition)
+	)
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			if name == "" || (def.Name != nil && def.Name.Value == name) {
+				selected = def
+				count++
+			}
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		}
+	}
+	if count != 1 {
+		return nil, fragments
+	}
+	return selected, fragments
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// visiting guards against fragment cycles, which validation reports.
+	visiting map[string]bool
+}
+
+// measure returns how deeply set nests fields and how many fields it may
+// resolve. Introspection fields are free.
+func (m *measurer) measure(set *ast.SelectionSet) (depth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			d, c = m.measure(sel.SelectionSet)
+			d++
+			c = min(1+m.pageSize(sel)*c, maxCost)
+		case *ast.InlineFragment:
+			d, c = m.measure(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			if fragment, ok := m.fragments[name]; ok && !m.visiting[name] {
+				m.visiting[name] = true
+				d, c = m.measure(fragment.SelectionSet)
+				delete(m.visiting, name)
+			}
+		}
+		depth = max(depth, d)
+		cost = min(cost+c, maxCost)
+	}
+	return depth, cost
+}
+
+// rootFields lists the names of the fields set selects, through fragments.
+func (m *measurer) rootFields(set *ast.SelectionSet) []string {
+	var names []string
+	for _, selection := range set.Selections {
+		switch sel := selection.(type) {
+		case *ast.Field:
+			names = append(names, sel.Name.Value)
+		case *ast.InlineFragment:
+			names = append(names, m.rootFields(sel.SelectionSet)...)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			if fragment, ok := m.fragments[name]; ok && !m.visiting[name] {
+				m.visiting[name] = true
+				names = append(names, m.rootFields(fragment.SelectionSet)...)
+				delete(m.visiting, name)
+			}
+		}
+	}
+	return names
+}
+
+// pageSize is how many times the selections of field resolve: first or last
+// of a page of cars, or once for any other field.
+func (m *measurer) pageSize(field *ast.Field) int {
+	size := 0
+	for _, arg := range field.Arguments {
+		if name := arg.Name.Value; name == "first" || name == "last" {
+			size = m.intValue(arg.Value)
+		}
+	}
+	switch {
+	case size > 0:
+		return min(size, maxCost)
+	case field.Name.Value == "cars":
+		return defaultPageSize
+	default:
+		return 1
+	}
+}
+
+// intValue resolves an Int literal or variable, or returns 0. Literals too
+// large for an int count as maxCost.
+func (m *measurer) intValue(value ast.Value) int {
+	switch v := value.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		if err != nil {
+			return maxCost
+		}
+		return n
+	case *ast.Variable:
+		// Variables come from JSON, so numbers are float64.
+		if n, ok := m.variables[v.Name.Value].(float64); ok {
+			return int(min(n, maxCost))
+		}
+	}
+	return 0
+}
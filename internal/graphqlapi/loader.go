@@ -0,0 +1,103 @@
+package graphqlapi
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/service"
+	"context"
+	"sync"
+)
+
+// batchLoader collects the keys asked for while one level of a query is
+// resolved and fetches them with a single call once the first value is
+// needed. The executor resolves every field of a level before it calls the
+// thunks of that level, so a page of cars costs one fetch per loader rather
+// than one per car. Values are cached for the rest of the request.
+type batchLoader[V any] struct {
+	fetch func(ctx context.Context, keys []int) (map[int]V, error)
+
+	mu      sync.Mutex
+	pending []int
+	queued  map[int]bool
+	values  map[int]V
+	errs    map[int]error
+}
+
+func newBatchLoader[V any](fetch func(ctx context.Context, keys []int) (map[int]V, error)) *batchLoader[V] {
+	return &batchLoader[V]{
+		fetch:  fetch,
+		queued: make(map[int]bool),
+		values: make(map[int]V),
+		errs:   make(map[int]error),
+	}
+}
+
+// load queues key and returns the thunk yielding its value; ok is false for
+// keys the fetch did not return.
+func (l *batchLoader[V]) load(ctx context.Context, key int) func() (V, bool, error) {
+	l.mu.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, bool, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			values, err := l.fetch(ctx, keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+				} else if v, ok := values[k]; ok {
+					l.values[k] = v
+				}
+			}
+		}
+		if err := l.errs[key]; err != nil {
+			var zero V
+			return zero, false, err
+		}
+		v, ok := l.values[key]
+		return v, ok, nil
+	}
+}
+
+// loaders are the batch loaders of one request.
+type loaders struct {
+	// showOwner tells whether the caller may see owner data.
+	showOwner bool
+	cars      *batchLoader[dto.ExportCarDto]
+	owners    *batchLoader[[]dto.OwnershipDto]
+}
+
+type loadersKey struct{}
+
+func newLoaders(cars service.CarService, events service.EventService, showOwner bool) *loaders {
+	return &loaders{
+		showOwner: showOwner,
+		cars: newBatchLoader(func(ctx context.Context, carIds []int) (map[int]dto.ExportCarDto, error) {
+			found, err := cars.GetCars(ctx, carIds, showOwner)
+			if err != nil {
+				return nil, err
+			}
+			byId := make(map[int]dto.ExportCarDto, len(found))
+			for _, car := range found {
+				byId[car.CarId] = car
+			}
+			return byId, nil
+		}),
+		owners: newBatchLoader(events.OwnerHistory),
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
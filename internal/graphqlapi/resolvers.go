@@ -0,0 +1,323 @@
+package graphqlapi
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"car_catalog/internal/vin"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// defaultPageSize applies when cars has neither first nor last, as the
+// default of the limit query parameter does over HTTP.
+const defaultPageSize = 10
+
+func (s *CarSchema) queryType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"cars": &graphql.Field{
+				Type:        graphql.NewNonNull(carConnectionType),
+				Description: "Cars matching the filters, by id or, with q, by relevance. Page forward with first and after or backward with last and before.",
+				Args: graphql.FieldConfigArgument{
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+					"last":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"before": &graphql.ArgumentConfig{Type: graphql.String},
+					"mark":   &graphql.ArgumentConfig{Type: graphql.String},
+					"model":  &graphql.ArgumentConfig{Type: graphql.String},
+					"year":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"vin":    &graphql.ArgumentConfig{Type: graphql.String, Description: "Exact VIN; cannot be combined with q."},
+					"q":      &graphql.ArgumentConfig{Type: graphql.String, Description: "Fuzzy search over mark, model, reg number and owner."},
+				},
+				Resolve: s.resolveCars,
+			},
+			"car": &graphql.Field{
+				Type: carType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					carId, err := strconv.Atoi(p.Args["id"].(string))
+					if err != nil {
+						return nil, apiError("car", err)
+					}
+					return loadCar(p.Context, carId), nil
+				},
+			},
+		},
+	})
+}
+
+func (s *CarSchema) mutationType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createCars": &graphql.Field{
+				Type:        graphql.NewNonNull(addCarsResultType),
+				Description: "Look the registration numbers up in the external registry and add the cars found, all or none. The numbers the registry does not know are skipped and returned in notFound.",
+				Args: graphql.FieldConfigArgument{
+					"regNums": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: s.resolveCreateCars,
+			},
+			"importCars": &graphql.Field{
+				Type:        graphql.NewNonNull(importReportType),
+				Description: "Add complete records without the registry. Invalid cars are reported and skipped; with dryRun nothing is written.",
+				Args: graphql.FieldConfigArgument{
+					"cars":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(carInputType)))},
+					"dryRun": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: s.resolveImportCars,
+			},
+			"updateCar": &graphql.Field{
+				Type: graphql.NewNonNull(carType),
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"changes": &graphql.ArgumentConfig{Type: graphql.NewNonNull(carChangesType)},
+				},
+				Resolve: s.resolveUpdateCar,
+			},
+			"deleteCar": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Returns the id of the deleted car.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					carId := p.Args["id"].(string)
+					log.Printf("[INFO] GraphQL - deleteCar - Received request for car ID: %s", carId)
+					if err := s.CarService.DeleteCar(p.Context, carId); err != nil {
+						return nil, apiError("deleteCar", err)
+					}
+					return carId, nil
+				},
+			},
+		},
+	})
+}
+
+func (s *CarSchema) resolveCars(p graphql.ResolveParams) (interface{}, error) {
+	log.Println("[INFO] GraphQL - cars - Received request")
+
+	first, hasFirst := p.Args["first"].(int)
+	last, hasLast := p.Args["last"].(int)
+	after, _ := p.Args["after"].(string)
+	before, _ := p.Args["before"].(string)
+	switch {
+	case hasFirst && hasLast:
+		return nil, badInput("first and last cannot be combined")
+	case after != "" && before != "":
+		return nil, badInput("after and before cannot be combined")
+	case hasFirst && before != "", hasLast && after != "":
+		return nil, badInput("page forward with first and after, backward with last and before")
+	case hasLast && before == "":
+		return nil, badInput("last needs a before cursor")
+	case (hasFirst && first <= 0) || (hasLast && last <= 0):
+		return nil, badInput("first and last must be positive")
+	}
+	pageSize := defaultPageSize
+	if hasFirst {
+		pageSize = first
+	} else if hasLast {
+		pageSize = last
+	}
+
+	filters := dto.Filters{
+		Limit: strconv.Itoa(pageSize),
+		Mark:  stringArg(p.Args, "mark"),
+		Model: stringArg(p.Args, "model"),
+		Vin:   stringArg(p.Args, "vin"),
+		Query: strings.TrimSpace(stringArg(p.Args, "q")),
+	}
+	if year, ok := p.Args["year"].(int); ok {
+		filters.Year = strconv.Itoa(year)
+	}
+	cursors := dto.Cursors{Next: after, Prev: before}
+	log.Printf("[DEBUG] GraphQL - cars - Filters: %+v, Cursors: %+v", filters, cursors)
+
+	cars, cursors, err := s.CarService.GetFilteredCars(p.Context, filters, cursors)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, apiError("cars", err)
+	}
+	if err != nil {
+		// As in the REST API, the service only fails on bad filters here.
+		log.Printf("[ERROR] GraphQL - cars - Unable to get filtered cars: %v", err)
+		return nil, badInput(err.Error())
+	}
+
+	conn := connection{
+		Edges: make([]edge, len(cars)),
+		PageInfo: pageInfo{
+			HasNextPage:     cursors.Next != "",
+			HasPreviousPage: cursors.Prev != "",
+		},
+	}
+	for i, car := range cars {
+		conn.Edges[i] = edge{car: car}
+	}
+	if len(cars) > 0 {
+		conn.PageInfo.StartCursor = &cars[0].Cursor
+		conn.PageInfo.EndCursor = &cars[len(cars)-1].Cursor
+	}
+	return conn, nil
+}
+
+func (s *CarSchema) resolveCreateCars(p graphql.ResolveParams) (interface{}, error) {
+	log.Println("[INFO] GraphQL - createCars - Received request")
+
+	regNums := p.Args["regNums"].([]interface{})
+	if s.Opts.MaxRegNums > 0 && len(regNums) > s.Opts.MaxRegNums {
+		log.Printf("[INFO] GraphQL - createCars - Rejected %d registration numbers, limit is %d", len(regNums), s.Opts.MaxRegNums)
+		return nil, badInput(fmt.Sprintf("at most %d registration numbers per request", s.Opts.MaxRegNums))
+	}
+
+	plates := make([]string, len(regNums))
+	for i, regNum := range regNums {
+		plates[i] = regNum.(string)
+	}
+	result, err := s.CarService.AddCarsByRegNum(p.Context, plates)
+	if err != nil {
+		return nil, apiError("createCars", err)
+	}
+	return result, nil
+}
+
+func (s *CarSchema) resolveImportCars(p graphql.ResolveParams) (interface{}, error) {
+	cars := p.Args["cars"].([]interface{})
+	dryRun := p.Args["dryRun"].(bool)
+	log.Printf("[INFO] GraphQL - importCars - Received %d cars, DryRun: %t", len(cars), dryRun)
+
+	rows := make([]dto.ImportRow, len(cars))
+	for i, arg := range cars {
+		car := arg.(map[string]interface{})
+		owner := car["owner"].(map[string]interface{})
+		rows[i] = dto.ImportRow{
+			Line: i + 1,
+			Car: dto.ImportCarDto{
+				Mark:   stringArg(car, "mark"),
+				Model:  stringArg(car, "model"),
+				Year:   car["year"].(int),
+				RegNum: stringArg(car, "regNum"),
+				Vin:    stringArg(car, "vin"),
+				Owner: dto.People{
+					Name:       stringArg(owner, "name"),
+					Surname:    stringArg(owner, "surname"),
+					Patronymic: stringArg(owner, "patronymic"),
+				},
+			},
+		}
+	}
+
+	report, err := s.CarService.ImportCars(p.Context, rows, dryRun)
+	if err != nil {
+		return nil, apiError("importCars", err)
+	}
+	return report, nil
+}
+
+func (s *CarSchema) resolveUpdateCar(p graphql.ResolveParams) (interface{}, error) {
+	carId := p.Args["id"].(string)
+	log.Printf("[INFO] GraphQL - updateCar - Received request for car ID: %s", carId)
+
+	args := p.Args["changes"].(map[string]interface{})
+	changes := dto.UpdateCarDto{
+		Mark:   stringArg(args, "mark"),
+		Model:  stringArg(args, "model"),
+		RegNum: stringArg(args, "regNum"),
+		Vin:    stringArg(args, "vin"),
+	}
+	if year, ok := args["year"].(int); ok {
+		changes.Year = strconv.Itoa(year)
+	}
+	if owner, ok := args["owner"].(map[string]interface{}); ok {
+		changes.Owner = &dto.People{
+			Name:       stringArg(owner, "name"),
+			Surname:    stringArg(owner, "surname"),
+			Patronymic: stringArg(owner, "patronymic"),
+		}
+	}
+
+	if err := s.CarService.UpdateCar(p.Context, carId, changes); err != nil {
+		return nil, apiError("updateCar", err)
+	}
+	car, err := s.CarService.GetCar(p.Context, carId, loadersFrom(p.Context).showOwner)
+	if err != nil {
+		return nil, apiError("updateCar", err)
+	}
+	return car, nil
+}
+
+// loadCar returns the thunk of a car loaded in a batch with the other cars
+// of the query level; a missing car resolves to null.
+func loadCar(ctx context.Context, carId int) func() (interface{}, error) {
+	car := loadersFrom(ctx).cars.load(ctx, carId)
+	return func() (interface{}, error) {
+		found, ok, err := car()
+		if err != nil {
+			return nil, apiError("loadCar", err)
+		}
+		if !ok {
+			return nil, nil
+		}
+		return found, nil
+	}
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// Error is a resolver error with a machine-readable code in the
+// "extensions" of the response.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+func badInput(message string) error {
+	return &Error{Code: "BAD_USER_INPUT", Message: message}
+}
+
+// apiError maps a service or registry error to the code the REST API's
+// status would stand for.
+func apiError(funcName string, err error) error {
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &numErr):
+		return badInput("invalid id")
+	case errors.Is(err, repository.ErrCarNotFound):
+		return &Error{Code: "NOT_FOUND", Message: err.Error()}
+	case errors.Is(err, carinfo.ErrNotFound):
+		return &Error{Code: "NOT_FOUND", Message: "car not found in external API"}
+	case errors.Is(err, vin.ErrInvalid), errors.Is(err, service.ErrVinMismatch):
+		return badInput(err.Error())
+	case errors.Is(err, repository.ErrDuplicateVin), errors.Is(err, repository.ErrDuplicateRegNum):
+		return &Error{Code: "CONFLICT", Message: err.Error()}
+	case errors.Is(err, carinfo.ErrUnavailable), errors.Is(err, carinfo.ErrRejected),
+		errors.Is(err, carinfo.ErrInvalidResponse):
+		return &Error{Code: "UNAVAILABLE", Message: "failed to get car information from external API"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: "TIMEOUT", Message: err.Error()}
+	default:
+		log.Printf("[ERROR] GraphQL - %s - %v", funcName, err)
+		return &Error{Code: "INTERNAL", Message: "internal error"}
+	}
+}
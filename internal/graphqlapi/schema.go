@@ -0,0 +1,118 @@
+// Package graphqlapi serves the car catalog as a GraphQL schema: cars with
+// their owners and ownership history, paged as Relay connections over the
+// cursors of the listing, and mutations mapped onto service.CarService.
+package graphqlapi
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/service"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// ErrMutationNotAllowed is returned by Execute for mutations sent where only
+// queries are allowed, like GET requests.
+var ErrMutationNotAllowed = errors.New("mutations must be sent with POST")
+
+// ownerViewerRoles may see owner data, as in the REST API.
+var ownerViewerRoles = map[string]bool{"admin": true, "finance": true}
+
+// Schema executes GraphQL requests against the catalog.
+type Schema interface {
+	// Execute runs req for a caller with the given role. Owner data is
+	// resolved to null for roles other than admin and finance. Unless
+	// allowMutations is set, mutations fail with ErrMutationNotAllowed.
+	Execute(ctx context.Context, req dto.GraphQLRequest, role string, allowMutations bool) (*graphql.Result, error)
+}
+
+// Options bound the operations a schema runs; zero disables a limit.
+type Options struct {
+	// MaxRegNums caps the regNums of createCars.
+	MaxRegNums int
+	// MaxDepth caps how deeply an operation nests fields.
+	MaxDepth int
+	// MaxComplexity caps how many fields an operation may resolve, counting
+	// the fields under a page of cars once per car.
+	MaxComplexity int
+}
+
+type CarSchema struct {
+	CarService service.CarService
+	Events     service.EventService
+	Opts       Options
+
+	schema graphql.Schema
+}
+
+func NewSchema(carService service.CarService, events service.EventService, opts Options) (Schema, error) {
+	s := &CarSchema{
+		CarService: carService,
+		Events:     events,
+		Opts:       opts,
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    s.queryType(),
+		Mutation: s.mutationType(),
+	})
+	if err != nil {
+		log.Printf("[ERROR] GraphQL - Unable to build the schema: %v", err)
+		return nil, err
+	}
+	s.schema = schema
+	return s, nil
+}
+
+func (s *CarSchema) Execute(ctx context.Context, req dto.GraphQLRequest, role string, allowMutations bool) (*graphql.Result, error) {
+	// Unparsable requests are left for the executor to report.
+	if doc, err := parser.Parse(parser.ParseParams{Source: req.Query}); err == nil {
+		if !allowMutations && isMutation(doc, req.OperationName) {
+			log.Printf("[INFO] GraphQL - Execute - Rejected mutation %q", req.OperationName)
+			return nil, ErrMutationNotAllowed
+		}
+		if err := checkLimits(doc, req, s.Opts); err != nil {
+			log.Printf("[INFO] GraphQL - Execute - Rejected operation %q: %v", req.OperationName, err)
+			return &graphql.Result{Errors: gqlerrors.FormatErrors(&gqlerrors.Error{Message: err.Error(), OriginalError: err})}, nil
+		}
+	}
+	log.Printf("[DEBUG] GraphQL - Execute - Operation: %q, Role: %q", req.OperationName, role)
+
+	ctx = withLoaders(ctx, newLoaders(s.CarService, s.Events, ownerViewerRoles[role]))
+	result := graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	if result.HasErrors() {
+		log.Printf("[INFO] GraphQL - Execute - Finished with %d errors", len(result.Errors))
+	}
+	return result, nil
+}
+
+// IsMutation reports whether req selects a mutation, so that callers can
+// budget mutations apart from queries. Unparsable requests are not.
+func IsMutation(req dto.GraphQLRequest) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	return err == nil && isMutation(doc, req.OperationName)
+}
+
+func isMutation(doc *ast.Document, operationName string) bool {
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok || (operationName != "" && (op.Name == nil || op.Name.Value != operationName)) {
+			continue
+		}
+		if op.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
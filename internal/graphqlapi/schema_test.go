@@ -0,0 +1,356 @@
+package graphqlapi_test
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/graphqlapi"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// countingRepo counts the batched lookups behind the Car fields.
+type countingRepo struct {
+	repository.CarRepository
+	getCarsByIds atomic.Int32
+}
+
+func (r *countingRepo) GetCarsByIds(ctx context.Context, carIds []int) ([]model.Car, error) {
+	r.getCarsByIds.Add(1)
+	return r.CarRepository.GetCarsByIds(ctx, carIds)
+}
+
+type countingLog struct {
+	repository.CarEventLog
+	forCars atomic.Int32
+}
+
+func (l *countingLog) ForCars(ctx context.Context, carIds []int) ([]model.CarEvent, error) {
+	l.forCars.Add(1)
+	return l.CarEventLog.ForCars(ctx, carIds)
+}
+
+type fixture struct {
+	schema graphqlapi.Schema
+	repo   *countingRepo
+	log    *countingLog
+}
+
+// newFixture builds the schema with the limits of the default config.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	return newLimitedFixture(t, graphqlapi.Options{MaxDepth: 10, MaxComplexity: 2000})
+}
+
+func newLimitedFixture(t *testing.T, opts graphqlapi.Options) *fixture {
+	t.Helper()
+	memory := repository.NewMemoryCarRepository()
+	f := &fixture{
+		repo: &countingRepo{CarRepository: memory},
+		log:  &countingLog{CarEventLog: repository.NewMemoryCarEventLog(memory)},
+	}
+	schema, err := graphqlapi.NewSchema(service.NewCarService(f.repo, nil, carinfo.NewChain()), service.NewEventService(f.log), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.schema = schema
+	return f
+}
+
+// response is the JSON form of a result, decoded into out.
+type response struct {
+	Data   json.RawMessage
+	Errors []struct {
+		Message    string
+		Extensions struct{ Code string }
+	}
+}
+
+func (f *fixture) do(t *testing.T, role, query string, variables map[string]interface{}, out interface{}) response {
+	t.Helper()
+	result, err := f.schema.Execute(context.Background(), dto.GraphQLRequest{Query: query, Variables: variables}, role, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if out != nil {
+		if len(resp.Errors) > 0 {
+			t.Fatalf("%s: %s", query, body)
+		}
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp
+}
+
+type owner struct {
+	Name, Surname string
+	Patronymic    *string
+}
+
+type car struct {
+	Id               string
+	RegNum           string
+	Year             int
+	Vin              *string
+	Owner            *owner
+	OwnershipHistory []struct {
+		Owner owner
+		Since string
+		Until *string
+	}
+}
+
+type carsPage struct {
+	Cars struct {
+		Edges []struct {
+			Cursor string
+			Node   car
+		}
+		PageInfo struct {
+			HasNextPage, HasPreviousPage bool
+			StartCursor, EndCursor       *string
+		}
+	}
+}
+
+const importCars = `mutation($cars: [CarInput!]!) {
+	importCars(cars: $cars) { imported rejected errors { line error } }
+}`
+
+func seed(t *testing.T, f *fixture, regNums ...string) {
+	t.Helper()
+	cars := make([]interface{}, len(regNums))
+	for i, regNum := range regNums {
+		cars[i] = map[string]interface{}{
+			"mark": "Lada", "model": "Vesta", "year": 2018, "regNum": regNum,
+			"owner": map[string]interface{}{"name": "Иван", "surname": "Иванов"},
+		}
+	}
+	var out struct {
+		ImportCars struct{ Imported, Rejected int }
+	}
+	f.do(t, "", importCars, map[string]interface{}{"cars": cars}, &out)
+	if out.ImportCars.Imported != len(regNums) {
+		t.Fatalf("importCars = %+v", out.ImportCars)
+	}
+}
+
+func TestCarsConnection(t *testing.T) {
+	f := newFixture(t)
+	seed(t, f, "A001AA77", "B002BB77", "C003CC77", "E004EE77", "K005KK77")
+
+	const query = `query($first: Int, $after: String, $last: Int, $before: String) {
+		cars(first: $first, after: $after, last: $last, before: $before) {
+			edges { cursor node { id regNum year vin owner { name surname patronymic } } }
+			pageInfo { hasNextPage hasPreviousPage startCursor endCursor }
+		}
+	}`
+
+	var first carsPage
+	f.do(t, "", query, map[string]interface{}{"first": 2}, &first)
+	edges := first.Cars.Edges
+	if len(edges) != 2 || edges[0].Node.RegNum != "A001AA77" || edges[1].Node.RegNum != "B002BB77" ||
+		!first.Cars.PageInfo.HasNextPage || *first.Cars.PageInfo.EndCursor != edges[1].Cursor {
+		t.Fatalf("first page: %+v", first.Cars)
+	}
+	// Owners are hidden from roles other than admin and finance.
+	if node := edges[0].Node; node.Owner != nil || node.Vin != nil || node.Year != 2018 {
+		t.Fatalf("first car: %+v", node)
+	}
+	if n := f.repo.getCarsByIds.Load(); n != 1 {
+		t.Fatalf("a page of 2 cars took %d lookups, want 1", n)
+	}
+
+	var second carsPage
+	f.do(t, "", query, map[string]interface{}{"first": 2, "after": edges[1].Cursor}, &second)
+	if len(second.Cars.Edges) != 2 || second.Cars.Edges[0].Node.RegNum != "C003CC77" || !second.Cars.PageInfo.HasPreviousPage {
+		t.Fatalf("second page: %+v", second.Cars)
+	}
+
+	var back carsPage
+	f.do(t, "", query, map[string]interface{}{"last": 2, "before": second.Cars.Edges[0].Cursor}, &back)
+	if len(back.Cars.Edges) != 2 || back.Cars.Edges[0].Node.RegNum != "A001AA77" || back.Cars.PageInfo.HasPreviousPage {
+		t.Fatalf("backward page: %+v", back.Cars)
+	}
+
+	for _, variables := range []map[string]interface{}{
+		{"first": 2, "last": 2},
+		{"last": 2},
+		{"first": 0},
+		{"first": 2, "before": edges[1].Cursor},
+	} {
+		resp := f.do(t, "", query, variables, nil)
+		if len(resp.Errors) != 1 || resp.Errors[0].Extensions.Code != "BAD_USER_INPUT" {
+			t.Fatalf("cars%v = %+v, want BAD_USER_INPUT", variables, resp.Errors)
+		}
+	}
+}
+
+func TestOwnershipHistory(t *testing.T) {
+	f := newFixture(t)
+	seed(t, f, "A001AA77", "B002BB77")
+
+	var updated struct{ UpdateCar car }
+	f.do(t, "finance", `mutation {
+		updateCar(id: "1", changes: {year: 2019, owner: {surname: "Петров", patronymic: "Ильич"}}) {
+			year owner { surname patronymic }
+		}
+	}`, nil, &updated)
+	if updated.UpdateCar.Year != 2019 || updated.UpdateCar.Owner.Surname != "Петров" || *updated.UpdateCar.Owner.Patronymic != "Ильич" {
+		t.Fatalf("updateCar = %+v", updated.UpdateCar)
+	}
+	// A change that keeps the owner does not start a new entry.
+	f.do(t, "", `mutation { updateCar(id: "1", changes: {model: "Granta"}) { id } }`, nil, &struct{}{})
+
+	const query = `{ cars { edges { node { regNum ownershipHistory { owner { surname } since until } } } } }`
+	var page carsPage
+	f.do(t, "admin", query, nil, &page)
+	history := page.Cars.Edges[0].Node.OwnershipHistory
+	if len(history) != 2 || history[0].Owner.Surname != "Иванов" || history[0].Until == nil ||
+		*history[0].Until != history[1].Since || history[1].Owner.Surname != "Петров" || history[1].Until != nil {
+		t.Fatalf("history of the sold car: %+v", history)
+	}
+	if history := page.Cars.Edges[1].Node.OwnershipHistory; len(history) != 1 || history[0].Until != nil {
+		t.Fatalf("history of the other car: %+v", history)
+	}
+	if n := f.log.forCars.Load(); n != 1 {
+		t.Fatalf("histories of a page took %d reads, want 1", n)
+	}
+
+	f.do(t, "viewer", query, nil, &page)
+	if page.Cars.Edges[0].Node.OwnershipHistory != nil {
+		t.Fatalf("history shown to a viewer: %+v", page.Cars.Edges[0].Node)
+	}
+}
+
+func TestCarAndMutations(t *testing.T) {
+	f := newFixture(t)
+	seed(t, f, "A001AA77")
+
+	// Aliased lookups of one level are batched too.
+	var cars struct{ A, B, Missing *car }
+	f.do(t, "admin", `{
+		a: car(id: "1") { regNum owner { name } }
+		b: car(id: "1") { id }
+		missing: car(id: "42") { id }
+	}`, nil, &cars)
+	if cars.A == nil || cars.A.RegNum != "A001AA77" || cars.A.Owner.Name != "Иван" || cars.B.Id != "1" || cars.Missing != nil {
+		t.Fatalf("car = %+v", cars)
+	}
+	if n := f.repo.getCarsByIds.Load(); n != 1 {
+		t.Fatalf("three car fields took %d lookups, want 1", n)
+	}
+
+	var report struct {
+		ImportCars struct {
+			Imported, Rejected int
+			Errors             []struct {
+				Line  int
+				Error string
+			}
+		}
+	}
+	f.do(t, "", importCars, map[string]interface{}{"cars": []interface{}{
+		map[string]interface{}{"mark": "Kia", "model": "Rio", "year": 2020, "regNum": "A001AA77",
+			"owner": map[string]interface{}{"name": "Пётр", "surname": "Петров"}},
+	}}, &report)
+	if report.ImportCars.Rejected != 1 || len(report.ImportCars.Errors) != 1 || report.ImportCars.Errors[0].Line != 1 {
+		t.Fatalf("import of a duplicate = %+v", report.ImportCars)
+	}
+
+	for _, tt := range []struct {
+		query string
+		code  string
+	}{
+		{`{ car(id: "x") { id } }`, "BAD_USER_INPUT"},
+		{`mutation { updateCar(id: "42", changes: {model: "Rio"}) { id } }`, "NOT_FOUND"},
+		{`mutation { updateCar(id: "1", changes: {vin: "bad"}) { id } }`, "BAD_USER_INPUT"},
+	} {
+		resp := f.do(t, "", tt.query, nil, nil)
+		if len(resp.Errors) != 1 || resp.Errors[0].Extensions.Code != tt.code {
+			t.Fatalf("%s = %+v, want %s", tt.query, resp.Errors, tt.code)
+		}
+	}
+
+	// The registry of the fixture knows no plates, so they are all skipped.
+	var created struct {
+		CreateCars struct {
+			Added    int
+			NotFound []string
+		}
+	}
+	f.do(t, "", `mutation { createCars(regNums: ["A001AA77"]) { added notFound } }`, nil, &created)
+	if created.CreateCars.Added != 0 || len(created.CreateCars.NotFound) != 1 || created.CreateCars.NotFound[0] != "A001AA77" {
+		t.Fatalf("createCars of an unknown plate = %+v", created.CreateCars)
+	}
+
+	var deleted struct{ DeleteCar string }
+	f.do(t, "", `mutation { deleteCar(id: "1") }`, nil, &deleted)
+	if deleted.DeleteCar != "1" {
+		t.Fatalf("deleteCar = %+v", deleted)
+	}
+	if resp := f.do(t, "", `mutation { deleteCar(id: "1") }`, nil, nil); len(resp.Errors) != 1 || resp.Errors[0].Extensions.Code != "NOT_FOUND" {
+		t.Fatalf("second deleteCar = %+v", resp.Errors)
+	}
+}
+
+func TestMutationsOnlyWhenAllowed(t *testing.T) {
+	f := newFixture(t)
+	for _, tt := range []struct {
+		req      dto.GraphQLRequest
+		rejected bool
+	}{
+		{dto.GraphQLRequest{Query: `mutation { deleteCar(id: "1") }`}, true},
+		{dto.GraphQLRequest{Query: `query Q { car(id: "1") { id } } mutation M { deleteCar(id: "1") }`, OperationName: "M"}, true},
+		{dto.GraphQLRequest{Query: `query Q { car(id: "1") { id } } mutation M { deleteCar(id: "1") }`, OperationName: "Q"}, false},
+		{dto.GraphQLRequest{Query: `{ car(id: "1") { id } }`}, false},
+	} {
+		_, err := f.schema.Execute(context.Background(), tt.req, "", false)
+		if errors.Is(err, graphqlapi.ErrMutationNotAllowed) != tt.rejected {
+			t.Fatalf("Execute(%+v) = %v, want rejected %t", tt.req, err, tt.rejected)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	f := newLimitedFixture(t, graphqlapi.Options{MaxDepth: 4, MaxComplexity: 50})
+	for _, tt := range []struct {
+		query     string
+		variables map[string]interface{}
+		rejected  bool
+	}{
+		{`{ cars(first: 5) { edges { node { id regNum } } } }`, nil, false},
+		// Each field under the page counts once per car: 1 + 20*(1+1+2).
+		{`{ cars(first: 20) { edges { node { id regNum } } } }`, nil, true},
+		{`query($n: Int) { cars(first: $n) { edges { node { id regNum } } } }`, map[string]interface{}{"n": float64(20)}, true},
+		// Ten cars when the page size is not given.
+		{`{ cars { edges { node { id regNum } } } }`, nil, false},
+		{`{ cars(first: 1) { edges { node { ownershipHistory { owner { name } } } } } }`, nil, true},
+		{`{ cars(first: 1) { edges { ...Node } } } fragment Node on CarEdge { node { ... on Car { ownershipHistory { since } } } }`, nil, true},
+		// Introspection is not counted.
+		{`{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`, nil, false},
+		{`mutation { a: createCars(regNums: ["A001AA77"]) { added } b: createCars(regNums: ["B002BB77"]) { added } }`, nil, true},
+		{`mutation { ...M ...M } fragment M on Mutation { importCars(cars: []) { imported } }`, nil, true},
+		{`mutation { createCars(regNums: ["A001AA77"]) { added } importCars(cars: []) { imported } }`, nil, false},
+	} {
+		resp := f.do(t, "", tt.query, tt.variables, nil)
+		rejected := len(resp.Errors) == 1 && resp.Errors[0].Extensions.Code == "BAD_USER_INPUT" && string(resp.Data) == "null"
+		if rejected != tt.rejected || (!tt.rejected && len(resp.Errors) > 0) {
+			t.Fatalf("%s = %+v, want rejected %t", tt.query, resp.Errors, tt.rejected)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package graphqlapi
+
+import (
+	"car_catalog/internal/dto"
+
+	"github.com/graphql-go/graphql"
+)
+
+var ownerType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Owner",
+	Fields: graphql.Fields{
+		"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"surname":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"patronymic": &graphql.Field{Type: graphql.String, Resolve: emptyAsNull(func(o dto.People) string { return o.Patronymic })},
+	},
+})
+
+var ownershipType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Ownership",
+	Description: "One owner in the history of a car, from the first change the catalog recorded with them until the next owner took over.",
+	Fields: graphql.Fields{
+		"owner": &graphql.Field{Type: graphql.NewNonNull(ownerType)},
+		"since": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"until": &graphql.Field{Type: graphql.DateTime, Description: "Null for the current owner."},
+	},
+})
+
+var carType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Car",
+	Fields: graphql.Fields{
+		"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"mark":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"model":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"year":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"regNum": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"vin":    &graphql.Field{Type: graphql.String, Resolve: emptyAsNull(func(c dto.ExportCarDto) string { return c.Vin })},
+		"owner": &graphql.Field{
+			Type:        ownerType,
+			Description: "Null unless the caller has the admin or finance role.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if owner := p.Source.(dto.ExportCarDto).Owner; owner != nil {
+					return *owner, nil
+				}
+				return nil, nil
+			},
+		},
+		"ownershipHistory": &graphql.Field{
+			Type:        graphql.NewList(graphql.NewNonNull(ownershipType)),
+			Description: "Owners of the car, oldest first, as far back as the change feed is retained (events.retention). Null unless the caller has the admin or finance role.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				l := loadersFrom(p.Context)
+				if !l.showOwner {
+					return nil, nil
+				}
+				history := l.owners.load(p.Context, p.Source.(dto.ExportCarDto).CarId)
+				return func() (interface{}, error) {
+					owners, _, err := history()
+					if err != nil {
+						return nil, apiError("ownershipHistory", err)
+					}
+					if owners == nil {
+						owners = []dto.OwnershipDto{}
+					}
+					return owners, nil
+				}, nil
+			},
+		},
+	},
+})
+
+// edge is a car of a listing page; the car itself is loaded in a batch
+// with the rest of the page.
+type edge struct {
+	car dto.GetFilteredCarsDto
+}
+
+var carEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CarEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(edge).car.Cursor, nil
+			},
+		},
+		"score": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Relevance of the car to q, null without q.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if score := p.Source.(edge).car.Score; score != 0 {
+					return score, nil
+				}
+				return nil, nil
+			},
+		},
+		"node": &graphql.Field{
+			Type:        carType,
+			Description: "Null if the car was deleted after the page was read.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return loadCar(p.Context, p.Source.(edge).car.CarId), nil
+			},
+		},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	},
+})
+
+// pageInfo is the source of PageInfo; nil cursors resolve to null.
+type pageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// connection is the source of CarConnection.
+type connection struct {
+	Edges    []edge   `json:"edges"`
+	PageInfo pageInfo `json:"pageInfo"`
+}
+
+var carConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CarConnection",
+	Fields: graphql.Fields{
+		"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(carEdgeType)))},
+		"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+	},
+})
+
+var importRowErrorType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ImportRowError",
+	Fields: graphql.Fields{
+		"line":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Position of the car in the cars argument, from 1."},
+		"regNum": &graphql.Field{Type: graphql.String, Resolve: emptyAsNull(func(e dto.ImportRowError) string { return e.RegNum })},
+		"error":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var importReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ImportReport",
+	Fields: graphql.Fields{
+		"dryRun":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"total":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"valid":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"imported": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"rejected": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"errors":   &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(importRowErrorType)))},
+	},
+})
+
+var addCarsResultType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AddCarsResult",
+	Fields: graphql.Fields{
+		"added":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"notFound": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+	},
+})
+
+var ownerInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "OwnerInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"surname":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"patronymic": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var carInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CarInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"mark":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"model":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"year":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"regNum": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"vin":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"owner":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(ownerInputType)},
+	},
+})
+
+var ownerChangesType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "OwnerChanges",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"surname":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"patronymic": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var carChangesType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "CarChanges",
+	Description: "Fields left out or empty are not changed.",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"mark":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"model":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"year":   &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"regNum": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"vin":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"owner":  &graphql.InputObjectFieldConfig{Type: ownerChangesType},
+	},
+})
+
+// emptyAsNull resolves an optional string field of a T source, null when
+// the value is empty.
+func emptyAsNull[T any](get func(T) string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if v := get(p.Source.(T)); v != "" {
+			return v, nil
+		}
+		return nil, nil
+	}
+}
@@ -0,0 +1,273 @@
+package grpcapi
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/grpcapi/carsv1"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"car_catalog/internal/vin"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultPageSize applies when ListCars has no page_size, as the default of
+// the limit query parameter does over HTTP.
+const defaultPageSize = 10
+
+// ownerViewerRoles may see owner data, as in the REST API.
+var ownerViewerRoles = map[string]bool{"admin": true, "finance": true}
+
+// CarServer implements cars.v1.CarService on top of service.CarService.
+type CarServer struct {
+	carsv1.UnimplementedCarServiceServer
+
+	CarService service.CarService
+	// MaxRegNums caps the reg_nums of CreateCars; zero means no cap.
+	MaxRegNums int
+}
+
+func NewCarServer(carService service.CarService, maxRegNums int) carsv1.CarServiceServer {
+	return &CarServer{
+		CarService: carService,
+		MaxRegNums: maxRegNums,
+	}
+}
+
+func (s *CarServer) ListCars(ctx context.Context, req *carsv1.ListCarsRequest) (*carsv1.ListCarsResponse, error) {
+	log.Println("[INFO] GRPC - ListCars - Received request")
+
+	pageSize := req.GetPageSize()
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	filters := dto.Filters{
+		Mark:  req.GetMark(),
+		Model: req.GetModel(),
+		Vin:   req.GetVin(),
+		Query: strings.TrimSpace(req.GetQuery()),
+		Limit: strconv.Itoa(int(pageSize)),
+	}
+	if req.GetYear() != 0 {
+		filters.Year = strconv.Itoa(int(req.GetYear()))
+	}
+	var cursors dto.Cursors
+	if req.GetBackward() {
+		cursors.Prev = req.GetPageToken()
+	} else {
+		cursors.Next = req.GetPageToken()
+	}
+	log.Printf("[DEBUG] GRPC - ListCars - Filters: %+v, Cursors: %+v", filters, cursors)
+
+	cars, cursors, err := s.CarService.GetFilteredCars(ctx, filters, cursors)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("[ERROR] GRPC - ListCars - Query deadline exceeded: %v", err)
+		return nil, status.Error(codes.DeadlineExceeded, "query deadline exceeded")
+	}
+	if err != nil {
+		// As in the REST API, the service only fails on bad filters here.
+		log.Printf("[ERROR] GRPC - ListCars - Unable to get filtered cars: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &carsv1.ListCarsResponse{
+		Cars:          make([]*carsv1.ListedCar, len(cars)),
+		NextPageToken: cursors.Next,
+		PrevPageToken: cursors.Prev,
+	}
+	for i, car := range cars {
+		year, _ := strconv.Atoi(car.Year)
+		resp.Cars[i] = &carsv1.ListedCar{
+			Id:    int64(car.CarId),
+			Mark:  car.Mark,
+			Model: car.Model,
+			Year:  int32(year),
+			Vin:   car.Vin,
+			Score: car.Score,
+		}
+	}
+	return resp, nil
+}
+
+func (s *CarServer) StreamCars(req *carsv1.StreamCarsRequest, stream carsv1.CarService_StreamCarsServer) error {
+	log.Println("[INFO] GRPC - StreamCars - Received request")
+
+	ctx := stream.Context()
+	filters := dto.Filters{Mark: req.GetMark(), Model: req.GetModel()}
+	if req.GetYear() != 0 {
+		filters.Year = strconv.Itoa(int(req.GetYear()))
+	}
+	includeOwner := req.GetIncludeOwner() && ownerViewerRoles[roleFromContext(ctx)]
+	log.Printf("[DEBUG] GRPC - StreamCars - Filters: %+v, IncludeOwner: %t", filters, includeOwner)
+
+	err := s.CarService.ExportCars(ctx, filters, includeOwner, func(car dto.ExportCarDto) error {
+		return stream.Send(toCar(car))
+	})
+	if err != nil {
+		return statusError("StreamCars", err)
+	}
+	return nil
+}
+
+func (s *CarServer) GetCar(ctx context.Context, req *carsv1.GetCarRequest) (*carsv1.Car, error) {
+	log.Printf("[INFO] GRPC - GetCar - Received request for car ID: %d", req.GetId())
+
+	car, err := s.CarService.GetCar(ctx, strconv.FormatInt(req.GetId(), 10), ownerViewerRoles[roleFromContext(ctx)])
+	if err != nil {
+		return nil, statusError("GetCar", err)
+	}
+	return toCar(car), nil
+}
+
+func (s *CarServer) CreateCars(ctx context.Context, req *carsv1.CreateCarsRequest) (*carsv1.CreateCarsResponse, error) {
+	log.Println("[INFO] GRPC - CreateCars - Received request")
+
+	regNums := req.GetRegNums()
+	if s.MaxRegNums > 0 && len(regNums) > s.MaxRegNums {
+		log.Printf("[INFO] GRPC - CreateCars - Rejected %d registration numbers, limit is %d", len(regNums), s.MaxRegNums)
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d registration numbers per request", s.MaxRegNums)
+	}
+
+	result, err := s.CarService.AddCarsByRegNum(ctx, regNums)
+	if err != nil {
+		return nil, statusError("CreateCars", err)
+	}
+	return &carsv1.CreateCarsResponse{Created: int32(result.Added), NotFound: result.NotFound}, nil
+}
+
+func (s *CarServer) ImportCars(ctx context.Context, req *carsv1.ImportCarsRequest) (*carsv1.ImportReport, error) {
+	log.Printf("[INFO] GRPC - ImportCars - Received %d cars, DryRun: %t", len(req.GetCars()), req.GetDryRun())
+
+	rows := make([]dto.ImportRow, len(req.GetCars()))
+	for i, car := range req.GetCars() {
+		rows[i] = dto.ImportRow{
+			Line: i + 1,
+			Car: dto.ImportCarDto{
+				Mark:   car.GetMark(),
+				Model:  car.GetModel(),
+				Year:   int(car.GetYear()),
+				RegNum: car.GetRegNum(),
+				Vin:    car.GetVin(),
+				Owner: dto.People{
+					Name:       car.GetOwner().GetName(),
+					Surname:    car.GetOwner().GetSurname(),
+					Patronymic: car.GetOwner().GetPatronymic(),
+				},
+			},
+		}
+	}
+
+	report, err := s.CarService.ImportCars(ctx, rows, req.GetDryRun())
+	if err != nil {
+		return nil, statusError("ImportCars", err)
+	}
+
+	resp := &carsv1.ImportReport{
+		DryRun:   report.DryRun,
+		Total:    int32(report.Total),
+		Valid:    int32(report.Valid),
+		Imported: int32(report.Imported),
+		Rejected: int32(report.Rejected),
+	}
+	for _, rowErr := range report.Errors {
+		resp.Errors = append(resp.Errors, &carsv1.ImportRowError{
+			Line:   int32(rowErr.Line),
+			RegNum: rowErr.RegNum,
+			Error:  rowErr.Error,
+		})
+	}
+	return resp, nil
+}
+
+func (s *CarServer) UpdateCar(ctx context.Context, req *carsv1.UpdateCarRequest) (*carsv1.Car, error) {
+	log.Printf("[INFO] GRPC - UpdateCar - Received request for car ID: %d", req.GetId())
+
+	carId := strconv.FormatInt(req.GetId(), 10)
+	changes := dto.UpdateCarDto{
+		Mark:   req.GetMark(),
+		Model:  req.GetModel(),
+		RegNum: req.GetRegNum(),
+		Vin:    req.GetVin(),
+	}
+	if req.GetYear() != 0 {
+		changes.Year = strconv.Itoa(int(req.GetYear()))
+	}
+	if owner := req.GetOwner(); owner != nil {
+		changes.Owner = &dto.People{
+			Name:       owner.GetName(),
+			Surname:    owner.GetSurname(),
+			Patronymic: owner.GetPatronymic(),
+		}
+	}
+
+	if err := s.CarService.UpdateCar(ctx, carId, changes); err != nil {
+		return nil, statusError("UpdateCar", err)
+	}
+	car, err := s.CarService.GetCar(ctx, carId, ownerViewerRoles[roleFromContext(ctx)])
+	if err != nil {
+		return nil, statusError("UpdateCar", err)
+	}
+	return toCar(car), nil
+}
+
+func (s *CarServer) DeleteCar(ctx context.Context, req *carsv1.DeleteCarRequest) (*carsv1.DeleteCarResponse, error) {
+	log.Printf("[INFO] GRPC - DeleteCar - Received request for car ID: %d", req.GetId())
+
+	if err := s.CarService.DeleteCar(ctx, strconv.FormatInt(req.GetId(), 10)); err != nil {
+		return nil, statusError("DeleteCar", err)
+	}
+	return &carsv1.DeleteCarResponse{}, nil
+}
+
+// statusError maps a service or registry error to the status the REST API
+// would answer with.
+func statusError(funcName string, err error) error {
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &numErr):
+		return status.Error(codes.InvalidArgument, "invalid id")
+	case errors.Is(err, repository.ErrCarNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, carinfo.ErrNotFound):
+		return status.Error(codes.NotFound, "car not found in external API")
+	case errors.Is(err, vin.ErrInvalid), errors.Is(err, service.ErrVinMismatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrDuplicateVin), errors.Is(err, repository.ErrDuplicateRegNum):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, carinfo.ErrUnavailable), errors.Is(err, carinfo.ErrRejected),
+		errors.Is(err, carinfo.ErrInvalidResponse):
+		return status.Error(codes.Unavailable, "failed to get car information from external API")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		log.Printf("[ERROR] GRPC - %s - %v", funcName, err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toCar(car dto.ExportCarDto) *carsv1.Car {
+	c := &carsv1.Car{
+		Id:     int64(car.CarId),
+		Mark:   car.Mark,
+		Model:  car.Model,
+		Year:   int32(car.Year),
+		RegNum: car.RegNum,
+		Vin:    car.Vin,
+	}
+	if car.Owner != nil {
+		c.Owner = &carsv1.Owner{
+			Name:       car.Owner.Name,
+			Surname:    car.Owner.Surname,
+			Patronymic: car.Owner.Patronymic,
+		}
+	}
+	return c
+}
@@ -0,0 +1,172 @@
+// Package grpcapi serves the car catalog over gRPC, next to the REST API.
+//
+// The stubs in carsv1 are generated from proto/cars/v1/cars.proto:
+//
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=car_catalog --go-grpc_out=../.. --go-grpc_opt=module=car_catalog cars/v1/cars.proto
+package grpcapi
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/config"
+	"car_catalog/internal/grpcapi/carsv1"
+	"car_catalog/internal/ratelimit"
+	"context"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// reflectionPrefix marks the methods of the reflection service, which are
+// public like /swagger/ is over HTTP.
+const reflectionPrefix = "/grpc.reflection."
+
+// importMethods draw from the import budget of the rate limiter, like the
+// REST requests matched by router.IsImport; every other call draws from the
+// read budget.
+var importMethods = map[string]bool{
+	carsv1.CarService_CreateCars_FullMethodName: true,
+	carsv1.CarService_ImportCars_FullMethodName: true,
+}
+
+// NewServer builds the gRPC server of cars with the API keys of auth, the
+// budgets of limiter (nil for none), the certificate of http.tls and, when
+// enabled, reflection.
+func NewServer(cfg *config.Config, cars carsv1.CarServiceServer, limiter *ratelimit.Limiter) (*grpc.Server, error) {
+	// Rate limits are per caller, so they run after auth.
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryAuth(cfg.Auth), unaryRateLimit(limiter)),
+		grpc.ChainStreamInterceptor(streamAuth(cfg.Auth), streamRateLimit(limiter)),
+	}
+	if cfg.Limits.MaxBodyBytes > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.Limits.MaxBodyBytes))
+	}
+	if tls := cfg.HTTP.TLS; tls.Enabled {
+		creds, err := credentials.NewServerTLSFromFile(tls.CertFile, tls.KeyFile)
+		if err != nil {
+			log.Printf("[ERROR] GRPC - Unable to load the TLS certificate: %v", err)
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	carsv1.RegisterCarServiceServer(server, cars)
+	if cfg.GRPC.Reflection {
+		reflection.Register(server)
+	}
+	return server, nil
+}
+
+// authenticate resolves the caller of method from the x-api-key or
+// "authorization: Bearer" metadata and stores the identity in the context.
+// With auth disabled every call passes through.
+func authenticate(ctx context.Context, cfg config.AuthConfig, method string) (context.Context, error) {
+	if !cfg.Enabled || strings.HasPrefix(method, reflectionPrefix) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := firstValue(md, "x-api-key")
+	if key == "" {
+		key = strings.TrimPrefix(firstValue(md, "authorization"), "Bearer ")
+	}
+	identity, ok := auth.Authenticate(cfg, key)
+	if !ok {
+		log.Printf("[INFO] Auth - Rejected call to %s: missing or unknown API key", method)
+		return nil, status.Error(codes.Unauthenticated, "missing or unknown API key")
+	}
+	log.Printf("[DEBUG] Auth - Authenticated %q with role %q", identity.Name, identity.Role)
+	return auth.WithIdentity(ctx, identity), nil
+}
+
+func unaryAuth(cfg config.AuthConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, cfg, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(cfg config.AuthConfig) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), cfg, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// rateLimit takes a token from the caller's budget for method, failing with
+// ResourceExhausted when it is spent. A nil limiter lets every call through.
+func rateLimit(ctx context.Context, limiter *ratelimit.Limiter, method string) error {
+	if limiter == nil || strings.HasPrefix(method, reflectionPrefix) {
+		return nil
+	}
+	budget := ratelimit.BudgetRead
+	if importMethods[method] {
+		budget = ratelimit.BudgetImport
+	}
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	client := ratelimit.ClientKey(ctx, remoteAddr)
+
+	if ok, retryAfter := limiter.Allow(client, budget); !ok {
+		log.Printf("[INFO] RateLimit - Client %q exceeded the %s budget on %s", client, budget, method)
+		return status.Errorf(codes.ResourceExhausted, "%s budget exceeded, retry in %ds", budget, ratelimit.RetryAfterSeconds(retryAfter))
+	}
+	return nil
+}
+
+func unaryRateLimit(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := rateLimit(ctx, limiter, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamRateLimit(limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := rateLimit(stream.Context(), limiter, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// identityStream hands the authenticated context to stream handlers.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// roleFromContext returns the role of the API key. Calls without one have no
+// role, like requests over HTTP.
+func roleFromContext(ctx context.Context) string {
+	identity, _ := auth.FromContext(ctx)
+	return identity.Role
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,256 @@
+package grpcapi_test
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/config"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/grpcapi"
+	"car_catalog/internal/grpcapi/carsv1"
+	"car_catalog/internal/ratelimit"
+	"car_catalog/internal/registrystub"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves cars over an in-memory listener and returns a client for it.
+func dial(t *testing.T, cfg *config.Config, cars carsv1.CarServiceServer) *grpc.ClientConn {
+	t.Helper()
+	server, err := grpcapi.NewServer(cfg, cars, ratelimit.NewLimiter(cfg.Limits.RateLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func code(err error) codes.Code {
+	return status.Code(err)
+}
+
+func TestCarService(t *testing.T) {
+	registry := httptest.NewServer(registrystub.New(registrystub.Options{Fixtures: map[string]registrystub.Entry{
+		"A123BC77": {AddCarsDto: dto.AddCarsDto{Mark: "Lada", Model: "Vesta", Year: 2020, RegNum: "A123BC77",
+			Owner: dto.People{Name: "Иван", Surname: "Иванов"}}},
+	}}))
+	defer registry.Close()
+
+	repo := repository.NewMemoryCarRepository()
+	carInfo := carinfo.NewHTTPProvider(registry.URL, &http.Client{Timeout: time.Second})
+	carService := service.NewCarService(repo, nil, carInfo)
+	cfg := &config.Config{
+		GRPC: config.GRPCConfig{Reflection: true},
+		Auth: config.AuthConfig{Enabled: true, Keys: []config.APIKey{
+			{Name: "crm", Key: "crm-key", Role: "viewer"},
+			{Name: "back-office", Key: "admin-key", Role: "admin"},
+		}},
+	}
+	client := carsv1.NewCarServiceClient(dial(t, cfg, grpcapi.NewCarServer(carService, 2)))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "crm-key")
+	admin := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "admin-key")
+
+	created, err := client.CreateCars(ctx, &carsv1.CreateCarsRequest{RegNums: []string{"A123BC77"}})
+	if err != nil || created.GetCreated() != 1 {
+		t.Fatalf("CreateCars = %v, %v", created, err)
+	}
+	if _, err := client.CreateCars(ctx, &carsv1.CreateCarsRequest{RegNums: []string{"A123BC77"}}); code(err) != codes.AlreadyExists {
+		t.Fatalf("CreateCars of an existing plate = %v, want AlreadyExists", err)
+	}
+	// Plates the registry does not know are skipped and reported.
+	skipped, err := client.CreateCars(ctx, &carsv1.CreateCarsRequest{RegNums: []string{"M404MM77"}})
+	if err != nil || skipped.GetCreated() != 0 || len(skipped.GetNotFound()) != 1 || skipped.GetNotFound()[0] != "M404MM77" {
+		t.Fatalf("CreateCars of an unknown plate = %v, %v", skipped, err)
+	}
+	if _, err := client.CreateCars(ctx, &carsv1.CreateCarsRequest{RegNums: []string{"A", "B", "C"}}); code(err) != codes.InvalidArgument {
+		t.Fatalf("CreateCars over the limit = %v, want InvalidArgument", err)
+	}
+
+	report, err := client.ImportCars(ctx, &carsv1.ImportCarsRequest{Cars: []*carsv1.ImportCar{
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "C003CC77", Owner: &carsv1.Owner{Name: "Пётр", Surname: "Петров"}},
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "not a plate", Owner: &carsv1.Owner{Name: "Пётр", Surname: "Петров"}},
+	}})
+	if err != nil || report.GetImported() != 1 || report.GetRejected() != 1 || len(report.GetErrors()) != 1 || report.GetErrors()[0].GetLine() != 2 {
+		t.Fatalf("ImportCars = %v, %v", report, err)
+	}
+
+	page, err := client.ListCars(ctx, &carsv1.ListCarsRequest{Mark: "Kia", PageSize: 10})
+	if err != nil || len(page.GetCars()) != 1 || page.GetCars()[0].GetModel() != "Rio" || page.GetCars()[0].GetYear() != 2020 {
+		t.Fatalf("ListCars = %v, %v", page, err)
+	}
+	if _, err := client.ListCars(ctx, &carsv1.ListCarsRequest{Vin: "XTA21099043567890", Query: "rio"}); code(err) != codes.InvalidArgument {
+		t.Fatalf("ListCars with vin and query = %v, want InvalidArgument", err)
+	}
+
+	// Owners are shown to the admin and finance roles only.
+	car, err := client.GetCar(ctx, &carsv1.GetCarRequest{Id: 1})
+	if err != nil || car.GetRegNum() != "A123BC77" || car.GetOwner() != nil {
+		t.Fatalf("GetCar = %v, %v", car, err)
+	}
+	if car, err := client.GetCar(admin, &carsv1.GetCarRequest{Id: 1}); err != nil || car.GetOwner().GetSurname() != "Иванов" {
+		t.Fatalf("GetCar as admin = %v, %v", car, err)
+	}
+	if _, err := client.GetCar(ctx, &carsv1.GetCarRequest{Id: 99}); code(err) != codes.NotFound {
+		t.Fatalf("GetCar of a missing id = %v, want NotFound", err)
+	}
+
+	for _, tt := range []struct {
+		ctx        context.Context
+		wantOwners bool
+	}{{ctx, false}, {admin, true}} {
+		stream, err := client.StreamCars(tt.ctx, &carsv1.StreamCarsRequest{IncludeOwner: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cars []*carsv1.Car
+		for {
+			car, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			cars = append(cars, car)
+		}
+		if len(cars) != 2 || (cars[0].GetOwner() != nil) != tt.wantOwners {
+			t.Fatalf("StreamCars = %v, want owners %t", cars, tt.wantOwners)
+		}
+	}
+
+	updated, err := client.UpdateCar(admin, &carsv1.UpdateCarRequest{Id: 2, Model: "Ceed", Owner: &carsv1.Owner{Patronymic: "Ильич"}})
+	if err != nil || updated.GetModel() != "Ceed" || updated.GetYear() != 2020 || updated.GetOwner().GetPatronymic() != "Ильич" {
+		t.Fatalf("UpdateCar = %v, %v", updated, err)
+	}
+	if _, err := client.UpdateCar(ctx, &carsv1.UpdateCarRequest{Id: 2, Vin: "bad"}); code(err) != codes.InvalidArgument {
+		t.Fatalf("UpdateCar with an invalid VIN = %v, want InvalidArgument", err)
+	}
+
+	if _, err := client.DeleteCar(ctx, &carsv1.DeleteCarRequest{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteCar(ctx, &carsv1.DeleteCarRequest{Id: 2}); code(err) != codes.NotFound {
+		t.Fatalf("second DeleteCar = %v, want NotFound", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	cfg := &config.Config{
+		Auth: config.AuthConfig{Enabled: true, Keys: []config.APIKey{
+			{Name: "crm", Key: "crm-key", Role: "admin"},
+			{Name: "bi", Key: "bi-key", Role: "viewer"},
+		}},
+		Limits: config.LimitsConfig{RateLimit: config.RateLimitConfig{
+			Enabled: true,
+			Read:    config.BucketConfig{PerMinute: 60, Burst: 2},
+			Import:  config.BucketConfig{PerMinute: 1, Burst: 1},
+		}},
+	}
+	client := carsv1.NewCarServiceClient(dial(t, cfg, grpcapi.NewCarServer(service.NewCarService(repo, nil, carinfo.NewChain()), 0)))
+	crm := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "crm-key")
+	bi := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "bi-key")
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListCars(crm, &carsv1.ListCarsRequest{}); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	if _, err := client.ListCars(crm, &carsv1.ListCarsRequest{}); code(err) != codes.ResourceExhausted {
+		t.Fatalf("read over the burst = %v, want ResourceExhausted", err)
+	}
+	stream, err := client.StreamCars(crm, &carsv1.StreamCarsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if code(err) != codes.ResourceExhausted {
+		t.Fatalf("StreamCars over the read budget = %v, want ResourceExhausted", err)
+	}
+
+	// Budgets are separate per class and per API key.
+	if _, err := client.ImportCars(crm, &carsv1.ImportCarsRequest{}); err != nil {
+		t.Fatalf("import after reads: %v", err)
+	}
+	if _, err := client.CreateCars(crm, &carsv1.CreateCarsRequest{}); code(err) != codes.ResourceExhausted {
+		t.Fatalf("CreateCars after an import = %v, want ResourceExhausted", err)
+	}
+	if _, err := client.ListCars(bi, &carsv1.ListCarsRequest{}); err != nil {
+		t.Fatalf("another key: %v", err)
+	}
+}
+
+func TestAuthAndReflection(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	cfg := &config.Config{
+		GRPC: config.GRPCConfig{Reflection: true},
+		Auth: config.AuthConfig{Enabled: true, Keys: []config.APIKey{{Name: "crm", Key: "secret-key", Role: "viewer"}}},
+	}
+	conn := dial(t, cfg, grpcapi.NewCarServer(service.NewCarService(repo, nil, carinfo.NewChain()), 0))
+	client := carsv1.NewCarServiceClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.ListCars(ctx, &carsv1.ListCarsRequest{}); code(err) != codes.Unauthenticated {
+		t.Fatalf("ListCars without a key = %v, want Unauthenticated", err)
+	}
+	stream, err := client.StreamCars(metadata.AppendToOutgoingContext(ctx, "x-api-key", "wrong"), &carsv1.StreamCarsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if code(err) != codes.Unauthenticated {
+		t.Fatalf("StreamCars with a wrong key = %v, want Unauthenticated", err)
+	}
+	keyed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret-key")
+	if _, err := client.ListCars(keyed, &carsv1.ListCarsRequest{}); err != nil {
+		t.Fatalf("ListCars with a key = %v", err)
+	}
+
+	// Reflection needs no key.
+	info, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := info.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := info.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	found := false
+	for _, name := range services {
+		found = found || name == "cars.v1.CarService"
+	}
+	if !found {
+		t.Fatalf("reflection lists %v, want cars.v1.CarService", services)
+	}
+}
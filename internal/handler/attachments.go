@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// attachmentFormField is the multipart field uploads are read from.
+const attachmentFormField = "file"
+
+// attachmentError maps an attachment service error to a response.
+func attachmentError(w http.ResponseWriter, funcName string, err error) {
+	var (
+		numErr   *strconv.NumError
+		tooLarge *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &numErr):
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrCarNotFound), errors.Is(err, repository.ErrAttachmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &tooLarge), errors.Is(err, service.ErrAttachmentTooLarge):
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrUnsupportedAttachment):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, service.ErrInvalidAttachment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[ERROR] Handler - %s - Query deadline exceeded: %v", funcName, err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	default:
+		log.Printf("[ERROR] Handler - %s - %v", funcName, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// @Summary Attach a file to a car
+// @Description Upload a photo or document as the "file" field of a multipart form. The type is detected from the content and must be one of attachments.allowed_types (JPEG, PNG, WebP, GIF and PDF by default); the content is capped by attachments.max_size. Content the car already has is not stored again: the existing attachment is returned with 200.
+// @Tags attachments
+// @Accept mpfd
+// @Produce json
+// @Param id path int true "Car ID"
+// @Param file formData file true "Photo or document"
+// @Success 201 {object} dto.AttachmentDto "Created"
+// @Success 200 {object} dto.AttachmentDto "The car already has this content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 413 {string} string "Request Entity Too Large"
+// @Failure 415 {string} string "Unsupported Media Type"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/{id}/attachments [post]
+func (c *CarHandler) UploadAttachment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	parts, err := r.MultipartReader()
+	if err != nil {
+		log.Printf("[ERROR] Handler - UploadAttachment - Not a multipart form: %v", err)
+		http.Error(w, "Expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			http.Error(w, fmt.Sprintf("Missing %q field", attachmentFormField), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Handler - UploadAttachment - Unable to read the form: %v", err)
+			badBody(w, err)
+			return
+		}
+		if part.FormName() != attachmentFormField {
+			part.Close()
+			continue
+		}
+
+		attachment, created, err := c.Attachments.Upload(r.Context(), p.ByName("id"), part.FileName(), part)
+		part.Close()
+		if err != nil {
+			attachmentError(w, "UploadAttachment", err)
+			return
+		}
+		if !created {
+			writeJSON(w, http.StatusOK, attachment)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/api/cars/%d/attachments/%d", attachment.CarId, attachment.Id))
+		writeJSON(w, http.StatusCreated, attachment)
+		return
+	}
+}
+
+// @Summary List the attachments of a car
+// @Description Metadata of the files attached to a car, oldest first.
+// @Tags attachments
+// @Produce json
+// @Param id path int true "Car ID"
+// @Success 200 {array} dto.AttachmentDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/{id}/attachments [get]
+func (c *CarHandler) ListAttachments(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	attachments, err := c.Attachments.List(r.Context(), p.ByName("id"))
+	if err != nil {
+		attachmentError(w, "ListAttachments", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// @Summary Download an attachment
+// @Description The content of an attachment with its detected type. The ETag is the SHA-256 of the content; Range and If-None-Match requests are supported.
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path int true "Car ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {file} file "Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/{id}/attachments/{attachmentId} [get]
+func (c *CarHandler) GetAttachment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	attachment, content, err := c.Attachments.Open(r.Context(), p.ByName("id"), p.ByName("attachmentId"))
+	if err != nil {
+		attachmentError(w, "GetAttachment", err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("ETag", `"`+attachment.Sha256+`"`)
+	// Uploads are only checked against the allowed types, so browsers must
+	// neither guess another type nor run anything a file contains.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	http.ServeContent(w, r, attachment.FileName, attachment.CreatedAt, content)
+}
+
+// @Summary Delete an attachment
+// @Description Remove an attachment from a car. Its content is deleted from the blob store once no other attachment refers to it.
+// @Tags attachments
+// @Param id path int true "Car ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/{id}/attachments/{attachmentId} [delete]
+func (c *CarHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if err := c.Attachments.Delete(r.Context(), p.ByName("id"), p.ByName("attachmentId")); err != nil {
+		attachmentError(w, "DeleteAttachment", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// @Summary Update cars in bulk
+// @Description Apply per-car changes (items, each id at most once) or one change set to every car matching a filter. In transaction mode (default) any failing item aborts the whole batch with 422; per_item mode applies what it can and lists the failures. dryRun only reports how many cars would change.
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param request body dto.BatchUpdateRequest true "Items, or a filter with changes"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} dto.BatchResult "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 413 {string} string "Batch over limits.max_batch_size"
+// @Failure 422 {object} dto.BatchResult "Transaction aborted, nothing was changed"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars [patch]
+func (c *CarHandler) BatchUpdateCars(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - BatchUpdateCars - Received PATCH request")
+
+	var req dto.BatchUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - BatchUpdateCars - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+
+	result, err := c.CarService.BatchUpdateCars(r.Context(), req, c.MaxBatchSize)
+	writeBatchResult(w, "BatchUpdateCars", result, err)
+}
+
+// @Summary Delete cars in bulk
+// @Description Delete cars by ids or by a filter. In transaction mode (default) a missing id aborts the whole batch with 422; per_item mode deletes what it can and lists the failures. dryRun only reports how many cars would be deleted.
+// @Tags cars
+// @Accept json
+// @Produce json
+// @Param request body dto.BatchDeleteRequest true "Ids or a filter"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} dto.BatchResult "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 413 {string} string "Batch over limits.max_batch_size"
+// @Failure 422 {object} dto.BatchResult "Transaction aborted, nothing was changed"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars:batchDelete [post]
+func (c *CarHandler) BatchDeleteCars(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - BatchDeleteCars - Received POST request")
+
+	var req dto.BatchDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - BatchDeleteCars - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+
+	result, err := c.CarService.BatchDeleteCars(r.Context(), req, c.MaxBatchSize)
+	writeBatchResult(w, "BatchDeleteCars", result, err)
+}
+
+func writeBatchResult(w http.ResponseWriter, name string, result dto.BatchResult, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidBatch):
+		log.Printf("[INFO] Handler - %s - %v", name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrBatchTooLarge):
+		log.Printf("[INFO] Handler - %s - %v", name, err)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrBatchAborted):
+		log.Printf("[INFO] Handler - %s - %v: %d failed", name, err, result.Failed)
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+	case err != nil:
+		log.Printf("[ERROR] Handler - %s - %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
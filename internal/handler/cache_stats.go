@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"car_catalog/internal/carinfo"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// @Summary Registry cache counters
+// @Description Lookups served by the cache of the external API since the process started: hits of found and of unknown plates, misses, hits of the persistent store, lookups coalesced into one upstream call, LRU evictions and upstream errors. Admin role only.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} carinfo.CacheStats "OK"
+// @Failure 403 {string} string "Forbidden"
+// @Router /api/admin/cache-stats [get]
+func (c *CarHandler) GetCacheStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !requireAdmin(w, r, "GetCacheStats") {
+		return
+	}
+	writeJSON(w, http.StatusOK, carinfo.ReadCacheStats())
+}
@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// requireAdmin lets only the admin role curate the dictionary.
+func requireAdmin(w http.ResponseWriter, r *http.Request, funcName string) bool {
+	if role := roleFromRequest(r); role != "admin" {
+		log.Printf("[INFO] Handler - %s - Denied for role %q", funcName, role)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// dictionaryError maps a dictionary service error to a response.
+func dictionaryError(w http.ResponseWriter, funcName string, err error) {
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &numErr):
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidDictionaryEntry):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrMakeNotFound), errors.Is(err, repository.ErrModelNotFound),
+		errors.Is(err, repository.ErrAliasNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, repository.ErrAliasTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("[ERROR] Handler - %s - %v", funcName, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// @Summary List makes
+// @Description The reference dictionary: every make with its aliases and models. Admin only.
+// @Tags dictionary
+// @Produce json
+// @Success 200 {array} dto.MakeDto "OK"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/makes [get]
+func (c *CarHandler) ListMakes(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !requireAdmin(w, r, "ListMakes") {
+		return
+	}
+	makes, err := c.Dictionary.ListMakes(r.Context())
+	if err != nil {
+		dictionaryError(w, "ListMakes", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, makes)
+}
+
+// @Summary Add a make
+// @Description Add a canonical make. Its name is always an alias; aliases are matched ignoring case, spaces and punctuation. Admin only.
+// @Tags dictionary
+// @Accept json
+// @Produce json
+// @Param make body dto.DictionaryEntryRequest true "Make"
+// @Success 201 {object} dto.MakeDto "Created"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/makes [post]
+func (c *CarHandler) AddMake(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !requireAdmin(w, r, "AddMake") {
+		return
+	}
+	var req dto.DictionaryEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - AddMake - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	created, err := c.Dictionary.AddMake(r.Context(), req)
+	if err != nil {
+		dictionaryError(w, "AddMake", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// @Summary Delete a make
+// @Description Delete a make with its aliases and models. Cars keep their mark. Admin only.
+// @Tags dictionary
+// @Param id path int true "Make ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/makes/{id} [delete]
+func (c *CarHandler) DeleteMake(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "DeleteMake") {
+		return
+	}
+	if err := c.Dictionary.DeleteMake(r.Context(), p.ByName("id")); err != nil {
+		dictionaryError(w, "DeleteMake", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Add a make alias
+// @Description Admin only.
+// @Tags dictionary
+// @Accept json
+// @Param id path int true "Make ID"
+// @Param alias body dto.AliasRequest true "Alias"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/makes/{id}/aliases [post]
+func (c *CarHandler) AddMakeAlias(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "AddMakeAlias") {
+		return
+	}
+	var req dto.AliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - AddMakeAlias - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	if err := c.Dictionary.AddMakeAlias(r.Context(), p.ByName("id"), req.Alias); err != nil {
+		dictionaryError(w, "AddMakeAlias", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Delete a make alias
+// @Description Admin only.
+// @Tags dictionary
+// @Param id path int true "Make ID"
+// @Param alias path string true "Alias"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/makes/{id}/aliases/{alias} [delete]
+func (c *CarHandler) DeleteMakeAlias(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "DeleteMakeAlias") {
+		return
+	}
+	if err := c.Dictionary.DeleteMakeAlias(r.Context(), p.ByName("id"), p.ByName("alias")); err != nil {
+		dictionaryError(w, "DeleteMakeAlias", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Add a model
+// @Description Add a canonical model of a make. Its name is always an alias. Admin only.
+// @Tags dictionary
+// @Accept json
+// @Produce json
+// @Param id path int true "Make ID"
+// @Param model body dto.DictionaryEntryRequest true "Model"
+// @Success 201 {object} dto.ModelRefDto "Created"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/makes/{id}/models [post]
+func (c *CarHandler) AddModel(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "AddModel") {
+		return
+	}
+	var req dto.DictionaryEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - AddModel - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	created, err := c.Dictionary.AddModel(r.Context(), p.ByName("id"), req)
+	if err != nil {
+		dictionaryError(w, "AddModel", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// @Summary Delete a model
+// @Description Delete a model with its aliases. Cars keep their model. Admin only.
+// @Tags dictionary
+// @Param id path int true "Model ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/models/{id} [delete]
+func (c *CarHandler) DeleteModel(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "DeleteModel") {
+		return
+	}
+	if err := c.Dictionary.DeleteModel(r.Context(), p.ByName("id")); err != nil {
+		dictionaryError(w, "DeleteModel", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Add a model alias
+// @Description Admin only.
+// @Tags dictionary
+// @Accept json
+// @Param id path int true "Model ID"
+// @Param alias body dto.AliasRequest true "Alias"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/models/{id}/aliases [post]
+func (c *CarHandler) AddModelAlias(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "AddModelAlias") {
+		return
+	}
+	var req dto.AliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - AddModelAlias - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	if err := c.Dictionary.AddModelAlias(r.Context(), p.ByName("id"), req.Alias); err != nil {
+		dictionaryError(w, "AddModelAlias", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Delete a model alias
+// @Description Admin only.
+// @Tags dictionary
+// @Param id path int true "Model ID"
+// @Param alias path string true "Alias"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/models/{id}/aliases/{alias} [delete]
+func (c *CarHandler) DeleteModelAlias(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "DeleteModelAlias") {
+		return
+	}
+	if err := c.Dictionary.DeleteModelAlias(r.Context(), p.ByName("id"), p.ByName("alias")); err != nil {
+		dictionaryError(w, "DeleteModelAlias", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// defaultEventHeartbeat applies when the handler is built without
+// EventHeartbeat, as in tests.
+const defaultEventHeartbeat = 15 * time.Second
+
+// @Summary Stream catalog changes
+// @Description Server-Sent Events stream of created, updated and deleted cars, one "id", "event" and "data" block per change. Reconnecting with Last-Event-ID (or lastEventId) replays the changes missed since, as long as they are within events.retention. Tenant is the name of the API key that made the change; with authentication, callers other than admin only see the changes of their own key. Owner data is only included for the admin and finance roles. Idle streams get a comment line every events.heartbeat.
+// @Tags cars
+// @Produce text/event-stream
+// @Param mark query string false "Only cars of this mark"
+// @Param tenant query string false "Only changes made with this API key; ignored for callers other than admin, who get their own"
+// @Param Last-Event-ID header int false "Resume after this event id"
+// @Param lastEventId query int false "Resume after this event id, for clients that cannot set headers"
+// @Success 200 {object} dto.CarEventDto "Event data"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 503 {string} string "Service Unavailable"
+// @Router /api/cars/events [get]
+func (c *CarHandler) StreamEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - StreamEvents - Received GET request")
+
+	if c.Events == nil {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("lastEventId")
+	}
+	// A new client gets the changes from now on.
+	lastId := int64(-1)
+	if lastEventId != "" {
+		var err error
+		if lastId, err = strconv.ParseInt(lastEventId, 10, 64); err != nil || lastId < 0 {
+			log.Printf("[ERROR] Handler - StreamEvents - Invalid last event id %q", lastEventId)
+			http.Error(w, "Last-Event-ID must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	filter := dto.EventFilter{
+		Mark:   r.URL.Query().Get("mark"),
+		Tenant: r.URL.Query().Get("tenant"),
+	}
+	showOwner := ownerViewerRoles[roleFromRequest(r)]
+
+	// The stream outlives http.write_timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("[ERROR] Handler - StreamEvents - Unable to clear the write deadline: %v", err)
+	}
+
+	events, err := c.Events.Subscribe(r.Context(), lastId, filter)
+	if err != nil {
+		log.Printf("[ERROR] Handler - StreamEvents - Unable to subscribe: %v", err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("[DEBUG] Handler - StreamEvents - Streaming after %d, Filter: %+v", lastId, filter)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("[ERROR] Handler - StreamEvents - Unable to flush: %v", err)
+		return
+	}
+
+	heartbeat := c.EventHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = defaultEventHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// The client resumes from the last id it got.
+				log.Println("[INFO] Handler - StreamEvents - Stream closed")
+				return
+			}
+			if !showOwner {
+				event.Car.Owner = nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("[ERROR] Handler - StreamEvents - Error encoding event %d: %v", event.Id, err)
+				return
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.Printf("[INFO] Handler - StreamEvents - Client gone: %v", err)
+				return
+			}
+		case <-ticker.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.Printf("[INFO] Handler - StreamEvents - Client gone: %v", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/export"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// exportFlushEvery controls how many rows are written between flushes to the
+// client, so large exports start arriving before the query finishes.
+const exportFlushEvery = 1000
+
+// ownerViewerRoles lists the roles allowed to export owner columns and to
+// group statistics by owner.
+var ownerViewerRoles = map[string]bool{
+	"admin":   true,
+	"finance": true,
+}
+
+// roleFromRequest returns the role of the authenticated API key. Anonymous
+// requests, as all are when authentication is disabled, have no role: a
+// header the caller sets cannot grant one.
+func roleFromRequest(r *http.Request) string {
+	identity, _ := auth.FromContext(r.Context())
+	return identity.Role
+}
+
+// @Summary Export cars
+// @Description Stream the filtered catalog as CSV or NDJSON. Owner columns are included only when requested by an admin or finance role.
+// @Tags cars
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "Export format (csv or ndjson)" default(csv)
+// @Param mark query string false "Car mark"
+// @Param model query string false "Car model"
+// @Param year query string false "Car year"
+// @Param owner query bool false "Include owner columns"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/export [get]
+func (c *CarHandler) ExportCars(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - ExportCars - Received GET request")
+
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		log.Printf("[ERROR] Handler - ExportCars - %v", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	includeOwner := r.URL.Query().Get("owner") == "true"
+	if includeOwner && !ownerViewerRoles[roleFromRequest(r)] {
+		log.Printf("[INFO] Handler - ExportCars - Owner columns denied for role %q", roleFromRequest(r))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	filters := dto.Filters{
+		Mark:  r.URL.Query().Get("mark"),
+		Model: r.URL.Query().Get("model"),
+		Year:  r.URL.Query().Get("year"),
+	}
+	log.Printf("[DEBUG] Handler - ExportCars - Format: %s, Filters: %+v, Owner: %t", format, filters, includeOwner)
+
+	writer, err := export.NewWriter(format, w, includeOwner)
+	if err != nil {
+		log.Printf("[ERROR] Handler - ExportCars - %v", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+
+	// Headers are sent lazily with the first row, so a filter error can still
+	// be reported with a proper status code.
+	started := false
+	written := 0
+	err = c.CarService.ExportCars(r.Context(), filters, includeOwner, func(car dto.ExportCarDto) error {
+		if !started {
+			writeExportHeaders(w, format)
+			started = true
+		}
+		if err := writer.Write(car); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Handler - ExportCars - Unable to export cars: %v", err)
+		if !started {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if !started {
+		writeExportHeaders(w, format)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Printf("[ERROR] Handler - ExportCars - Unable to flush export: %v", err)
+	}
+}
+
+func writeExportHeaders(w http.ResponseWriter, format export.Format) {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"cars.%s\"", format))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/graphqlapi"
+	"car_catalog/internal/ratelimit"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// @Summary GraphQL endpoint
+// @Description Run a GraphQL query or mutation against the catalog schema: cars(first, after, last, before, mark, model, year, vin, q) is a Relay connection over the cursors of /api/getCars/, car(id) fetches one car, and the createCars, importCars, updateCar and deleteCar mutations map onto the REST methods. Owners and ownershipHistory resolve to null for roles other than admin and finance. Mutations draw from the import budget of limits.rate_limit, and createCars and importCars may appear once per operation. Operations deeper than graphql.max_depth or resolving more than graphql.max_complexity fields are refused before they run. Errors come back with status 200 in "errors", with extensions.code set to BAD_USER_INPUT, NOT_FOUND, CONFLICT, UNAVAILABLE, TIMEOUT or INTERNAL. Introspection is enabled.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body dto.GraphQLRequest true "Operation"
+// @Success 200 {object} object "GraphQL response with data and errors"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 413 {string} string "Request Entity Too Large"
+// @Failure 429 {string} string "Mutation over the import budget"
+// @Router /graphql [post]
+func (c *CarHandler) PostGraphQL(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req dto.GraphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - PostGraphQL - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	// The middleware drew from the read budget; mutations import or write
+	// cars, so they also draw from the import budget like /api/addCars.
+	if c.Limiter != nil && graphqlapi.IsMutation(req) {
+		client := ratelimit.ClientKey(r.Context(), r.RemoteAddr)
+		if ok, retryAfter := c.Limiter.Allow(client, ratelimit.BudgetImport); !ok {
+			log.Printf("[INFO] Handler - PostGraphQL - Client %q exceeded the import budget", client)
+			w.Header().Set("Retry-After", fmt.Sprint(ratelimit.RetryAfterSeconds(retryAfter)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+	c.serveGraphQL(w, r, req, true)
+}
+
+// @Summary GraphQL query over GET
+// @Description Run a GraphQL query given in the URL, see POST /graphql. Mutations are refused with 405.
+// @Tags graphql
+// @Produce json
+// @Param query query string true "GraphQL document"
+// @Param operationName query string false "Operation to run when the document has several"
+// @Param variables query string false "Variables as a JSON object"
+// @Success 200 {object} object "GraphQL response with data and errors"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 405 {string} string "Mutations must be sent with POST"
+// @Router /graphql [get]
+func (c *CarHandler) GetGraphQL(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	req := dto.GraphQLRequest{
+		Query:         query.Get("query"),
+		OperationName: query.Get("operationName"),
+	}
+	if variables := query.Get("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+			log.Printf("[ERROR] Handler - GetGraphQL - Unable to decode variables: %v", err)
+			http.Error(w, "Invalid variables", http.StatusBadRequest)
+			return
+		}
+	}
+	c.serveGraphQL(w, r, req, false)
+}
+
+func (c *CarHandler) serveGraphQL(w http.ResponseWriter, r *http.Request, req dto.GraphQLRequest, allowMutations bool) {
+	if req.Query == "" {
+		http.Error(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.GraphQL.Execute(r.Context(), req, roleFromRequest(r), allowMutations)
+	if errors.Is(err, graphqlapi.ErrMutationNotAllowed) {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Handler - GraphQL - %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
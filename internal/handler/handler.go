@@ -1,24 +1,59 @@
 package handler
 
 import (
+	"car_catalog/internal/carinfo"
 	"car_catalog/internal/dto"
+	"car_catalog/internal/graphqlapi"
+	"car_catalog/internal/ratelimit"
+	"car_catalog/internal/repository"
 	"car_catalog/internal/service"
+	"car_catalog/internal/vin"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 type CarHandler struct {
-	CarService     service.CarService
-	ExternalAPIURL string
+	CarService service.CarService
+	Resync     service.ResyncService
+	// MaxRegNums caps the regNums array of AddCars; zero means no cap.
+	MaxRegNums int
+	// MaxBatchSize caps the cars touched by one batch operation.
+	MaxBatchSize int
+	// SuggestTimeout is the latency budget of the suggest endpoints and
+	// MaxSuggestLimit caps their limit parameter.
+	SuggestTimeout  time.Duration
+	MaxSuggestLimit int
+	// Dictionary serves the admin API of the make and model dictionary.
+	Dictionary service.DictionaryService
+	// Stats serves /api/stats.
+	Stats service.StatsService
+	// Events serves the /api/cars/events stream; EventHeartbeat is how often
+	// an idle stream sends a comment to keep proxies from closing it.
+	Events         service.EventService
+	EventHeartbeat time.Duration
+	// Webhooks serves the admin API of webhook subscriptions.
+	Webhooks service.WebhookService
+	// GraphQL executes the operations sent to /graphql. Limiter, when set,
+	// charges GraphQL mutations to the import budget of the caller, which the
+	// rate-limit middleware cannot tell from queries.
+	GraphQL graphqlapi.Schema
+	Limiter *ratelimit.Limiter
+	// Attachments serves the files attached to cars.
+	Attachments service.AttachmentService
 }
 
-func NewCarHandler(carService service.CarService, externalAPIURL string) *CarHandler {
+func NewCarHandler(carService service.CarService, resync service.ResyncService) *CarHandler {
 	return &CarHandler{
-		CarService:     carService,
-		ExternalAPIURL: externalAPIURL,
+		CarService: carService,
+		Resync:     resync,
 	}
 }
 
@@ -29,6 +64,8 @@ func NewCarHandler(carService service.CarService, externalAPIURL string) *CarHan
 // @Param mark query string false "Car mark"
 // @Param model query string false "Car model"
 // @Param year query string false "Car year"
+// @Param vin query string false "Exact VIN; returns at most one car and cannot be combined with q"
+// @Param q query string false "Fuzzy search over mark, model, reg number and owner; results are ranked by score"
 // @Param limit query string false "Results limit" default(10)
 // @Param next query string false "Next cursor for pagination"
 // @Param prev query string false "Previous cursor for pagination"
@@ -36,6 +73,7 @@ func NewCarHandler(carService service.CarService, externalAPIURL string) *CarHan
 // @Failure 400 {string} string "Bad Request"
 // @Failure 405 {string} string "Method Not Allowed"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Service Unavailable"
 // @Router /api/getCars [get]
 func (c *CarHandler) GetFilteredCars(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	if r.Method != http.MethodGet {
@@ -48,7 +86,9 @@ func (c *CarHandler) GetFilteredCars(w http.ResponseWriter, r *http.Request, p h
 		Mark:  r.URL.Query().Get("mark"),
 		Model: r.URL.Query().Get("model"),
 		Year:  r.URL.Query().Get("year"),
+		Vin:   r.URL.Query().Get("vin"),
 		Limit: r.URL.Query().Get("limit"),
+		Query: strings.TrimSpace(r.URL.Query().Get("q")),
 	}
 	log.Printf("[DEBUG] Handler - GetFilteredCars - Filters: %+v", filters)
 
@@ -59,6 +99,11 @@ func (c *CarHandler) GetFilteredCars(w http.ResponseWriter, r *http.Request, p h
 	log.Printf("[DEBUG] Handler - GetFilteredCars - Cursors: %+v", cursors)
 
 	result, cursors, err := c.CarService.GetFilteredCars(r.Context(), filters, cursors)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("[ERROR] Handler - GetFilteredCars - Query deadline exceeded: %v", err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		log.Printf("[ERROR] Handler - GetFilteredCars - Unable to get filtered cars error: %v", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -82,13 +127,16 @@ func (c *CarHandler) GetFilteredCars(w http.ResponseWriter, r *http.Request, p h
 }
 
 // @Summary Add cars
-// @Description Add cars to the database from external API based on registration numbers
+// @Description Add cars to the database from external API based on registration numbers. Numbers the external API does not know are skipped and listed in notFound; any other lookup failure adds nothing.
 // @Tags cars
 // @Accept  json
 // @Produce  json
 // @Param regNums body dto.RegNumsRequest true "Registration numbers array"
-// @Success 200 {string} string "Request processed successfully"
-// @Failure 400 {string} string "Bad Request"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} dto.AddCarsResult "OK"
+// @Failure 400 {string} string "Bad Request, including a registry VIN that is invalid or does not match the car"
+// @Failure 413 {string} string "Request body or regNums over the limit"
+// @Failure 429 {string} string "Too Many Requests"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /api/addCars [post]
 func (c *CarHandler) AddCars(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -101,48 +149,38 @@ func (c *CarHandler) AddCars(w http.ResponseWriter, r *http.Request, _ httproute
 	var requestBody dto.RegNumsRequest
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
 		log.Printf("[ERROR] Handler - AddCars - Unable to decode JSON: %v", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		badBody(w, err)
 		return
 	}
-
-	cars := []dto.AddCarsDto{}
-	for _, regNum := range requestBody.RegNums {
-		var carResp dto.AddCarsDto
-
-		resp, err := http.Get(c.ExternalAPIURL + "?regNum=" + regNum)
-		if err != nil {
-			log.Printf("[ERROR] Handler - AddCars - Failed to get car information from external API: %v", err)
-			http.Error(w, "Failed to get car information from external API", http.StatusInternalServerError)
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusBadRequest {
-			log.Printf("[INFO] Handler - AddCars - Got StatusBadRequest from external API")
-			http.Error(w, "StatusBadRequest from external API", http.StatusInternalServerError)
-			return
-		} else if resp.StatusCode == http.StatusInternalServerError {
-			log.Printf("[INFO] Handler - AddCars - Got StatusInternalServerError from external API")
-			http.Error(w, "StatusInternalServerError from external API", http.StatusInternalServerError)
-			return
-		} else if resp.StatusCode == http.StatusOK {
-			if err := json.NewDecoder(resp.Body).Decode(&carResp); err != nil {
-				log.Printf("[ERROR] Handler - AddCars - Unable to decode response from external API: %v", err)
-				http.Error(w, "Bad Request", http.StatusBadRequest)
-				return
-			}
-			cars = append(cars, carResp)
-		}
+	if c.MaxRegNums > 0 && len(requestBody.RegNums) > c.MaxRegNums {
+		log.Printf("[INFO] Handler - AddCars - Rejected %d registration numbers, limit is %d", len(requestBody.RegNums), c.MaxRegNums)
+		http.Error(w, fmt.Sprintf("At most %d registration numbers per request", c.MaxRegNums), http.StatusRequestEntityTooLarge)
+		return
 	}
 
-	if err := c.CarService.AddCars(r.Context(), cars); err != nil {
+	result, err := c.CarService.AddCarsByRegNum(r.Context(), requestBody.RegNums)
+	switch {
+	case errors.Is(err, carinfo.ErrInvalidResponse):
+		log.Printf("[ERROR] Handler - AddCars - Unable to decode response from external API: %v", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	case errors.Is(err, carinfo.ErrRejected):
+		log.Printf("[INFO] Handler - AddCars - Lookup rejected by external API: %v", err)
+		http.Error(w, "StatusBadRequest from external API", http.StatusInternalServerError)
+		return
+	case errors.Is(err, carinfo.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[ERROR] Handler - AddCars - Failed to get car information from external API: %v", err)
+		http.Error(w, "Failed to get car information from external API", http.StatusInternalServerError)
+		return
+	case err != nil:
 		log.Printf("[ERROR] Handler - AddCars - Unable to add car: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if !writeVinError(w, err) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Request processed successfully"))
+	writeJSON(w, http.StatusOK, result)
 }
 
 // @Summary Update a car
@@ -152,8 +190,10 @@ func (c *CarHandler) AddCars(w http.ResponseWriter, r *http.Request, _ httproute
 // @Produce json
 // @Param id path string true "Car ID"
 // @Param updateDto body dto.UpdateCarDto true "Car update information"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {string} string "OK"
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {string} string "Bad Request, including an invalid VIN or one that does not match the car"
+// @Failure 409 {string} string "VIN already belongs to another car"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /api/updateCar/{id} [patch]
 func (c *CarHandler) UpdateCar(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -167,13 +207,15 @@ func (c *CarHandler) UpdateCar(w http.ResponseWriter, r *http.Request, p httprou
 	var updateDto dto.UpdateCarDto
 	if err := json.NewDecoder(r.Body).Decode(&updateDto); err != nil {
 		log.Printf("[ERROR] Handler - UpdateCar - Unable to decode JSON: %v", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		badBody(w, err)
 		return
 	}
 
 	if err := c.CarService.UpdateCar(r.Context(), carId, updateDto); err != nil {
 		log.Printf("[ERROR] Handler - UpdateCar - Unable to update car error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if !writeVinError(w, err) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -185,6 +227,7 @@ func (c *CarHandler) UpdateCar(w http.ResponseWriter, r *http.Request, p httprou
 // @Description Delete a car by its ID
 // @Tags cars
 // @Param id path string true "Car ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {string} string "OK"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 500 {string} string "Internal Server Error"
@@ -206,3 +249,29 @@ func (c *CarHandler) DeleteCar(w http.ResponseWriter, r *http.Request, p httprou
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Request processed successfully"))
 }
+
+// writeVinError answers a VIN the service rejected: 400 when it is invalid or
+// does not match the car, 409 when another car has it. It reports false for
+// any other error.
+func writeVinError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, vin.ErrInvalid), errors.Is(err, service.ErrVinMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrDuplicateVin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
+// badBody answers a request whose body could not be read or decoded: 413 when
+// it exceeded limits.max_body_bytes, 400 otherwise.
+func badBody(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Bad Request", http.StatusBadRequest)
+}
@@ -0,0 +1,1086 @@
+package handler_test
+
+import (
+	"bufio"
+	"bytes"
+	"car_catalog/internal/auth"
+	"car_catalog/internal/blobstore"
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/config"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/graphqlapi"
+	"car_catalog/internal/handler"
+	"car_catalog/internal/model"
+	"car_catalog/internal/ratelimit"
+	"car_catalog/internal/registrystub"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/router"
+	"car_catalog/internal/service"
+	"car_catalog/internal/webhook"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestAddCarsAgainstRegistryStub drives AddCars end to end against the stub
+// registry and checks how each kind of registry failure surfaces.
+// roleKeys authenticates "<role>-key" as an API key of that role.
+var roleKeys = config.AuthConfig{Enabled: true, Keys: []config.APIKey{
+	{Name: "admin", Key: "admin-key", Role: "admin"},
+	{Name: "finance", Key: "finance-key", Role: "finance"},
+	{Name: "viewer", Key: "viewer-key", Role: "viewer"},
+}}
+
+// asRole authenticates req as an API key of role, as auth.Middleware would;
+// an empty role leaves it anonymous.
+func asRole(req *http.Request, role string) *http.Request {
+	if role == "" {
+		return req
+	}
+	return req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: role, Role: role}))
+}
+
+func TestAddCarsAgainstRegistryStub(t *testing.T) {
+	fixtures := map[string]registrystub.Entry{
+		"A123BC77": {AddCarsDto: dto.AddCarsDto{Mark: "Lada", Model: "Vesta", Year: 2020, RegNum: "A123BC77"}},
+		"B400BB77": {AddCarsDto: dto.AddCarsDto{RegNum: "B400BB77"}, Fault: registrystub.FaultBadRequest},
+		"C500CC77": {AddCarsDto: dto.AddCarsDto{RegNum: "C500CC77"}, Fault: registrystub.FaultServerError},
+		"E111EE77": {AddCarsDto: dto.AddCarsDto{RegNum: "E111EE77"}, Fault: registrystub.FaultMalformed},
+		"T001TT77": {AddCarsDto: dto.AddCarsDto{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "T001TT77", Vin: "5YJ3E1EA2JF000316"}},
+	}
+
+	tests := []struct {
+		name       string
+		opts       registrystub.Options
+		regNum     string
+		wantStatus int
+		wantStored bool
+	}{
+		{name: "fixture", regNum: "A123BC77", wantStatus: http.StatusOK, wantStored: true},
+		{name: "generated", opts: registrystub.Options{Generate: true}, regNum: "K777KK777", wantStatus: http.StatusOK, wantStored: true},
+		{name: "unknown plate", regNum: "M404MM77", wantStatus: http.StatusOK},
+		{name: "registry 400", regNum: "B400BB77", wantStatus: http.StatusInternalServerError},
+		{name: "registry 500", regNum: "C500CC77", wantStatus: http.StatusInternalServerError},
+		{name: "malformed JSON", regNum: "E111EE77", wantStatus: http.StatusBadRequest},
+		{name: "VIN of another mark", regNum: "T001TT77", wantStatus: http.StatusBadRequest},
+		{name: "random 500", opts: registrystub.Options{ServerErrorRate: 1}, regNum: "A123BC77", wantStatus: http.StatusInternalServerError},
+		{name: "timeout", opts: registrystub.Options{Latency: time.Second}, regNum: "A123BC77", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Fixtures = fixtures
+			registry := httptest.NewServer(registrystub.New(tt.opts))
+			defer registry.Close()
+
+			repo := repository.NewMemoryCarRepository()
+			carInfo := carinfo.NewHTTPProvider(registry.URL, &http.Client{Timeout: 100 * time.Millisecond})
+			carService := service.NewCarService(repo, nil, carInfo)
+			h := handler.NewCarHandler(carService, service.NewResyncService(carService, repo, carInfo))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/addCars", strings.NewReader(`{"regNums":["`+tt.regNum+`"]}`))
+			rec := httptest.NewRecorder()
+			h.AddCars(rec, req, nil)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			existing, err := repo.FindExistingRegNums(context.Background(), []string{tt.regNum})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := len(existing) == 1; stored != tt.wantStored {
+				t.Fatalf("stored = %t, want %t", stored, tt.wantStored)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			// An unknown plate is skipped and reported, not an error.
+			var result dto.AddCarsResult
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if skipped := len(result.NotFound) == 1 && result.NotFound[0] == tt.regNum; skipped == tt.wantStored || result.Added+len(result.NotFound) != 1 {
+				t.Fatalf("result = %+v, want %s stored %t", result, tt.regNum, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestAddCarsRejectsTooManyRegNums(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	h := handler.NewCarHandler(service.NewCarService(repo, nil, carinfo.NewChain()), nil)
+	h.MaxRegNums = 2
+
+	req := httptest.NewRequest(http.MethodPost, "/api/addCars", strings.NewReader(`{"regNums":["A1","A2","A3"]}`))
+	rec := httptest.NewRecorder()
+	h.AddCars(rec, req, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 (%s)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestResyncCarAppliesRegistryChanges(t *testing.T) {
+	registry := httptest.NewServer(registrystub.New(registrystub.Options{
+		Fixtures: map[string]registrystub.Entry{
+			"A123BC77": {AddCarsDto: dto.AddCarsDto{
+				Mark: "Lada", Model: "Granta", Year: 2020, RegNum: "A123BC77",
+				Owner: dto.People{Name: "Пётр", Surname: "Иванов"},
+			}},
+		},
+	}))
+	defer registry.Close()
+
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{{
+		Mark: "Lada", Model: "Vesta", Year: 2020, RegNum: "A123BC77",
+		OwnerName: "Иван", OwnerSurname: "Иванов", OwnerPatronymic: "Петрович",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	carInfo := carinfo.NewHTTPProvider(registry.URL, http.DefaultClient)
+	carService := service.NewCarService(repo, nil, carInfo)
+	h := handler.NewCarHandler(carService, service.NewResyncService(carService, repo, carInfo))
+
+	rec := httptest.NewRecorder()
+	h.ResyncCar(rec, httptest.NewRequest(http.MethodPost, "/api/cars/1/resync", nil), httprouter.Params{{Key: "id", Value: "1"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	var result dto.ResyncResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	// The empty registry patronymic is not a divergence.
+	if len(result.Divergences) != 2 || result.Divergences[0].Field != "model" || result.Divergences[1].Field != "owner_name" || result.Divergences[0].Status != model.DivergenceApplied {
+		t.Fatalf("divergences = %+v, want model and owner_name", result.Divergences)
+	}
+
+	car, err := repo.GetCarById(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car.Model != "Granta" || car.OwnerName != "Пётр" || car.OwnerPatronymic != "Петрович" || car.LastSyncedAt == nil {
+		t.Fatalf("car after resync = %+v", car)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetDivergences(rec, httptest.NewRequest(http.MethodGet, "/api/sync/divergences?carId=1", nil), nil)
+	var divergences []dto.DivergenceDto
+	if err := json.Unmarshal(rec.Body.Bytes(), &divergences); err != nil {
+		t.Fatal(err)
+	}
+	if len(divergences) != 2 || divergences[0].Field != "owner_name" {
+		t.Fatalf("report = %+v, want two, newest first", divergences)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ResyncCar(rec, httptest.NewRequest(http.MethodPost, "/api/cars/7/resync", nil), httprouter.Params{{Key: "id", Value: "7"}})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("resync of a missing car: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestResyncRecordsRefusedUpdateOnce(t *testing.T) {
+	// The registry VIN belongs to a Tesla, so the catalog refuses to update
+	// the Lada with it.
+	registry := httptest.NewServer(registrystub.New(registrystub.Options{
+		Fixtures: map[string]registrystub.Entry{
+			"A123BC77": {AddCarsDto: dto.AddCarsDto{
+				Mark: "Lada", Model: "Granta", Year: 2020, RegNum: "A123BC77", Vin: "5YJ3E1EA2JF000316",
+			}},
+		},
+	}))
+	defer registry.Close()
+
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{{Mark: "Lada", Model: "Vesta", Year: 2020, RegNum: "A123BC77"}}); err != nil {
+		t.Fatal(err)
+	}
+	carInfo := carinfo.NewHTTPProvider(registry.URL, http.DefaultClient)
+	resync := service.NewResyncService(service.NewCarService(repo, nil, carInfo), repo, carInfo)
+	ctx := context.Background()
+
+	summary, err := resync.ResyncStale(ctx, 0, 10)
+	if err != nil || summary.Checked != 1 || summary.Failed != 1 {
+		t.Fatalf("ResyncStale = %+v, %v, want one failed", summary, err)
+	}
+	car, err := repo.GetCarById(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car.Model != "Vesta" || car.Vin != "" || car.LastSyncedAt == nil {
+		t.Fatalf("car after a refused update = %+v, want unchanged and synced", car)
+	}
+
+	// Synced despite the failure, the car is not picked again before it is
+	// stale, so the divergences are not recorded twice.
+	if summary, err := resync.ResyncStale(ctx, time.Hour, 10); err != nil || summary.Checked != 0 {
+		t.Fatalf("second ResyncStale = %+v, %v, want nothing to check", summary, err)
+	}
+	divergences, err := resync.GetDivergences(ctx, "1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(divergences) != 2 {
+		t.Fatalf("divergences = %+v, want model and vin once", divergences)
+	}
+	for _, d := range divergences {
+		if d.Status != model.DivergenceFailed || d.Error == "" {
+			t.Fatalf("divergence = %+v, want failed with the error", d)
+		}
+	}
+}
+
+func TestBatchUpdateAndDelete(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77"},
+		{Mark: "Lada", Model: "Granta", Year: 2019, RegNum: "B002BB77"},
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "C003CC77"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewCarHandler(service.NewCarService(repo, nil, carinfo.NewChain()), nil)
+	h.MaxBatchSize = 10
+	routes := router.NewRouter(h)
+
+	send := func(method, path, body string) (int, dto.BatchResult) {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var result dto.BatchResult
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec.Code, result
+	}
+
+	code, result := send(http.MethodPatch, "/api/cars", `{"filter":{"mark":"Lada"},"changes":{"mark":"LADA"},"dryRun":true}`)
+	if code != http.StatusOK || result.Matched != 2 || result.Affected != 0 {
+		t.Fatalf("dry run: %d %+v", code, result)
+	}
+
+	// One missing id aborts the whole transaction.
+	code, result = send(http.MethodPatch, "/api/cars", `{"items":[{"id":1,"changes":{"year":"2021"}},{"id":9,"changes":{"year":"2021"}}]}`)
+	if code != http.StatusUnprocessableEntity || result.Failed != 1 || result.Errors[0].Id != 9 {
+		t.Fatalf("aborted transaction: %d %+v", code, result)
+	}
+	if car, _ := repo.GetCarById(context.Background(), 1); car.Year != 2018 {
+		t.Fatalf("aborted transaction changed car 1: %+v", car)
+	}
+
+	// A repeated id would otherwise apply two change sets to one row.
+	if code, _ := send(http.MethodPatch, "/api/cars", `{"items":[{"id":1,"changes":{"year":"2021"}},{"id":1,"changes":{"model":"Niva"}}]}`); code != http.StatusBadRequest {
+		t.Fatalf("repeated id: status = %d, want 400", code)
+	}
+
+	code, result = send(http.MethodPatch, "/api/cars", `{"items":[{"id":1,"changes":{"year":"2021"}},{"id":9,"changes":{"year":"2021"}}],"mode":"per_item"}`)
+	if code != http.StatusOK || result.Affected != 1 || result.Failed != 1 {
+		t.Fatalf("per item: %d %+v", code, result)
+	}
+	if car, _ := repo.GetCarById(context.Background(), 1); car.Year != 2021 {
+		t.Fatalf("per item did not update car 1: %+v", car)
+	}
+
+	code, result = send(http.MethodPost, "/api/cars:batchDelete", `{"filter":{"mark":"Lada"}}`)
+	if code != http.StatusOK || result.Affected != 2 {
+		t.Fatalf("delete by filter: %d %+v", code, result)
+	}
+	if code, _ := send(http.MethodPost, "/api/cars:batchDelete", `{"filter":{}}`); code != http.StatusBadRequest {
+		t.Fatalf("empty filter: status = %d, want 400", code)
+	}
+	if code, _ := send(http.MethodPost, "/api/cars:batchDelete", `{"ids":[1,2,3,4,5,6,7,8,9,10,11]}`); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("too many ids: status = %d, want 413", code)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77"},
+		{Mark: "Lada", Model: "Granta", Year: 2019, RegNum: "A002AA77"},
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "C003CC77"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	viewer := auth.WithIdentity(context.Background(), auth.Identity{Name: "viewer", Role: "viewer"})
+	if err := repo.AddCars(viewer, []model.Car{{Mark: "Lada", Model: "Niva", Year: 2021, RegNum: "E004EE77"}}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewCarHandler(service.NewCarService(repo, nil, carinfo.NewChain()), nil)
+	h.SuggestTimeout = time.Second
+	h.MaxSuggestLimit = 5
+	routes := router.NewRouter(h)
+
+	tests := []struct {
+		role string
+		path string
+		code int
+		want string
+	}{
+		{"", "/api/suggest/marks?prefix=l", http.StatusOK, `[{"value":"Lada","count":3}]`},
+		{"", "/api/suggest/models?mark=LADA&prefix=g", http.StatusOK, `[{"value":"Granta","count":1}]`},
+		// Cyrillic look-alikes and spaces are normalized like stored plates.
+		{"", "/api/suggest/regnums?prefix=%D0%90%200", http.StatusOK, `[{"value":"A001AA77","count":1},{"value":"A002AA77","count":1}]`},
+		{"", "/api/suggest/regnums?prefix=A", http.StatusBadRequest, ""},
+		{"", "/api/suggest/marks?prefix=l&limit=6", http.StatusBadRequest, ""},
+		// An empty prefix would group the whole catalog, unless the models
+		// are those of one mark.
+		{"", "/api/suggest/marks", http.StatusBadRequest, ""},
+		{"", "/api/suggest/models?prefix=%20", http.StatusBadRequest, ""},
+		{"", "/api/suggest/models?mark=kia", http.StatusOK, `[{"value":"Rio","count":1}]`},
+		// API keys only see the cars they added; admins see every car.
+		{"viewer", "/api/suggest/marks?prefix=l", http.StatusOK, `[{"value":"Lada","count":1}]`},
+		{"viewer", "/api/suggest/models?mark=kia", http.StatusOK, `[]`},
+		{"viewer", "/api/suggest/regnums?prefix=A0", http.StatusOK, `[]`},
+		{"admin", "/api/suggest/marks?prefix=l", http.StatusOK, `[{"value":"Lada","count":3}]`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, asRole(httptest.NewRequest(http.MethodGet, tt.path, nil), tt.role))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.path, rec.Code, tt.code)
+			continue
+		}
+		if tt.want != "" && rec.Body.String() != tt.want {
+			t.Errorf("%s: body %s, want %s", tt.path, rec.Body, tt.want)
+		}
+	}
+}
+
+func TestDictionary(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	dictionary := repository.NewMemoryDictionaryRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77"},
+		{Mark: "bmw", Model: "X-5", Year: 2019, RegNum: "A002AA77"},
+		{Mark: "bmw", Model: "M3", Year: 2020, RegNum: "A003AA77"},
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "C004CC77"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewCarHandler(service.NewCarService(repo, dictionary, carinfo.NewChain()), nil)
+	h.Dictionary = service.NewDictionaryService(dictionary, repo)
+	routes := router.NewRouter(h)
+
+	send := func(role, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, asRole(req, role))
+		return rec
+	}
+
+	if rec := send("viewer", http.MethodPost, "/api/admin/makes", `{"name":"BMW"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer: status = %d, want 403", rec.Code)
+	}
+	if rec := send("admin", http.MethodPost, "/api/admin/makes", `{"name":"BMW","aliases":["БМВ"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("add make: status = %d (%s)", rec.Code, rec.Body)
+	}
+	if rec := send("admin", http.MethodPost, "/api/admin/makes", `{"name":"B.M.W."}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate make: status = %d, want 409", rec.Code)
+	}
+	if rec := send("admin", http.MethodPost, "/api/admin/makes/1/models", `{"name":"X5","aliases":["Икс 5"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("add model: status = %d (%s)", rec.Code, rec.Body)
+	}
+	if rec := send("admin", http.MethodPost, "/api/admin/makes/9/models", `{"name":"X6"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("model of unknown make: status = %d, want 404", rec.Code)
+	}
+
+	if rec := send("", http.MethodPatch, "/api/updateCar/1", `{"mark":"бмв","model":"икс5"}`); rec.Code != http.StatusOK {
+		t.Fatalf("update car: status = %d (%s)", rec.Code, rec.Body)
+	}
+	if car, _ := repo.GetCarById(context.Background(), 1); car.Mark != "BMW" || car.Model != "X5" {
+		t.Fatalf("updated car = %s %s, want BMW X5", car.Mark, car.Model)
+	}
+
+	dictionaryService := service.NewDictionaryService(dictionary, repo)
+	report, err := dictionaryService.MapCars(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Changed != 2 || report.Updated != 0 ||
+		len(report.UnmatchedMarks) != 1 || report.UnmatchedMarks[0] != (dto.UnmatchedValue{Mark: "Kia", Count: 1}) ||
+		len(report.UnmatchedModels) != 1 || report.UnmatchedModels[0] != (dto.UnmatchedValue{Mark: "BMW", Model: "M3", Count: 1}) {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if report, err = dictionaryService.MapCars(context.Background(), false); err != nil || report.Updated != 2 {
+		t.Fatalf("map report = %+v, %v", report, err)
+	}
+	if car, _ := repo.GetCarById(context.Background(), 2); car.Mark != "BMW" || car.Model != "X5" {
+		t.Fatalf("mapped car = %s %s, want BMW X5", car.Mark, car.Model)
+	}
+}
+
+func TestStats(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77", OwnerSurname: "Иванов", OwnerName: "Иван"},
+		{Mark: "Lada", Model: "Granta", Year: 2019, RegNum: "A002AA99", OwnerSurname: "Иванов", OwnerName: "Иван"},
+		{Mark: "Kia", Model: "Rio", Year: 2019, RegNum: "C003CC77"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewCarHandler(service.NewCarService(repo, nil, carinfo.NewChain()), nil)
+	h.Stats = service.NewStatsService(repo, nil)
+	routes := router.NewRouter(h)
+
+	tests := []struct {
+		path string
+		role string
+		code int
+		want string
+	}{
+		{"/api/stats?groupBy=mark", "", http.StatusOK,
+			`{"groupBy":["mark"],"source":"live","totalCars":3,"totalGroups":2,"groups":[{"mark":"Lada","count":2},{"mark":"Kia","count":1}]}`},
+		{"/api/stats?groupBy=region,year&mark=Lada&limit=1", "", http.StatusOK,
+			`{"groupBy":["region","year"],"source":"live","totalCars":2,"totalGroups":2,"groups":[{"year":2018,"region":"77","count":1}]}`},
+		{"/api/stats?groupBy=owner", "finance", http.StatusOK,
+			`{"groupBy":["owner"],"source":"live","totalCars":3,"totalGroups":2,"groups":[{"owner":"Иванов Иван","count":2},{"count":1}]}`},
+		{"/api/stats?groupBy=mark,owner", "viewer", http.StatusForbidden, ""},
+		{"/api/stats", "", http.StatusBadRequest, ""},
+		{"/api/stats?groupBy=mark,mark", "", http.StatusBadRequest, ""},
+		{"/api/stats?groupBy=color", "", http.StatusBadRequest, ""},
+		{"/api/stats?groupBy=mark&limit=1001", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, asRole(req, tt.role))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d (%s)", tt.path, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if tt.want != "" && rec.Body.String() != tt.want {
+			t.Errorf("%s: body %s, want %s", tt.path, rec.Body, tt.want)
+		}
+	}
+}
+
+func TestVin(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "BMW", Model: "X5", Year: 2010, RegNum: "A001AA77"},
+		{Mark: "Tesla", Model: "Model 3", Year: 2018, RegNum: "B002BB77"},
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "C003CC77"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewCarHandler(service.NewCarService(repo, nil, carinfo.NewChain()), nil)
+	routes := router.NewRouter(h)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	updates := []struct {
+		id   string
+		body string
+		code int
+	}{
+		{"1", `{"vin":"wbaph7c59-be123456"}`, http.StatusOK},
+		{"2", `{"vin":"5YJ3E1EA3JF000316"}`, http.StatusBadRequest}, // check digit
+		{"2", `{"vin":"5YJ3E1EA2JF000Q16"}`, http.StatusBadRequest}, // Q is not allowed
+		{"3", `{"vin":"5YJ3E1EA2JF000316"}`, http.StatusBadRequest}, // a Tesla VIN
+		{"2", `{"vin":"5YJ3E1EA2JF000316"}`, http.StatusOK},
+		{"2", `{"year":"2012"}`, http.StatusBadRequest}, // model year 2018
+		{"3", `{"mark":"BMW","model":"X5","year":"2010","vin":"WBAPH7C59BE123456"}`, http.StatusConflict},
+	}
+	for _, tt := range updates {
+		if rec := send(http.MethodPatch, "/api/updateCar/"+tt.id, tt.body); rec.Code != tt.code {
+			t.Errorf("update %s with %s: status %d, want %d (%s)", tt.id, tt.body, rec.Code, tt.code, rec.Body)
+		}
+	}
+	if car, _ := repo.GetCarById(context.Background(), 1); car.Vin != "WBAPH7C59BE123456" {
+		t.Fatalf("stored VIN = %q, want it normalized", car.Vin)
+	}
+
+	lookups := []struct {
+		query string
+		code  int
+		ids   []int
+	}{
+		{"vin=WBAPH7C59BE123456", http.StatusOK, []int{1}},
+		{"vin=wbaph7c59be123456&mark=BMW", http.StatusOK, []int{1}},
+		{"vin=WBAPH7C59BE123456&mark=Kia", http.StatusOK, nil},
+		{"vin=XTAGFL110KY123456", http.StatusOK, nil},
+		{"vin=WBA", http.StatusBadRequest, nil},
+		{"vin=WBAPH7C59BE123456&q=bmw", http.StatusBadRequest, nil},
+	}
+	for _, tt := range lookups {
+		rec := send(http.MethodGet, "/api/getCars/?limit=10&"+tt.query, "")
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d (%s)", tt.query, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var page struct{ Cars []dto.GetFilteredCarsDto }
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, car := range page.Cars {
+			ids = append(ids, car.CarId)
+		}
+		if len(ids) != len(tt.ids) || (len(ids) == 1 && ids[0] != tt.ids[0]) {
+			t.Errorf("%s: cars %v, want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
+
+func TestCarEvents(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77", OwnerName: "Иван"},
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "C003CC77"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eventLog := repository.NewMemoryCarEventLog(repo)
+	carService := service.NewCarService(repo, nil, carinfo.NewChain())
+	events := service.NewEventService(eventLog)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go events.Follow(ctx)
+
+	h := handler.NewCarHandler(carService, nil)
+	h.Events = events
+	server := httptest.NewServer(auth.Middleware(roleKeys, nil, router.NewRouter(h)))
+	defer server.Close()
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	stream := func(query, role, lastEventId string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/cars/events"+query, nil)
+		req.Header.Set("X-API-Key", role+"-key")
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	read := func(resp *http.Response, n int) []dto.CarEventDto {
+		defer resp.Body.Close()
+		var got []dto.CarEventDto
+		scanner := bufio.NewScanner(resp.Body)
+		for len(got) < n && scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var event dto.CarEventDto
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, event)
+			}
+		}
+		if len(got) < n {
+			t.Fatalf("got %d events, want %d: %v", len(got), n, scanner.Err())
+		}
+		return got
+	}
+
+	// The stream starts from now: the response headers come after the
+	// subscription.
+	lada := stream("?mark=Lada", "viewer", "")
+	if lada.StatusCode != http.StatusOK || lada.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: %d %s", lada.StatusCode, lada.Header.Get("Content-Type"))
+	}
+	// Callers other than admin only follow the changes of their own key.
+	viewer := auth.WithIdentity(context.Background(), auth.Identity{Name: "viewer", Role: "viewer"})
+	if err := carService.UpdateCar(viewer, "1", dto.UpdateCarDto{Model: "Granta"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := carService.UpdateCar(viewer, "2", dto.UpdateCarDto{Year: "2021"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := carService.DeleteCar(viewer, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := read(lada, 2)
+	if got[0].Type != model.CarUpdated || got[0].CarId != 1 || got[0].Car.Model != "Granta" || got[1].Type != model.CarDeleted {
+		t.Fatalf("mark filter: %+v", got)
+	}
+	if got[0].Car.Owner != nil {
+		t.Fatalf("owner shown to a viewer: %+v", got[0].Car.Owner)
+	}
+
+	// Resuming replays what came after the given id, owners included for
+	// admins.
+	got = read(stream("", "admin", strconv.FormatInt(got[0].Id, 10)), 2)
+	if got[0].CarId != 2 || got[0].Car.Year != 2021 || got[1].CarId != 1 || got[1].Type != model.CarDeleted {
+		t.Fatalf("resume: %+v", got)
+	}
+	if got[1].Car.Owner == nil || got[1].Car.Owner.Name != "Иван" {
+		t.Fatalf("owner hidden from an admin: %+v", got[1].Car.Owner)
+	}
+
+	if resp := stream("?lastEventId=abc", "viewer", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid id: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestCarEventsTenantScope(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	if err := repo.AddCars(context.Background(), []model.Car{{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77"}}); err != nil {
+		t.Fatal(err)
+	}
+	carService := service.NewCarService(repo, nil, carinfo.NewChain())
+	events := service.NewEventService(repository.NewMemoryCarEventLog(repo))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go events.Follow(ctx)
+
+	for _, name := range []string{"fleet", "other"} {
+		as := auth.WithIdentity(context.Background(), auth.Identity{Name: name, Role: "editor"})
+		if err := carService.UpdateCar(as, "1", dto.UpdateCarDto{Model: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := handler.NewCarHandler(carService, nil)
+	h.Events = events
+	server := httptest.NewServer(auth.Middleware(config.AuthConfig{Enabled: true, Keys: []config.APIKey{
+		{Name: "other", Key: "other-key", Role: "editor"},
+		{Name: "root", Key: "root-key", Role: "admin"},
+	}}, nil, router.NewRouter(h)))
+	defer server.Close()
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	// The first update is all either caller gets from the start of the log.
+	first := func(key, query string) dto.CarEventDto {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/cars/events?lastEventId=0"+query, nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var event dto.CarEventDto
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatal(err)
+				}
+				return event
+			}
+		}
+		t.Fatalf("no event: %v", scanner.Err())
+		return dto.CarEventDto{}
+	}
+
+	if got := first("other-key", "&tenant=fleet"); got.Tenant != "other" {
+		t.Fatalf("editor asking for another tenant got %+v", got)
+	}
+	if got := first("root-key", "&tenant=fleet"); got.Tenant != "fleet" {
+		t.Fatalf("admin asking for fleet got %+v", got)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	eventLog := repository.NewMemoryCarEventLog(repo)
+	carService := service.NewCarService(repo, nil, carinfo.NewChain())
+	webhooks := service.NewWebhookService(repository.NewMemoryWebhookRepository(), eventLog, service.WebhookOptions{
+		Timeout:      5 * time.Second,
+		MaxAttempts:  2,
+		Backoff:      10 * time.Millisecond,
+		MaxBackoff:   20 * time.Millisecond,
+		Workers:      2,
+		AllowPrivate: true, // the receiver listens on loopback
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go webhooks.Dispatch(ctx)
+
+	var failing atomic.Bool
+	received := make(chan dto.CarEventDto, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify("s3cret", r.Header.Get(webhook.SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("delivery %s: %v", r.Header.Get(webhook.IdHeader), err)
+		}
+		if failing.Load() {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var event dto.CarEventDto
+		if err := json.Unmarshal(body, &event); err != nil || r.Header.Get(webhook.EventHeader) != event.Type {
+			t.Errorf("delivery body %s: %v", body, err)
+		}
+		received <- event
+	}))
+	defer receiver.Close()
+
+	h := handler.NewCarHandler(carService, nil)
+	h.Webhooks = webhooks
+	routes := router.NewRouter(h)
+	send := func(role, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, asRole(req, role))
+		return rec
+	}
+	receive := func(n int) []dto.CarEventDto {
+		t.Helper()
+		var got []dto.CarEventDto
+		for len(got) < n {
+			select {
+			case event := <-received:
+				got = append(got, event)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("received %d deliveries, want %d", len(got), n)
+			}
+		}
+		return got
+	}
+
+	subscription := `{"url":"` + receiver.URL + `","secret":"s3cret","events":["created","deleted"],"mark":"Lada"}`
+	if rec := send("viewer", http.MethodPost, "/api/admin/webhooks", subscription); rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer: status = %d, want 403", rec.Code)
+	}
+	if rec := send("admin", http.MethodPost, "/api/admin/webhooks", `{"url":"ftp://example.com"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid url: status = %d, want 400", rec.Code)
+	}
+	if rec := send("admin", http.MethodPost, "/api/admin/webhooks", `{"url":"https://example.com","events":["moved"]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid event: status = %d, want 400", rec.Code)
+	}
+
+	// Without AllowPrivate, internal addresses are refused.
+	strict := handler.NewCarHandler(carService, nil)
+	strict.Webhooks = service.NewWebhookService(repository.NewMemoryWebhookRepository(), eventLog, service.WebhookOptions{Timeout: time.Second})
+	for _, target := range []string{receiver.URL, "http://169.254.169.254/latest/meta-data", "http://10.0.0.1:8080"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", strings.NewReader(`{"url":"`+target+`"}`))
+		rec := httptest.NewRecorder()
+		router.NewRouter(strict).ServeHTTP(rec, asRole(req, "admin"))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("subscribing %s: status = %d, want 400", target, rec.Code)
+		}
+	}
+	rec := send("admin", http.MethodPost, "/api/admin/webhooks", subscription)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("subscribe: status = %d (%s)", rec.Code, rec.Body)
+	}
+	var sub dto.WebhookSubscriptionDto
+	if err := json.Unmarshal(rec.Body.Bytes(), &sub); err != nil || sub.Secret != "s3cret" {
+		t.Fatalf("subscription = %+v, %v", sub, err)
+	}
+	rec = send("admin", http.MethodGet, "/api/admin/webhooks", "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "s3cret") {
+		t.Fatalf("list: status = %d (%s)", rec.Code, rec.Body)
+	}
+	deliveries := "/api/admin/webhooks/" + strconv.Itoa(sub.Id) + "/deliveries"
+
+	// Only created and deleted Ladas are delivered.
+	if err := repo.AddCars(context.Background(), []model.Car{
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "C003CC77"},
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77", OwnerName: "Иван"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := carService.UpdateCar(context.Background(), "2", dto.UpdateCarDto{Model: "Granta"}); err != nil {
+		t.Fatal(err)
+	}
+	got := receive(1)
+	if got[0].Type != model.CarCreated || got[0].CarId != 2 || got[0].Car.Owner == nil || got[0].Car.Owner.Name != "Иван" {
+		t.Fatalf("delivered: %+v", got[0])
+	}
+
+	// A receiver that keeps failing runs the delivery out of attempts.
+	failing.Store(true)
+	if err := carService.DeleteCar(context.Background(), "2"); err != nil {
+		t.Fatal(err)
+	}
+	var dead []dto.WebhookDeliveryDto
+	for deadline := time.Now().Add(5 * time.Second); len(dead) == 0; time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the failing delivery did not die")
+		}
+		rec := send("admin", http.MethodGet, deliveries+"?status=dead", "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &dead); err != nil {
+			t.Fatalf("deliveries: %d %s", rec.Code, rec.Body)
+		}
+	}
+	if dead[0].EventType != model.CarDeleted || dead[0].Attempts != 2 || dead[0].LastStatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dead delivery: %+v", dead[0])
+	}
+
+	// Replaying without a body retries the dead delivery.
+	failing.Store(false)
+	rec = send("admin", http.MethodPost, "/api/admin/webhooks/"+strconv.Itoa(sub.Id)+"/replay", "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"queued":1}` {
+		t.Fatalf("replay dead: %d %s", rec.Code, rec.Body)
+	}
+	if got := receive(1); got[0].Type != model.CarDeleted || got[0].CarId != 2 {
+		t.Fatalf("replayed: %+v", got[0])
+	}
+
+	// Replaying from an event redelivers what matched since.
+	rec = send("admin", http.MethodPost, "/api/admin/webhooks/"+strconv.Itoa(sub.Id)+"/replay", `{"fromEventId":0}`)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"queued":2}` {
+		t.Fatalf("replay from 0: %d %s", rec.Code, rec.Body)
+	}
+	// Workers may deliver them in any order.
+	got = receive(2)
+	if types := got[0].Type + " " + got[1].Type; types != "created deleted" && types != "deleted created" {
+		t.Fatalf("replayed from 0: %+v", got)
+	}
+
+	if rec := send("admin", http.MethodGet, "/api/admin/webhooks/9/deliveries", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown subscription: status = %d, want 404", rec.Code)
+	}
+	if rec := send("admin", http.MethodDelete, "/api/admin/webhooks/"+strconv.Itoa(sub.Id), ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d", rec.Code)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	carService := service.NewCarService(repo, nil, carinfo.NewChain())
+	if err := carService.AddCars(context.Background(), []dto.AddCarsDto{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77", Owner: dto.People{Name: "Иван", Surname: "Иванов"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewCarHandler(carService, nil)
+	schema, err := graphqlapi.NewSchema(carService, service.NewEventService(repository.NewMemoryCarEventLog(repo)), graphqlapi.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.GraphQL = schema
+	h.Limiter = ratelimit.NewLimiter(config.RateLimitConfig{
+		Enabled: true,
+		Read:    config.BucketConfig{PerMinute: 600, Burst: 100},
+		Import:  config.BucketConfig{PerMinute: 1, Burst: 1},
+	})
+	routes := router.NewRouter(h)
+	send := func(role string, req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, asRole(req, role))
+		return rec
+	}
+
+	body := `{"query":"query Car($id: ID!) { car(id: $id) { regNum owner { surname } } }","variables":{"id":"1"}}`
+	rec := send("admin", httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"data":{"car":{"owner":{"surname":"Иванов"},"regNum":"A001AA77"}}}` {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+
+	query := url.Values{"query": {"{ cars(first: 1) { edges { node { owner { surname } } } } }"}}
+	rec = send("viewer", httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"data":{"cars":{"edges":[{"node":{"owner":null}}]}}}` {
+		t.Fatalf("GET: %d %s", rec.Code, rec.Body)
+	}
+
+	// Errors of the operation are reported in the body.
+	rec = send("", httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ car(id: 1) { color } }"}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"errors"`) {
+		t.Fatalf("invalid query: %d %s", rec.Code, rec.Body)
+	}
+
+	query = url.Values{"query": {`mutation { deleteCar(id: "1") }`}}
+	if rec := send("", httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil)); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("mutation over GET: status = %d, want 405", rec.Code)
+	}
+	// Mutations draw from the import budget, queries do not.
+	mutation := `{"query":"mutation { createCars(regNums: []) { added } }"}`
+	if rec := send("", httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(mutation))); rec.Code != http.StatusOK {
+		t.Fatalf("first mutation: %d %s", rec.Code, rec.Body)
+	}
+	if rec := send("", httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(mutation))); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("second mutation: status = %d, Retry-After = %q, want 429 and 60", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := send("admin", httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))); rec.Code != http.StatusOK {
+		t.Fatalf("query after the import budget is spent: status = %d", rec.Code)
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":`)),
+		httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{}`)),
+		httptest.NewRequest(http.MethodGet, "/graphql?query=%7Bcars%7D&variables=nope", nil),
+	} {
+		if rec := send("", req); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s %s: status = %d, want 400", req.Method, req.URL, rec.Code)
+		}
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	repo := repository.NewMemoryCarRepository()
+	carService := service.NewCarService(repo, nil, carinfo.NewChain())
+	if err := carService.AddCars(context.Background(), []dto.AddCarsDto{
+		{Mark: "Lada", Model: "Vesta", Year: 2018, RegNum: "A001AA77", Owner: dto.People{Name: "Иван", Surname: "Иванов"}},
+		{Mark: "Kia", Model: "Rio", Year: 2020, RegNum: "B002BB77", Owner: dto.People{Name: "Пётр", Surname: "Петров"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := blobstore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments := service.NewAttachmentService(repository.NewMemoryAttachmentRepository(repo), repo, blobs, service.AttachmentOptions{
+		MaxSize:      1024,
+		AllowedTypes: []string{"image/png", "application/pdf"},
+	})
+	h := handler.NewCarHandler(carService, nil)
+	h.Attachments = attachments
+	routes := router.NewRouter(h)
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+	upload := func(path, fileName string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("comment", "left side")
+		part, _ := form.CreateFormFile("file", fileName)
+		part.Write(content)
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, path, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return send(req)
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100)...)
+
+	rec := upload("/api/cars/1/attachments", "C:\\photos\\фото.png", png)
+	var created dto.AttachmentDto
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &created) != nil ||
+		created.FileName != "фото.png" || created.ContentType != "image/png" || created.Size != int64(len(png)) ||
+		rec.Header().Get("Location") != "/api/cars/1/attachments/"+strconv.Itoa(created.Id) {
+		t.Fatalf("upload: %d %s", rec.Code, rec.Body)
+	}
+	// The same content again is not stored twice.
+	var duplicate dto.AttachmentDto
+	if rec := upload("/api/cars/1/attachments", "copy.png", png); rec.Code != http.StatusOK ||
+		json.Unmarshal(rec.Body.Bytes(), &duplicate) != nil || duplicate.Id != created.Id {
+		t.Fatalf("duplicate upload: %d %s", rec.Code, rec.Body)
+	}
+	if rec := upload("/api/cars/2/attachments", "same.png", png); rec.Code != http.StatusCreated {
+		t.Fatalf("same content on another car: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		path    string
+		content []byte
+		status  int
+	}{
+		{"HTML", "/api/cars/1/attachments", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType},
+		{"too large", "/api/cars/1/attachments", append(append([]byte{}, png...), make([]byte, 1024)...), http.StatusRequestEntityTooLarge},
+		{"empty", "/api/cars/1/attachments", nil, http.StatusBadRequest},
+		{"missing car", "/api/cars/42/attachments", png, http.StatusNotFound},
+		{"invalid id", "/api/cars/one/attachments", png, http.StatusBadRequest},
+	} {
+		if rec := upload(tt.path, "file", tt.content); rec.Code != tt.status {
+			t.Fatalf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+	noFile := httptest.NewRequest(http.MethodPost, "/api/cars/1/attachments", strings.NewReader(`{}`))
+	noFile.Header.Set("Content-Type", "application/json")
+	if rec := send(noFile); rec.Code != http.StatusBadRequest {
+		t.Fatalf("JSON body: status = %d, want 400", rec.Code)
+	}
+
+	rec = send(httptest.NewRequest(http.MethodGet, "/api/cars/1/attachments", nil))
+	var list []dto.AttachmentDto
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &list) != nil || len(list) != 1 || list[0].Id != created.Id {
+		t.Fatalf("list: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = send(httptest.NewRequest(http.MethodGet, "/api/cars/1/attachments/"+strconv.Itoa(created.Id), nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), png) || rec.Header().Get("Content-Type") != "image/png" ||
+		rec.Header().Get("ETag") != `"`+created.Sha256+`"` || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("download: %d %v", rec.Code, rec.Header())
+	}
+	conditional := httptest.NewRequest(http.MethodGet, "/api/cars/1/attachments/"+strconv.Itoa(created.Id), nil)
+	conditional.Header.Set("If-None-Match", `"`+created.Sha256+`"`)
+	if rec := send(conditional); rec.Code != http.StatusNotModified {
+		t.Fatalf("conditional download: status = %d, want 304", rec.Code)
+	}
+	if rec := send(httptest.NewRequest(http.MethodGet, "/api/cars/2/attachments/"+strconv.Itoa(created.Id), nil)); rec.Code != http.StatusNotFound {
+		t.Fatalf("attachment through another car: status = %d, want 404", rec.Code)
+	}
+
+	// The single car routes must not shadow export and events.
+	if rec := send(httptest.NewRequest(http.MethodGet, "/api/cars/export", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("export: status = %d, want 200", rec.Code)
+	}
+
+	if rec := send(httptest.NewRequest(http.MethodDelete, "/api/cars/1/attachments/"+strconv.Itoa(created.Id), nil)); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d, want 204", rec.Code)
+	}
+	if rec := send(httptest.NewRequest(http.MethodDelete, "/api/cars/1/attachments/"+strconv.Itoa(created.Id), nil)); rec.Code != http.StatusNotFound {
+		t.Fatalf("second delete: status = %d, want 404", rec.Code)
+	}
+
+	// The content is still attached to the second car; once that car is
+	// gone, the sweep deletes it.
+	if swept, err := attachments.SweepBlobs(context.Background(), 0); err != nil || swept != 0 {
+		t.Fatalf("sweep of a shared blob = %d, %v", swept, err)
+	}
+	if err := carService.DeleteCar(context.Background(), "2"); err != nil {
+		t.Fatal(err)
+	}
+	if swept, err := attachments.SweepBlobs(context.Background(), time.Hour); err != nil || swept != 0 {
+		t.Fatalf("sweep within the grace period = %d, %v", swept, err)
+	}
+	if swept, err := attachments.SweepBlobs(context.Background(), 0); err != nil || swept != 1 {
+		t.Fatalf("sweep of an unreferenced blob = %d, %v, want 1", swept, err)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	h := handler.NewCarHandler(service.NewCarService(repository.NewMemoryCarRepository(), nil, carinfo.NewChain()), nil)
+	routes := router.NewRouter(h)
+
+	for role, want := range map[string]int{"admin": http.StatusOK, "finance": http.StatusForbidden, "": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/cache-stats", nil)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, asRole(req, role))
+		if rec.Code != want {
+			t.Fatalf("role %q: status = %d, want %d", role, rec.Code, want)
+		}
+		if want != http.StatusOK {
+			continue
+		}
+		var stats map[string]int64
+		if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := stats["upstream_errors"]; !ok || len(stats) != 7 {
+			t.Fatalf("stats = %v", stats)
+		}
+	}
+
+	// A role claimed in a header is not an identity.
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/cache-stats", nil)
+	req.Header.Set("X-Role", "admin")
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("X-Role without a key: status = %d, want 403", rec.Code)
+	}
+
+	// The process metrics are not served anymore.
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("/debug/vars: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"car_catalog/internal/export"
+	"car_catalog/internal/importer"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// @Summary Import cars
+// @Description Bulk load complete car records from CSV or NDJSON without the external API. Every row is validated and its registration number normalized; rejected rows are listed in the report.
+// @Tags cars
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "Import format (csv or ndjson), taken from Content-Type when omitted"
+// @Param dryRun query bool false "Validate only, do not write anything"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} dto.ImportReport "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 413 {string} string "Request Entity Too Large"
+// @Failure 429 {string} string "Too Many Requests"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/import [post]
+func (c *CarHandler) ImportCars(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - ImportCars - Received POST request")
+
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" && strings.Contains(r.Header.Get("Content-Type"), "ndjson") {
+		formatName = string(export.FormatNDJSON)
+	}
+	format, err := export.ParseFormat(formatName)
+	if err != nil {
+		log.Printf("[ERROR] Handler - ImportCars - %v", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	log.Printf("[DEBUG] Handler - ImportCars - Format: %s, DryRun: %t", format, dryRun)
+
+	rows, err := importer.Parse(format, r.Body)
+	if err != nil {
+		log.Printf("[ERROR] Handler - ImportCars - Unable to parse upload: %v", err)
+		badBody(w, err)
+		return
+	}
+
+	report, err := c.CarService.ImportCars(r.Context(), rows, dryRun)
+	if err != nil {
+		log.Printf("[ERROR] Handler - ImportCars - Unable to import cars: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("[ERROR] Handler - ImportCars - Unable to encode JSON: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse)
+}
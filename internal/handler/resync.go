@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/repository"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultDivergenceLimit = 50
+	maxDivergenceLimit     = 1000
+)
+
+// @Summary Resync a car
+// @Description Compare a car with the external registry now, record the differences and apply the registry values
+// @Tags sync
+// @Produce json
+// @Param id path string true "Car ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} dto.ResyncResult "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 502 {string} string "Bad Gateway"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/cars/{id}/resync [post]
+func (c *CarHandler) ResyncCar(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	log.Printf("[INFO] Handler - ResyncCar - Received POST request for car ID: %s", p.ByName("id"))
+
+	result, err := c.Resync.ResyncCar(r.Context(), p.ByName("id"))
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &numErr):
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	case errors.Is(err, repository.ErrCarNotFound):
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	case errors.Is(err, carinfo.ErrUnavailable), errors.Is(err, carinfo.ErrRejected), errors.Is(err, carinfo.ErrInvalidResponse):
+		log.Printf("[ERROR] Handler - ResyncCar - Registry lookup failed: %v", err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Handler - ResyncCar - Unable to resync car: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// @Summary List sync divergences
+// @Description Differences between the catalog and the registry found by resyncs, newest first
+// @Tags sync
+// @Produce json
+// @Param carId query string false "Only divergences of this car"
+// @Param limit query int false "Results limit" default(50)
+// @Success 200 {array} dto.DivergenceDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/sync/divergences [get]
+func (c *CarHandler) GetDivergences(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - GetDivergences - Received GET request")
+
+	limit := defaultDivergenceLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxDivergenceLimit {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	divergences, err := c.Resync.GetDivergences(r.Context(), r.URL.Query().Get("carId"), limit)
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Handler - GetDivergences - Unable to list divergences: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, divergences)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	jsonResponse, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("[ERROR] Handler - writeJSON - Unable to encode JSON: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
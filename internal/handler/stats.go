@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/service"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// @Summary Catalog statistics
+// @Description Count cars per distinct combination of the groupBy dimensions, most cars first. Region is the region code of the plate, empty for malformed plates. Grouping by owner needs an admin or finance role. With stats.materialized, queries without owner and q read a periodically refreshed snapshot (source "materialized").
+// @Tags cars
+// @Produce json
+// @Param groupBy query string true "Comma-separated dimensions: mark, model, year, region, owner"
+// @Param mark query string false "Filter by mark"
+// @Param model query string false "Filter by model"
+// @Param year query int false "Filter by year"
+// @Param q query string false "Fuzzy search, as in getCars"
+// @Param limit query int false "Groups limit, at most 1000" default(100)
+// @Success 200 {object} dto.StatsDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/stats [get]
+func (c *CarHandler) GetStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Println("[INFO] Handler - GetStats - Received GET request")
+
+	var groupBy []string
+	for _, dimension := range strings.Split(r.URL.Query().Get("groupBy"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			groupBy = append(groupBy, dimension)
+		}
+	}
+	for _, dimension := range groupBy {
+		if dimension == model.StatsByOwner && !ownerViewerRoles[roleFromRequest(r)] {
+			log.Printf("[INFO] Handler - GetStats - Owner grouping denied for role %q", roleFromRequest(r))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	filters := dto.Filters{
+		Mark:  r.URL.Query().Get("mark"),
+		Model: r.URL.Query().Get("model"),
+		Year:  r.URL.Query().Get("year"),
+		Limit: r.URL.Query().Get("limit"),
+		Query: strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+	log.Printf("[DEBUG] Handler - GetStats - Group by: %v, Filters: %+v", groupBy, filters)
+
+	stats, err := c.Stats.CarStats(r.Context(), groupBy, filters)
+	switch {
+	case errors.Is(err, service.ErrInvalidStats):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Handler - GetStats - Unable to get statistics: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/service"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const defaultSuggestLimit = 10
+
+// @Summary Suggest marks
+// @Description Distinct car marks starting with the prefix, case-insensitively, most common first. API keys other than admin only see the cars they added.
+// @Tags suggest
+// @Produce json
+// @Param prefix query string true "Mark prefix, at least 1 character"
+// @Param limit query int false "Results limit" default(10)
+// @Success 200 {array} dto.SuggestionDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Service Unavailable"
+// @Router /api/suggest/marks [get]
+func (c *CarHandler) SuggestMarks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c.suggest(w, r, "SuggestMarks", func(ctx context.Context, limit int) ([]dto.SuggestionDto, error) {
+		return c.CarService.SuggestMarks(ctx, r.URL.Query().Get("prefix"), limit)
+	})
+}
+
+// @Summary Suggest models
+// @Description Distinct car models starting with the prefix, case-insensitively, most common first. API keys other than admin only see the cars they added.
+// @Tags suggest
+// @Produce json
+// @Param mark query string false "Only models of this mark, case-insensitive"
+// @Param prefix query string false "Model prefix, at least 1 character unless mark is given"
+// @Param limit query int false "Results limit" default(10)
+// @Success 200 {array} dto.SuggestionDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Service Unavailable"
+// @Router /api/suggest/models [get]
+func (c *CarHandler) SuggestModels(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c.suggest(w, r, "SuggestModels", func(ctx context.Context, limit int) ([]dto.SuggestionDto, error) {
+		return c.CarService.SuggestModels(ctx, r.URL.Query().Get("mark"), r.URL.Query().Get("prefix"), limit)
+	})
+}
+
+// @Summary Suggest registration numbers
+// @Description Registration numbers starting with the prefix, normalized like stored plates. API keys other than admin only see the cars they added.
+// @Tags suggest
+// @Produce json
+// @Param prefix query string true "Plate prefix, at least 2 characters"
+// @Param limit query int false "Results limit" default(10)
+// @Success 200 {array} dto.SuggestionDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Service Unavailable"
+// @Router /api/suggest/regnums [get]
+func (c *CarHandler) SuggestRegNums(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c.suggest(w, r, "SuggestRegNums", func(ctx context.Context, limit int) ([]dto.SuggestionDto, error) {
+		return c.CarService.SuggestRegNums(ctx, r.URL.Query().Get("prefix"), limit)
+	})
+}
+
+// suggest parses the limit, runs fn within the suggest latency budget and
+// writes the result. A typeahead answer that comes late is useless, so an
+// exceeded budget is reported as 503 rather than waited for.
+func (c *CarHandler) suggest(w http.ResponseWriter, r *http.Request, name string, fn func(ctx context.Context, limit int) ([]dto.SuggestionDto, error)) {
+	log.Printf("[INFO] Handler - %s - Received GET request", name)
+
+	limit := defaultSuggestLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || (c.MaxSuggestLimit > 0 && n > c.MaxSuggestLimit) {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx := r.Context()
+	if c.SuggestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.SuggestTimeout)
+		defer cancel()
+	}
+
+	suggestions, err := fn(ctx, limit)
+	switch {
+	case errors.Is(err, service.ErrInvalidSuggest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[ERROR] Handler - %s - Latency budget exceeded: %v", name, err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("[ERROR] Handler - %s - Unable to get suggestions: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Suggestions are per caller and change slowly; let the browser reuse
+	// them while the user keeps typing.
+	w.Header().Set("Cache-Control", "private, max-age=30")
+	writeJSON(w, http.StatusOK, suggestions)
+}
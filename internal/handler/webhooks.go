@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// webhookError maps a webhook service error to a response.
+func webhookError(w http.ResponseWriter, funcName string, err error) {
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &numErr):
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidWebhook):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrSubscriptionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("[ERROR] Handler - %s - %v", funcName, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// @Summary Subscribe a webhook
+// @Description Deliver the change feed events recorded from now on to url. Each event is POSTed as a dto.CarEventDto, owner included, with X-Webhook-Id (the delivery id, the same on every attempt), X-Webhook-Event and X-Webhook-Signature "t=<unix>,v1=<hex HMAC-SHA256 of t.body keyed with the secret>". Any status but 2xx is retried with exponential backoff; after webhooks.max_attempts the delivery is dead until replayed. The url must lead to a public address; loopback, private and link-local ones are refused unless webhooks.allow_private is set. The secret is generated when omitted and only returned here. Admin only.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param subscription body dto.WebhookSubscriptionRequest true "Subscription"
+// @Success 201 {object} dto.WebhookSubscriptionDto "Created"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/webhooks [post]
+func (c *CarHandler) AddWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !requireAdmin(w, r, "AddWebhook") {
+		return
+	}
+	var req dto.WebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Handler - AddWebhook - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	created, err := c.Webhooks.Subscribe(r.Context(), req)
+	if err != nil {
+		webhookError(w, "AddWebhook", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// @Summary List webhooks
+// @Description Every webhook subscription, without secrets. Admin only.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} dto.WebhookSubscriptionDto "OK"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/webhooks [get]
+func (c *CarHandler) ListWebhooks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !requireAdmin(w, r, "ListWebhooks") {
+		return
+	}
+	subs, err := c.Webhooks.ListSubscriptions(r.Context())
+	if err != nil {
+		webhookError(w, "ListWebhooks", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+// @Summary Delete a webhook
+// @Description Delete a subscription with its deliveries. Admin only.
+// @Tags webhooks
+// @Param id path int true "Subscription ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/webhooks/{id} [delete]
+func (c *CarHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "DeleteWebhook") {
+		return
+	}
+	if err := c.Webhooks.DeleteSubscription(r.Context(), p.ByName("id")); err != nil {
+		webhookError(w, "DeleteWebhook", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary List webhook deliveries
+// @Description The latest deliveries of a subscription, newest first. Admin only.
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param status query string false "pending, delivered or dead"
+// @Param limit query int false "Deliveries to return, at most 500" default(50)
+// @Success 200 {array} dto.WebhookDeliveryDto "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/webhooks/{id}/deliveries [get]
+func (c *CarHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "ListWebhookDeliveries") {
+		return
+	}
+	query := r.URL.Query()
+	deliveries, err := c.Webhooks.ListDeliveries(r.Context(), p.ByName("id"), query.Get("status"), query.Get("limit"))
+	if err != nil {
+		webhookError(w, "ListWebhookDeliveries", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// @Summary Replay webhook deliveries
+// @Description Without a body, queue the dead deliveries of a subscription again with a fresh attempt budget. With fromEventId, queue every event after it that is still within events.retention and matches the subscription, delivered before or not. Admin only.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param replay body dto.WebhookReplayRequest false "Replay"
+// @Success 200 {object} dto.WebhookReplayResult "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /api/admin/webhooks/{id}/replay [post]
+func (c *CarHandler) ReplayWebhook(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if !requireAdmin(w, r, "ReplayWebhook") {
+		return
+	}
+	var req dto.WebhookReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("[ERROR] Handler - ReplayWebhook - Unable to decode JSON: %v", err)
+		badBody(w, err)
+		return
+	}
+	result, err := c.Webhooks.Replay(r.Context(), p.ByName("id"), req)
+	if err != nil {
+		webhookError(w, "ReplayWebhook", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,324 @@
+// Package idempotency makes retries of mutating requests safe: a request sent
+// with an Idempotency-Key header is executed once and its response is
+// replayed for every retry with the same key.
+package idempotency
+
+import (
+	"bytes"
+	"car_catalog/internal/auth"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+	maxKeyLength   = 255
+)
+
+// ErrNotReserved is returned by Store.Renew, Store.Complete and
+// Store.Release when the key is no longer reserved, e.g. because the reservation expired meanwhile.
+var ErrNotReserved = errors.New("idempotency key is not reserved")
+
+// Record is a stored request. Status is zero while the first request with
+// the key is still being processed.
+type Record struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Store keeps records until they expire.
+type Store interface {
+	// Reserve stores rec unless an unexpired record with the same scope and
+	// key exists; that record is returned instead, with reserved false.
+	Reserve(ctx context.Context, rec Record) (existing Record, reserved bool, err error)
+	// Renew moves the expiry of a reserved key to rec.ExpiresAt.
+	Renew(ctx context.Context, rec Record) error
+	// Complete saves the response of a reserved key and extends its expiry.
+	Complete(ctx context.Context, rec Record) error
+	// Release drops a reservation, so the request may be retried.
+	Release(ctx context.Context, scope, key string) error
+	// Purge deletes expired records.
+	Purge(ctx context.Context) (int64, error)
+}
+
+type Options struct {
+	// TTL is how long a completed response is replayed.
+	TTL time.Duration
+	// LockTimeout bounds a reservation whose request never completes, e.g.
+	// because the instance crashed, so the key does not stay locked for TTL.
+	// The reservation is renewed while the request runs, so a request may
+	// take longer than that.
+	LockTimeout time.Duration
+}
+
+// Middleware applies idempotency keys to POST, PUT, PATCH and DELETE requests
+// that carry the header; everything else passes through. Keys are scoped to
+// the authenticated caller and fingerprinted by method, path, query and body:
+//   - a retry with the same fingerprint gets the stored response replayed;
+//   - reusing a key for a different request is rejected with 422;
+//   - a retry while the first request is still running gets 409.
+//
+// 5xx and 429 responses are not stored, so a request that failed on our side
+// or was turned away by a rate limit can be retried with the same key.
+func Middleware(store Store, opts Options, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || !mutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("[ERROR] Idempotency - Unable to read request body: %v", err)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := Record{
+			Scope:       scope(r.Context()),
+			Key:         key,
+			Fingerprint: fingerprint(r, body),
+			ExpiresAt:   time.Now().Add(opts.LockTimeout),
+		}
+		existing, reserved, err := store.Reserve(r.Context(), rec)
+		if err != nil {
+			log.Printf("[ERROR] Idempotency - Unable to reserve key %q: %v", key, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !reserved {
+			replay(w, existing, rec.Fingerprint)
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		stopRenewing := keepReserved(store, rec, opts.LockTimeout)
+		defer func() {
+			// A panicking handler must not keep the key locked.
+			if p := recover(); p != nil {
+				stopRenewing()
+				release(store, rec)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+		stopRenewing()
+
+		if rw.status >= http.StatusInternalServerError || rw.status == http.StatusTooManyRequests {
+			release(store, rec)
+			return
+		}
+		rec.Status = rw.status
+		rec.Header = w.Header().Clone()
+		rec.Body = rw.body.Bytes()
+		rec.ExpiresAt = time.Now().Add(opts.TTL)
+		if err := store.Complete(context.WithoutCancel(r.Context()), rec); err != nil {
+			log.Printf("[ERROR] Idempotency - Unable to store response for key %q: %v", key, err)
+		}
+	})
+}
+
+func replay(w http.ResponseWriter, existing Record, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		log.Printf("[INFO] Idempotency - Key %q reused with a different request", existing.Key)
+		http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+	case existing.Status == 0:
+		log.Printf("[INFO] Idempotency - Key %q is still being processed", existing.Key)
+		http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+	default:
+		log.Printf("[DEBUG] Idempotency - Replaying response for key %q", existing.Key)
+		for name, values := range existing.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(existing.Status)
+		w.Write(existing.Body)
+	}
+}
+
+// keepReserved renews the reservation of rec every half lock timeout until
+// the returned function is called. Without it a request running longer than
+// the lock timeout would let a retry with the same key execute it again.
+func keepReserved(store Store, rec Record, lockTimeout time.Duration) (stop func()) {
+	if lockTimeout <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTimeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rec.ExpiresAt = time.Now().Add(lockTimeout)
+				if err := store.Renew(context.Background(), rec); err != nil {
+					log.Printf("[ERROR] Idempotency - Unable to renew key %q: %v", rec.Key, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func release(store Store, rec Record) {
+	if err := store.Release(context.Background(), rec.Scope, rec.Key); err != nil {
+		log.Printf("[ERROR] Idempotency - Unable to release key %q: %v", rec.Key, err)
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// scope separates the keys of different API clients; without auth all
+// callers share one scope.
+func scope(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return identity.Name
+	}
+	return ""
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.Path+"\n"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes the response through and keeps a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// MemoryStore keeps records in process memory. It is used with storage
+// drivers other than postgres, where keys do not survive a restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[[2]string]Record
+}
+
+func NewMemoryStore() Store {
+	return &MemoryStore{records: make(map[[2]string]Record)}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, rec Record) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{rec.Scope, rec.Key}
+	if existing, ok := s.records[id]; ok && existing.ExpiresAt.After(time.Now()) {
+		return existing, false, nil
+	}
+	s.records[id] = rec
+	return Record{}, true, nil
+}
+
+func (s *MemoryStore) Renew(ctx context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{rec.Scope, rec.Key}
+	existing, ok := s.records[id]
+	if !ok || existing.Status != 0 || existing.Fingerprint != rec.Fingerprint {
+		return ErrNotReserved
+	}
+	existing.ExpiresAt = rec.ExpiresAt
+	s.records[id] = existing
+	return nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{rec.Scope, rec.Key}
+	existing, ok := s.records[id]
+	if !ok || existing.Status != 0 || existing.Fingerprint != rec.Fingerprint {
+		return ErrNotReserved
+	}
+	s.records[id] = rec
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{scope, key}
+	if existing, ok := s.records[id]; !ok || existing.Status != 0 {
+		return ErrNotReserved
+	}
+	delete(s.records, id)
+	return nil
+}
+
+func (s *MemoryStore) Purge(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	now := time.Now()
+	for id, rec := range s.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(s.records, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
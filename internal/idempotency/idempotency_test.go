@@ -0,0 +1,157 @@
+package idempotency_test
+
+import (
+	"car_catalog/internal/idempotency"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var (
+		calls  atomic.Int32
+		status = http.StatusOK
+	)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"call":` + strconv.Itoa(int(n)) + `}`))
+	})
+	h := idempotency.Middleware(idempotency.NewMemoryStore(), idempotency.Options{TTL: time.Hour, LockTimeout: time.Minute}, next)
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/addCars", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("k1", `{"regNums":["A123BC77"]}`)
+	retry := send("k1", `{"regNums":["A123BC77"]}`)
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() || retry.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("replay = %d %q, want %d %q", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Fatal("replayed response is not marked")
+	}
+
+	if rec := send("k1", `{"regNums":["B222BB77"]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("conflicting payload: status = %d, want 422", rec.Code)
+	}
+
+	send("", `{}`)
+	send("", `{}`)
+	if calls.Load() != 3 {
+		t.Fatalf("requests without a key must always run, calls = %d", calls.Load())
+	}
+
+	status = http.StatusInternalServerError
+	send("k2", `{}`)
+	status = http.StatusOK
+	if rec := send("k2", `{}`); rec.Code != http.StatusOK || calls.Load() != 5 {
+		t.Fatalf("retry after a 5xx must run again: status = %d, calls = %d", rec.Code, calls.Load())
+	}
+
+	status = http.StatusTooManyRequests
+	send("k3", `{}`)
+	status = http.StatusOK
+	if rec := send("k3", `{}`); rec.Code != http.StatusOK || calls.Load() != 7 {
+		t.Fatalf("retry after a 429 must run again: status = %d, calls = %d", rec.Code, calls.Load())
+	}
+}
+
+func TestMiddlewareInProgress(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	h := idempotency.Middleware(idempotency.NewMemoryStore(), idempotency.Options{TTL: time.Hour, LockTimeout: time.Minute}, next)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPatch, "/api/updateCar/1", strings.NewReader(`{"year":"2020"}`))
+		req.Header.Set(idempotency.Header, "k")
+		return req
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), newRequest())
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest())
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("concurrent retry: status = %d, want 409", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestMiddlewareRenewsLock(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	lockTimeout := 20 * time.Millisecond
+	h := idempotency.Middleware(idempotency.NewMemoryStore(), idempotency.Options{TTL: time.Hour, LockTimeout: lockTimeout}, next)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/cars", strings.NewReader(`{"regNums":["A123BC77"]}`))
+		req.Header.Set(idempotency.Header, "k")
+		return req
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), newRequest())
+		close(done)
+	}()
+	<-started
+
+	// The first request outlives its lock timeout several times over; the
+	// retry must still find the key taken.
+	time.Sleep(5 * lockTimeout)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newRequest())
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("retry of a long request: status = %d, want 409", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := idempotency.NewMemoryStore()
+	rec := idempotency.Record{Key: "k", Fingerprint: "a", ExpiresAt: time.Now().Add(-time.Second)}
+	if _, reserved, _ := store.Reserve(ctx, rec); !reserved {
+		t.Fatal("first reservation failed")
+	}
+
+	rec.Fingerprint = "b"
+	rec.ExpiresAt = time.Now().Add(time.Minute)
+	if _, reserved, _ := store.Reserve(ctx, rec); !reserved {
+		t.Fatal("an expired key must be reusable")
+	}
+	if purged, _ := store.Purge(ctx); purged != 0 {
+		t.Fatalf("purged %d live keys", purged)
+	}
+}
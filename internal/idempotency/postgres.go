@@ -0,0 +1,129 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps records in cars.idempotency_key, so retries are
+// recognized across restarts and by every instance sharing the database.
+type PostgresStore struct {
+	conn *pgxpool.Pool
+}
+
+func NewPostgresStore(conn *pgxpool.Pool) Store {
+	return &PostgresStore{conn: conn}
+}
+
+func (s *PostgresStore) Reserve(ctx context.Context, rec Record) (Record, bool, error) {
+	// An expired row is taken over in place; a live one is left untouched
+	// and RETURNING yields no row.
+	reserve := `INSERT INTO cars.idempotency_key (scope, key, fingerprint, expires_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (scope, key) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint, status = NULL, header = NULL, body = NULL,
+		created_at = now(), expires_at = EXCLUDED.expires_at
+	WHERE cars.idempotency_key.expires_at <= now()
+	RETURNING key`
+
+	lookup := `SELECT fingerprint, status, header, body, expires_at
+	FROM cars.idempotency_key
+	WHERE scope = $1 AND key = $2`
+
+	// The existing row may expire and be purged between the two statements;
+	// one more attempt then reserves the key.
+	for attempt := 0; attempt < 2; attempt++ {
+		var key string
+		err := s.conn.QueryRow(ctx, reserve, rec.Scope, rec.Key, rec.Fingerprint, rec.ExpiresAt).Scan(&key)
+		if err == nil {
+			return Record{}, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return Record{}, false, err
+		}
+
+		existing := Record{Scope: rec.Scope, Key: rec.Key}
+		var (
+			status *int
+			header []byte
+		)
+		err = s.conn.QueryRow(ctx, lookup, rec.Scope, rec.Key).
+			Scan(&existing.Fingerprint, &status, &header, &existing.Body, &existing.ExpiresAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		if status != nil {
+			existing.Status = *status
+		}
+		if header != nil {
+			if err := json.Unmarshal(header, &existing.Header); err != nil {
+				return Record{}, false, err
+			}
+		}
+		return existing, false, nil
+	}
+	return Record{}, false, errors.New("idempotency key changed concurrently")
+}
+
+func (s *PostgresStore) Renew(ctx context.Context, rec Record) error {
+	query := `UPDATE cars.idempotency_key
+	SET expires_at = $4
+	WHERE scope = $1 AND key = $2 AND fingerprint = $3 AND status IS NULL`
+
+	tag, err := s.conn.Exec(ctx, query, rec.Scope, rec.Key, rec.Fingerprint, rec.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, rec Record) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE cars.idempotency_key
+	SET status = $4, header = $5, body = $6, expires_at = $7
+	WHERE scope = $1 AND key = $2 AND fingerprint = $3 AND status IS NULL`
+
+	tag, err := s.conn.Exec(ctx, query, rec.Scope, rec.Key, rec.Fingerprint, rec.Status, header, rec.Body, rec.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM cars.idempotency_key WHERE scope = $1 AND key = $2 AND status IS NULL`
+
+	tag, err := s.conn.Exec(ctx, query, scope, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
+
+func (s *PostgresStore) Purge(ctx context.Context) (int64, error) {
+	tag, err := s.conn.Exec(ctx, `DELETE FROM cars.idempotency_key WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"bufio"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/export"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLineBytes bounds a single NDJSON record.
+const maxLineBytes = 1 << 20
+
+var requiredColumns = []string{"mark", "model", "year", "reg_num"}
+
+// columnAliases maps accepted CSV header spellings to canonical column names,
+// so files produced by the export endpoint can be imported back as is.
+var columnAliases = map[string]string{
+	"mark":             "mark",
+	"model":            "model",
+	"year":             "year",
+	"reg_num":          "reg_num",
+	"regnum":           "reg_num",
+	"vin":              "vin",
+	"owner_name":       "owner_name",
+	"owner_surname":    "owner_surname",
+	"owner_patronymic": "owner_patronymic",
+}
+
+// Parse reads every record of an import file. Records that cannot be parsed
+// are returned with Error set instead of aborting the whole file; an error is
+// returned only when the file as a whole is unreadable.
+func Parse(format export.Format, r io.Reader) ([]dto.ImportRow, error) {
+	switch format {
+	case export.FormatCSV:
+		return parseCSV(r)
+	case export.FormatNDJSON:
+		return parseNDJSON(r)
+	}
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+func parseCSV(r io.Reader) ([]dto.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if canonical, ok := columnAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[canonical] = i
+		}
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []dto.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, dto.ImportRow{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := dto.ImportRow{
+			Line: line,
+			Car: dto.ImportCarDto{
+				Mark:   field(record, "mark"),
+				Model:  field(record, "model"),
+				RegNum: field(record, "reg_num"),
+				Vin:    field(record, "vin"),
+				Owner: dto.People{
+					Name:       field(record, "owner_name"),
+					Surname:    field(record, "owner_surname"),
+					Patronymic: field(record, "owner_patronymic"),
+				},
+			},
+		}
+		if year := field(record, "year"); year != "" {
+			row.Car.Year, err = strconv.Atoi(year)
+			if err != nil {
+				row.Error = fmt.Sprintf("invalid year %q", year)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseNDJSON(r io.Reader) ([]dto.ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	var rows []dto.ImportRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := dto.ImportRow{Line: line}
+		if err := json.Unmarshal([]byte(text), &row.Car); err != nil {
+			row.Error = fmt.Sprintf("invalid JSON: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,128 @@
+package importer_test
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/export"
+	"car_catalog/internal/importer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format export.Format
+		input  string
+		want   []dto.ImportRow
+	}{
+		{
+			name:   "csv export round trip",
+			format: export.FormatCSV,
+			input: "id,mark,model,year,reg_num,vin,owner_name,owner_surname,owner_patronymic\n" +
+				"1,Lada,\"Vesta, SW\",2020,A123BC77,,Иван,Иванов,\n",
+			want: []dto.ImportRow{{Line: 2, Car: dto.ImportCarDto{
+				Mark: "Lada", Model: "Vesta, SW", Year: 2020, RegNum: "A123BC77",
+				Owner: dto.People{Name: "Иван", Surname: "Иванов"},
+			}}},
+		},
+		{
+			name:   "csv header aliases and spacing",
+			format: export.FormatCSV,
+			input:  " RegNum , Mark,Model,YEAR,comment\nA123BC77, Kia ,Rio,2019,ignored\n",
+			want: []dto.ImportRow{{Line: 2, Car: dto.ImportCarDto{
+				Mark: "Kia", Model: "Rio", Year: 2019, RegNum: "A123BC77",
+			}}},
+		},
+		{
+			name:   "csv short record and empty year",
+			format: export.FormatCSV,
+			input:  "mark,model,year,reg_num,vin\nLada,Niva,,B456CD77\n",
+			want: []dto.ImportRow{{Line: 2, Car: dto.ImportCarDto{
+				Mark: "Lada", Model: "Niva", RegNum: "B456CD77",
+			}}},
+		},
+		{
+			name:   "csv bad rows are reported, not fatal",
+			format: export.FormatCSV,
+			input: "mark,model,year,reg_num\n" +
+				"Lada,Vesta,twenty,A123BC77\n" +
+				"Lada,Ve\"sta,2020,B456CD77\n" +
+				"Kia,Rio,2019,C789EE77\n",
+			want: []dto.ImportRow{
+				{Line: 2, Car: dto.ImportCarDto{Mark: "Lada", Model: "Vesta", RegNum: "A123BC77"}, Error: `invalid year "twenty"`},
+				{Line: 3, Error: `bare " in non-quoted-field`},
+				{Line: 4, Car: dto.ImportCarDto{Mark: "Kia", Model: "Rio", Year: 2019, RegNum: "C789EE77"}},
+			},
+		},
+		{
+			name:   "ndjson skips blank lines and keeps line numbers",
+			format: export.FormatNDJSON,
+			input: `{"mark":"Lada","model":"Vesta","year":2020,"regNum":"A123BC77","owner":{"name":"Иван","surname":"Иванов"}}` + "\n" +
+				"\n" +
+				`{"mark":"Kia","model":"Rio","year":2019,"regNum":"B456CD77","vin":"5YJ3E1EA2JF000316"}` + "\n",
+			want: []dto.ImportRow{
+				{Line: 1, Car: dto.ImportCarDto{
+					Mark: "Lada", Model: "Vesta", Year: 2020, RegNum: "A123BC77",
+					Owner: dto.People{Name: "Иван", Surname: "Иванов"},
+				}},
+				{Line: 3, Car: dto.ImportCarDto{Mark: "Kia", Model: "Rio", Year: 2019, RegNum: "B456CD77", Vin: "5YJ3E1EA2JF000316"}},
+			},
+		},
+		{
+			name:   "ndjson bad rows are reported, not fatal",
+			format: export.FormatNDJSON,
+			input:  "{\"mark\":\"Lada\"\n" + `{"mark":"Kia","year":"2019"}` + "\n",
+			want: []dto.ImportRow{
+				{Line: 1, Error: "invalid JSON: "},
+				{Line: 2, Car: dto.ImportCarDto{Mark: "Kia"}, Error: "invalid JSON: "},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := importer.Parse(tt.format, strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !sameRows(got, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// sameRows compares rows with the wanted errors as prefixes, since the
+// details come from encoding/json.
+func sameRows(got, want []dto.ImportRow) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !strings.HasPrefix(got[i].Error, want[i].Error) || (got[i].Error == "") != (want[i].Error == "") {
+			return false
+		}
+		g, w := got[i], want[i]
+		g.Error, w.Error = "", ""
+		if !reflect.DeepEqual(g, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseRejectsFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format export.Format
+		input  string
+	}{
+		{"empty csv", export.FormatCSV, ""},
+		{"csv without reg_num", export.FormatCSV, "mark,model,year\nLada,Vesta,2020\n"},
+		{"ndjson line too long", export.FormatNDJSON, `{"mark":"` + strings.Repeat("a", 1<<20) + `"}`},
+		{"unknown format", "xlsx", "mark,model,year,reg_num\n"},
+	}
+	for _, tt := range tests {
+		if _, err := importer.Parse(tt.format, strings.NewReader(tt.input)); err == nil {
+			t.Errorf("%s: want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"car_catalog/internal/config"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+var levels = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"error": 2,
+}
+
+var tags = map[string]int{
+	"[DEBUG]": 0,
+	"[INFO]":  1,
+	"[ERROR]": 2,
+}
+
+// Setup points the standard logger at the configured output and drops lines
+// tagged below the configured level. Untagged lines are always written.
+func Setup(cfg config.LoggingConfig) error {
+	var out io.Writer
+	switch cfg.Output {
+	case "", "stderr":
+		out = os.Stderr
+	case "stdout":
+		out = os.Stdout
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("unable to open log file: %w", err)
+		}
+		out = file
+	}
+
+	min, ok := levels[cfg.Level]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", cfg.Level)
+	}
+
+	log.SetOutput(&levelWriter{out: out, min: min})
+	return nil
+}
+
+type levelWriter struct {
+	out io.Writer
+	min int
+}
+
+// Write receives one complete line per log call, with the tag right after
+// the timestamp prefix.
+func (w *levelWriter) Write(p []byte) (int, error) {
+	start := bytes.IndexByte(p, '[')
+	if start >= 0 {
+		if end := bytes.IndexByte(p[start:], ']'); end > 0 {
+			if level, ok := tags[string(p[start:start+end+1])]; ok && level < w.min {
+				return len(p), nil
+			}
+		}
+	}
+	return w.out.Write(p)
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Attachment is a file attached to a car, such as a photo or a registration
+// scan. The content is kept in the blob store under Sha256, the hex SHA-256
+// of it, once however many attachments share it.
+type Attachment struct {
+	Id          int
+	CarId       int
+	FileName    string
+	ContentType string
+	Size        int64
+	Sha256      string
+	CreatedAt   time.Time
+}
@@ -1,12 +1,55 @@
 package model
 
+import "time"
+
 type Car struct {
-	CarId           int
-	Mark            string
-	Model           string
-	Year            int
-	RegNum          string
+	CarId  int
+	Mark   string
+	Model  string
+	Year   int
+	RegNum string
+	// Vin is normalized and validated by the service; empty when unknown.
+	Vin             string
 	OwnerName       string
 	OwnerSurname    string
 	OwnerPatronymic string
+	// LastSyncedAt is when the car was last compared with the registry; nil
+	// means never.
+	LastSyncedAt *time.Time
+}
+
+// Divergence statuses tell whether resync wrote the registry value to the
+// car.
+const (
+	DivergenceApplied = "applied"
+	// DivergenceFailed means the update was refused; Error says why.
+	DivergenceFailed = "failed"
+	// DivergenceSkipped is only recorded, like a plate the registry no
+	// longer knows.
+	DivergenceSkipped = "skipped"
+)
+
+// Divergence records one field that differed between the catalog and the
+// registry during a resync.
+type Divergence struct {
+	Id            int
+	CarId         int
+	Field         string
+	LocalValue    string
+	RegistryValue string
+	DetectedAt    time.Time
+	Status        string
+	Error         string
+}
+
+// CarMatch is a search result; a higher Score is a better match.
+type CarMatch struct {
+	Car
+	Score float64
+}
+
+// Suggestion is a distinct column value and the number of cars having it.
+type Suggestion struct {
+	Value string
+	Count int
 }
@@ -0,0 +1,19 @@
+package model
+
+// Make is a canonical car mark of the reference dictionary. Aliases are the
+// normalized spellings that resolve to it, the canonical name's included.
+type Make struct {
+	Id      int
+	Name    string
+	Aliases []string
+	Models  []ModelRef
+}
+
+// ModelRef is a canonical model of a make; its aliases are unique within the
+// make only.
+type ModelRef struct {
+	Id      int
+	MakeId  int
+	Name    string
+	Aliases []string
+}
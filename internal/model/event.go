@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+const (
+	CarCreated = "created"
+	CarUpdated = "updated"
+	CarDeleted = "deleted"
+)
+
+// CarEvent is one entry of the change feed. Ids grow in the order events
+// were recorded.
+type CarEvent struct {
+	Id    int64
+	Type  string
+	CarId int
+	// Tenant is the API key that made the change, empty without auth.
+	Tenant string
+	// Car is the state after the change; for deletions, the last one.
+	Car       Car
+	CreatedAt time.Time
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Dimensions the catalog statistics can be grouped by.
+const (
+	StatsByMark   = "mark"
+	StatsByModel  = "model"
+	StatsByYear   = "year"
+	StatsByRegion = "region"
+	StatsByOwner  = "owner"
+)
+
+// StatsQuery is one aggregation of the catalog: cars matching the filters,
+// and any of the search variants when given, counted per distinct
+// combination of the GroupBy dimensions. At most Limit groups are returned,
+// largest first.
+type StatsQuery struct {
+	GroupBy  []string
+	Variants []string
+	Mark     string
+	Model    string
+	Year     string
+	Limit    int
+}
+
+// StatsGroup is one combination of the grouped dimensions; the others are
+// left zero. Region is the region code of a valid plate, empty otherwise,
+// and Owner is "surname name patronymic".
+type StatsGroup struct {
+	Mark   string
+	Model  string
+	Year   int
+	Region string
+	Owner  string
+	Count  int
+}
+
+// CarStats holds the largest groups of a StatsQuery with totals over all of
+// them. RefreshedAt is set when the figures come from a periodically
+// refreshed snapshot rather than the live table.
+type CarStats struct {
+	Groups      []StatsGroup
+	Cars        int
+	GroupCount  int
+	RefreshedAt *time.Time
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// Webhook delivery states. A pending delivery waits for its next attempt; a
+// dead one ran out of attempts and waits for a replay.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription asks for the change feed events matching its filters
+// to be POSTed to Url, signed with Secret.
+type WebhookSubscription struct {
+	Id     int
+	Url    string
+	Secret string
+	// Events lists the event types to deliver, every type when empty.
+	Events []string
+	// Mark limits the subscription to the cars of one mark when set.
+	Mark string
+	// AfterEventId is the newest event when the subscription was created;
+	// only later events are delivered.
+	AfterEventId int64
+	CreatedAt    time.Time
+}
+
+// WebhookDelivery is one event on its way to one subscription. Payload is
+// the exact body sent on every attempt.
+type WebhookDelivery struct {
+	Id             int64
+	SubscriptionId int
+	EventId        int64
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	// LastStatusCode and LastError describe the last failed attempt;
+	// LastStatusCode is 0 when no response was received.
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
@@ -1,59 +1,147 @@
 package app
 
 import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/blobstore"
 	"car_catalog/internal/config"
-	"car_catalog/internal/database"
+	"car_catalog/internal/graphqlapi"
+	"car_catalog/internal/grpcapi"
 	"car_catalog/internal/handler"
+	"car_catalog/internal/ratelimit"
 	"car_catalog/internal/repository"
 	"car_catalog/internal/router"
 	"car_catalog/internal/service"
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+
+	"google.golang.org/grpc"
 )
 
 type App struct {
 	Server *http.Server
+	TLS    config.TLSConfig
+	// GRPC serves the gRPC API on GRPCAddr; nil when grpc.enabled is off.
+	GRPC     *grpc.Server
+	GRPCAddr string
 }
 
-func New() (*App, error) {
+func New(cfg *config.Config) (*App, error) {
 	log.Println("[INFO] Creating new application instance")
 
-	cfg := config.New()
-
-	err := database.MigrateDatabase(cfg)
+	storage, err := OpenStorage(cfg)
 	if err != nil {
-		log.Printf("[ERROR] Failed to migrate database: %v", err)
 		return nil, err
 	}
-
-	conn := database.DatabaseConnection(cfg)
-
-	carRepo := repository.NewCarRepository(conn)
-	carService := service.NewCarService(carRepo)
-	carHandler := handler.NewCarHandler(carService, cfg.ExternalAPIURL)
+	carInfo, err := NewCarInfoProvider(cfg, storage.Pool)
+	if err != nil {
+		return nil, err
+	}
+	carService := service.NewCarService(storage.Cars, storage.Dictionary, carInfo)
+	resyncService := service.NewResyncService(carService, storage.Cars, carInfo)
+	carHandler := handler.NewCarHandler(carService, resyncService)
+	carHandler.MaxRegNums = cfg.Limits.MaxRegNums
+	carHandler.MaxBatchSize = cfg.Limits.MaxBatchSize
+	carHandler.SuggestTimeout = cfg.Suggest.Timeout
+	carHandler.MaxSuggestLimit = cfg.Suggest.MaxLimit
+	carHandler.Dictionary = service.NewDictionaryService(storage.Dictionary, storage.Cars)
+	var statsView repository.CarStatsView
+	if cfg.Stats.Materialized {
+		statsView = repository.NewCarStatsView(storage.Pool, cfg.Database.QueryTimeout)
+	}
+	carHandler.Stats = service.NewStatsService(storage.Cars, statsView)
+	eventService := service.NewEventService(storage.Events)
+	carHandler.Events = eventService
+	carHandler.EventHeartbeat = cfg.Events.Heartbeat
+	webhookService := service.NewWebhookService(storage.Webhooks, storage.Events, service.WebhookOptions{
+		Timeout:      cfg.Webhooks.Timeout,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		Backoff:      cfg.Webhooks.Backoff,
+		MaxBackoff:   cfg.Webhooks.MaxBackoff,
+		Workers:      cfg.Webhooks.Workers,
+		AllowPrivate: cfg.Webhooks.AllowPrivate,
+	})
+	carHandler.Webhooks = webhookService
+	blobs, err := blobstore.NewLocalStore(cfg.Attachments.Dir)
+	if err != nil {
+		return nil, err
+	}
+	attachmentService := service.NewAttachmentService(storage.Attachments, storage.Cars, blobs, service.AttachmentOptions{
+		MaxSize:      int64(cfg.Attachments.MaxSize),
+		AllowedTypes: cfg.Attachments.AllowedTypes,
+	})
+	carHandler.Attachments = attachmentService
+	limiter := ratelimit.NewLimiter(cfg.Limits.RateLimit)
+	if cfg.GraphQL.Enabled {
+		carHandler.Limiter = limiter
+		carHandler.GraphQL, err = graphqlapi.NewSchema(carService, eventService, graphqlapi.Options{
+			MaxRegNums:    cfg.Limits.MaxRegNums,
+			MaxDepth:      cfg.GraphQL.MaxDepth,
+			MaxComplexity: cfg.GraphQL.MaxComplexity,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	routes := router.NewRouter(carHandler)
 
-	server := &http.Server{Addr: fmt.Sprintf("%s:%d", cfg.HTTPHost, cfg.HTTPPort), Handler: routes}
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Enabled {
+		cars := grpcapi.NewCarServer(carService, cfg.Limits.MaxRegNums)
+		if grpcServer, err = grpcapi.NewServer(cfg, cars, limiter); err != nil {
+			return nil, err
+		}
+	}
+
+	background, stopBackground := context.WithCancel(context.Background())
+	// Rate limits and idempotency keys are per caller, so they run after auth.
+	root := withIdempotency(background, cfg.Idempotency, storage, routes)
+	root = ratelimit.MaxBytes(int64(cfg.Limits.MaxBodyBytes), root)
+	root = ratelimit.Middleware(limiter, router.IsImport, root)
+	root = auth.Middleware(cfg.Auth, []string{"/swagger/"}, root)
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.HTTP.Host, cfg.HTTP.Port),
+		Handler:           root,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
 
 	log.Println("[INFO] Application instance created successfully")
 
+	if cfg.Resync.Enabled {
+		go runResyncScheduler(background, resyncService, cfg.Resync)
+	}
+	if statsView != nil {
+		go runStatsRefresher(background, statsView, cfg.Stats.RefreshInterval)
+	}
+	go eventService.Follow(background)
+	go webhookService.Dispatch(background)
+	go runEventPruner(background, eventService, webhookService, cfg.Events.Retention)
+	go runBlobSweeper(background, attachmentService)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-quit
 		log.Println("[INFO] Server is shutting down...")
+		stopBackground()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 		defer cancel()
 
+		if grpcServer != nil {
+			go stopGRPC(ctx, grpcServer)
+		}
 		if err := server.Shutdown(ctx); err != nil {
 			log.Fatalf("[ERROR] Server shutdown failed: %v", err)
 		}
@@ -61,12 +149,36 @@ func New() (*App, error) {
 		log.Println("[INFO] Server shutdown completed")
 	}()
 
-	return &App{Server: server}, nil
+	return &App{
+		Server:   server,
+		TLS:      cfg.HTTP.TLS,
+		GRPC:     grpcServer,
+		GRPCAddr: fmt.Sprintf("%s:%d", cfg.GRPC.Host, cfg.GRPC.Port),
+	}, nil
 }
 
 func (a *App) Run() error {
+	if a.GRPC != nil {
+		listener, err := net.Listen("tcp", a.GRPCAddr)
+		if err != nil {
+			log.Printf("[ERROR] Unable to listen for gRPC on %s: %v", a.GRPCAddr, err)
+			return err
+		}
+		log.Printf("[INFO] Starting gRPC server on %s", a.GRPCAddr)
+		go func() {
+			if err := a.GRPC.Serve(listener); err != nil {
+				log.Printf("[ERROR] gRPC server stopped with error: %v", err)
+			}
+		}()
+	}
+
 	log.Printf("[INFO] Starting server on %s", a.Server.Addr)
-	err := a.Server.ListenAndServe()
+	var err error
+	if a.TLS.Enabled {
+		err = a.Server.ListenAndServeTLS(a.TLS.CertFile, a.TLS.KeyFile)
+	} else {
+		err = a.Server.ListenAndServe()
+	}
 	if err != nil && err != http.ErrServerClosed {
 		log.Printf("[ERROR] Server stopped with error: %v", err)
 	} else {
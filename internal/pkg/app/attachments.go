@@ -0,0 +1,42 @@
+package app
+
+import (
+	"car_catalog/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// blobSweepInterval is how often contents no attachment refers to are
+	// deleted.
+	blobSweepInterval = time.Hour
+	// blobSweepGrace spares contents put this recently: their upload may
+	// not have added its attachment yet.
+	blobSweepGrace = time.Hour
+)
+
+// runBlobSweeper deletes unreferenced attachment contents on start and then
+// every blobSweepInterval until ctx is cancelled. A failed run is retried on
+// the next tick.
+func runBlobSweeper(ctx context.Context, attachments service.AttachmentService) {
+	log.Printf("[INFO] Blob sweeper started: grace %s", blobSweepGrace)
+
+	ticker := time.NewTicker(blobSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := attachments.SweepBlobs(ctx, blobSweepGrace)
+		if err != nil {
+			log.Printf("[ERROR] Blob sweep failed: %v", err)
+		} else if deleted > 0 {
+			log.Printf("[INFO] Swept %d unreferenced blobs", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("[INFO] Blob sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
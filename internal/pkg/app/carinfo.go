@@ -0,0 +1,58 @@
+package app
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/config"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewCarInfoProvider builds the lookup chain listed in external.providers.
+// A cache entry wraps the rest of the list, so "cache,http,fixture" caches
+// answers of both the registry and the fixture. pool backs the persistent
+// cache and may be nil when it is disabled.
+func NewCarInfoProvider(cfg *config.Config, pool *pgxpool.Pool) (carinfo.CarInfoProvider, error) {
+	log.Printf("[INFO] Car info providers: %v", cfg.External.Providers)
+	return buildProviders(cfg, pool, cfg.External.Providers)
+}
+
+func buildProviders(cfg *config.Config, pool *pgxpool.Pool, names []string) (carinfo.CarInfoProvider, error) {
+	chain := carinfo.NewChain()
+	for i, name := range names {
+		switch name {
+		case "http":
+			client := &http.Client{Timeout: cfg.External.Timeout}
+			chain.Add(name, carinfo.NewHTTPProvider(cfg.External.URL, client))
+		case "fixture":
+			provider, err := carinfo.NewFixtureProvider(cfg.External.Fixture.Path)
+			if err != nil {
+				return nil, err
+			}
+			chain.Add(name, provider)
+		case "cache":
+			rest, err := buildProviders(cfg, pool, names[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			opts := carinfo.CacheOptions{
+				TTL:         cfg.External.Cache.TTL,
+				NegativeTTL: cfg.External.Cache.NegativeTTL,
+				Size:        cfg.External.Cache.Size,
+			}
+			if cfg.External.Cache.Persistent {
+				if pool == nil {
+					return nil, fmt.Errorf("persistent car info cache needs the postgres storage driver")
+				}
+				opts.Store = carinfo.NewPostgresCacheStore(pool)
+			}
+			chain.Add(name, carinfo.NewCacheProvider(rest, opts))
+			return chain, nil
+		default:
+			return nil, fmt.Errorf("unknown car info provider %q", name)
+		}
+	}
+	return chain, nil
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"car_catalog/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// eventPruneInterval is how often events, and webhook deliveries delivered,
+// longer than the retention ago are deleted.
+const eventPruneInterval = time.Hour
+
+// runEventPruner deletes expired events and webhook deliveries on start and then every
+// eventPruneInterval until ctx is cancelled. A failed run is retried on the
+// next tick.
+func runEventPruner(ctx context.Context, events service.EventService, webhooks service.WebhookService, retention time.Duration) {
+	log.Printf("[INFO] Event pruner started: retention %s", retention)
+
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		// Events the webhook dispatcher has not fanned out yet are kept
+		// past the retention, or their deliveries would never be queued.
+		if cursor, err := webhooks.Cursor(ctx); err == nil {
+			if pruned, err := events.Prune(ctx, retention, cursor); err == nil && pruned > 0 {
+				log.Printf("[INFO] Pruned %d events", pruned)
+			}
+		}
+		if pruned, err := webhooks.Prune(ctx, retention); err == nil && pruned > 0 {
+			log.Printf("[INFO] Pruned %d webhook deliveries", pruned)
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("[INFO] Event pruner stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
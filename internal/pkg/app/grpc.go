@@ -0,0 +1,24 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc"
+)
+
+// stopGRPC lets running calls finish until ctx is done, then closes the
+// remaining ones, such as long StreamCars listings.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+	log.Println("[INFO] gRPC server stopped")
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/idempotency"
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// withIdempotency wraps next with Idempotency-Key handling and starts purging
+// expired keys until ctx is cancelled.
+func withIdempotency(ctx context.Context, cfg config.IdempotencyConfig, storage *Storage, next http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+
+	store := idempotency.NewMemoryStore()
+	if storage.Pool != nil {
+		store = idempotency.NewPostgresStore(storage.Pool)
+	}
+	go purgeIdempotencyKeys(ctx, store, cfg.LockTimeout)
+
+	return idempotency.Middleware(store, idempotency.Options{TTL: cfg.TTL, LockTimeout: cfg.LockTimeout}, next)
+}
+
+func purgeIdempotencyKeys(ctx context.Context, store idempotency.Store, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := store.Purge(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[ERROR] Idempotency - Unable to purge expired keys: %v", err)
+			} else if purged > 0 {
+				log.Printf("[DEBUG] Idempotency - Purged %d expired keys", purged)
+			}
+		}
+	}
+}
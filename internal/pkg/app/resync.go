@@ -0,0 +1,31 @@
+package app
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// runResyncScheduler resyncs one batch of stale cars every interval until ctx
+// is cancelled. A batch that is still running delays the next tick instead of
+// overlapping with it.
+func runResyncScheduler(ctx context.Context, resync service.ResyncService, cfg config.ResyncConfig) {
+	log.Printf("[INFO] Resync scheduler started: every %s, cars older than %s, %d per run", cfg.Interval, cfg.StaleAfter, cfg.BatchSize)
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[INFO] Resync scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := resync.ResyncStale(ctx, cfg.StaleAfter, cfg.BatchSize); err != nil && ctx.Err() == nil {
+				log.Printf("[ERROR] Resync scheduler run failed: %v", err)
+			}
+		}
+	}
+}
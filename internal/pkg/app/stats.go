@@ -0,0 +1,30 @@
+package app
+
+import (
+	"car_catalog/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// runStatsRefresher rebuilds the statistics view on start and then every
+// interval until ctx is cancelled. A failed refresh leaves the previous
+// snapshot in place and is retried on the next tick.
+func runStatsRefresher(ctx context.Context, view repository.CarStatsView, interval time.Duration) {
+	log.Printf("[INFO] Stats refresher started: every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := view.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[ERROR] Stats refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("[INFO] Stats refresher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/database"
+	"car_catalog/internal/repository"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Storage is the opened storage backend. Pool is set only for the postgres
+// driver, so other Postgres-backed components can share its connections.
+type Storage struct {
+	Cars        repository.CarRepository
+	Dictionary  repository.DictionaryRepository
+	Events      repository.CarEventLog
+	Webhooks    repository.WebhookRepository
+	Attachments repository.AttachmentRepository
+	Pool        *pgxpool.Pool
+	close       func()
+}
+
+// OpenStorage opens the backend selected by storage.driver.
+func OpenStorage(cfg *config.Config) (*Storage, error) {
+	switch cfg.Storage.Driver {
+	case "postgres":
+		conn := database.DatabaseConnection(cfg)
+		return &Storage{
+			Cars:        repository.NewCarRepository(conn, cfg.Database.QueryTimeout),
+			Dictionary:  repository.NewDictionaryRepository(conn, cfg.Database.QueryTimeout),
+			Events:      repository.NewPostgresCarEventLog(conn, cfg.Database.QueryTimeout),
+			Webhooks:    repository.NewWebhookRepository(conn, cfg.Database.QueryTimeout),
+			Attachments: repository.NewAttachmentRepository(conn, cfg.Database.QueryTimeout),
+			Pool:        conn,
+			close:       conn.Close,
+		}, nil
+	case "sqlite":
+		db, err := database.SQLiteConnection(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			Cars:        repository.NewSQLiteCarRepository(db, cfg.Database.QueryTimeout),
+			Dictionary:  repository.NewSQLiteDictionaryRepository(db, cfg.Database.QueryTimeout),
+			Events:      repository.NewSQLiteCarEventLog(db, cfg.Database.QueryTimeout),
+			Webhooks:    repository.NewSQLiteWebhookRepository(db, cfg.Database.QueryTimeout),
+			Attachments: repository.NewSQLiteAttachmentRepository(db, cfg.Database.QueryTimeout),
+			close:       func() { db.Close() },
+		}, nil
+	case "memory":
+		log.Println("[INFO] Using in-memory storage, data will not survive a restart")
+		cars := repository.NewMemoryCarRepository()
+		return &Storage{
+			Cars:        cars,
+			Dictionary:  repository.NewMemoryDictionaryRepository(),
+			Events:      repository.NewMemoryCarEventLog(cars),
+			Webhooks:    repository.NewMemoryWebhookRepository(),
+			Attachments: repository.NewMemoryAttachmentRepository(cars),
+			close:       func() {},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+}
+
+// Close releases whatever the backend holds open.
+func (s *Storage) Close() {
+	s.close()
+}
+
+// NewCarRepository builds the repository selected by storage.driver. The
+// returned function releases whatever the repository holds open.
+func NewCarRepository(cfg *config.Config) (repository.CarRepository, func(), error) {
+	storage, err := OpenStorage(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage.Cars, storage.Close, nil
+}
@@ -0,0 +1,171 @@
+// Package ratelimit bounds how much of the service a single client can use:
+// token buckets per client and budget, and a cap on request body size.
+package ratelimit
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/config"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	BudgetRead   = "read"
+	BudgetImport = "import"
+
+	// sweepEvery is how often buckets of idle clients are dropped.
+	sweepEvery = time.Minute
+)
+
+// Limiter keeps one token bucket per client and budget.
+type Limiter struct {
+	budgets map[string]config.BucketConfig
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*rate.Limiter
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	client string
+	budget string
+}
+
+// NewLimiter returns the limiter of cfg, or nil when rate limiting is off. One
+// limiter is shared by the HTTP and gRPC servers so a client has the same
+// budget on both.
+func NewLimiter(cfg config.RateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Limiter{
+		budgets: map[string]config.BucketConfig{
+			BudgetRead:   cfg.Read,
+			BudgetImport: cfg.Import,
+		},
+		buckets:   make(map[bucketKey]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the client's bucket. When the bucket is empty it
+// reports how long until the next token is available.
+func (l *Limiter) Allow(client, budget string) (bool, time.Duration) {
+	now := time.Now()
+	bucket := l.bucket(client, budget, now)
+
+	reservation := bucket.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Minute
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *Limiter) bucket(client, budget string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepEvery {
+		l.sweep(now)
+	}
+
+	key := bucketKey{client: client, budget: budget}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		cfg := l.budgets[budget]
+		bucket = rate.NewLimiter(rate.Limit(float64(cfg.PerMinute)/60), cfg.Burst)
+		l.buckets[key] = bucket
+	}
+	return bucket
+}
+
+// sweep drops full buckets: a client that has been idle long enough to refill
+// is indistinguishable from a new one.
+func (l *Limiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Middleware rejects requests over the client's budget with 429 and a
+// Retry-After header. Clients are told apart by API key name, or by remote
+// address when auth is disabled. Requests matched by isImport draw from the
+// import budget, everything else from the read budget. A nil limiter lets
+// every request through.
+func Middleware(limiter *Limiter, isImport func(*http.Request) bool, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := BudgetRead
+		if isImport(r) {
+			budget = BudgetImport
+		}
+		client := clientKey(r)
+
+		if ok, retryAfter := limiter.Allow(client, budget); !ok {
+			log.Printf("[INFO] RateLimit - Client %q exceeded the %s budget on %s", client, budget, r.URL.Path)
+			w.Header().Set("Retry-After", fmt.Sprint(RetryAfterSeconds(retryAfter)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientKey(r *http.Request) string {
+	return ClientKey(r.Context(), r.RemoteAddr)
+}
+
+// ClientKey tells clients apart: by the name of the API key in ctx, or by the
+// host of remoteAddr when the caller is anonymous.
+func ClientKey(ctx context.Context, remoteAddr string) string {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return "key:" + identity.Name
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// RetryAfterSeconds rounds a delay up to whole seconds, as Retry-After wants.
+func RetryAfterSeconds(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
+
+// MaxBytes caps request bodies at limit bytes. A declared Content-Length over
+// the limit is rejected with 413 right away; otherwise reading past the limit
+// fails with *http.MaxBytesError and the handler answers 413.
+func MaxBytes(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			log.Printf("[INFO] RateLimit - Rejected %d byte body on %s, limit is %d", r.ContentLength, r.URL.Path, limit)
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package ratelimit_test
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/ratelimit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	cfg := config.RateLimitConfig{
+		Enabled: true,
+		Read:    config.BucketConfig{PerMinute: 60, Burst: 2},
+		Import:  config.BucketConfig{PerMinute: 1, Burst: 1},
+	}
+	isImport := func(r *http.Request) bool { return r.Method == http.MethodPost }
+	h := ratelimit.Middleware(ratelimit.NewLimiter(cfg), isImport, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(method, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/getCars/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := send(http.MethodGet, "10.0.0.1:1000"); rec.Code != http.StatusOK {
+			t.Fatalf("read %d: status = %d, want 200 within the burst", i, rec.Code)
+		}
+	}
+	rec := send(http.MethodGet, "10.0.0.1:1001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("read over the burst: status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+	}
+
+	// Budgets are separate per class and per client.
+	if rec := send(http.MethodPost, "10.0.0.1:1000"); rec.Code != http.StatusOK {
+		t.Fatalf("import after reads: status = %d, want 200", rec.Code)
+	}
+	if rec := send(http.MethodPost, "10.0.0.1:1000"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("second import: status = %d, Retry-After = %q, want 429 and 60", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := send(http.MethodGet, "10.0.0.2:1000"); rec.Code != http.StatusOK {
+		t.Fatalf("another client: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	var readErr error
+	h := ratelimit.MaxBytes(8, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/addCars", strings.NewReader("0123456789")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("declared length over the limit: status = %d, want 413", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/addCars", io.NopCloser(strings.NewReader("0123456789")))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if _, ok := readErr.(*http.MaxBytesError); !ok {
+		t.Fatalf("reading past the limit: error = %v, want *http.MaxBytesError", readErr)
+	}
+}
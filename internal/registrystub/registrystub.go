@@ -0,0 +1,204 @@
+// Package registrystub is a stand-in for the external car registry queried by
+// AddCars. It answers GET ?regNum=... with AddCarsDto-shaped JSON taken from a
+// fixture file or generated deterministically from the plate, and can inject
+// latency, 4xx/5xx responses and malformed bodies.
+package registrystub
+
+import (
+	"car_catalog/internal/dto"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Fault is a failure the stub answers with instead of a car.
+type Fault string
+
+const (
+	FaultNone        Fault = ""
+	FaultBadRequest  Fault = "bad_request"
+	FaultServerError Fault = "server_error"
+	FaultMalformed   Fault = "malformed"
+)
+
+// Entry is one fixture record. Fault, when set, is returned for the plate
+// every time, which makes a failing row reproducible in tests.
+type Entry struct {
+	dto.AddCarsDto
+	Fault Fault `json:"fault,omitempty"`
+}
+
+type Options struct {
+	// Fixtures maps a registration number to its answer.
+	Fixtures map[string]Entry
+	// Generate answers unknown plates with a car derived from the plate;
+	// otherwise they get 404.
+	Generate bool
+
+	// Latency delays every response; Jitter adds up to that much on top.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Probabilities in [0, 1] of a random fault on a request that has no
+	// fixture fault.
+	BadRequestRate  float64
+	ServerErrorRate float64
+	MalformedRate   float64
+
+	// Seed makes jitter and random faults reproducible.
+	Seed int64
+}
+
+type Server struct {
+	opts Options
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func New(opts Options) *Server {
+	return &Server{
+		opts: opts,
+		rnd:  rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// LoadFixtures reads a JSON array of entries keyed by their regNum.
+func LoadFixtures(path string) (map[string]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid fixture file %s: %w", path, err)
+	}
+
+	fixtures := make(map[string]Entry, len(entries))
+	for i, entry := range entries {
+		if entry.RegNum == "" {
+			return nil, fmt.Errorf("invalid fixture file %s: entry %d has no regNum", path, i)
+		}
+		switch entry.Fault {
+		case FaultNone, FaultBadRequest, FaultServerError, FaultMalformed:
+		default:
+			return nil, fmt.Errorf("invalid fixture file %s: unknown fault %q", path, entry.Fault)
+		}
+		fixtures[entry.RegNum] = entry
+	}
+	return fixtures, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	regNum := r.URL.Query().Get("regNum")
+	log.Printf("[DEBUG] RegistryStub - ServeHTTP - Lookup of %q", regNum)
+
+	delay, fault := s.roll()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	entry, known := s.opts.Fixtures[regNum]
+	if entry.Fault != FaultNone {
+		fault = entry.Fault
+	}
+	if regNum == "" {
+		fault = FaultBadRequest
+	}
+
+	switch fault {
+	case FaultBadRequest:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	case FaultServerError:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	case FaultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"regNum": "` + regNum + `", "mark": `))
+		return
+	}
+
+	var car dto.AddCarsDto
+	switch {
+	case known:
+		car = entry.AddCarsDto
+	case s.opts.Generate:
+		car = Generate(regNum)
+	default:
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(car)
+}
+
+// roll draws the delay and random fault of one request.
+func (s *Server) roll() (time.Duration, Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += time.Duration(s.rnd.Int63n(int64(s.opts.Jitter) + 1))
+	}
+
+	p := s.rnd.Float64()
+	switch {
+	case p < s.opts.BadRequestRate:
+		return delay, FaultBadRequest
+	case p < s.opts.BadRequestRate+s.opts.ServerErrorRate:
+		return delay, FaultServerError
+	case p < s.opts.BadRequestRate+s.opts.ServerErrorRate+s.opts.MalformedRate:
+		return delay, FaultMalformed
+	}
+	return delay, FaultNone
+}
+
+var (
+	generatedModels = [][2]string{
+		{"Lada", "Vesta"}, {"Lada", "Granta"}, {"Toyota", "Camry"}, {"Kia", "Rio"},
+		{"Hyundai", "Solaris"}, {"Volkswagen", "Polo"}, {"Skoda", "Octavia"}, {"Renault", "Logan"},
+	}
+	generatedOwners = []dto.People{
+		{Name: "Иван", Surname: "Иванов", Patronymic: "Петрович"},
+		{Name: "Мария", Surname: "Смирнова", Patronymic: "Андреевна"},
+		{Name: "Сергей", Surname: "Кузнецов", Patronymic: "Николаевич"},
+		{Name: "Ольга", Surname: "Попова"},
+	}
+)
+
+// Generate derives a car from the plate alone, so the same plate always
+// yields the same answer across runs.
+func Generate(regNum string) dto.AddCarsDto {
+	h := fnv.New64a()
+	h.Write([]byte(regNum))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	model := generatedModels[rnd.Intn(len(generatedModels))]
+	return dto.AddCarsDto{
+		Mark:   model[0],
+		Model:  model[1],
+		Year:   2000 + rnd.Intn(25),
+		RegNum: regNum,
+		Owner:  generatedOwners[rnd.Intn(len(generatedOwners))],
+	}
+}
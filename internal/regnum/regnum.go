@@ -0,0 +1,54 @@
+package regnum
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Letters allowed on Russian plates are the ones that look the same in the
+// Cyrillic and Latin alphabets. Plates are stored with their Latin spelling.
+var cyrillicToLatin = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H',
+	'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X',
+}
+
+var plateRe = regexp.MustCompile(`^[ABEKMHOPCTYX]\d{3}[ABEKMHOPCTYX]{2}\d{2,3}$`)
+
+var ErrInvalid = errors.New("invalid registration number")
+
+// Normalize brings a plate to its canonical spelling: upper case, Latin
+// letters, no spaces or dashes.
+func Normalize(regNum string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(regNum)) {
+		switch {
+		case r == ' ' || r == '-' || r == '\t':
+			continue
+		case cyrillicToLatin[r] != 0:
+			b.WriteRune(cyrillicToLatin[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Validate reports whether an already normalized plate has the
+// "letter, three digits, two letters, region" shape.
+func Validate(regNum string) error {
+	if !plateRe.MatchString(regNum) {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Region returns the region code of an already normalized plate, or "" when
+// the plate does not have the expected shape.
+func Region(regNum string) string {
+	if Validate(regNum) != nil {
+		return ""
+	}
+	// Letter, three digits and two letters are all single-byte.
+	return regNum[6:]
+}
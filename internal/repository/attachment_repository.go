@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// AttachmentRepository stores the metadata of the files attached to cars.
+// Attachments go with their car when it is deleted.
+type AttachmentRepository interface {
+	// AddAttachment stores a and returns it with Id and CreatedAt set and
+	// created true. When the car already has an attachment with the same
+	// Sha256, nothing is written and that attachment is returned with
+	// created false. It fails with ErrCarNotFound for a missing car.
+	AddAttachment(ctx context.Context, a model.Attachment) (attachment model.Attachment, created bool, err error)
+	// ListAttachments returns the attachments of a car, oldest first.
+	ListAttachments(ctx context.Context, carId int) ([]model.Attachment, error)
+	GetAttachment(ctx context.Context, carId, id int) (model.Attachment, error)
+	// DeleteAttachment removes an attachment and returns what it was.
+	DeleteAttachment(ctx context.Context, carId, id int) (model.Attachment, error)
+	// BlobReferenced reports whether any attachment has the content with the
+	// given SHA-256.
+	BlobReferenced(ctx context.Context, sha256 string) (bool, error)
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AttachmentRepositoryImpl struct {
+	conn         *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+func NewAttachmentRepository(conn *pgxpool.Pool, queryTimeout time.Duration) AttachmentRepository {
+	return &AttachmentRepositoryImpl{
+		conn:         conn,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (a *AttachmentRepositoryImpl) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.queryTimeout)
+}
+
+const pgAttachmentColumns = `id, car_id, file_name, content_type, size, sha256, created_at`
+
+func scanPgAttachment(row pgx.Row) (model.Attachment, error) {
+	var a model.Attachment
+	err := row.Scan(&a.Id, &a.CarId, &a.FileName, &a.ContentType, &a.Size, &a.Sha256, &a.CreatedAt)
+	return a, err
+}
+
+func (a *AttachmentRepositoryImpl) AddAttachment(ctx context.Context, attachment model.Attachment) (model.Attachment, bool, error) {
+	ctx, cancel := a.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `INSERT INTO cars.car_attachment (car_id, file_name, content_type, size, sha256)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (car_id, sha256) DO NOTHING
+	RETURNING ` + pgAttachmentColumns
+
+	added, err := scanPgAttachment(a.conn.QueryRow(ctx, query,
+		attachment.CarId, attachment.FileName, attachment.ContentType, attachment.Size, attachment.Sha256))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The car already has this content; the conflicting row is visible
+		// once the insert that skipped it has finished.
+		existing, err := a.getBySha(ctx, attachment.CarId, attachment.Sha256)
+		if err != nil {
+			log.Printf("[ERROR] Repo - AddAttachment - Error reading the existing attachment: %v", err)
+			return model.Attachment{}, false, err
+		}
+		log.Printf("[INFO] Repo - AddAttachment - Car %d already has attachment %d", existing.CarId, existing.Id)
+		return existing, false, nil
+	}
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return model.Attachment{}, false, ErrCarNotFound
+		}
+		log.Printf("[ERROR] Repo - AddAttachment - Error inserting attachment: %v", err)
+		return model.Attachment{}, false, err
+	}
+
+	log.Printf("[INFO] Repo - AddAttachment - Added attachment %d to car %d", added.Id, added.CarId)
+	return added, true, nil
+}
+
+func (a *AttachmentRepositoryImpl) getBySha(ctx context.Context, carId int, sha256 string) (model.Attachment, error) {
+	return scanPgAttachment(a.conn.QueryRow(ctx, `SELECT `+pgAttachmentColumns+`
+	FROM cars.car_attachment
+	WHERE car_id = $1 AND sha256 = $2`, carId, sha256))
+}
+
+func (a *AttachmentRepositoryImpl) ListAttachments(ctx context.Context, carId int) ([]model.Attachment, error) {
+	ctx, cancel := a.withQueryDeadline(ctx)
+	defer cancel()
+
+	rows, err := a.conn.Query(ctx, `SELECT `+pgAttachmentColumns+`
+	FROM cars.car_attachment
+	WHERE car_id = $1
+	ORDER BY id`, carId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListAttachments - Error executing select query: %v", err)
+		return nil, err
+	}
+	attachments, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Attachment, error) {
+		return scanPgAttachment(row)
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListAttachments - Error scanning rows: %v", err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (a *AttachmentRepositoryImpl) GetAttachment(ctx context.Context, carId, id int) (model.Attachment, error) {
+	ctx, cancel := a.withQueryDeadline(ctx)
+	defer cancel()
+
+	attachment, err := scanPgAttachment(a.conn.QueryRow(ctx, `SELECT `+pgAttachmentColumns+`
+	FROM cars.car_attachment
+	WHERE car_id = $1 AND id = $2`, carId, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Attachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetAttachment - Error executing select query: %v", err)
+		return model.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (a *AttachmentRepositoryImpl) DeleteAttachment(ctx context.Context, carId, id int) (model.Attachment, error) {
+	ctx, cancel := a.withQueryDeadline(ctx)
+	defer cancel()
+
+	deleted, err := scanPgAttachment(a.conn.QueryRow(ctx, `DELETE FROM cars.car_attachment
+	WHERE car_id = $1 AND id = $2
+	RETURNING `+pgAttachmentColumns, carId, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Attachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteAttachment - Error executing delete query: %v", err)
+		return model.Attachment{}, err
+	}
+
+	log.Printf("[INFO] Repo - DeleteAttachment - Deleted attachment %d of car %d", id, carId)
+	return deleted, nil
+}
+
+func (a *AttachmentRepositoryImpl) BlobReferenced(ctx context.Context, sha256 string) (bool, error) {
+	ctx, cancel := a.withQueryDeadline(ctx)
+	defer cancel()
+
+	var referenced bool
+	err := a.conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM cars.car_attachment WHERE sha256 = $1)`, sha256).Scan(&referenced)
+	if err != nil {
+		log.Printf("[ERROR] Repo - BlobReferenced - Error executing select query: %v", err)
+		return false, err
+	}
+	return referenced, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryAttachmentRepository keeps attachment metadata in process memory;
+// ids are never reused, like those of the SQL tables.
+type MemoryAttachmentRepository struct {
+	cars *MemoryCarRepository
+
+	mu          sync.Mutex
+	attachments []model.Attachment
+	nextId      int
+}
+
+// NewMemoryAttachmentRepository returns the attachments of cars, which must
+// come from NewMemoryCarRepository.
+func NewMemoryAttachmentRepository(cars CarRepository) AttachmentRepository {
+	return &MemoryAttachmentRepository{cars: cars.(*MemoryCarRepository), nextId: 1}
+}
+
+// liveLocked drops the attachments of deleted cars, like ON DELETE CASCADE.
+// Car ids are never reused, so a missing car is gone for good.
+func (m *MemoryAttachmentRepository) liveLocked() {
+	m.cars.mu.RLock()
+	defer m.cars.mu.RUnlock()
+
+	kept := m.attachments[:0]
+	for _, a := range m.attachments {
+		if _, ok := m.cars.cars[a.CarId]; ok {
+			kept = append(kept, a)
+		}
+	}
+	m.attachments = kept
+}
+
+func (m *MemoryAttachmentRepository) AddAttachment(ctx context.Context, attachment model.Attachment) (model.Attachment, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.liveLocked()
+	if _, err := m.cars.GetCarById(ctx, attachment.CarId); err != nil {
+		return model.Attachment{}, false, err
+	}
+	for _, a := range m.attachments {
+		if a.CarId == attachment.CarId && a.Sha256 == attachment.Sha256 {
+			return a, false, nil
+		}
+	}
+	attachment.Id = m.nextId
+	attachment.CreatedAt = time.Now()
+	m.nextId++
+	m.attachments = append(m.attachments, attachment)
+	return attachment, true, nil
+}
+
+func (m *MemoryAttachmentRepository) ListAttachments(ctx context.Context, carId int) ([]model.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.liveLocked()
+	var attachments []model.Attachment
+	for _, a := range m.attachments {
+		if a.CarId == carId {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (m *MemoryAttachmentRepository) GetAttachment(ctx context.Context, carId, id int) (model.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.liveLocked()
+	for _, a := range m.attachments {
+		if a.CarId == carId && a.Id == id {
+			return a, nil
+		}
+	}
+	return model.Attachment{}, ErrAttachmentNotFound
+}
+
+func (m *MemoryAttachmentRepository) DeleteAttachment(ctx context.Context, carId, id int) (model.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.liveLocked()
+	for i, a := range m.attachments {
+		if a.CarId == carId && a.Id == id {
+			m.attachments = append(m.attachments[:i], m.attachments[i+1:]...)
+			return a, nil
+		}
+	}
+	return model.Attachment{}, ErrAttachmentNotFound
+}
+
+func (m *MemoryAttachmentRepository) BlobReferenced(ctx context.Context, sha256 string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.liveLocked()
+	for _, a := range m.attachments {
+		if a.Sha256 == sha256 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteAttachmentRepository keeps attachment metadata next to the SQLite
+// catalog, with the same table and constraints as Postgres.
+type SQLiteAttachmentRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewSQLiteAttachmentRepository(db *sql.DB, queryTimeout time.Duration) AttachmentRepository {
+	return &SQLiteAttachmentRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (s *SQLiteAttachmentRepository) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+const sqliteAttachmentColumns = `id, car_id, file_name, content_type, size, sha256, created_at`
+
+func scanSQLiteAttachment(row interface{ Scan(...any) error }) (model.Attachment, error) {
+	var (
+		a         model.Attachment
+		createdAt int64
+	)
+	err := row.Scan(&a.Id, &a.CarId, &a.FileName, &a.ContentType, &a.Size, &a.Sha256, &createdAt)
+	a.CreatedAt = time.UnixMilli(createdAt)
+	return a, err
+}
+
+func (s *SQLiteAttachmentRepository) AddAttachment(ctx context.Context, attachment model.Attachment) (model.Attachment, bool, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `INSERT INTO car_attachment (car_id, file_name, content_type, size, sha256, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (car_id, sha256) DO NOTHING
+	RETURNING ` + sqliteAttachmentColumns
+
+	added, err := scanSQLiteAttachment(s.db.QueryRowContext(ctx, query, attachment.CarId, attachment.FileName,
+		attachment.ContentType, attachment.Size, attachment.Sha256, time.Now().UnixMilli()))
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := scanSQLiteAttachment(s.db.QueryRowContext(ctx, `SELECT `+sqliteAttachmentColumns+`
+		FROM car_attachment
+		WHERE car_id = ? AND sha256 = ?`, attachment.CarId, attachment.Sha256))
+		if err != nil {
+			log.Printf("[ERROR] Repo - AddAttachment - Error reading the existing attachment: %v", err)
+			return model.Attachment{}, false, err
+		}
+		log.Printf("[INFO] Repo - AddAttachment - Car %d already has attachment %d", existing.CarId, existing.Id)
+		return existing, false, nil
+	}
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+			return model.Attachment{}, false, ErrCarNotFound
+		}
+		log.Printf("[ERROR] Repo - AddAttachment - Error inserting attachment: %v", err)
+		return model.Attachment{}, false, err
+	}
+
+	log.Printf("[INFO] Repo - AddAttachment - Added attachment %d to car %d", added.Id, added.CarId)
+	return added, true, nil
+}
+
+func (s *SQLiteAttachmentRepository) ListAttachments(ctx context.Context, carId int) ([]model.Attachment, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteAttachmentColumns+`
+	FROM car_attachment
+	WHERE car_id = ?
+	ORDER BY id`, carId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListAttachments - Error executing select query: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []model.Attachment
+	for rows.Next() {
+		a, err := scanSQLiteAttachment(rows)
+		if err != nil {
+			log.Printf("[ERROR] Repo - ListAttachments - Error scanning row: %v", err)
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *SQLiteAttachmentRepository) GetAttachment(ctx context.Context, carId, id int) (model.Attachment, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	attachment, err := scanSQLiteAttachment(s.db.QueryRowContext(ctx, `SELECT `+sqliteAttachmentColumns+`
+	FROM car_attachment
+	WHERE car_id = ? AND id = ?`, carId, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Attachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetAttachment - Error executing select query: %v", err)
+		return model.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (s *SQLiteAttachmentRepository) DeleteAttachment(ctx context.Context, carId, id int) (model.Attachment, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	deleted, err := scanSQLiteAttachment(s.db.QueryRowContext(ctx, `DELETE FROM car_attachment
+	WHERE car_id = ? AND id = ?
+	RETURNING `+sqliteAttachmentColumns, carId, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Attachment{}, ErrAttachmentNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteAttachment - Error executing delete query: %v", err)
+		return model.Attachment{}, err
+	}
+
+	log.Printf("[INFO] Repo - DeleteAttachment - Deleted attachment %d of car %d", id, carId)
+	return deleted, nil
+}
+
+func (s *SQLiteAttachmentRepository) BlobReferenced(ctx context.Context, sha256 string) (bool, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	var referenced bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM car_attachment WHERE sha256 = ?)`, sha256).Scan(&referenced)
+	if err != nil {
+		log.Printf("[ERROR] Repo - BlobReferenced - Error executing select query: %v", err)
+		return false, err
+	}
+	return referenced, nil
+}
@@ -4,12 +4,72 @@ import (
 	"car_catalog/internal/model"
 	"car_catalog/internal/dto"
 	"context"
+	"time"
 )
 
 type CarRepository interface {
+	// AddCars inserts every car or none and sets CarId of each to the id it
+	// was given.
 	AddCars(ctx context.Context, cars []model.Car) error
 	GetCarById(ctx context.Context, carId int) (model.Car, error)
+	// GetCarByVin looks a car up by its normalized VIN.
+	GetCarByVin(ctx context.Context, vin string) (model.Car, error)
+	// GetCarsByIds returns the cars with the given ids in id order; ids
+	// without a car are skipped.
+	GetCarsByIds(ctx context.Context, carIds []int) ([]model.Car, error)
 	GetCars(ctx context.Context, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.Car, dto.Cursors, error)
 	UpdateCar(ctx context.Context, car model.Car) error
 	DeleteCar(ctx context.Context, carId int) error
+	// UpdateCars and DeleteCars are all or nothing: a missing id fails the
+	// whole batch with ErrCarNotFound and nothing is written.
+	UpdateCars(ctx context.Context, cars []model.Car) error
+	DeleteCars(ctx context.Context, carIds []int) error
+	// ModifyCars reads the cars of carIds that exist, in id order, and
+	// passes them to modify while holding them against concurrent writes;
+	// the cars modify returns are written, with their events, in the same
+	// transaction. An error from modify writes nothing and is returned.
+	// modify must not call the repository.
+	ModifyCars(ctx context.Context, carIds []int, modify func(cars []model.Car) ([]model.Car, error)) error
+	FindExistingRegNums(ctx context.Context, regNums []string) ([]string, error)
+	StreamCars(ctx context.Context, mark, carModel, year string, fn func(model.Car) error) error
+	// SearchCars ranks the cars matching any of the search variants (see
+	// package search), best first, narrowed by the usual filters. Its cursors
+	// encode the score and id of the edge rows.
+	SearchCars(ctx context.Context, variants []string, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.CarMatch, dto.Cursors, error)
+	// SuggestMarks, SuggestModels and SuggestRegNums return up to limit
+	// distinct values starting with prefix, most common first. Marks and
+	// models match case-insensitively, SuggestModels optionally within one
+	// mark; reg numbers are stored normalized and match as they are. A
+	// non-nil tenant only counts the cars added with that API key.
+	SuggestMarks(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error)
+	SuggestModels(ctx context.Context, tenant *string, mark, prefix string, limit int) ([]model.Suggestion, error)
+	SuggestRegNums(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error)
+	// CarStats counts the cars matching the query per group, see
+	// model.StatsQuery.
+	CarStats(ctx context.Context, query model.StatsQuery) (model.CarStats, error)
+	// ListStaleCars returns up to limit cars never synced or last synced
+	// before the given time, least recently synced first.
+	ListStaleCars(ctx context.Context, before time.Time, limit int) ([]model.Car, error)
+	MarkSynced(ctx context.Context, carId int, at time.Time) error
+	AddDivergences(ctx context.Context, divergences []model.Divergence) error
+	// ListDivergences returns the newest divergences first; carId 0 lists
+	// them for every car.
+	ListDivergences(ctx context.Context, carId int, limit int) ([]model.Divergence, error)
+}
+
+// assignedId pairs a new car with the id the database gave it.
+type assignedId struct {
+	carId  int
+	regNum string
+}
+
+// setAssignedIds copies the ids of freshly inserted cars back by plate.
+func setAssignedIds(cars []model.Car, ids []assignedId) {
+	byRegNum := make(map[string]int, len(ids))
+	for _, id := range ids {
+		byRegNum[id.regNum] = id.carId
+	}
+	for i := range cars {
+		cars[i].CarId = byRegNum[cars[i].RegNum]
+	}
 }
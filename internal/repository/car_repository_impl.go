@@ -3,37 +3,58 @@ package repository
 import (
 	"car_catalog/internal/dto"
 	"car_catalog/internal/model"
+	"car_catalog/internal/search"
 	"context"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const streamBatchSize = 500
+
 type CarRepositoryImpl struct {
-	conn *pgxpool.Pool
+	conn         *pgxpool.Pool
+	queryTimeout time.Duration
 }
 
-func NewCarRepository(conn *pgxpool.Pool) CarRepository {
+// NewCarRepository returns the Postgres repository. A positive queryTimeout
+// bounds every single-row and listing call; bulk loads and streaming exports
+// are limited only by the caller's context and the server statement_timeout.
+func NewCarRepository(conn *pgxpool.Pool, queryTimeout time.Duration) CarRepository {
 	return &CarRepositoryImpl{
-		conn: conn,
+		conn:         conn,
+		queryTimeout: queryTimeout,
 	}
 }
 
+// withQueryDeadline derives the query deadline from the caller's context, so
+// a slow listing releases its pool connection once either expires.
+func (c *CarRepositoryImpl) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.queryTimeout)
+}
+
 func (c *CarRepositoryImpl) AddCars(ctx context.Context, cars []model.Car) error {
 	entries := [][]any{}
 	columns := []string{
-		"mark", "model", "year", "reg_num",
-		"owner_name", "owner_surname", "owner_patronymic",
+		"mark", "model", "year", "reg_num", "vin",
+		"owner_name", "owner_surname", "owner_patronymic", "last_synced_at", "tenant",
 	}
-	tableName := "car"
+	tableName := pgx.Identifier{"cars", "car"}
 
+	tenant := tenantOf(ctx)
 	for _, car := range cars {
 		entries = append(entries, []any{
-			car.Mark, car.Model, car.Year, car.RegNum,
-			car.OwnerName, car.OwnerSurname, car.OwnerPatronymic,
+			car.Mark, car.Model, car.Year, car.RegNum, nullableVin(car.Vin),
+			car.OwnerName, car.OwnerSurname, car.OwnerPatronymic, car.LastSyncedAt, tenant,
 		})
 	}
 
@@ -44,14 +65,38 @@ func (c *CarRepositoryImpl) AddCars(ctx context.Context, cars []model.Car) error
 	}
 	defer tx.Rollback(ctx)
 
-	copyCount, err := c.conn.CopyFrom(
+	copyCount, err := tx.CopyFrom(
 		ctx,
-		pgx.Identifier{tableName},
+		tableName,
 		columns,
 		pgx.CopyFromRows(entries),
 	)
 	if err != nil {
-		return fmt.Errorf("[ERROR] Repo - AddCars - error copying into %s table: %w", tableName, err)
+		return fmt.Errorf("[ERROR] Repo - AddCars - error copying into %s table: %w", tableName.Sanitize(), mapPgError(err))
+	}
+
+	// COPY reports no ids; reg_num is unique, so read them back by plate.
+	regNums := make([]string, len(cars))
+	for i, car := range cars {
+		regNums[i] = car.RegNum
+	}
+	rows, err := tx.Query(ctx, "SELECT id, reg_num FROM cars.car WHERE reg_num = ANY($1)", regNums)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddCars - Error reading assigned ids: %v", err)
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (assignedId, error) {
+		var id assignedId
+		err := row.Scan(&id.carId, &id.regNum)
+		return id, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddCars - Error scanning assigned ids: %v", err)
+		return err
+	}
+	setAssignedIds(cars, ids)
+	if err := appendCarEvents(ctx, tx, carEvents(ctx, model.CarCreated, cars)); err != nil {
+		return err
 	}
 
 	err = tx.Commit(ctx)
@@ -66,8 +111,12 @@ func (c *CarRepositoryImpl) AddCars(ctx context.Context, cars []model.Car) error
 }
 
 func (c *CarRepositoryImpl) DeleteCar(ctx context.Context, carId int) error {
-	query := `DELETE FROM car
-	WHERE id = $1`
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `DELETE FROM cars.car
+	WHERE id = $1
+	RETURNING ` + pgCarColumns
 
 	tx, err := c.conn.Begin(ctx)
 	if err != nil {
@@ -76,20 +125,22 @@ func (c *CarRepositoryImpl) DeleteCar(ctx context.Context, carId int) error {
 	}
 	defer tx.Rollback(ctx)
 
-	commandTag, err := c.conn.Exec(ctx, query, carId)
+	deleted, err := scanPgCar(tx.QueryRow(ctx, query, carId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[ERROR] Repo - DeleteCar - Error executing delete query: %v", ErrCarNotFound)
+		return ErrCarNotFound
+	}
 	if err != nil {
 		log.Printf("[ERROR] Repo - DeleteCar - Error executing delete query: %v", err)
 		return err
 	}
-	if commandTag.RowsAffected() <= 0 {
-		err := fmt.Errorf("no rows found to update")
-		log.Printf("[ERROR] Repo - DeleteCar - Error executing delete query: %v", err)
+	if err := appendCarEvents(ctx, tx, carEvents(ctx, model.CarDeleted, []model.Car{deleted})); err != nil {
 		return err
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		log.Printf("[ERROR] Repo - AddCars - Failed to commit transaction: %v", err)
+		log.Printf("[ERROR] Repo - DeleteCar - Failed to commit transaction: %v", err)
 		return fmt.Errorf("failed to commit transaction")
 	}
 	log.Println("[INFO] Repo - DeleteCar - Transaction committed successfully")
@@ -99,12 +150,21 @@ func (c *CarRepositoryImpl) DeleteCar(ctx context.Context, carId int) error {
 }
 
 func (c *CarRepositoryImpl) GetCarById(ctx context.Context, carId int) (model.Car, error) {
-	query := `SELECT mark, model, year, reg_num
-	FROM car
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM cars.car
 	WHERE id = $1`
 
 	var car model.Car
-	err := c.conn.QueryRow(ctx, query, carId).Scan(&car.Mark, &car.Model, &car.Year, &car.RegNum)
+	err := c.conn.QueryRow(ctx, query, carId).Scan(&car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+		&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &car.LastSyncedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[ERROR] Repo - GetCarById - No car with id %d", carId)
+		return model.Car{}, ErrCarNotFound
+	}
 	if err != nil {
 		log.Printf("[ERROR] Repo - GetCarById - Error executing select query: %v", err)
 		return model.Car{}, err
@@ -116,7 +176,62 @@ func (c *CarRepositoryImpl) GetCarById(ctx context.Context, carId int) (model.Ca
 	return car, nil
 }
 
+func (c *CarRepositoryImpl) GetCarByVin(ctx context.Context, vin string) (model.Car, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, mark, model, year, reg_num, vin,
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM cars.car
+	WHERE vin = $1`
+
+	var car model.Car
+	err := c.conn.QueryRow(ctx, query, vin).Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+		&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &car.LastSyncedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[INFO] Repo - GetCarByVin - No car with VIN %s", vin)
+		return model.Car{}, ErrCarNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCarByVin - Error executing select query: %v", err)
+		return model.Car{}, err
+	}
+	return car, nil
+}
+
+func (c *CarRepositoryImpl) GetCarsByIds(ctx context.Context, carIds []int) ([]model.Car, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM cars.car
+	WHERE id = ANY($1)
+	ORDER BY id ASC`
+
+	rows, err := c.conn.Query(ctx, query, carIds)
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCarsByIds - Error executing select query: %v", err)
+		return nil, err
+	}
+	cars, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Car, error) {
+		var car model.Car
+		err := row.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+			&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &car.LastSyncedAt)
+		return car, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCarsByIds - Error scanning rows: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[INFO] Repo - GetCarsByIds - Found %d of %d cars", len(cars), len(carIds))
+	return cars, nil
+}
+
 func (c *CarRepositoryImpl) GetCars(ctx context.Context, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.Car, dto.Cursors, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
 
 	if limit == 0 {
 		return []model.Car{}, dto.Cursors{}, errors.New("limit cannot be zero")
@@ -126,7 +241,7 @@ func (c *CarRepositoryImpl) GetCars(ctx context.Context, limit int, mark, carMod
 	}
 
 	values := make([]interface{}, 0, 8)
-	rowsLeftQuery := "SELECT COUNT(*) FROM car c"
+	rowsLeftQuery := "SELECT COUNT(*) FROM cars.car c"
 	pagination := ""
 
 	if cursors.Next != "" {
@@ -156,11 +271,11 @@ func (c *CarRepositoryImpl) GetCars(ctx context.Context, limit int, mark, carMod
 
 	stmt := fmt.Sprintf(`
 	WITH c AS (
-		SELECT * FROM car c %s
+		SELECT * FROM cars.car c %s
 	)
-	SELECT id, mark, model, year, reg_num,
+	SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
 	(%s) AS rows_left,
-	(SELECT COUNT(*) FROM car) AS total
+	(SELECT COUNT(*) FROM cars.car) AS total
 	FROM c
 	ORDER BY id ASC
 	`, pagination, rowsLeftQuery)
@@ -185,45 +300,31 @@ func (c *CarRepositoryImpl) GetCars(ctx context.Context, limit int, mark, carMod
 	for rows.Next() {
 		var car model.Car
 
-		err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &rowsLeft, &total)
+		err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin, &rowsLeft, &total)
 		if err != nil {
 			log.Printf("[ERROR] Repo - GetCars - Error scanning row: %v", err)
 			return []model.Car{}, dto.Cursors{}, err
 		}
 		cars = append(cars, car)
 	}
-	var (
-		prevCursor string
-		nextCursor string
-	)
-
-	switch {
-	case rowsLeft < 0:
-	case cursors.Prev == "" && cursors.Next == "":
-		nextCursor = fmt.Sprint((cars[len(cars)-1].CarId))
-
-	case cursors.Next != "" && rowsLeft == len(cars):
-		prevCursor = fmt.Sprint(cars[0].CarId)
-
-	case cursors.Prev != "" && rowsLeft == len(cars):
-		nextCursor = fmt.Sprint(cars[len(cars)-1].CarId)
-
-	case cursors.Prev != "" && total == rowsLeft:
-		prevCursor = fmt.Sprint(cars[0].CarId)
-
-	default:
-		nextCursor = fmt.Sprint(cars[len(cars)-1].CarId)
-		prevCursor = fmt.Sprint(cars[0].CarId)
+	if len(cars) == 0 {
+		log.Println("[INFO] Repo - GetCars - Got 0 records from the database")
+		return []model.Car{}, dto.Cursors{}, nil
 	}
+
 	log.Printf("[INFO] Repo - GetCars - Got %d records from the database", len(cars))
 
-	return cars, dto.Cursors{Prev: prevCursor, Next: nextCursor}, nil
+	return cars, pageCursors(cursors, cars, rowsLeft, total), nil
 }
 
 func (c *CarRepositoryImpl) UpdateCar(ctx context.Context, car model.Car) error {
-	query := `UPDATE car
-	SET mark = $1, model = $2, year = $3, reg_num = $4
-	WHERE id = $5`
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `UPDATE cars.car
+	SET mark = $1, model = $2, year = $3, reg_num = $4,
+	owner_name = $5, owner_surname = $6, owner_patronymic = $7, vin = $9
+	WHERE id = $8`
 
 	tx, err := c.conn.Begin(ctx)
 	if err != nil {
@@ -232,14 +333,17 @@ func (c *CarRepositoryImpl) UpdateCar(ctx context.Context, car model.Car) error
 	}
 	defer tx.Rollback(ctx)
 
-	commandTag, err := c.conn.Exec(ctx, query, car.Mark, car.Model, car.Year, car.RegNum, car.CarId)
+	commandTag, err := tx.Exec(ctx, query, car.Mark, car.Model, car.Year, car.RegNum,
+		car.OwnerName, car.OwnerSurname, car.OwnerPatronymic, car.CarId, nullableVin(car.Vin))
 	if err != nil {
 		log.Printf("[ERROR] Repo - UpdateCar - Error executing delete query: %v", err)
-		return err
+		return mapPgError(err)
 	}
 	if commandTag.RowsAffected() <= 0 {
-		err := fmt.Errorf("no rows found to update")
-		log.Printf("[ERROR] Repo - UpdateCar - Error executing delete query: %v", err)
+		log.Printf("[ERROR] Repo - UpdateCar - Error executing delete query: %v", ErrCarNotFound)
+		return ErrCarNotFound
+	}
+	if err := appendCarEvents(ctx, tx, carEvents(ctx, model.CarUpdated, []model.Car{car})); err != nil {
 		return err
 	}
 
@@ -253,3 +357,574 @@ func (c *CarRepositoryImpl) UpdateCar(ctx context.Context, car model.Car) error
 	log.Printf("[INFO] Repo - UpdateCar - Car updated successfuly")
 	return nil
 }
+
+// UpdateCars sends every UPDATE in one batch inside a transaction. Like the
+// other bulk calls it is limited only by the caller's context.
+func (c *CarRepositoryImpl) UpdateCars(ctx context.Context, cars []model.Car) error {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - UpdateCars - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateCarsTx(ctx, tx, cars); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - UpdateCars - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+
+	log.Printf("[INFO] Repo - UpdateCars - %d cars updated", len(cars))
+	return nil
+}
+
+// ModifyCars holds the rows of carIds with SELECT ... FOR UPDATE while modify
+// runs, so a concurrent UpdateCar waits instead of being overwritten.
+func (c *CarRepositoryImpl) ModifyCars(ctx context.Context, carIds []int, modify func([]model.Car) ([]model.Car, error)) error {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT `+pgCarColumns+`
+	FROM cars.car
+	WHERE id = ANY($1)
+	ORDER BY id ASC
+	FOR UPDATE`, carIds)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Error executing select query: %v", err)
+		return err
+	}
+	cars, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Car, error) {
+		return scanPgCar(row)
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Error scanning rows: %v", err)
+		return err
+	}
+
+	changed, err := modify(cars)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := updateCarsTx(ctx, tx, changed); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+
+	log.Printf("[INFO] Repo - ModifyCars - %d of %d cars updated", len(changed), len(carIds))
+	return nil
+}
+
+// updateCarsTx writes cars and their events in tx.
+func updateCarsTx(ctx context.Context, tx pgx.Tx, cars []model.Car) error {
+	query := `UPDATE cars.car
+	SET mark = $1, model = $2, year = $3, reg_num = $4,
+	owner_name = $5, owner_surname = $6, owner_patronymic = $7, vin = $9
+	WHERE id = $8`
+
+	batch := &pgx.Batch{}
+	for _, car := range cars {
+		batch.Queue(query, car.Mark, car.Model, car.Year, car.RegNum,
+			car.OwnerName, car.OwnerSurname, car.OwnerPatronymic, car.CarId, nullableVin(car.Vin))
+	}
+	results := tx.SendBatch(ctx, batch)
+	for _, car := range cars {
+		commandTag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			log.Printf("[ERROR] Repo - UpdateCars - Error updating car %d: %v", car.CarId, err)
+			return mapPgError(err)
+		}
+		if commandTag.RowsAffected() == 0 {
+			results.Close()
+			return fmt.Errorf("%w: %d", ErrCarNotFound, car.CarId)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return mapPgError(err)
+	}
+	return appendCarEvents(ctx, tx, carEvents(ctx, model.CarUpdated, cars))
+}
+
+func (c *CarRepositoryImpl) DeleteCars(ctx context.Context, carIds []int) error {
+	query := `DELETE FROM cars.car
+	WHERE id = ANY($1)
+	RETURNING ` + pgCarColumns
+
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteCars - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, carIds)
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteCars - Error executing delete query: %v", err)
+		return err
+	}
+	deletedCars, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Car, error) {
+		return scanPgCar(row)
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteCars - Error executing delete query: %v", err)
+		return err
+	}
+	deleted := make([]int, len(deletedCars))
+	for i, car := range deletedCars {
+		deleted[i] = car.CarId
+	}
+	if missing, ok := firstMissing(carIds, deleted); ok {
+		return fmt.Errorf("%w: %d", ErrCarNotFound, missing)
+	}
+	if err := appendCarEvents(ctx, tx, carEvents(ctx, model.CarDeleted, deletedCars)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - DeleteCars - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+
+	log.Printf("[INFO] Repo - DeleteCars - %d cars deleted", len(deleted))
+	return nil
+}
+
+// pgCarColumns reads a whole car row, as RETURNING of the deletes that
+// record it in the change feed.
+const pgCarColumns = `id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at`
+
+func scanPgCar(row pgx.Row) (model.Car, error) {
+	var car model.Car
+	err := row.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+		&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &car.LastSyncedAt)
+	return car, err
+}
+
+// StreamCars walks every car matching the filters through a server-side
+// cursor, fetching streamBatchSize rows at a time, so exports never hold the
+// whole table in memory.
+func (c *CarRepositoryImpl) StreamCars(ctx context.Context, mark, carModel, year string, fn func(model.Car) error) error {
+	query := `DECLARE export_cursor NO SCROLL CURSOR FOR
+	SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, '')
+	FROM cars.car
+	WHERE ($1 = '' OR mark = $1) AND ($2 = '' OR model = $2) AND ($3 = '' OR year = $3::int)
+	ORDER BY id ASC`
+
+	tx, err := c.conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		log.Printf("[ERROR] Repo - StreamCars - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, query, mark, carModel, year); err != nil {
+		log.Printf("[ERROR] Repo - StreamCars - Error declaring cursor: %v", err)
+		return err
+	}
+
+	streamed := 0
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM export_cursor", streamBatchSize))
+		if err != nil {
+			log.Printf("[ERROR] Repo - StreamCars - Error fetching from cursor: %v", err)
+			return err
+		}
+
+		fetched := 0
+		for rows.Next() {
+			var car model.Car
+			err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+				&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic)
+			if err != nil {
+				rows.Close()
+				log.Printf("[ERROR] Repo - StreamCars - Error scanning row: %v", err)
+				return err
+			}
+			if err := fn(car); err != nil {
+				rows.Close()
+				return err
+			}
+			fetched++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Printf("[ERROR] Repo - StreamCars - Error reading rows: %v", err)
+			return err
+		}
+
+		streamed += fetched
+		if fetched < streamBatchSize {
+			break
+		}
+	}
+
+	log.Printf("[INFO] Repo - StreamCars - Streamed %d records from the database", streamed)
+	return nil
+}
+
+// SearchCars matches every variant against the trigram index on search_text
+// and the full-text index on search_tsv. The score is the best trigram word
+// similarity plus the full-text rank, so whole-word hits come first.
+func (c *CarRepositoryImpl) SearchCars(ctx context.Context, variants []string, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.CarMatch, dto.Cursors, error) {
+	if err := checkSearchArgs(variants, limit, cursors); err != nil {
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	values := []any{mark, carModel, year}
+	var similarities, ranks, matches []string
+	for _, v := range variants {
+		values = append(values, v)
+		n := len(values)
+		similarities = append(similarities, fmt.Sprintf("word_similarity($%d, search_text)", n))
+		ranks = append(ranks, fmt.Sprintf("ts_rank(search_tsv, plainto_tsquery('simple', $%d))", n))
+		matches = append(matches, fmt.Sprintf("$%d <%% search_text OR search_tsv @@ plainto_tsquery('simple', $%d)", n, n))
+	}
+
+	order, position := "score DESC, id ASC", ""
+	var cursor string
+	switch {
+	case cursors.Next != "":
+		cursor, position = cursors.Next, "score < $%[1]d OR (score = $%[1]d AND id > $%[2]d)"
+	case cursors.Prev != "":
+		cursor, position = cursors.Prev, "score > $%[1]d OR (score = $%[1]d AND id < $%[2]d)"
+		order = "score ASC, id DESC"
+	}
+	if cursor != "" {
+		parsed, err := parseSearchCursor(cursor)
+		if err != nil {
+			return []model.CarMatch{}, dto.Cursors{}, err
+		}
+		values = append(values, parsed.score, parsed.id)
+		position = "WHERE " + fmt.Sprintf(position, len(values)-1, len(values))
+	}
+	// One extra row tells whether another page follows.
+	values = append(values, limit+1)
+
+	stmt := fmt.Sprintf(`
+	WITH m AS (
+		SELECT id, mark, model, year, reg_num, COALESCE(vin, '') AS vin,
+		(GREATEST(%s) + GREATEST(%s))::float8 AS score
+		FROM cars.car
+		WHERE (%s)
+		AND ($1 = '' OR mark = $1) AND ($2 = '' OR model = $2) AND ($3 = '' OR year = $3::int)
+	)
+	SELECT id, mark, model, year, reg_num, vin, score
+	FROM m
+	%s
+	ORDER BY %s
+	LIMIT $%d`,
+		strings.Join(similarities, ", "), strings.Join(ranks, ", "), strings.Join(matches, " OR "),
+		position, order, len(values))
+
+	log.Printf("[DEBUG] Repo - SearchCars - Statement: %s", stmt)
+	log.Printf("[DEBUG] Repo - SearchCars - Values: %+v", values)
+
+	tx, err := c.conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		log.Printf("[ERROR] Repo - SearchCars - Failed to begin transaction: %v", err)
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// <% uses this threshold; SET LOCAL keeps it to this transaction.
+	threshold := strconv.FormatFloat(search.Threshold, 'f', -1, 64)
+	if _, err := tx.Exec(ctx, "SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)", threshold); err != nil {
+		log.Printf("[ERROR] Repo - SearchCars - Unable to set the similarity threshold: %v", err)
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+
+	rows, err := tx.Query(ctx, stmt, values...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - SearchCars - Error executing select query: %v", err)
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+	page, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.CarMatch, error) {
+		var m model.CarMatch
+		err := row.Scan(&m.CarId, &m.Mark, &m.Model, &m.Year, &m.RegNum, &m.Vin, &m.Score)
+		return m, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - SearchCars - Error scanning row: %v", err)
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+
+	hasMore := len(page) > limit
+	if hasMore {
+		page = page[:limit]
+	}
+	if cursors.Prev != "" {
+		for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+			page[i], page[j] = page[j], page[i]
+		}
+	}
+	if len(page) == 0 {
+		log.Println("[INFO] Repo - SearchCars - Got 0 records from the database")
+		return []model.CarMatch{}, dto.Cursors{}, nil
+	}
+
+	log.Printf("[INFO] Repo - SearchCars - Got %d records from the database", len(page))
+	return page, searchPageCursors(cursors, page, hasMore), nil
+}
+
+func (c *CarRepositoryImpl) FindExistingRegNums(ctx context.Context, regNums []string) ([]string, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT reg_num
+	FROM cars.car
+	WHERE reg_num = ANY($1)`
+
+	rows, err := c.conn.Query(ctx, query, regNums)
+	if err != nil {
+		log.Printf("[ERROR] Repo - FindExistingRegNums - Error executing select query: %v", err)
+		return nil, err
+	}
+
+	existing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		log.Printf("[ERROR] Repo - FindExistingRegNums - Error scanning rows: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[INFO] Repo - FindExistingRegNums - %d of %d registration numbers already exist", len(existing), len(regNums))
+	return existing, nil
+}
+
+// The suggest queries are prefix range scans of the text_pattern_ops indexes
+// from migration 7, or of those leading with tenant from migration 17. Ties
+// are broken in byte order, like the other backends.
+
+func (c *CarRepositoryImpl) SuggestMarks(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error) {
+	query := `SELECT mark, COUNT(*)
+	FROM cars.car
+	WHERE lower(mark) LIKE $1 AND %s
+	GROUP BY mark
+	ORDER BY COUNT(*) DESC, mark COLLATE "C"
+	LIMIT $2`
+
+	return c.suggest(ctx, "SuggestMarks", query, tenant, likePrefix(strings.ToLower(prefix)), limit)
+}
+
+func (c *CarRepositoryImpl) SuggestModels(ctx context.Context, tenant *string, mark, prefix string, limit int) ([]model.Suggestion, error) {
+	if mark == "" {
+		query := `SELECT model, COUNT(*)
+		FROM cars.car
+		WHERE lower(model) LIKE $1 AND %s
+		GROUP BY model
+		ORDER BY COUNT(*) DESC, model COLLATE "C"
+		LIMIT $2`
+
+		return c.suggest(ctx, "SuggestModels", query, tenant, likePrefix(strings.ToLower(prefix)), limit)
+	}
+
+	query := `SELECT model, COUNT(*)
+	FROM cars.car
+	WHERE lower(mark) = lower($1) AND lower(model) LIKE $2 AND %s
+	GROUP BY model
+	ORDER BY COUNT(*) DESC, model COLLATE "C"
+	LIMIT $3`
+
+	return c.suggest(ctx, "SuggestModels", query, tenant, mark, likePrefix(strings.ToLower(prefix)), limit)
+}
+
+func (c *CarRepositoryImpl) SuggestRegNums(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error) {
+	query := `SELECT reg_num, 1
+	FROM cars.car
+	WHERE reg_num LIKE $1 AND %s
+	ORDER BY reg_num COLLATE "C"
+	LIMIT $2`
+
+	return c.suggest(ctx, "SuggestRegNums", query, tenant, likePrefix(prefix), limit)
+}
+
+// suggest runs query with the tenant condition in place of its %s, as the
+// parameter after args.
+func (c *CarRepositoryImpl) suggest(ctx context.Context, name, query string, tenant *string, args ...any) ([]model.Suggestion, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	if tenant != nil {
+		args = append(args, *tenant)
+		query = fmt.Sprintf(query, "tenant = $"+strconv.Itoa(len(args)))
+	} else {
+		query = fmt.Sprintf(query, "TRUE")
+	}
+
+	rows, err := c.conn.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error executing select query: %v", name, err)
+		return nil, err
+	}
+	suggestions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Suggestion, error) {
+		var s model.Suggestion
+		err := row.Scan(&s.Value, &s.Count)
+		return s, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error scanning rows: %v", name, err)
+		return nil, err
+	}
+
+	log.Printf("[DEBUG] Repo - %s - Got %d suggestions", name, len(suggestions))
+	return suggestions, nil
+}
+
+func (c *CarRepositoryImpl) CarStats(ctx context.Context, query model.StatsQuery) (model.CarStats, error) {
+	if err := checkStatsQuery(query); err != nil {
+		return model.CarStats{}, err
+	}
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	values := []any{query.Mark, query.Model, query.Year}
+	where := "($1 = '' OR mark = $1) AND ($2 = '' OR model = $2) AND ($3 = '' OR year = $3::int)"
+	if len(query.Variants) > 0 {
+		var matches []string
+		for _, v := range query.Variants {
+			values = append(values, v)
+			n := len(values)
+			matches = append(matches, fmt.Sprintf("$%d <%% search_text OR search_tsv @@ plainto_tsquery('simple', $%d)", n, n))
+		}
+		where += " AND (" + strings.Join(matches, " OR ") + ")"
+	}
+	stmt, values := statsStatement("cars.car", statsColumns, "COUNT(*)", where, query, values)
+
+	log.Printf("[DEBUG] Repo - CarStats - Statement: %s", stmt)
+	log.Printf("[DEBUG] Repo - CarStats - Values: %+v", values)
+
+	tx, err := c.conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		log.Printf("[ERROR] Repo - CarStats - Failed to begin transaction: %v", err)
+		return model.CarStats{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(query.Variants) > 0 {
+		threshold := strconv.FormatFloat(search.Threshold, 'f', -1, 64)
+		if _, err := tx.Exec(ctx, "SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)", threshold); err != nil {
+			log.Printf("[ERROR] Repo - CarStats - Unable to set the similarity threshold: %v", err)
+			return model.CarStats{}, err
+		}
+	}
+
+	stats, err := queryStats(ctx, tx, stmt, values, query.GroupBy)
+	if err != nil {
+		log.Printf("[ERROR] Repo - CarStats - Error executing select query: %v", err)
+		return model.CarStats{}, err
+	}
+	log.Printf("[DEBUG] Repo - CarStats - Got %d of %d groups", len(stats.Groups), stats.GroupCount)
+	return stats, nil
+}
+
+func (c *CarRepositoryImpl) ListStaleCars(ctx context.Context, before time.Time, limit int) ([]model.Car, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM cars.car
+	WHERE last_synced_at IS NULL OR last_synced_at < $1
+	ORDER BY last_synced_at ASC NULLS FIRST, id ASC
+	LIMIT $2`
+
+	rows, err := c.conn.Query(ctx, query, before, limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListStaleCars - Error executing select query: %v", err)
+		return nil, err
+	}
+
+	cars, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Car, error) {
+		var car model.Car
+		err := row.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+			&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &car.LastSyncedAt)
+		return car, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListStaleCars - Error scanning rows: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[INFO] Repo - ListStaleCars - Got %d cars synced before %s", len(cars), before.Format(time.RFC3339))
+	return cars, nil
+}
+
+func (c *CarRepositoryImpl) MarkSynced(ctx context.Context, carId int, at time.Time) error {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	commandTag, err := c.conn.Exec(ctx, `UPDATE cars.car SET last_synced_at = $1 WHERE id = $2`, at, carId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - MarkSynced - Error executing update query: %v", err)
+		return err
+	}
+	if commandTag.RowsAffected() <= 0 {
+		return ErrCarNotFound
+	}
+	return nil
+}
+
+func (c *CarRepositoryImpl) AddDivergences(ctx context.Context, divergences []model.Divergence) error {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	entries := make([][]any, 0, len(divergences))
+	for _, d := range divergences {
+		entries = append(entries, []any{d.CarId, d.Field, d.LocalValue, d.RegistryValue, d.DetectedAt, d.Status, d.Error})
+	}
+
+	_, err := c.conn.CopyFrom(
+		ctx,
+		pgx.Identifier{"cars", "car_divergence"},
+		[]string{"car_id", "field", "local_value", "registry_value", "detected_at", "status", "error"},
+		pgx.CopyFromRows(entries),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddDivergences - Error copying divergences: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (c *CarRepositoryImpl) ListDivergences(ctx context.Context, carId int, limit int) ([]model.Divergence, error) {
+	ctx, cancel := c.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, car_id, field, local_value, registry_value, detected_at, status, error
+	FROM cars.car_divergence
+	WHERE $1 = 0 OR car_id = $1
+	ORDER BY id DESC
+	LIMIT $2`
+
+	rows, err := c.conn.Query(ctx, query, carId, limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDivergences - Error executing select query: %v", err)
+		return nil, err
+	}
+
+	divergences, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Divergence, error) {
+		var d model.Divergence
+		err := row.Scan(&d.Id, &d.CarId, &d.Field, &d.LocalValue, &d.RegistryValue, &d.DetectedAt, &d.Status, &d.Error)
+		return d, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDivergences - Error scanning rows: %v", err)
+		return nil, err
+	}
+	return divergences, nil
+}
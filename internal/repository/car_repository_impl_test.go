@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/database"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/repository/repotest"
+	"context"
+	"flag"
+	"os"
+	"testing"
+)
+
+// TestCarRepositoryImpl runs the conformance suite against Postgres. It needs
+// a disposable database described by the usual DB_* variables and is skipped
+// unless TEST_POSTGRES is set.
+func TestCarRepositoryImpl(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("set TEST_POSTGRES=1 and DB_* variables to run against a disposable database")
+	}
+
+	cfg, err := config.NewLoader(flag.NewFlagSet("test", flag.ContinueOnError)).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.MigrateDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	conn := database.DatabaseConnection(cfg)
+	defer conn.Close()
+
+	repotest.Run(t, func(t *testing.T) repository.CarRepository {
+		if _, err := conn.Exec(context.Background(), "TRUNCATE cars.car, cars.car_divergence, cars.car_attachment RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewCarRepository(conn, cfg.Database.QueryTimeout)
+	})
+
+	repotest.RunDictionary(t, func(t *testing.T) repository.DictionaryRepository {
+		if _, err := conn.Exec(context.Background(), "TRUNCATE cars.make, cars.make_alias, cars.model_ref, cars.model_alias RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewDictionaryRepository(conn, cfg.Database.QueryTimeout)
+	})
+
+	repotest.RunEventLog(t, func(t *testing.T) (repository.CarRepository, repository.CarEventLog) {
+		if _, err := conn.Exec(context.Background(), "TRUNCATE cars.car, cars.car_divergence, cars.car_event, cars.car_attachment RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewCarRepository(conn, cfg.Database.QueryTimeout), repository.NewPostgresCarEventLog(conn, cfg.Database.QueryTimeout)
+	})
+
+	repotest.RunWebhooks(t, func(t *testing.T) repository.WebhookRepository {
+		if _, err := conn.Exec(context.Background(), `TRUNCATE cars.webhook_subscription, cars.webhook_delivery RESTART IDENTITY;
+			UPDATE cars.webhook_cursor SET last_event_id = 0`); err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewWebhookRepository(conn, cfg.Database.QueryTimeout)
+	})
+
+	repotest.RunAttachments(t, func(t *testing.T) (repository.CarRepository, repository.AttachmentRepository) {
+		if _, err := conn.Exec(context.Background(), "TRUNCATE cars.car, cars.car_divergence, cars.car_event, cars.car_attachment RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewCarRepository(conn, cfg.Database.QueryTimeout), repository.NewAttachmentRepository(conn, cfg.Database.QueryTimeout)
+	})
+}
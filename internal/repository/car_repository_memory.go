@@ -0,0 +1,585 @@
+package repository
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCarRepository keeps the catalog in process memory. It mirrors the
+// Postgres implementation: ids come from a never-reused sequence, reg_num and
+// a set vin are unique, and GetCars reproduces the same filters and cursor rules. It is
+// meant for tests and demo mode; nothing survives a restart.
+type MemoryCarRepository struct {
+	mu      sync.RWMutex
+	cars    map[int]model.Car
+	regNums map[string]int
+	vins    map[string]int
+	// tenants holds the API key each car was added with.
+	tenants     map[int]string
+	nextId      int
+	divergences []model.Divergence
+	nextDivId   int
+	events      *MemoryCarEventLog
+}
+
+func NewMemoryCarRepository() CarRepository {
+	return &MemoryCarRepository{
+		cars:      make(map[int]model.Car),
+		regNums:   make(map[string]int),
+		vins:      make(map[string]int),
+		tenants:   make(map[int]string),
+		nextId:    1,
+		nextDivId: 1,
+		events:    newMemoryCarEventLog(),
+	}
+}
+
+func (m *MemoryCarRepository) AddCars(ctx context.Context, cars []model.Car) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like COPY, the batch is all or nothing.
+	batch := make(map[string]bool, len(cars))
+	batchVins := make(map[string]bool)
+	for _, car := range cars {
+		if _, exists := m.regNums[car.RegNum]; exists || batch[car.RegNum] {
+			return fmt.Errorf("%w: %s", ErrDuplicateRegNum, car.RegNum)
+		}
+		batch[car.RegNum] = true
+		if car.Vin == "" {
+			continue
+		}
+		if _, exists := m.vins[car.Vin]; exists || batchVins[car.Vin] {
+			return fmt.Errorf("%w: %s", ErrDuplicateVin, car.Vin)
+		}
+		batchVins[car.Vin] = true
+	}
+
+	for i := range cars {
+		cars[i].CarId = m.nextId
+		m.nextId++
+		car := cars[i]
+		m.cars[car.CarId] = car
+		m.regNums[car.RegNum] = car.CarId
+		m.tenants[car.CarId] = tenantOf(ctx)
+		if car.Vin != "" {
+			m.vins[car.Vin] = car.CarId
+		}
+	}
+	m.events.record(carEvents(ctx, model.CarCreated, cars))
+
+	log.Printf("[INFO] Repo - AddCars - New cars recorded, %d rows inserted", len(cars))
+	return nil
+}
+
+func (m *MemoryCarRepository) GetCarById(ctx context.Context, carId int) (model.Car, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	car, ok := m.cars[carId]
+	if !ok {
+		log.Printf("[ERROR] Repo - GetCarById - No car with id %d", carId)
+		return model.Car{}, ErrCarNotFound
+	}
+	return car, nil
+}
+
+func (m *MemoryCarRepository) GetCarByVin(ctx context.Context, vin string) (model.Car, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.vins[vin]
+	if !ok {
+		log.Printf("[INFO] Repo - GetCarByVin - No car with VIN %s", vin)
+		return model.Car{}, ErrCarNotFound
+	}
+	return m.cars[id], nil
+}
+
+func (m *MemoryCarRepository) GetCarsByIds(ctx context.Context, carIds []int) ([]model.Car, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cars := []model.Car{}
+	seen := make(map[int]bool, len(carIds))
+	for _, carId := range carIds {
+		if car, ok := m.cars[carId]; ok && !seen[carId] {
+			seen[carId] = true
+			cars = append(cars, car)
+		}
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].CarId < cars[j].CarId })
+	return cars, nil
+}
+
+func (m *MemoryCarRepository) GetCars(ctx context.Context, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.Car, dto.Cursors, error) {
+	if limit == 0 {
+		return []model.Car{}, dto.Cursors{}, errors.New("limit cannot be zero")
+	}
+	if cursors.Next != "" && cursors.Prev != "" {
+		return []model.Car{}, dto.Cursors{}, errors.New("two cursors cannot be provided at the same time")
+	}
+	match, err := memoryFilter(mark, carModel, year)
+	if err != nil {
+		return []model.Car{}, dto.Cursors{}, err
+	}
+
+	var after, before int
+	if cursors.Next != "" {
+		if after, err = strconv.Atoi(cursors.Next); err != nil {
+			return []model.Car{}, dto.Cursors{}, fmt.Errorf("invalid next cursor: %w", err)
+		}
+	}
+	if cursors.Prev != "" {
+		if before, err = strconv.Atoi(cursors.Prev); err != nil {
+			return []model.Car{}, dto.Cursors{}, fmt.Errorf("invalid prev cursor: %w", err)
+		}
+	}
+
+	m.mu.RLock()
+	all := m.sortedLocked()
+	m.mu.RUnlock()
+
+	// rowsLeft counts every car past the cursor regardless of the filters,
+	// exactly like the Postgres rows_left subquery.
+	var (
+		page     []model.Car
+		rowsLeft int
+	)
+	switch {
+	case cursors.Prev != "":
+		for i := len(all) - 1; i >= 0; i-- {
+			car := all[i]
+			if car.CarId >= before {
+				continue
+			}
+			rowsLeft++
+			if match(car) && len(page) < limit {
+				page = append([]model.Car{listed(car)}, page...)
+			}
+		}
+	default:
+		for _, car := range all {
+			if car.CarId <= after {
+				continue
+			}
+			rowsLeft++
+			if match(car) && len(page) < limit {
+				page = append(page, listed(car))
+			}
+		}
+	}
+	if len(page) == 0 {
+		return []model.Car{}, dto.Cursors{}, nil
+	}
+
+	log.Printf("[INFO] Repo - GetCars - Got %d records from memory", len(page))
+	return page, pageCursors(cursors, page, rowsLeft, len(all)), nil
+}
+
+func (m *MemoryCarRepository) UpdateCar(ctx context.Context, car model.Car) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.cars[car.CarId]
+	if !ok {
+		return ErrCarNotFound
+	}
+	if owner, exists := m.regNums[car.RegNum]; exists && owner != car.CarId {
+		return fmt.Errorf("%w: %s", ErrDuplicateRegNum, car.RegNum)
+	}
+	if owner, exists := m.vins[car.Vin]; car.Vin != "" && exists && owner != car.CarId {
+		return fmt.Errorf("%w: %s", ErrDuplicateVin, car.Vin)
+	}
+
+	// Only the columns touched by the Postgres UPDATE change.
+	delete(m.regNums, stored.RegNum)
+	delete(m.vins, stored.Vin)
+	stored.Mark = car.Mark
+	stored.Model = car.Model
+	stored.Year = car.Year
+	stored.RegNum = car.RegNum
+	stored.Vin = car.Vin
+	stored.OwnerName = car.OwnerName
+	stored.OwnerSurname = car.OwnerSurname
+	stored.OwnerPatronymic = car.OwnerPatronymic
+	m.cars[car.CarId] = stored
+	m.regNums[stored.RegNum] = stored.CarId
+	if stored.Vin != "" {
+		m.vins[stored.Vin] = stored.CarId
+	}
+	m.events.record(carEvents(ctx, model.CarUpdated, []model.Car{stored}))
+
+	log.Printf("[INFO] Repo - UpdateCar - Car updated successfuly")
+	return nil
+}
+
+func (m *MemoryCarRepository) DeleteCar(ctx context.Context, carId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted, ok := m.cars[carId]
+	if !ok {
+		return ErrCarNotFound
+	}
+	m.deleteLocked(carId)
+	m.events.record(carEvents(ctx, model.CarDeleted, []model.Car{deleted}))
+
+	log.Println("[INFO] Repo - DeleteCar - Car deleted successfuly")
+	return nil
+}
+
+func (m *MemoryCarRepository) UpdateCars(ctx context.Context, cars []model.Car) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.updateCarsLocked(ctx, cars); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Repo - UpdateCars - %d cars updated", len(cars))
+	return nil
+}
+
+// ModifyCars holds the write lock of the repository while modify runs.
+func (m *MemoryCarRepository) ModifyCars(ctx context.Context, carIds []int, modify func([]model.Car) ([]model.Car, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := slices.Clone(carIds)
+	slices.Sort(ids)
+	var cars []model.Car
+	for _, id := range slices.Compact(ids) {
+		if car, ok := m.cars[id]; ok {
+			cars = append(cars, car)
+		}
+	}
+
+	changed, err := modify(cars)
+	if err != nil {
+		return err
+	}
+	if err := m.updateCarsLocked(ctx, changed); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Repo - ModifyCars - %d of %d cars updated", len(changed), len(carIds))
+	return nil
+}
+
+// updateCarsLocked checks the batch row by row against the state left by the
+// previous rows, as Postgres does, before changing anything.
+func (m *MemoryCarRepository) updateCarsLocked(ctx context.Context, cars []model.Car) error {
+	if len(cars) == 0 {
+		return nil
+	}
+	regNums := make(map[string]int, len(m.regNums))
+	for regNum, id := range m.regNums {
+		regNums[regNum] = id
+	}
+	vins := make(map[string]int, len(m.vins))
+	for vin, id := range m.vins {
+		vins[vin] = id
+	}
+	current := make(map[int]model.Car, len(cars))
+	for _, car := range cars {
+		stored, ok := m.cars[car.CarId]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrCarNotFound, car.CarId)
+		}
+		old, ok := current[car.CarId]
+		if !ok {
+			old = stored
+		}
+		if owner, exists := regNums[car.RegNum]; exists && owner != car.CarId {
+			return fmt.Errorf("%w: %s", ErrDuplicateRegNum, car.RegNum)
+		}
+		if owner, exists := vins[car.Vin]; car.Vin != "" && exists && owner != car.CarId {
+			return fmt.Errorf("%w: %s", ErrDuplicateVin, car.Vin)
+		}
+		delete(regNums, old.RegNum)
+		regNums[car.RegNum] = car.CarId
+		delete(vins, old.Vin)
+		if car.Vin != "" {
+			vins[car.Vin] = car.CarId
+		}
+		current[car.CarId] = car
+	}
+
+	updated := make([]model.Car, 0, len(cars))
+	for _, car := range cars {
+		stored := m.cars[car.CarId]
+		stored.Mark = car.Mark
+		stored.Model = car.Model
+		stored.Year = car.Year
+		stored.RegNum = car.RegNum
+		stored.Vin = car.Vin
+		stored.OwnerName = car.OwnerName
+		stored.OwnerSurname = car.OwnerSurname
+		stored.OwnerPatronymic = car.OwnerPatronymic
+		m.cars[car.CarId] = stored
+		updated = append(updated, stored)
+	}
+	m.regNums = regNums
+	m.vins = vins
+	m.events.record(carEvents(ctx, model.CarUpdated, updated))
+	return nil
+}
+
+func (m *MemoryCarRepository) DeleteCars(ctx context.Context, carIds []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range carIds {
+		if _, ok := m.cars[id]; !ok {
+			return fmt.Errorf("%w: %d", ErrCarNotFound, id)
+		}
+	}
+	var deleted []model.Car
+	for _, id := range carIds {
+		if car, ok := m.cars[id]; ok {
+			m.deleteLocked(id)
+			deleted = append(deleted, car)
+		}
+	}
+	m.events.record(carEvents(ctx, model.CarDeleted, deleted))
+
+	log.Printf("[INFO] Repo - DeleteCars - %d cars deleted", len(deleted))
+	return nil
+}
+
+func (m *MemoryCarRepository) deleteLocked(carId int) {
+	delete(m.regNums, m.cars[carId].RegNum)
+	delete(m.vins, m.cars[carId].Vin)
+	delete(m.tenants, carId)
+	delete(m.cars, carId)
+
+	// Divergences go with the car, like ON DELETE CASCADE.
+	kept := m.divergences[:0]
+	for _, d := range m.divergences {
+		if d.CarId != carId {
+			kept = append(kept, d)
+		}
+	}
+	m.divergences = kept
+}
+
+func (m *MemoryCarRepository) FindExistingRegNums(ctx context.Context, regNums []string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	existing := []string{}
+	for _, regNum := range regNums {
+		if _, ok := m.regNums[regNum]; ok {
+			existing = append(existing, regNum)
+		}
+	}
+	return existing, nil
+}
+
+// StreamCars works on a snapshot, so fn may call back into the repository.
+func (m *MemoryCarRepository) StreamCars(ctx context.Context, mark, carModel, year string, fn func(model.Car) error) error {
+	match, err := memoryFilter(mark, carModel, year)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	all := m.sortedLocked()
+	m.mu.RUnlock()
+
+	for _, car := range all {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !match(car) {
+			continue
+		}
+		if err := fn(car); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCarRepository) SearchCars(ctx context.Context, variants []string, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.CarMatch, dto.Cursors, error) {
+	if err := checkSearchArgs(variants, limit, cursors); err != nil {
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+	match, err := memoryFilter(mark, carModel, year)
+	if err != nil {
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+
+	m.mu.RLock()
+	all := m.sortedLocked()
+	m.mu.RUnlock()
+
+	candidates := all[:0]
+	for _, car := range all {
+		if match(car) {
+			candidates = append(candidates, car)
+		}
+	}
+
+	page, pageCursors, err := rankCars(candidates, variants, limit, cursors)
+	if err != nil {
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+	log.Printf("[INFO] Repo - SearchCars - Got %d records from memory", len(page))
+	return page, pageCursors, nil
+}
+
+func (m *MemoryCarRepository) SuggestMarks(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error) {
+	return m.suggest(tenant, limit, func(car model.Car) (string, bool) {
+		return car.Mark, hasFoldPrefix(car.Mark, strings.ToLower(prefix))
+	}), nil
+}
+
+func (m *MemoryCarRepository) SuggestModels(ctx context.Context, tenant *string, mark, prefix string, limit int) ([]model.Suggestion, error) {
+	return m.suggest(tenant, limit, func(car model.Car) (string, bool) {
+		return car.Model, (mark == "" || strings.EqualFold(car.Mark, mark)) && hasFoldPrefix(car.Model, strings.ToLower(prefix))
+	}), nil
+}
+
+func (m *MemoryCarRepository) SuggestRegNums(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error) {
+	return m.suggest(tenant, limit, func(car model.Car) (string, bool) {
+		return car.RegNum, strings.HasPrefix(car.RegNum, prefix)
+	}), nil
+}
+
+// suggest counts the values pick selects among the cars of tenant.
+func (m *MemoryCarRepository) suggest(tenant *string, limit int, pick func(model.Car) (string, bool)) []model.Suggestion {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, car := range m.cars {
+		if tenant != nil && m.tenants[car.CarId] != *tenant {
+			continue
+		}
+		if value, ok := pick(car); ok {
+			counts[value]++
+		}
+	}
+	return topSuggestions(counts, limit)
+}
+
+func (m *MemoryCarRepository) CarStats(ctx context.Context, query model.StatsQuery) (model.CarStats, error) {
+	return aggregateStats(ctx, query, m.StreamCars)
+}
+
+func (m *MemoryCarRepository) ListStaleCars(ctx context.Context, before time.Time, limit int) ([]model.Car, error) {
+	m.mu.RLock()
+	all := m.sortedLocked()
+	m.mu.RUnlock()
+
+	var stale []model.Car
+	for _, car := range all {
+		if car.LastSyncedAt == nil || car.LastSyncedAt.Before(before) {
+			stale = append(stale, car)
+		}
+	}
+	// Never synced first, then oldest sync; ids break ties.
+	sort.SliceStable(stale, func(i, j int) bool {
+		a, b := stale[i].LastSyncedAt, stale[j].LastSyncedAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+	if len(stale) > limit {
+		stale = stale[:limit]
+	}
+	return stale, nil
+}
+
+func (m *MemoryCarRepository) MarkSynced(ctx context.Context, carId int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	car, ok := m.cars[carId]
+	if !ok {
+		return ErrCarNotFound
+	}
+	car.LastSyncedAt = &at
+	m.cars[carId] = car
+	return nil
+}
+
+func (m *MemoryCarRepository) AddDivergences(ctx context.Context, divergences []model.Divergence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range divergences {
+		if _, ok := m.cars[d.CarId]; !ok {
+			return fmt.Errorf("%w: divergence for car %d", ErrCarNotFound, d.CarId)
+		}
+	}
+	for _, d := range divergences {
+		d.Id = m.nextDivId
+		m.nextDivId++
+		m.divergences = append(m.divergences, d)
+	}
+	return nil
+}
+
+func (m *MemoryCarRepository) ListDivergences(ctx context.Context, carId int, limit int) ([]model.Divergence, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := []model.Divergence{}
+	for i := len(m.divergences) - 1; i >= 0 && len(result) < limit; i-- {
+		if d := m.divergences[i]; carId == 0 || d.CarId == carId {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryCarRepository) sortedLocked() []model.Car {
+	all := make([]model.Car, 0, len(m.cars))
+	for _, car := range m.cars {
+		all = append(all, car)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CarId < all[j].CarId })
+	return all
+}
+
+// memoryFilter applies the same "empty means any" rules as the SQL filters,
+// including the failure of a non-numeric year.
+func memoryFilter(mark, carModel, year string) (func(model.Car) bool, error) {
+	yearValue := 0
+	if year != "" {
+		var err error
+		if yearValue, err = strconv.Atoi(year); err != nil {
+			return nil, fmt.Errorf("invalid year filter %q: %w", year, err)
+		}
+	}
+
+	return func(car model.Car) bool {
+		return (mark == "" || car.Mark == mark) &&
+			(carModel == "" || car.Model == carModel) &&
+			(year == "" || car.Year == yearValue)
+	}, nil
+}
+
+// listed trims a car down to the columns GetCars selects.
+func listed(car model.Car) model.Car {
+	return model.Car{
+		CarId:  car.CarId,
+		Mark:   car.Mark,
+		Model:  car.Model,
+		Year:   car.Year,
+		RegNum: car.RegNum,
+		Vin:    car.Vin,
+	}
+}
@@ -0,0 +1,39 @@
+package repository_test
+
+import (
+	"car_catalog/internal/repository"
+	"car_catalog/internal/repository/repotest"
+	"testing"
+)
+
+func TestMemoryCarRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.CarRepository {
+		return repository.NewMemoryCarRepository()
+	})
+}
+
+func TestMemoryDictionaryRepository(t *testing.T) {
+	repotest.RunDictionary(t, func(t *testing.T) repository.DictionaryRepository {
+		return repository.NewMemoryDictionaryRepository()
+	})
+}
+
+func TestMemoryCarEventLog(t *testing.T) {
+	repotest.RunEventLog(t, func(t *testing.T) (repository.CarRepository, repository.CarEventLog) {
+		cars := repository.NewMemoryCarRepository()
+		return cars, repository.NewMemoryCarEventLog(cars)
+	})
+}
+
+func TestMemoryWebhookRepository(t *testing.T) {
+	repotest.RunWebhooks(t, func(t *testing.T) repository.WebhookRepository {
+		return repository.NewMemoryWebhookRepository()
+	})
+}
+
+func TestMemoryAttachmentRepository(t *testing.T) {
+	repotest.RunAttachments(t, func(t *testing.T) (repository.CarRepository, repository.AttachmentRepository) {
+		cars := repository.NewMemoryCarRepository()
+		return cars, repository.NewMemoryAttachmentRepository(cars)
+	})
+}
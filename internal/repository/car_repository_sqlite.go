@@ -0,0 +1,863 @@
+package repository
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteInsertBatch keeps multi-row INSERTs under SQLite's default limit of
+// 999 bound parameters (9 columns per row).
+const sqliteInsertBatch = 110
+
+// SQLiteCarRepository stores the catalog in a single SQLite file for
+// deployments without Postgres. It follows the Postgres implementation's
+// filter, cursor and uniqueness rules.
+type SQLiteCarRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+	// waker tells the event log of this database about new events.
+	waker *localWaker
+}
+
+func NewSQLiteCarRepository(db *sql.DB, queryTimeout time.Duration) CarRepository {
+	return &SQLiteCarRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+		waker:        sqliteWaker(db),
+	}
+}
+
+func (s *SQLiteCarRepository) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+func mapSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		if strings.Contains(sqliteErr.Error(), "car.vin") {
+			return fmt.Errorf("%w: %s", ErrDuplicateVin, sqliteErr.Error())
+		}
+		return fmt.Errorf("%w: %s", ErrDuplicateRegNum, sqliteErr.Error())
+	}
+	return err
+}
+
+func (s *SQLiteCarRepository) AddCars(ctx context.Context, cars []model.Car) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddCars - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(cars); start += sqliteInsertBatch {
+		batch := cars[start:min(start+sqliteInsertBatch, len(cars))]
+
+		placeholders := make([]string, 0, len(batch))
+		values := make([]any, 0, len(batch)*10)
+		for _, car := range batch {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			values = append(values,
+				car.Mark, car.Model, car.Year, car.RegNum, nullableVin(car.Vin),
+				car.OwnerName, car.OwnerSurname, car.OwnerPatronymic, toMillis(car.LastSyncedAt), tenantOf(ctx))
+		}
+
+		query := `INSERT INTO car (mark, model, year, reg_num, vin, owner_name, owner_surname, owner_patronymic, last_synced_at, tenant)
+	VALUES ` + strings.Join(placeholders, ", ") + `
+	RETURNING id, reg_num`
+		rows, err := tx.QueryContext(ctx, query, values...)
+		if err != nil {
+			return fmt.Errorf("[ERROR] Repo - AddCars - error inserting into car table: %w", mapSQLiteError(err))
+		}
+		var ids []assignedId
+		for rows.Next() {
+			var id assignedId
+			if err := rows.Scan(&id.carId, &id.regNum); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("[ERROR] Repo - AddCars - error inserting into car table: %w", mapSQLiteError(err))
+		}
+		setAssignedIds(batch, ids)
+	}
+	if err := appendSQLiteCarEvents(ctx, tx, carEvents(ctx, model.CarCreated, cars)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - AddCars - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+	s.waker.wakeAll()
+
+	log.Printf("[INFO] Repo - AddCars - New cars recorded, %d rows inserted", len(cars))
+	return nil
+}
+
+func (s *SQLiteCarRepository) GetCarById(ctx context.Context, carId int) (model.Car, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM car
+	WHERE id = ?`
+
+	car := model.Car{CarId: carId}
+	var lastSynced sql.NullInt64
+	err := s.db.QueryRowContext(ctx, query, carId).Scan(&car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+		&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &lastSynced)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[ERROR] Repo - GetCarById - No car with id %d", carId)
+		return model.Car{}, ErrCarNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCarById - Error executing select query: %v", err)
+		return model.Car{}, err
+	}
+	car.LastSyncedAt = fromMillis(lastSynced)
+	return car, nil
+}
+
+func (s *SQLiteCarRepository) GetCarByVin(ctx context.Context, vin string) (model.Car, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, mark, model, year, reg_num, vin,
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM car
+	WHERE vin = ?`
+
+	var (
+		car        model.Car
+		lastSynced sql.NullInt64
+	)
+	err := s.db.QueryRowContext(ctx, query, vin).Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+		&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &lastSynced)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[INFO] Repo - GetCarByVin - No car with VIN %s", vin)
+		return model.Car{}, ErrCarNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCarByVin - Error executing select query: %v", err)
+		return model.Car{}, err
+	}
+	car.LastSyncedAt = fromMillis(lastSynced)
+	return car, nil
+}
+
+func (s *SQLiteCarRepository) GetCarsByIds(ctx context.Context, carIds []int) ([]model.Car, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	cars := []model.Car{}
+	for start := 0; start < len(carIds); start += sqliteInsertBatch {
+		batch := carIds[start:min(start+sqliteInsertBatch, len(carIds))]
+
+		args := make([]any, len(batch))
+		for i, carId := range batch {
+			args[i] = carId
+		}
+		query := `SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
+		COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+		FROM car
+		WHERE id IN (?` + strings.Repeat(", ?", len(batch)-1) + `)`
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			log.Printf("[ERROR] Repo - GetCarsByIds - Error executing select query: %v", err)
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				car        model.Car
+				lastSynced sql.NullInt64
+			)
+			if err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+				&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &lastSynced); err != nil {
+				rows.Close()
+				log.Printf("[ERROR] Repo - GetCarsByIds - Error scanning row: %v", err)
+				return nil, err
+			}
+			car.LastSyncedAt = fromMillis(lastSynced)
+			cars = append(cars, car)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	// The ids are looked up in batches of the caller's order.
+	sort.Slice(cars, func(i, j int) bool { return cars[i].CarId < cars[j].CarId })
+	return cars, nil
+}
+
+func (s *SQLiteCarRepository) GetCars(ctx context.Context, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.Car, dto.Cursors, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	if limit == 0 {
+		return []model.Car{}, dto.Cursors{}, errors.New("limit cannot be zero")
+	}
+	if cursors.Next != "" && cursors.Prev != "" {
+		return []model.Car{}, dto.Cursors{}, errors.New("two cursors cannot be provided at the same time")
+	}
+	// SQLite casts anything to a number, so reject what Postgres would.
+	if err := checkNumeric(year, cursors.Next, cursors.Prev); err != nil {
+		return []model.Car{}, dto.Cursors{}, err
+	}
+
+	var (
+		bound    = "id > ?"
+		cursor   = cursors.Next
+		order    = "ASC"
+		rowsLeft int
+		total    int
+	)
+	if cursors.Prev != "" {
+		bound, cursor, order = "id < ?", cursors.Prev, "DESC"
+	}
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCars - Failed to begin transaction: %v", err)
+		return []model.Car{}, dto.Cursors{}, err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`SELECT id, mark, model, year, reg_num, COALESCE(vin, '')
+	FROM car
+	WHERE %s AND (? = '' OR mark = ?) AND (? = '' OR model = ?) AND (? = '' OR year = CAST(? AS INTEGER))
+	ORDER BY id %s LIMIT ?`, bound, order)
+	log.Printf("[DEBUG] Repo - GetCars - Statement: %s", query)
+
+	rows, err := tx.QueryContext(ctx, query, cursor, mark, mark, carModel, carModel, year, year, limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetCars - Error executing select query: %v", err)
+		return []model.Car{}, dto.Cursors{}, err
+	}
+	defer rows.Close()
+
+	var cars []model.Car
+	for rows.Next() {
+		var car model.Car
+		if err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin); err != nil {
+			log.Printf("[ERROR] Repo - GetCars - Error scanning row: %v", err)
+			return []model.Car{}, dto.Cursors{}, err
+		}
+		cars = append(cars, car)
+	}
+	if err := rows.Err(); err != nil {
+		return []model.Car{}, dto.Cursors{}, err
+	}
+	if len(cars) == 0 {
+		return []model.Car{}, dto.Cursors{}, nil
+	}
+	if order == "DESC" {
+		for i, j := 0, len(cars)-1; i < j; i, j = i+1, j-1 {
+			cars[i], cars[j] = cars[j], cars[i]
+		}
+	}
+
+	countQuery := fmt.Sprintf("SELECT (SELECT COUNT(*) FROM car WHERE %s), (SELECT COUNT(*) FROM car)", bound)
+	if err := tx.QueryRowContext(ctx, countQuery, cursor).Scan(&rowsLeft, &total); err != nil {
+		log.Printf("[ERROR] Repo - GetCars - Error counting rows: %v", err)
+		return []model.Car{}, dto.Cursors{}, err
+	}
+
+	log.Printf("[INFO] Repo - GetCars - Got %d records from the database", len(cars))
+	return cars, pageCursors(cursors, cars, rowsLeft, total), nil
+}
+
+func (s *SQLiteCarRepository) UpdateCar(ctx context.Context, car model.Car) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `UPDATE car
+	SET mark = ?, model = ?, year = ?, reg_num = ?, vin = ?,
+	owner_name = ?, owner_surname = ?, owner_patronymic = ?
+	WHERE id = ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - UpdateCar - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, car.Mark, car.Model, car.Year, car.RegNum, nullableVin(car.Vin),
+		car.OwnerName, car.OwnerSurname, car.OwnerPatronymic, car.CarId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - UpdateCar - Error executing update query: %v", err)
+		return mapSQLiteError(err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrCarNotFound
+	}
+	if err := appendSQLiteCarEvents(ctx, tx, carEvents(ctx, model.CarUpdated, []model.Car{car})); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - UpdateCar - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+	s.waker.wakeAll()
+
+	log.Printf("[INFO] Repo - UpdateCar - Car updated successfuly")
+	return nil
+}
+
+func (s *SQLiteCarRepository) DeleteCar(ctx context.Context, carId int) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteCar - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	deleted, err := scanSQLiteCar(tx.QueryRowContext(ctx, `DELETE FROM car WHERE id = ? RETURNING `+sqliteCarColumns, carId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCarNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteCar - Error executing delete query: %v", err)
+		return err
+	}
+	if err := appendSQLiteCarEvents(ctx, tx, carEvents(ctx, model.CarDeleted, []model.Car{deleted})); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - DeleteCar - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+	s.waker.wakeAll()
+
+	log.Println("[INFO] Repo - DeleteCar - Car deleted successfuly")
+	return nil
+}
+
+func (s *SQLiteCarRepository) UpdateCars(ctx context.Context, cars []model.Car) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - UpdateCars - Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateSQLiteCarsTx(ctx, tx, cars); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - UpdateCars - Failed to commit transaction: %v", err)
+		return err
+	}
+	s.waker.wakeAll()
+
+	log.Printf("[INFO] Repo - UpdateCars - %d cars updated", len(cars))
+	return nil
+}
+
+// ModifyCars takes the write lock of the database before reading, with a
+// write that changes nothing: a deferred transaction would only read a
+// snapshot that concurrent writers may overtake.
+func (s *SQLiteCarRepository) ModifyCars(ctx context.Context, carIds []int, modify func([]model.Car) ([]model.Car, error)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE car SET id = id WHERE 0`); err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Unable to lock the database: %v", err)
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `SELECT `+sqliteCarColumns+` FROM car WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	ids := slices.Clone(carIds)
+	slices.Sort(ids)
+	var cars []model.Car
+	for _, id := range slices.Compact(ids) {
+		car, err := scanSQLiteCar(stmt.QueryRowContext(ctx, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[ERROR] Repo - ModifyCars - Error reading car %d: %v", id, err)
+			return err
+		}
+		cars = append(cars, car)
+	}
+
+	changed, err := modify(cars)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := updateSQLiteCarsTx(ctx, tx, changed); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - ModifyCars - Failed to commit transaction: %v", err)
+		return err
+	}
+	s.waker.wakeAll()
+
+	log.Printf("[INFO] Repo - ModifyCars - %d of %d cars updated", len(changed), len(carIds))
+	return nil
+}
+
+// updateSQLiteCarsTx writes cars and their events in tx.
+func updateSQLiteCarsTx(ctx context.Context, tx *sql.Tx, cars []model.Car) error {
+	query := `UPDATE car
+	SET mark = ?, model = ?, year = ?, reg_num = ?, vin = ?,
+	owner_name = ?, owner_surname = ?, owner_patronymic = ?
+	WHERE id = ?`
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, car := range cars {
+		result, err := stmt.ExecContext(ctx, car.Mark, car.Model, car.Year, car.RegNum, nullableVin(car.Vin),
+			car.OwnerName, car.OwnerSurname, car.OwnerPatronymic, car.CarId)
+		if err != nil {
+			log.Printf("[ERROR] Repo - UpdateCars - Error updating car %d: %v", car.CarId, err)
+			return mapSQLiteError(err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return fmt.Errorf("%w: %d", ErrCarNotFound, car.CarId)
+		}
+	}
+	return appendSQLiteCarEvents(ctx, tx, carEvents(ctx, model.CarUpdated, cars))
+}
+
+func (s *SQLiteCarRepository) DeleteCars(ctx context.Context, carIds []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteCars - Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `DELETE FROM car WHERE id = ? RETURNING `+sqliteCarColumns)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var (
+		deleted     []int
+		deletedCars []model.Car
+	)
+	for _, id := range carIds {
+		car, err := scanSQLiteCar(stmt.QueryRowContext(ctx, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[ERROR] Repo - DeleteCars - Error deleting car %d: %v", id, err)
+			return err
+		}
+		deleted = append(deleted, id)
+		deletedCars = append(deletedCars, car)
+	}
+	// A repeated id deletes nothing the second time; it is not missing.
+	if missing, ok := firstMissing(carIds, deleted); ok {
+		return fmt.Errorf("%w: %d", ErrCarNotFound, missing)
+	}
+	if err := appendSQLiteCarEvents(ctx, tx, carEvents(ctx, model.CarDeleted, deletedCars)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - DeleteCars - Failed to commit transaction: %v", err)
+		return err
+	}
+	s.waker.wakeAll()
+
+	log.Printf("[INFO] Repo - DeleteCars - %d cars deleted", len(deleted))
+	return nil
+}
+
+func (s *SQLiteCarRepository) FindExistingRegNums(ctx context.Context, regNums []string) ([]string, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	existing := []string{}
+	for start := 0; start < len(regNums); start += sqliteInsertBatch {
+		batch := regNums[start:min(start+sqliteInsertBatch, len(regNums))]
+
+		args := make([]any, len(batch))
+		for i, regNum := range batch {
+			args[i] = regNum
+		}
+		query := `SELECT reg_num FROM car WHERE reg_num IN (?` + strings.Repeat(", ?", len(batch)-1) + `)`
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			log.Printf("[ERROR] Repo - FindExistingRegNums - Error executing select query: %v", err)
+			return nil, err
+		}
+		for rows.Next() {
+			var regNum string
+			if err := rows.Scan(&regNum); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			existing = append(existing, regNum)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// StreamCars pages through the table by id, streamBatchSize rows at a time,
+// so no read transaction stays open while fn runs.
+func (s *SQLiteCarRepository) StreamCars(ctx context.Context, mark, carModel, year string, fn func(model.Car) error) error {
+	if err := checkNumeric(year); err != nil {
+		return err
+	}
+
+	query := `SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, '')
+	FROM car
+	WHERE id > ? AND (? = '' OR mark = ?) AND (? = '' OR model = ?) AND (? = '' OR year = CAST(? AS INTEGER))
+	ORDER BY id ASC LIMIT ?`
+
+	lastId := 0
+	for {
+		rows, err := s.db.QueryContext(ctx, query, lastId, mark, mark, carModel, carModel, year, year, streamBatchSize)
+		if err != nil {
+			log.Printf("[ERROR] Repo - StreamCars - Error executing select query: %v", err)
+			return err
+		}
+
+		var batch []model.Car
+		for rows.Next() {
+			var car model.Car
+			if err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+				&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, car)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, car := range batch {
+			if err := fn(car); err != nil {
+				return err
+			}
+		}
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		lastId = batch[len(batch)-1].CarId
+	}
+}
+
+// SearchCars scores the filtered cars in Go, as SQLite has no trigram index;
+// that is fine for the catalog sizes the sqlite driver is meant for.
+func (s *SQLiteCarRepository) SearchCars(ctx context.Context, variants []string, limit int, mark, carModel, year string, cursors dto.Cursors) ([]model.CarMatch, dto.Cursors, error) {
+	if err := checkSearchArgs(variants, limit, cursors); err != nil {
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+
+	var candidates []model.Car
+	err := s.StreamCars(ctx, mark, carModel, year, func(car model.Car) error {
+		candidates = append(candidates, car)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - SearchCars - Error reading candidates: %v", err)
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+
+	page, pageCursors, err := rankCars(candidates, variants, limit, cursors)
+	if err != nil {
+		return []model.CarMatch{}, dto.Cursors{}, err
+	}
+	log.Printf("[INFO] Repo - SearchCars - Got %d records from the database", len(page))
+	return page, pageCursors, nil
+}
+
+// SQLite's lower() folds ASCII only, so marks and models are grouped over the
+// car_mark_model_idx index, or car_tenant_mark_model_idx for one tenant, and
+// matched in Go; there are few distinct values.
+func (s *SQLiteCarRepository) SuggestMarks(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error) {
+	where, args := sqliteTenantFilter(tenant)
+	query := `SELECT mark, '', COUNT(*) FROM car WHERE ` + where + ` GROUP BY mark`
+	return s.suggest(ctx, "SuggestMarks", query, limit, func(mark, _ string) (string, bool) {
+		return mark, hasFoldPrefix(mark, strings.ToLower(prefix))
+	}, args...)
+}
+
+func (s *SQLiteCarRepository) SuggestModels(ctx context.Context, tenant *string, mark, prefix string, limit int) ([]model.Suggestion, error) {
+	where, args := sqliteTenantFilter(tenant)
+	query := `SELECT mark, model, COUNT(*) FROM car WHERE ` + where + ` GROUP BY mark, model`
+	return s.suggest(ctx, "SuggestModels", query, limit, func(carMark, carModel string) (string, bool) {
+		return carModel, (mark == "" || strings.EqualFold(carMark, mark)) && hasFoldPrefix(carModel, strings.ToLower(prefix))
+	}, args...)
+}
+
+// SuggestRegNums scans the unique reg_num index from prefix up to the first
+// value past it; reg numbers are ASCII, so no byte sorts after 0xff.
+func (s *SQLiteCarRepository) SuggestRegNums(ctx context.Context, tenant *string, prefix string, limit int) ([]model.Suggestion, error) {
+	where, args := sqliteTenantFilter(tenant)
+	query := `SELECT reg_num, '', 1 FROM car WHERE reg_num >= ? AND reg_num < ? AND ` + where + ` ORDER BY reg_num LIMIT ?`
+	args = append([]any{prefix, prefix + "\xff"}, append(args, limit)...)
+	return s.suggest(ctx, "SuggestRegNums", query, limit, func(regNum, _ string) (string, bool) {
+		return regNum, true
+	}, args...)
+}
+
+// sqliteTenantFilter is the condition limiting a query to the cars of
+// tenant, with its arguments; nil matches every car.
+func sqliteTenantFilter(tenant *string) (string, []any) {
+	if tenant == nil {
+		return "1", nil
+	}
+	return "tenant = ?", []any{*tenant}
+}
+
+// suggest sums the counts of the (a, b, count) rows of query under the value
+// pick selects.
+func (s *SQLiteCarRepository) suggest(ctx context.Context, name, query string, limit int, pick func(a, b string) (string, bool), args ...any) ([]model.Suggestion, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error executing select query: %v", name, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			a, b  string
+			count int
+		)
+		if err := rows.Scan(&a, &b, &count); err != nil {
+			log.Printf("[ERROR] Repo - %s - Error scanning row: %v", name, err)
+			return nil, err
+		}
+		if value, ok := pick(a, b); ok {
+			counts[value] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[ERROR] Repo - %s - Error reading rows: %v", name, err)
+		return nil, err
+	}
+
+	suggestions := topSuggestions(counts, limit)
+	log.Printf("[DEBUG] Repo - %s - Got %d suggestions", name, len(suggestions))
+	return suggestions, nil
+}
+
+// CarStats groups in Go: the region and owner dimensions and the search
+// filter have no cheap SQLite counterpart, and catalogs on this driver are
+// small.
+func (s *SQLiteCarRepository) CarStats(ctx context.Context, query model.StatsQuery) (model.CarStats, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	stats, err := aggregateStats(ctx, query, s.StreamCars)
+	if err != nil {
+		log.Printf("[ERROR] Repo - CarStats - Error aggregating cars: %v", err)
+		return model.CarStats{}, err
+	}
+	return stats, nil
+}
+
+func (s *SQLiteCarRepository) ListStaleCars(ctx context.Context, before time.Time, limit int) ([]model.Car, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	// NULLs sort first in ascending order, so never-synced cars lead.
+	query := `SELECT id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at
+	FROM car
+	WHERE last_synced_at IS NULL OR last_synced_at < ?
+	ORDER BY last_synced_at ASC, id ASC
+	LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, query, before.UnixMilli(), limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListStaleCars - Error executing select query: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cars []model.Car
+	for rows.Next() {
+		var (
+			car        model.Car
+			lastSynced sql.NullInt64
+		)
+		if err := rows.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+			&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &lastSynced); err != nil {
+			return nil, err
+		}
+		car.LastSyncedAt = fromMillis(lastSynced)
+		cars = append(cars, car)
+	}
+	return cars, rows.Err()
+}
+
+func (s *SQLiteCarRepository) MarkSynced(ctx context.Context, carId int, at time.Time) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE car SET last_synced_at = ? WHERE id = ?`, at.UnixMilli(), carId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - MarkSynced - Error executing update query: %v", err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrCarNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteCarRepository) AddDivergences(ctx context.Context, divergences []model.Divergence) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO car_divergence (car_id, field, local_value, registry_value, detected_at, status, error)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	for _, d := range divergences {
+		if _, err := tx.ExecContext(ctx, query, d.CarId, d.Field, d.LocalValue, d.RegistryValue, d.DetectedAt.UnixMilli(), d.Status, d.Error); err != nil {
+			log.Printf("[ERROR] Repo - AddDivergences - Error executing insert query: %v", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteCarRepository) ListDivergences(ctx context.Context, carId int, limit int) ([]model.Divergence, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, car_id, field, local_value, registry_value, detected_at, status, error
+	FROM car_divergence
+	WHERE ? = 0 OR car_id = ?
+	ORDER BY id DESC
+	LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, query, carId, carId, limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDivergences - Error executing select query: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	divergences := []model.Divergence{}
+	for rows.Next() {
+		var (
+			d          model.Divergence
+			detectedAt int64
+		)
+		if err := rows.Scan(&d.Id, &d.CarId, &d.Field, &d.LocalValue, &d.RegistryValue, &detectedAt, &d.Status, &d.Error); err != nil {
+			return nil, err
+		}
+		d.DetectedAt = time.UnixMilli(detectedAt)
+		divergences = append(divergences, d)
+	}
+	return divergences, rows.Err()
+}
+
+// toMillis and fromMillis convert the nullable INTEGER time columns.
+// sqliteCarColumns reads a whole car row, as RETURNING of the deletes that
+// record it in the change feed.
+const sqliteCarColumns = `id, mark, model, year, reg_num, COALESCE(vin, ''),
+	COALESCE(owner_name, ''), COALESCE(owner_surname, ''), COALESCE(owner_patronymic, ''), last_synced_at`
+
+func scanSQLiteCar(row *sql.Row) (model.Car, error) {
+	var (
+		car        model.Car
+		lastSynced sql.NullInt64
+	)
+	err := row.Scan(&car.CarId, &car.Mark, &car.Model, &car.Year, &car.RegNum, &car.Vin,
+		&car.OwnerName, &car.OwnerSurname, &car.OwnerPatronymic, &lastSynced)
+	car.LastSyncedAt = fromMillis(lastSynced)
+	return car, err
+}
+
+func toMillis(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixMilli()
+}
+
+func fromMillis(ms sql.NullInt64) *time.Time {
+	if !ms.Valid {
+		return nil
+	}
+	t := time.UnixMilli(ms.Int64)
+	return &t
+}
+
+func checkNumeric(values ...string) error {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid number %q: %w", value, err)
+		}
+	}
+	return nil
+}
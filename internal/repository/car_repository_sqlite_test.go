@@ -0,0 +1,60 @@
+package repository_test
+
+import (
+	"car_catalog/internal/config"
+	"car_catalog/internal/database"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/repository/repotest"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteCarRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.CarRepository {
+		return repository.NewSQLiteCarRepository(openSQLite(t), 0)
+	})
+}
+
+func TestSQLiteDictionaryRepository(t *testing.T) {
+	repotest.RunDictionary(t, func(t *testing.T) repository.DictionaryRepository {
+		return repository.NewSQLiteDictionaryRepository(openSQLite(t), 0)
+	})
+}
+
+func TestSQLiteCarEventLog(t *testing.T) {
+	repotest.RunEventLog(t, func(t *testing.T) (repository.CarRepository, repository.CarEventLog) {
+		db := openSQLite(t)
+		return repository.NewSQLiteCarRepository(db, 0), repository.NewSQLiteCarEventLog(db, 0)
+	})
+}
+
+func TestSQLiteWebhookRepository(t *testing.T) {
+	repotest.RunWebhooks(t, func(t *testing.T) repository.WebhookRepository {
+		return repository.NewSQLiteWebhookRepository(openSQLite(t), 0)
+	})
+}
+
+func TestSQLiteAttachmentRepository(t *testing.T) {
+	repotest.RunAttachments(t, func(t *testing.T) (repository.CarRepository, repository.AttachmentRepository) {
+		db := openSQLite(t)
+		return repository.NewSQLiteCarRepository(db, 0), repository.NewSQLiteAttachmentRepository(db, 0)
+	})
+}
+
+// openSQLite migrates a fresh database file for the test.
+func openSQLite(t *testing.T) *sql.DB {
+	cfg := &config.Config{}
+	cfg.Storage.Driver = "sqlite"
+	cfg.Storage.SQLite.Path = filepath.Join(t.TempDir(), "cars.db")
+	if err := database.MigrateDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.SQLiteConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
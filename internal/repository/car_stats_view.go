@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CarStatsView serves catalog statistics from the cars.car_stats
+// materialized view, which lags the catalog until the next Refresh.
+type CarStatsView interface {
+	// Covers reports whether the view can answer the query: it keeps the
+	// mark, model, year and region dimensions and cannot apply a search.
+	Covers(query model.StatsQuery) bool
+	CarStats(ctx context.Context, query model.StatsQuery) (model.CarStats, error)
+	Refresh(ctx context.Context) error
+}
+
+// carStatsViewColumns are the stats dimensions kept by cars.car_stats.
+var carStatsViewColumns = map[string]string{
+	model.StatsByMark:   "mark",
+	model.StatsByModel:  "model",
+	model.StatsByYear:   "year",
+	model.StatsByRegion: "region",
+}
+
+type CarStatsViewImpl struct {
+	conn         *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+// NewCarStatsView returns the view of the Postgres backend. queryTimeout
+// bounds CarStats; Refresh is limited by its context only.
+func NewCarStatsView(conn *pgxpool.Pool, queryTimeout time.Duration) CarStatsView {
+	return &CarStatsViewImpl{
+		conn:         conn,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (v *CarStatsViewImpl) Covers(query model.StatsQuery) bool {
+	if len(query.Variants) > 0 {
+		return false
+	}
+	for _, dimension := range query.GroupBy {
+		if _, ok := carStatsViewColumns[dimension]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *CarStatsViewImpl) CarStats(ctx context.Context, query model.StatsQuery) (model.CarStats, error) {
+	if err := checkStatsQuery(query); err != nil {
+		return model.CarStats{}, err
+	}
+	if v.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.queryTimeout)
+		defer cancel()
+	}
+
+	values := []any{query.Mark, query.Model, query.Year}
+	where := "($1 = '' OR mark = $1) AND ($2 = '' OR model = $2) AND ($3 = '' OR year = $3::int)"
+	stmt, values := statsStatement("cars.car_stats", carStatsViewColumns, "SUM(cars)", where, query, values)
+
+	log.Printf("[DEBUG] Repo - CarStatsView - Statement: %s", stmt)
+	log.Printf("[DEBUG] Repo - CarStatsView - Values: %+v", values)
+
+	// The refresh time and the figures must come from the same snapshot.
+	tx, err := v.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		log.Printf("[ERROR] Repo - CarStatsView - Failed to begin transaction: %v", err)
+		return model.CarStats{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var refreshedAt time.Time
+	if err := tx.QueryRow(ctx, "SELECT refreshed_at FROM cars.car_stats_refresh").Scan(&refreshedAt); err != nil {
+		log.Printf("[ERROR] Repo - CarStatsView - Unable to read the refresh time: %v", err)
+		return model.CarStats{}, err
+	}
+
+	stats, err := queryStats(ctx, tx, stmt, values, query.GroupBy)
+	if err != nil {
+		log.Printf("[ERROR] Repo - CarStatsView - Error executing select query: %v", err)
+		return model.CarStats{}, err
+	}
+	stats.RefreshedAt = &refreshedAt
+	return stats, nil
+}
+
+// Refresh rebuilds the view without blocking readers.
+func (v *CarStatsViewImpl) Refresh(ctx context.Context) error {
+	tx, err := v.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - RefreshCarStats - Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY cars.car_stats"); err != nil {
+		log.Printf("[ERROR] Repo - RefreshCarStats - Error refreshing the view: %v", err)
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE cars.car_stats_refresh SET refreshed_at = now()"); err != nil {
+		log.Printf("[ERROR] Repo - RefreshCarStats - Error recording the refresh time: %v", err)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - RefreshCarStats - Failed to commit transaction: %v", err)
+		return err
+	}
+
+	log.Println("[INFO] Repo - RefreshCarStats - Car statistics refreshed")
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"fmt"
+)
+
+// CarCursor is the GetCars cursor at car: as Next it pages on after the car,
+// as Prev before it.
+func CarCursor(car model.Car) string {
+	return fmt.Sprint(car.CarId)
+}
+
+// SearchCursor is the SearchCars cursor at match.
+func SearchCursor(match model.CarMatch) string {
+	return searchCursor{score: match.Score, id: match.CarId}.String()
+}
+
+// pageCursors decides which cursors a non-empty GetCars page gets. rowsLeft
+// is the number of cars past the request cursor and total the size of the
+// table, both counted without filters. Every backend shares these rules so
+// clients page identically whatever the storage.
+func pageCursors(cursors dto.Cursors, cars []model.Car, rowsLeft, total int) dto.Cursors {
+	var (
+		prevCursor string
+		nextCursor string
+	)
+
+	switch {
+	case rowsLeft < 0:
+	case cursors.Prev == "" && cursors.Next == "":
+		nextCursor = CarCursor(cars[len(cars)-1])
+
+	case cursors.Next != "" && rowsLeft == len(cars):
+		prevCursor = CarCursor(cars[0])
+
+	case cursors.Prev != "" && rowsLeft == len(cars):
+		nextCursor = CarCursor(cars[len(cars)-1])
+
+	case cursors.Prev != "" && total == rowsLeft:
+		prevCursor = CarCursor(cars[0])
+
+	default:
+		nextCursor = CarCursor(cars[len(cars)-1])
+		prevCursor = CarCursor(cars[0])
+	}
+
+	return dto.Cursors{Prev: prevCursor, Next: nextCursor}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+)
+
+var (
+	ErrMakeNotFound  = errors.New("make not found")
+	ErrModelNotFound = errors.New("model not found")
+	ErrAliasNotFound = errors.New("alias not found")
+	// ErrAliasTaken is returned when a name or alias is already used by
+	// another entry of the same scope: any make, or a model of the same make.
+	ErrAliasTaken = errors.New("name or alias is already taken")
+)
+
+// DictionaryRepository stores the reference dictionary of marks and models.
+// Aliases are stored as given; callers normalize them first.
+type DictionaryRepository interface {
+	// ListMakes returns every make with its aliases and models, by name.
+	ListMakes(ctx context.Context) ([]model.Make, error)
+	AddMake(ctx context.Context, name string, aliases []string) (model.Make, error)
+	// DeleteMake removes a make with its aliases and models.
+	DeleteMake(ctx context.Context, id int) error
+	AddMakeAlias(ctx context.Context, makeId int, alias string) error
+	DeleteMakeAlias(ctx context.Context, makeId int, alias string) error
+	AddModel(ctx context.Context, makeId int, name string, aliases []string) (model.ModelRef, error)
+	DeleteModel(ctx context.Context, id int) error
+	AddModelAlias(ctx context.Context, modelId int, alias string) error
+	DeleteModelAlias(ctx context.Context, modelId int, alias string) error
+	// ResolveMake and ResolveModel look an alias up; the result carries no
+	// aliases or models.
+	ResolveMake(ctx context.Context, alias string) (model.Make, error)
+	ResolveModel(ctx context.Context, makeId int, alias string) (model.ModelRef, error)
+}
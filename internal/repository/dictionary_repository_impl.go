@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const foreignKeyViolation = "23503"
+
+type DictionaryRepositoryImpl struct {
+	conn         *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+func NewDictionaryRepository(conn *pgxpool.Pool, queryTimeout time.Duration) DictionaryRepository {
+	return &DictionaryRepositoryImpl{
+		conn:         conn,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (d *DictionaryRepositoryImpl) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.queryTimeout)
+}
+
+// mapDictionaryPgError turns unique violations into ErrAliasTaken and
+// foreign key violations into notFound.
+func mapDictionaryPgError(err error, notFound error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case uniqueViolation:
+			return fmt.Errorf("%w: %s", ErrAliasTaken, pgErr.Detail)
+		case foreignKeyViolation:
+			return notFound
+		}
+	}
+	return err
+}
+
+func (d *DictionaryRepositoryImpl) ListMakes(ctx context.Context) ([]model.Make, error) {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	// One snapshot, so models never point at a make the list lacks.
+	tx, err := d.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Failed to begin transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT id, name FROM cars.make ORDER BY name COLLATE "C"`)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error selecting makes: %v", err)
+		return nil, err
+	}
+	makes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Make, error) {
+		var m model.Make
+		err := row.Scan(&m.Id, &m.Name)
+		return m, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error scanning makes: %v", err)
+		return nil, err
+	}
+
+	index := make(map[int]*model.Make, len(makes))
+	for i := range makes {
+		index[makes[i].Id] = &makes[i]
+	}
+
+	rows, err = tx.Query(ctx, `SELECT make_id, alias FROM cars.make_alias ORDER BY alias COLLATE "C"`)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error selecting make aliases: %v", err)
+		return nil, err
+	}
+	var (
+		makeId int
+		alias  string
+	)
+	_, err = pgx.ForEachRow(rows, []any{&makeId, &alias}, func() error {
+		if m := index[makeId]; m != nil {
+			m.Aliases = append(m.Aliases, alias)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error scanning make aliases: %v", err)
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT id, make_id, name FROM cars.model_ref ORDER BY name COLLATE "C"`)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error selecting models: %v", err)
+		return nil, err
+	}
+	models, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.ModelRef, error) {
+		var m model.ModelRef
+		err := row.Scan(&m.Id, &m.MakeId, &m.Name)
+		return m, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error scanning models: %v", err)
+		return nil, err
+	}
+
+	modelAliases := make(map[int][]string)
+	rows, err = tx.Query(ctx, `SELECT model_id, alias FROM cars.model_alias ORDER BY alias COLLATE "C"`)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error selecting model aliases: %v", err)
+		return nil, err
+	}
+	var modelId int
+	_, err = pgx.ForEachRow(rows, []any{&modelId, &alias}, func() error {
+		modelAliases[modelId] = append(modelAliases[modelId], alias)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error scanning model aliases: %v", err)
+		return nil, err
+	}
+
+	for _, m := range models {
+		m.Aliases = modelAliases[m.Id]
+		if parent := index[m.MakeId]; parent != nil {
+			parent.Models = append(parent.Models, m)
+		}
+	}
+
+	log.Printf("[INFO] Repo - ListMakes - Got %d makes and %d models", len(makes), len(models))
+	return makes, nil
+}
+
+func (d *DictionaryRepositoryImpl) AddMake(ctx context.Context, name string, aliases []string) (model.Make, error) {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddMake - Failed to begin transaction: %v", err)
+		return model.Make{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	created := model.Make{Name: name, Aliases: aliases}
+	if err := tx.QueryRow(ctx, `INSERT INTO cars.make (name) VALUES ($1) RETURNING id`, name).Scan(&created.Id); err != nil {
+		log.Printf("[ERROR] Repo - AddMake - Error inserting make %q: %v", name, err)
+		return model.Make{}, mapDictionaryPgError(err, err)
+	}
+	for _, alias := range aliases {
+		if _, err := tx.Exec(ctx, `INSERT INTO cars.make_alias (alias, make_id) VALUES ($1, $2)`, alias, created.Id); err != nil {
+			log.Printf("[ERROR] Repo - AddMake - Error inserting alias %q: %v", alias, err)
+			return model.Make{}, mapDictionaryPgError(err, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - AddMake - Failed to commit transaction: %v", err)
+		return model.Make{}, err
+	}
+
+	log.Printf("[INFO] Repo - AddMake - Added make %q with id %d", name, created.Id)
+	return created, nil
+}
+
+func (d *DictionaryRepositoryImpl) DeleteMake(ctx context.Context, id int) error {
+	return d.delete(ctx, "DeleteMake", ErrMakeNotFound, `DELETE FROM cars.make WHERE id = $1`, id)
+}
+
+func (d *DictionaryRepositoryImpl) AddMakeAlias(ctx context.Context, makeId int, alias string) error {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	if _, err := d.conn.Exec(ctx, `INSERT INTO cars.make_alias (alias, make_id) VALUES ($1, $2)`, alias, makeId); err != nil {
+		log.Printf("[ERROR] Repo - AddMakeAlias - Error inserting alias %q: %v", alias, err)
+		return mapDictionaryPgError(err, ErrMakeNotFound)
+	}
+	return nil
+}
+
+func (d *DictionaryRepositoryImpl) DeleteMakeAlias(ctx context.Context, makeId int, alias string) error {
+	return d.delete(ctx, "DeleteMakeAlias", ErrAliasNotFound, `DELETE FROM cars.make_alias WHERE make_id = $1 AND alias = $2`, makeId, alias)
+}
+
+func (d *DictionaryRepositoryImpl) AddModel(ctx context.Context, makeId int, name string, aliases []string) (model.ModelRef, error) {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddModel - Failed to begin transaction: %v", err)
+		return model.ModelRef{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	created := model.ModelRef{MakeId: makeId, Name: name, Aliases: aliases}
+	err = tx.QueryRow(ctx, `INSERT INTO cars.model_ref (make_id, name) VALUES ($1, $2) RETURNING id`, makeId, name).Scan(&created.Id)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddModel - Error inserting model %q: %v", name, err)
+		return model.ModelRef{}, mapDictionaryPgError(err, ErrMakeNotFound)
+	}
+	for _, alias := range aliases {
+		_, err := tx.Exec(ctx, `INSERT INTO cars.model_alias (make_id, alias, model_id) VALUES ($1, $2, $3)`, makeId, alias, created.Id)
+		if err != nil {
+			log.Printf("[ERROR] Repo - AddModel - Error inserting alias %q: %v", alias, err)
+			return model.ModelRef{}, mapDictionaryPgError(err, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - AddModel - Failed to commit transaction: %v", err)
+		return model.ModelRef{}, err
+	}
+
+	log.Printf("[INFO] Repo - AddModel - Added model %q with id %d", name, created.Id)
+	return created, nil
+}
+
+func (d *DictionaryRepositoryImpl) DeleteModel(ctx context.Context, id int) error {
+	return d.delete(ctx, "DeleteModel", ErrModelNotFound, `DELETE FROM cars.model_ref WHERE id = $1`, id)
+}
+
+func (d *DictionaryRepositoryImpl) AddModelAlias(ctx context.Context, modelId int, alias string) error {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `INSERT INTO cars.model_alias (make_id, alias, model_id)
+	SELECT make_id, $1, id FROM cars.model_ref WHERE id = $2`
+
+	tag, err := d.conn.Exec(ctx, query, alias, modelId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddModelAlias - Error inserting alias %q: %v", alias, err)
+		return mapDictionaryPgError(err, ErrModelNotFound)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+func (d *DictionaryRepositoryImpl) DeleteModelAlias(ctx context.Context, modelId int, alias string) error {
+	return d.delete(ctx, "DeleteModelAlias", ErrAliasNotFound, `DELETE FROM cars.model_alias WHERE model_id = $1 AND alias = $2`, modelId, alias)
+}
+
+func (d *DictionaryRepositoryImpl) delete(ctx context.Context, name string, notFound error, query string, args ...any) error {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	tag, err := d.conn.Exec(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error executing delete query: %v", name, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return notFound
+	}
+	log.Printf("[INFO] Repo - %s - Deleted %v", name, args)
+	return nil
+}
+
+func (d *DictionaryRepositoryImpl) ResolveMake(ctx context.Context, alias string) (model.Make, error) {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT m.id, m.name
+	FROM cars.make_alias a JOIN cars.make m ON m.id = a.make_id
+	WHERE a.alias = $1`
+
+	var m model.Make
+	err := d.conn.QueryRow(ctx, query, alias).Scan(&m.Id, &m.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Make{}, ErrMakeNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - ResolveMake - Error resolving %q: %v", alias, err)
+		return model.Make{}, err
+	}
+	return m, nil
+}
+
+func (d *DictionaryRepositoryImpl) ResolveModel(ctx context.Context, makeId int, alias string) (model.ModelRef, error) {
+	ctx, cancel := d.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT m.id, m.make_id, m.name
+	FROM cars.model_alias a JOIN cars.model_ref m ON m.id = a.model_id
+	WHERE a.make_id = $1 AND a.alias = $2`
+
+	var m model.ModelRef
+	err := d.conn.QueryRow(ctx, query, makeId, alias).Scan(&m.Id, &m.MakeId, &m.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.ModelRef{}, ErrModelNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - ResolveModel - Error resolving %q: %v", alias, err)
+		return model.ModelRef{}, err
+	}
+	return m, nil
+}
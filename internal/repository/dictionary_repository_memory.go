@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryDictionaryRepository keeps the dictionary in process memory with the
+// uniqueness rules of the SQL tables.
+type MemoryDictionaryRepository struct {
+	mu           sync.RWMutex
+	makes        map[int]model.Make
+	makeAliases  map[string]int
+	models       map[int]model.ModelRef
+	modelAliases map[modelAliasKey]int
+	nextMakeId   int
+	nextModelId  int
+}
+
+// modelAliasKey mirrors the (make_id, alias) primary key of model_alias.
+type modelAliasKey struct {
+	makeId int
+	alias  string
+}
+
+func NewMemoryDictionaryRepository() DictionaryRepository {
+	return &MemoryDictionaryRepository{
+		makes:        make(map[int]model.Make),
+		makeAliases:  make(map[string]int),
+		models:       make(map[int]model.ModelRef),
+		modelAliases: make(map[modelAliasKey]int),
+		nextMakeId:   1,
+		nextModelId:  1,
+	}
+}
+
+func (m *MemoryDictionaryRepository) ListMakes(ctx context.Context) ([]model.Make, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	makes := make([]model.Make, 0, len(m.makes))
+	for _, mk := range m.makes {
+		mk.Aliases = nil
+		for alias, id := range m.makeAliases {
+			if id == mk.Id {
+				mk.Aliases = append(mk.Aliases, alias)
+			}
+		}
+		sort.Strings(mk.Aliases)
+
+		for _, ref := range m.models {
+			if ref.MakeId != mk.Id {
+				continue
+			}
+			for key, id := range m.modelAliases {
+				if id == ref.Id {
+					ref.Aliases = append(ref.Aliases, key.alias)
+				}
+			}
+			sort.Strings(ref.Aliases)
+			mk.Models = append(mk.Models, ref)
+		}
+		sort.Slice(mk.Models, func(i, j int) bool { return mk.Models[i].Name < mk.Models[j].Name })
+		makes = append(makes, mk)
+	}
+	sort.Slice(makes, func(i, j int) bool { return makes[i].Name < makes[j].Name })
+	return makes, nil
+}
+
+func (m *MemoryDictionaryRepository) AddMake(ctx context.Context, name string, aliases []string) (model.Make, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, mk := range m.makes {
+		if mk.Name == name {
+			return model.Make{}, fmt.Errorf("%w: make %q", ErrAliasTaken, name)
+		}
+	}
+	if err := m.checkMakeAliasesLocked(aliases); err != nil {
+		return model.Make{}, err
+	}
+
+	created := model.Make{Id: m.nextMakeId, Name: name}
+	m.nextMakeId++
+	m.makes[created.Id] = created
+	for _, alias := range aliases {
+		m.makeAliases[alias] = created.Id
+	}
+	created.Aliases = aliases
+	return created, nil
+}
+
+func (m *MemoryDictionaryRepository) checkMakeAliasesLocked(aliases []string) error {
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		if _, taken := m.makeAliases[alias]; taken || seen[alias] {
+			return fmt.Errorf("%w: alias %q", ErrAliasTaken, alias)
+		}
+		seen[alias] = true
+	}
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) DeleteMake(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.makes[id]; !ok {
+		return ErrMakeNotFound
+	}
+	delete(m.makes, id)
+	for alias, makeId := range m.makeAliases {
+		if makeId == id {
+			delete(m.makeAliases, alias)
+		}
+	}
+	for modelId, ref := range m.models {
+		if ref.MakeId == id {
+			m.deleteModelLocked(modelId)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) AddMakeAlias(ctx context.Context, makeId int, alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.makes[makeId]; !ok {
+		return ErrMakeNotFound
+	}
+	if err := m.checkMakeAliasesLocked([]string{alias}); err != nil {
+		return err
+	}
+	m.makeAliases[alias] = makeId
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) DeleteMakeAlias(ctx context.Context, makeId int, alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, ok := m.makeAliases[alias]; !ok || id != makeId {
+		return ErrAliasNotFound
+	}
+	delete(m.makeAliases, alias)
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) AddModel(ctx context.Context, makeId int, name string, aliases []string) (model.ModelRef, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.makes[makeId]; !ok {
+		return model.ModelRef{}, ErrMakeNotFound
+	}
+	for _, ref := range m.models {
+		if ref.MakeId == makeId && ref.Name == name {
+			return model.ModelRef{}, fmt.Errorf("%w: model %q", ErrAliasTaken, name)
+		}
+	}
+	if err := m.checkModelAliasesLocked(makeId, aliases); err != nil {
+		return model.ModelRef{}, err
+	}
+
+	created := model.ModelRef{Id: m.nextModelId, MakeId: makeId, Name: name}
+	m.nextModelId++
+	m.models[created.Id] = created
+	for _, alias := range aliases {
+		m.modelAliases[modelAliasKey{makeId, alias}] = created.Id
+	}
+	created.Aliases = aliases
+	return created, nil
+}
+
+func (m *MemoryDictionaryRepository) checkModelAliasesLocked(makeId int, aliases []string) error {
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		if _, taken := m.modelAliases[modelAliasKey{makeId, alias}]; taken || seen[alias] {
+			return fmt.Errorf("%w: alias %q", ErrAliasTaken, alias)
+		}
+		seen[alias] = true
+	}
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) DeleteModel(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.models[id]; !ok {
+		return ErrModelNotFound
+	}
+	m.deleteModelLocked(id)
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) deleteModelLocked(id int) {
+	delete(m.models, id)
+	for key, modelId := range m.modelAliases {
+		if modelId == id {
+			delete(m.modelAliases, key)
+		}
+	}
+}
+
+func (m *MemoryDictionaryRepository) AddModelAlias(ctx context.Context, modelId int, alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ref, ok := m.models[modelId]
+	if !ok {
+		return ErrModelNotFound
+	}
+	if err := m.checkModelAliasesLocked(ref.MakeId, []string{alias}); err != nil {
+		return err
+	}
+	m.modelAliases[modelAliasKey{ref.MakeId, alias}] = modelId
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) DeleteModelAlias(ctx context.Context, modelId int, alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ref, ok := m.models[modelId]
+	if !ok {
+		return ErrAliasNotFound
+	}
+	key := modelAliasKey{ref.MakeId, alias}
+	if id, ok := m.modelAliases[key]; !ok || id != modelId {
+		return ErrAliasNotFound
+	}
+	delete(m.modelAliases, key)
+	return nil
+}
+
+func (m *MemoryDictionaryRepository) ResolveMake(ctx context.Context, alias string) (model.Make, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.makeAliases[alias]
+	if !ok {
+		return model.Make{}, ErrMakeNotFound
+	}
+	mk := m.makes[id]
+	return model.Make{Id: mk.Id, Name: mk.Name}, nil
+}
+
+func (m *MemoryDictionaryRepository) ResolveModel(ctx context.Context, makeId int, alias string) (model.ModelRef, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.modelAliases[modelAliasKey{makeId, alias}]
+	if !ok {
+		return model.ModelRef{}, ErrModelNotFound
+	}
+	ref := m.models[id]
+	return model.ModelRef{Id: ref.Id, MakeId: ref.MakeId, Name: ref.Name}, nil
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteDictionaryRepository keeps the dictionary next to the SQLite catalog,
+// with the same tables and constraints as Postgres.
+type SQLiteDictionaryRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewSQLiteDictionaryRepository(db *sql.DB, queryTimeout time.Duration) DictionaryRepository {
+	return &SQLiteDictionaryRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (s *SQLiteDictionaryRepository) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// mapDictionarySQLiteError is the SQLite counterpart of mapDictionaryPgError.
+func mapDictionarySQLiteError(err error, notFound error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %s", ErrAliasTaken, sqliteErr.Error())
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return notFound
+		}
+	}
+	return err
+}
+
+func (s *SQLiteDictionaryRepository) ListMakes(ctx context.Context) ([]model.Make, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Failed to begin transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var makes []model.Make
+	err = sqliteEach(ctx, tx, `SELECT id, name FROM make ORDER BY name`, func(rows *sql.Rows) error {
+		var m model.Make
+		if err := rows.Scan(&m.Id, &m.Name); err != nil {
+			return err
+		}
+		makes = append(makes, m)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error reading makes: %v", err)
+		return nil, err
+	}
+
+	index := make(map[int]*model.Make, len(makes))
+	for i := range makes {
+		index[makes[i].Id] = &makes[i]
+	}
+
+	err = sqliteEach(ctx, tx, `SELECT make_id, alias FROM make_alias ORDER BY alias`, func(rows *sql.Rows) error {
+		var (
+			makeId int
+			alias  string
+		)
+		if err := rows.Scan(&makeId, &alias); err != nil {
+			return err
+		}
+		if m := index[makeId]; m != nil {
+			m.Aliases = append(m.Aliases, alias)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error reading make aliases: %v", err)
+		return nil, err
+	}
+
+	modelAliases := make(map[int][]string)
+	err = sqliteEach(ctx, tx, `SELECT model_id, alias FROM model_alias ORDER BY alias`, func(rows *sql.Rows) error {
+		var (
+			modelId int
+			alias   string
+		)
+		if err := rows.Scan(&modelId, &alias); err != nil {
+			return err
+		}
+		modelAliases[modelId] = append(modelAliases[modelId], alias)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error reading model aliases: %v", err)
+		return nil, err
+	}
+
+	models := 0
+	err = sqliteEach(ctx, tx, `SELECT id, make_id, name FROM model_ref ORDER BY name`, func(rows *sql.Rows) error {
+		var m model.ModelRef
+		if err := rows.Scan(&m.Id, &m.MakeId, &m.Name); err != nil {
+			return err
+		}
+		m.Aliases = modelAliases[m.Id]
+		if parent := index[m.MakeId]; parent != nil {
+			parent.Models = append(parent.Models, m)
+		}
+		models++
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListMakes - Error reading models: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[INFO] Repo - ListMakes - Got %d makes and %d models", len(makes), models)
+	return makes, nil
+}
+
+// sqliteEach runs query and calls fn for every row.
+func sqliteEach(ctx context.Context, tx *sql.Tx, query string, fn func(*sql.Rows) error, args ...any) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLiteDictionaryRepository) AddMake(ctx context.Context, name string, aliases []string) (model.Make, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddMake - Failed to begin transaction: %v", err)
+		return model.Make{}, err
+	}
+	defer tx.Rollback()
+
+	created := model.Make{Name: name, Aliases: aliases}
+	if err := tx.QueryRowContext(ctx, `INSERT INTO make (name) VALUES (?) RETURNING id`, name).Scan(&created.Id); err != nil {
+		log.Printf("[ERROR] Repo - AddMake - Error inserting make %q: %v", name, err)
+		return model.Make{}, mapDictionarySQLiteError(err, err)
+	}
+	for _, alias := range aliases {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO make_alias (alias, make_id) VALUES (?, ?)`, alias, created.Id); err != nil {
+			log.Printf("[ERROR] Repo - AddMake - Error inserting alias %q: %v", alias, err)
+			return model.Make{}, mapDictionarySQLiteError(err, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - AddMake - Failed to commit transaction: %v", err)
+		return model.Make{}, err
+	}
+
+	log.Printf("[INFO] Repo - AddMake - Added make %q with id %d", name, created.Id)
+	return created, nil
+}
+
+func (s *SQLiteDictionaryRepository) DeleteMake(ctx context.Context, id int) error {
+	return s.delete(ctx, "DeleteMake", ErrMakeNotFound, `DELETE FROM make WHERE id = ?`, id)
+}
+
+func (s *SQLiteDictionaryRepository) AddMakeAlias(ctx context.Context, makeId int, alias string) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO make_alias (alias, make_id) VALUES (?, ?)`, alias, makeId); err != nil {
+		log.Printf("[ERROR] Repo - AddMakeAlias - Error inserting alias %q: %v", alias, err)
+		return mapDictionarySQLiteError(err, ErrMakeNotFound)
+	}
+	return nil
+}
+
+func (s *SQLiteDictionaryRepository) DeleteMakeAlias(ctx context.Context, makeId int, alias string) error {
+	return s.delete(ctx, "DeleteMakeAlias", ErrAliasNotFound, `DELETE FROM make_alias WHERE make_id = ? AND alias = ?`, makeId, alias)
+}
+
+func (s *SQLiteDictionaryRepository) AddModel(ctx context.Context, makeId int, name string, aliases []string) (model.ModelRef, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddModel - Failed to begin transaction: %v", err)
+		return model.ModelRef{}, err
+	}
+	defer tx.Rollback()
+
+	created := model.ModelRef{MakeId: makeId, Name: name, Aliases: aliases}
+	err = tx.QueryRowContext(ctx, `INSERT INTO model_ref (make_id, name) VALUES (?, ?) RETURNING id`, makeId, name).Scan(&created.Id)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddModel - Error inserting model %q: %v", name, err)
+		return model.ModelRef{}, mapDictionarySQLiteError(err, ErrMakeNotFound)
+	}
+	for _, alias := range aliases {
+		_, err := tx.ExecContext(ctx, `INSERT INTO model_alias (make_id, alias, model_id) VALUES (?, ?, ?)`, makeId, alias, created.Id)
+		if err != nil {
+			log.Printf("[ERROR] Repo - AddModel - Error inserting alias %q: %v", alias, err)
+			return model.ModelRef{}, mapDictionarySQLiteError(err, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - AddModel - Failed to commit transaction: %v", err)
+		return model.ModelRef{}, err
+	}
+
+	log.Printf("[INFO] Repo - AddModel - Added model %q with id %d", name, created.Id)
+	return created, nil
+}
+
+func (s *SQLiteDictionaryRepository) DeleteModel(ctx context.Context, id int) error {
+	return s.delete(ctx, "DeleteModel", ErrModelNotFound, `DELETE FROM model_ref WHERE id = ?`, id)
+}
+
+func (s *SQLiteDictionaryRepository) AddModelAlias(ctx context.Context, modelId int, alias string) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `INSERT INTO model_alias (make_id, alias, model_id)
+	SELECT make_id, ?, id FROM model_ref WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, alias, modelId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddModelAlias - Error inserting alias %q: %v", alias, err)
+		return mapDictionarySQLiteError(err, ErrModelNotFound)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteDictionaryRepository) DeleteModelAlias(ctx context.Context, modelId int, alias string) error {
+	return s.delete(ctx, "DeleteModelAlias", ErrAliasNotFound, `DELETE FROM model_alias WHERE model_id = ? AND alias = ?`, modelId, alias)
+}
+
+func (s *SQLiteDictionaryRepository) delete(ctx context.Context, name string, notFound error, query string, args ...any) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error executing delete query: %v", name, err)
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	log.Printf("[INFO] Repo - %s - Deleted %v", name, args)
+	return nil
+}
+
+func (s *SQLiteDictionaryRepository) ResolveMake(ctx context.Context, alias string) (model.Make, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT m.id, m.name
+	FROM make_alias a JOIN make m ON m.id = a.make_id
+	WHERE a.alias = ?`
+
+	var m model.Make
+	err := s.db.QueryRowContext(ctx, query, alias).Scan(&m.Id, &m.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Make{}, ErrMakeNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - ResolveMake - Error resolving %q: %v", alias, err)
+		return model.Make{}, err
+	}
+	return m, nil
+}
+
+func (s *SQLiteDictionaryRepository) ResolveModel(ctx context.Context, makeId int, alias string) (model.ModelRef, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT m.id, m.make_id, m.name
+	FROM model_alias a JOIN model_ref m ON m.id = a.model_id
+	WHERE a.make_id = ? AND a.alias = ?`
+
+	var m model.ModelRef
+	err := s.db.QueryRowContext(ctx, query, makeId, alias).Scan(&m.Id, &m.MakeId, &m.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ModelRef{}, ErrModelNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - ResolveModel - Error resolving %q: %v", alias, err)
+		return model.ModelRef{}, err
+	}
+	return m, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors shared by every CarRepository implementation, so callers do not
+// depend on driver-specific error values.
+var (
+	ErrCarNotFound     = errors.New("car not found")
+	ErrDuplicateRegNum = errors.New("registration number already exists")
+	ErrDuplicateVin    = errors.New("VIN already exists")
+)
+
+const uniqueViolation = "23505"
+
+// mapPgError translates Postgres constraint violations into the shared
+// repository errors and leaves everything else untouched.
+func mapPgError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		if pgErr.ConstraintName == "car_vin_idx" {
+			return fmt.Errorf("%w: %s", ErrDuplicateVin, pgErr.Detail)
+		}
+		return fmt.Errorf("%w: %s", ErrDuplicateRegNum, pgErr.Detail)
+	}
+	return err
+}
+
+// firstMissing returns the first of want that is not in got.
+func firstMissing(want, got []int) (int, bool) {
+	seen := make(map[int]bool, len(got))
+	for _, id := range got {
+		seen[id] = true
+	}
+	for _, id := range want {
+		if !seen[id] {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// nullableVin stores a missing VIN as NULL, so the unique index only covers
+// cars that have one.
+func nullableVin(vin string) any {
+	if vin == "" {
+		return nil
+	}
+	return vin
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/model"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// CarEventLog reads the change feed, so a subscriber can resume where it
+// left off, and wakes listeners when events are appended. The events are the
+// outbox of the car repository: every write of a car records its events in
+// the same transaction, so the feed has exactly the committed changes.
+type CarEventLog interface {
+	// Since returns up to limit events with ids above afterId, oldest first.
+	Since(ctx context.Context, afterId int64, limit int) ([]model.CarEvent, error)
+	// ForCars returns the retained events of the given cars, oldest first.
+	ForCars(ctx context.Context, carIds []int) ([]model.CarEvent, error)
+	// LastId is the id of the newest event, 0 for an empty log.
+	LastId(ctx context.Context) (int64, error)
+	// Listen calls wake whenever events may have been appended, by this or
+	// any other instance sharing the storage, until ctx is done. Spurious
+	// calls are possible; wake must not block.
+	Listen(ctx context.Context, wake func()) error
+	// Prune deletes the events recorded before the given time, but none with
+	// an id above maxId, which a reader of the log has yet to consume.
+	Prune(ctx context.Context, before time.Time, maxId int64) (int64, error)
+}
+
+// localWaker implements Listen for logs written by this process only.
+type localWaker struct {
+	mu        sync.Mutex
+	nextId    int
+	listeners map[int]func()
+}
+
+func (w *localWaker) listen(ctx context.Context, wake func()) error {
+	w.mu.Lock()
+	if w.listeners == nil {
+		w.listeners = make(map[int]func())
+	}
+	id := w.nextId
+	w.nextId++
+	w.listeners[id] = wake
+	w.mu.Unlock()
+
+	<-ctx.Done()
+
+	w.mu.Lock()
+	delete(w.listeners, id)
+	w.mu.Unlock()
+	return ctx.Err()
+}
+
+func (w *localWaker) wakeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, wake := range w.listeners {
+		wake()
+	}
+}
+
+// sqliteWakers shares one localWaker between the repositories opened on the
+// same SQLite database, so the event log hears the writes of the car
+// repository.
+var sqliteWakers sync.Map
+
+func sqliteWaker(db *sql.DB) *localWaker {
+	waker, _ := sqliteWakers.LoadOrStore(db, &localWaker{})
+	return waker.(*localWaker)
+}
+
+// carEvents builds the events of one write, recorded for the tenant of ctx.
+func carEvents(ctx context.Context, eventType string, cars []model.Car) []model.CarEvent {
+	tenant := tenantOf(ctx)
+	events := make([]model.CarEvent, len(cars))
+	for i, car := range cars {
+		events[i] = model.CarEvent{Type: eventType, CarId: car.CarId, Tenant: tenant, Car: car}
+	}
+	return events
+}
+
+// tenantOf is the API key a write is made with, empty without
+// authentication.
+func tenantOf(ctx context.Context) string {
+	identity, _ := auth.FromContext(ctx)
+	return identity.Name
+}
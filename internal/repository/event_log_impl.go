@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// carEventChannel is the NOTIFY channel of cars.car_event; the payload is
+	// empty, as event ids are only assigned at commit (see migration 16).
+	carEventChannel  = "car_events"
+	listenRetryDelay = time.Second
+)
+
+type PostgresCarEventLog struct {
+	conn         *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+// NewPostgresCarEventLog returns the log kept in cars.car_event, the outbox
+// of CarRepositoryImpl. Instances sharing the database wake each other with
+// LISTEN/NOTIFY.
+func NewPostgresCarEventLog(conn *pgxpool.Pool, queryTimeout time.Duration) CarEventLog {
+	return &PostgresCarEventLog{
+		conn:         conn,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (e *PostgresCarEventLog) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.queryTimeout)
+}
+
+// appendCarEvents records the events of a car write in its transaction and
+// sets their CreatedAt. Their ids are assigned when the transaction commits,
+// in commit order. NOTIFY is delivered on commit, to every listening
+// instance.
+func appendCarEvents(ctx context.Context, tx pgx.Tx, events []model.CarEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO cars.car_event (type, car_id, tenant, car)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at`
+
+	batch := &pgx.Batch{}
+	for i := range events {
+		car, err := json.Marshal(events[i].Car)
+		if err != nil {
+			return err
+		}
+		event := &events[i]
+		batch.Queue(query, event.Type, event.CarId, event.Tenant, car).QueryRow(func(row pgx.Row) error {
+			return row.Scan(&event.CreatedAt)
+		})
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		log.Printf("[ERROR] Repo - AppendEvents - Error inserting events: %v", err)
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT pg_notify($1, '')", carEventChannel); err != nil {
+		log.Printf("[ERROR] Repo - AppendEvents - Unable to notify listeners: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (e *PostgresCarEventLog) Since(ctx context.Context, afterId int64, limit int) ([]model.CarEvent, error) {
+	ctx, cancel := e.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, type, car_id, tenant, car, created_at
+	FROM cars.car_event
+	WHERE id > $1
+	ORDER BY id ASC
+	LIMIT $2`
+
+	return e.query(ctx, "EventsSince", query, afterId, limit)
+}
+
+func (e *PostgresCarEventLog) ForCars(ctx context.Context, carIds []int) ([]model.CarEvent, error) {
+	ctx, cancel := e.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, type, car_id, tenant, car, created_at
+	FROM cars.car_event
+	WHERE car_id = ANY($1)
+	ORDER BY id ASC`
+
+	return e.query(ctx, "EventsForCars", query, carIds)
+}
+
+func (e *PostgresCarEventLog) query(ctx context.Context, funcName, query string, args ...any) ([]model.CarEvent, error) {
+	rows, err := e.conn.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error executing select query: %v", funcName, err)
+		return nil, err
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.CarEvent, error) {
+		var (
+			event model.CarEvent
+			car   []byte
+		)
+		if err := row.Scan(&event.Id, &event.Type, &event.CarId, &event.Tenant, &car, &event.CreatedAt); err != nil {
+			return event, err
+		}
+		return event, json.Unmarshal(car, &event.Car)
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error scanning row: %v", funcName, err)
+		return nil, err
+	}
+	return events, nil
+}
+
+func (e *PostgresCarEventLog) LastId(ctx context.Context) (int64, error) {
+	ctx, cancel := e.withQueryDeadline(ctx)
+	defer cancel()
+
+	var id int64
+	if err := e.conn.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) FROM cars.car_event").Scan(&id); err != nil {
+		log.Printf("[ERROR] Repo - LastEventId - Error executing select query: %v", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// Listen holds a connection of its own for LISTEN and re-establishes it
+// after errors; every (re)connect wakes the caller once to catch up.
+func (e *PostgresCarEventLog) Listen(ctx context.Context, wake func()) error {
+	for {
+		err := e.listen(ctx, wake)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("[ERROR] Repo - Listen - Lost the %s channel, reconnecting: %v", carEventChannel, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (e *PostgresCarEventLog) listen(ctx context.Context, wake func()) error {
+	pooled, err := e.conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A session that ran LISTEN must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+carEventChannel); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Repo - Listen - Listening on %s", carEventChannel)
+	wake()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		wake()
+	}
+}
+
+func (e *PostgresCarEventLog) Prune(ctx context.Context, before time.Time, maxId int64) (int64, error) {
+	commandTag, err := e.conn.Exec(ctx, "DELETE FROM cars.car_event WHERE created_at < $1 AND id <= $2", before, maxId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - PruneEvents - Error executing delete query: %v", err)
+		return 0, err
+	}
+	return commandTag.RowsAffected(), nil
+}
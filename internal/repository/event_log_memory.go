@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryCarEventLog keeps the change feed of a MemoryCarRepository in process
+// memory; ids are never reused, like those of the SQL tables.
+type MemoryCarEventLog struct {
+	mu     sync.RWMutex
+	events []model.CarEvent
+	nextId int64
+	waker  localWaker
+}
+
+func newMemoryCarEventLog() *MemoryCarEventLog {
+	return &MemoryCarEventLog{nextId: 1}
+}
+
+// NewMemoryCarEventLog returns the change feed of cars, which must come from
+// NewMemoryCarRepository.
+func NewMemoryCarEventLog(cars CarRepository) CarEventLog {
+	return cars.(*MemoryCarRepository).events
+}
+
+// record appends events under the lock of the car write they belong to.
+func (m *MemoryCarEventLog) record(events []model.CarEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	for i := range events {
+		events[i].Id = m.nextId
+		events[i].CreatedAt = now
+		m.nextId++
+		m.events = append(m.events, events[i])
+	}
+	m.mu.Unlock()
+
+	m.waker.wakeAll()
+}
+
+func (m *MemoryCarEventLog) Since(ctx context.Context, afterId int64, limit int) ([]model.CarEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := sort.Search(len(m.events), func(i int) bool { return m.events[i].Id > afterId })
+	end := min(start+limit, len(m.events))
+	return append([]model.CarEvent(nil), m.events[start:end]...), nil
+}
+
+func (m *MemoryCarEventLog) ForCars(ctx context.Context, carIds []int) ([]model.CarEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := make(map[int]bool, len(carIds))
+	for _, carId := range carIds {
+		wanted[carId] = true
+	}
+	var events []model.CarEvent
+	for _, event := range m.events {
+		if wanted[event.CarId] {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryCarEventLog) LastId(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nextId - 1, nil
+}
+
+func (m *MemoryCarEventLog) Listen(ctx context.Context, wake func()) error {
+	return m.waker.listen(ctx, wake)
+}
+
+func (m *MemoryCarEventLog) Prune(ctx context.Context, before time.Time, maxId int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Events are appended in id and time order.
+	n := sort.Search(len(m.events), func(i int) bool {
+		return !m.events[i].CreatedAt.Before(before) || m.events[i].Id > maxId
+	})
+	m.events = append([]model.CarEvent(nil), m.events[n:]...)
+	return int64(n), nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SQLiteCarEventLog reads the change feed from the car_event table. SQLite
+// has no NOTIFY, so only writes made through this process wake listeners;
+// subscribers pick up other writers by polling.
+type SQLiteCarEventLog struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+	waker        *localWaker
+}
+
+func NewSQLiteCarEventLog(db *sql.DB, queryTimeout time.Duration) CarEventLog {
+	return &SQLiteCarEventLog{
+		db:           db,
+		queryTimeout: queryTimeout,
+		waker:        sqliteWaker(db),
+	}
+}
+
+func (s *SQLiteCarEventLog) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// appendSQLiteCarEvents records the events of a car write in its transaction
+// and sets their Id and CreatedAt. The caller wakes listeners after commit.
+func appendSQLiteCarEvents(ctx context.Context, tx *sql.Tx, events []model.CarEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO car_event (type, car_id, tenant, car, created_at)
+	VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	for i := range events {
+		car, err := json.Marshal(events[i].Car)
+		if err != nil {
+			return err
+		}
+		result, err := stmt.ExecContext(ctx, events[i].Type, events[i].CarId, events[i].Tenant, string(car), now.UnixMilli())
+		if err != nil {
+			log.Printf("[ERROR] Repo - AppendEvents - Error inserting event: %v", err)
+			return err
+		}
+		if events[i].Id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		events[i].CreatedAt = now
+	}
+	return nil
+}
+
+func (s *SQLiteCarEventLog) Since(ctx context.Context, afterId int64, limit int) ([]model.CarEvent, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT id, type, car_id, tenant, car, created_at
+	FROM car_event
+	WHERE id > ?
+	ORDER BY id ASC
+	LIMIT ?`
+
+	return s.query(ctx, "EventsSince", query, afterId, limit)
+}
+
+func (s *SQLiteCarEventLog) ForCars(ctx context.Context, carIds []int) ([]model.CarEvent, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	var events []model.CarEvent
+	for start := 0; start < len(carIds); start += sqliteInsertBatch {
+		batch := carIds[start:min(start+sqliteInsertBatch, len(carIds))]
+
+		args := make([]any, len(batch))
+		for i, carId := range batch {
+			args[i] = carId
+		}
+		query := `SELECT id, type, car_id, tenant, car, created_at
+		FROM car_event
+		WHERE car_id IN (?` + strings.Repeat(", ?", len(batch)-1) + `)`
+
+		batchEvents, err := s.query(ctx, "EventsForCars", query, args...)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, batchEvents...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+	return events, nil
+}
+
+func (s *SQLiteCarEventLog) query(ctx context.Context, funcName, query string, args ...any) ([]model.CarEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Repo - %s - Error executing select query: %v", funcName, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.CarEvent
+	for rows.Next() {
+		var (
+			event     model.CarEvent
+			car       string
+			createdAt int64
+		)
+		if err := rows.Scan(&event.Id, &event.Type, &event.CarId, &event.Tenant, &car, &createdAt); err != nil {
+			log.Printf("[ERROR] Repo - %s - Error scanning row: %v", funcName, err)
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(car), &event.Car); err != nil {
+			return nil, err
+		}
+		event.CreatedAt = time.UnixMilli(createdAt)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLiteCarEventLog) LastId(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM car_event").Scan(&id); err != nil {
+		log.Printf("[ERROR] Repo - LastEventId - Error executing select query: %v", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *SQLiteCarEventLog) Listen(ctx context.Context, wake func()) error {
+	return s.waker.listen(ctx, wake)
+}
+
+func (s *SQLiteCarEventLog) Prune(ctx context.Context, before time.Time, maxId int64) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM car_event WHERE created_at < ? AND id <= ?", before.UnixMilli(), maxId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - PruneEvents - Error executing delete query: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,156 @@
+package repotest
+
+import (
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// RunAttachments is the conformance suite of repository.AttachmentRepository
+// together with the car repository its attachments belong to. newStore must
+// return an empty pair for every call.
+func RunAttachments(t *testing.T, newStore func(t *testing.T) (repository.CarRepository, repository.AttachmentRepository)) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.CarRepository, attachments repository.AttachmentRepository)
+	}{
+		{"AddAndList", testAddAttachments},
+		{"Dedup", testAttachmentDedup},
+		{"Delete", testDeleteAttachment},
+		{"CarDeleted", testAttachmentsOfDeletedCar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, attachments := newStore(t)
+			tt.fn(t, repo, attachments)
+		})
+	}
+}
+
+func attachment(carId int, fileName, sha256 string) model.Attachment {
+	return model.Attachment{
+		CarId:       carId,
+		FileName:    fileName,
+		ContentType: "image/jpeg",
+		Size:        1024,
+		Sha256:      strings.Repeat(sha256, 64/len(sha256)),
+	}
+}
+
+func mustAttach(t *testing.T, attachments repository.AttachmentRepository, a model.Attachment) model.Attachment {
+	t.Helper()
+	added, created, err := attachments.AddAttachment(ctx, a)
+	if err != nil || !created {
+		t.Fatalf("AddAttachment(%s) = %t, %v", a.FileName, created, err)
+	}
+	return added
+}
+
+func testAddAttachments(t *testing.T, repo repository.CarRepository, attachments repository.AttachmentRepository) {
+	cars := mustAdd(t, repo, car("Lada", "Vesta", 2018, "A001AA77"), car("Kia", "Rio", 2020, "B002BB77"))
+
+	front := mustAttach(t, attachments, attachment(cars[0].CarId, "front.jpg", "a"))
+	if front.Id == 0 || front.CreatedAt.IsZero() || front.Size != 1024 || front.ContentType != "image/jpeg" {
+		t.Fatalf("added attachment = %+v", front)
+	}
+	back := mustAttach(t, attachments, attachment(cars[0].CarId, "back.jpg", "b"))
+	mustAttach(t, attachments, attachment(cars[1].CarId, "scan.pdf", "c"))
+
+	list, err := attachments.ListAttachments(ctx, cars[0].CarId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Id != front.Id || list[1].Id != back.Id || list[1].FileName != "back.jpg" {
+		t.Fatalf("attachments of the first car = %+v", list)
+	}
+
+	got, err := attachments.GetAttachment(ctx, cars[0].CarId, back.Id)
+	if err != nil || got.Sha256 != back.Sha256 || !got.CreatedAt.Equal(back.CreatedAt) {
+		t.Fatalf("GetAttachment = %+v, %v, want %+v", got, err, back)
+	}
+	// An attachment is only found through its own car.
+	if _, err := attachments.GetAttachment(ctx, cars[1].CarId, back.Id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Fatalf("attachment of another car: %v, want ErrAttachmentNotFound", err)
+	}
+
+	if _, _, err := attachments.AddAttachment(ctx, attachment(cars[1].CarId+100, "ghost.jpg", "d")); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("attachment of a missing car: %v, want ErrCarNotFound", err)
+	}
+	if list, err := attachments.ListAttachments(ctx, cars[1].CarId+100); err != nil || len(list) != 0 {
+		t.Fatalf("attachments of a missing car = %+v, %v", list, err)
+	}
+}
+
+func testAttachmentDedup(t *testing.T, repo repository.CarRepository, attachments repository.AttachmentRepository) {
+	cars := mustAdd(t, repo, car("Lada", "Vesta", 2018, "A001AA77"), car("Kia", "Rio", 2020, "B002BB77"))
+
+	first := mustAttach(t, attachments, attachment(cars[0].CarId, "photo.jpg", "a"))
+	again, created, err := attachments.AddAttachment(ctx, attachment(cars[0].CarId, "copy of photo.jpg", "a"))
+	if err != nil || created || again.Id != first.Id || again.FileName != "photo.jpg" {
+		t.Fatalf("same content again = %+v, %t, %v, want attachment %d", again, created, err, first.Id)
+	}
+	// Another car may have the same content.
+	other := mustAttach(t, attachments, attachment(cars[1].CarId, "photo.jpg", "a"))
+	if other.Id == first.Id {
+		t.Fatalf("attachment of another car reused id %d", first.Id)
+	}
+
+	if list, _ := attachments.ListAttachments(ctx, cars[0].CarId); len(list) != 1 {
+		t.Fatalf("attachments after a duplicate = %+v", list)
+	}
+}
+
+func testDeleteAttachment(t *testing.T, repo repository.CarRepository, attachments repository.AttachmentRepository) {
+	cars := mustAdd(t, repo, car("Lada", "Vesta", 2018, "A001AA77"), car("Kia", "Rio", 2020, "B002BB77"))
+	first := mustAttach(t, attachments, attachment(cars[0].CarId, "photo.jpg", "a"))
+	second := mustAttach(t, attachments, attachment(cars[1].CarId, "photo.jpg", "a"))
+
+	if _, err := attachments.DeleteAttachment(ctx, cars[1].CarId, first.Id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Fatalf("delete through another car: %v, want ErrAttachmentNotFound", err)
+	}
+	deleted, err := attachments.DeleteAttachment(ctx, cars[0].CarId, first.Id)
+	if err != nil || deleted.Sha256 != first.Sha256 || deleted.FileName != "photo.jpg" {
+		t.Fatalf("DeleteAttachment = %+v, %v", deleted, err)
+	}
+	if _, err := attachments.DeleteAttachment(ctx, cars[0].CarId, first.Id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Fatalf("second delete: %v, want ErrAttachmentNotFound", err)
+	}
+
+	// The content is still referenced by the other car.
+	if referenced, err := attachments.BlobReferenced(ctx, first.Sha256); err != nil || !referenced {
+		t.Fatalf("BlobReferenced after one of two deletes = %t, %v", referenced, err)
+	}
+	if _, err := attachments.DeleteAttachment(ctx, cars[1].CarId, second.Id); err != nil {
+		t.Fatal(err)
+	}
+	if referenced, err := attachments.BlobReferenced(ctx, first.Sha256); err != nil || referenced {
+		t.Fatalf("BlobReferenced after both deletes = %t, %v", referenced, err)
+	}
+
+	// Deleted content can be attached again, as a new attachment.
+	again := mustAttach(t, attachments, attachment(cars[0].CarId, "photo.jpg", "a"))
+	if again.Id == first.Id {
+		t.Fatalf("re-added attachment reused id %d", first.Id)
+	}
+}
+
+func testAttachmentsOfDeletedCar(t *testing.T, repo repository.CarRepository, attachments repository.AttachmentRepository) {
+	cars := mustAdd(t, repo, car("Lada", "Vesta", 2018, "A001AA77"), car("Kia", "Rio", 2020, "B002BB77"))
+	sold := mustAttach(t, attachments, attachment(cars[0].CarId, "photo.jpg", "a"))
+	mustAttach(t, attachments, attachment(cars[1].CarId, "scan.pdf", "b"))
+
+	if err := repo.DeleteCar(ctx, cars[0].CarId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attachments.GetAttachment(ctx, cars[0].CarId, sold.Id); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Fatalf("attachment of a deleted car: %v, want ErrAttachmentNotFound", err)
+	}
+	if referenced, err := attachments.BlobReferenced(ctx, sold.Sha256); err != nil || referenced {
+		t.Fatalf("BlobReferenced after the car was deleted = %t, %v", referenced, err)
+	}
+	if list, err := attachments.ListAttachments(ctx, cars[1].CarId); err != nil || len(list) != 1 {
+		t.Fatalf("attachments of the other car = %+v, %v", list, err)
+	}
+}
@@ -0,0 +1,173 @@
+package repotest
+
+import (
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// RunDictionary is the conformance suite of repository.DictionaryRepository.
+// newRepo must return an empty repository for every call.
+func RunDictionary(t *testing.T, newRepo func(t *testing.T) repository.DictionaryRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.DictionaryRepository)
+	}{
+		{"MakesAndAliases", testMakesAndAliases},
+		{"Models", testModels},
+		{"DeleteCascades", testDictionaryDeleteCascades},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func mustAddMake(t *testing.T, repo repository.DictionaryRepository, name string, aliases ...string) model.Make {
+	t.Helper()
+	created, err := repo.AddMake(ctx, name, aliases)
+	if err != nil {
+		t.Fatalf("AddMake %q: %v", name, err)
+	}
+	return created
+}
+
+func testMakesAndAliases(t *testing.T, repo repository.DictionaryRepository) {
+	bmw := mustAddMake(t, repo, "BMW", "bmw", "бмв")
+	mustAddMake(t, repo, "Audi", "audi")
+
+	if _, err := repo.AddMake(ctx, "Bmw Group", []string{"bmwgroup", "bmw"}); !errors.Is(err, repository.ErrAliasTaken) {
+		t.Fatalf("AddMake with a taken alias = %v, want ErrAliasTaken", err)
+	}
+	if _, err := repo.AddMake(ctx, "BMW", []string{"bayerische"}); !errors.Is(err, repository.ErrAliasTaken) {
+		t.Fatalf("AddMake with a taken name = %v, want ErrAliasTaken", err)
+	}
+	// The failed AddMake must not leave its other aliases behind.
+	if _, err := repo.ResolveMake(ctx, "bmwgroup"); !errors.Is(err, repository.ErrMakeNotFound) {
+		t.Fatalf("alias of a rejected make resolves: %v", err)
+	}
+
+	if err := repo.AddMakeAlias(ctx, bmw.Id, "бэмвэ"); err != nil {
+		t.Fatalf("AddMakeAlias: %v", err)
+	}
+	if err := repo.AddMakeAlias(ctx, bmw.Id+100, "x"); !errors.Is(err, repository.ErrMakeNotFound) {
+		t.Fatalf("AddMakeAlias to a missing make = %v, want ErrMakeNotFound", err)
+	}
+
+	got, err := repo.ResolveMake(ctx, "бэмвэ")
+	if err != nil || got.Id != bmw.Id || got.Name != "BMW" {
+		t.Fatalf("ResolveMake = %+v %v, want BMW", got, err)
+	}
+
+	if err := repo.DeleteMakeAlias(ctx, bmw.Id, "бмв"); err != nil {
+		t.Fatalf("DeleteMakeAlias: %v", err)
+	}
+	if err := repo.DeleteMakeAlias(ctx, bmw.Id, "бмв"); !errors.Is(err, repository.ErrAliasNotFound) {
+		t.Fatalf("second DeleteMakeAlias = %v, want ErrAliasNotFound", err)
+	}
+
+	makes, err := repo.ListMakes(ctx)
+	if err != nil {
+		t.Fatalf("ListMakes: %v", err)
+	}
+	if got := fmt.Sprint(makeSummary(makes)); got != "[Audi[audi] BMW[bmw бэмвэ]]" {
+		t.Fatalf("ListMakes = %s", got)
+	}
+}
+
+func testModels(t *testing.T, repo repository.DictionaryRepository) {
+	bmw := mustAddMake(t, repo, "BMW", "bmw")
+	lada := mustAddMake(t, repo, "Lada", "lada")
+
+	x5, err := repo.AddModel(ctx, bmw.Id, "X5", []string{"x5", "икс5"})
+	if err != nil {
+		t.Fatalf("AddModel: %v", err)
+	}
+	// Model aliases are scoped to the make.
+	if _, err := repo.AddModel(ctx, lada.Id, "X5", []string{"x5"}); err != nil {
+		t.Fatalf("AddModel with the same alias under another make: %v", err)
+	}
+	if _, err := repo.AddModel(ctx, bmw.Id, "X5 M", []string{"x5m", "x5"}); !errors.Is(err, repository.ErrAliasTaken) {
+		t.Fatalf("AddModel with a taken alias = %v, want ErrAliasTaken", err)
+	}
+	if _, err := repo.AddModel(ctx, bmw.Id+100, "X6", []string{"x6"}); !errors.Is(err, repository.ErrMakeNotFound) {
+		t.Fatalf("AddModel to a missing make = %v, want ErrMakeNotFound", err)
+	}
+
+	if err := repo.AddModelAlias(ctx, x5.Id, "x-5"); err != nil {
+		t.Fatalf("AddModelAlias: %v", err)
+	}
+	if err := repo.AddModelAlias(ctx, x5.Id+100, "x"); !errors.Is(err, repository.ErrModelNotFound) {
+		t.Fatalf("AddModelAlias to a missing model = %v, want ErrModelNotFound", err)
+	}
+
+	got, err := repo.ResolveModel(ctx, bmw.Id, "x-5")
+	if err != nil || got.Id != x5.Id || got.Name != "X5" || got.MakeId != bmw.Id {
+		t.Fatalf("ResolveModel = %+v %v, want BMW X5", got, err)
+	}
+	if _, err := repo.ResolveModel(ctx, lada.Id, "икс5"); !errors.Is(err, repository.ErrModelNotFound) {
+		t.Fatalf("ResolveModel under the wrong make = %v, want ErrModelNotFound", err)
+	}
+
+	if err := repo.DeleteModelAlias(ctx, x5.Id, "икс5"); err != nil {
+		t.Fatalf("DeleteModelAlias: %v", err)
+	}
+	makes, err := repo.ListMakes(ctx)
+	if err != nil {
+		t.Fatalf("ListMakes: %v", err)
+	}
+	if got := fmt.Sprint(makeSummary(makes)); got != "[BMW[bmw] X5[x-5 x5] Lada[lada] X5[x5]]" {
+		t.Fatalf("ListMakes = %s", got)
+	}
+}
+
+func testDictionaryDeleteCascades(t *testing.T, repo repository.DictionaryRepository) {
+	bmw := mustAddMake(t, repo, "BMW", "bmw")
+	x5, err := repo.AddModel(ctx, bmw.Id, "X5", []string{"x5"})
+	if err != nil {
+		t.Fatalf("AddModel: %v", err)
+	}
+
+	if err := repo.DeleteModel(ctx, x5.Id); err != nil {
+		t.Fatalf("DeleteModel: %v", err)
+	}
+	if err := repo.DeleteModel(ctx, x5.Id); !errors.Is(err, repository.ErrModelNotFound) {
+		t.Fatalf("second DeleteModel = %v, want ErrModelNotFound", err)
+	}
+	if _, err := repo.ResolveModel(ctx, bmw.Id, "x5"); !errors.Is(err, repository.ErrModelNotFound) {
+		t.Fatalf("alias of a deleted model resolves: %v", err)
+	}
+
+	if _, err := repo.AddModel(ctx, bmw.Id, "X3", []string{"x3"}); err != nil {
+		t.Fatalf("AddModel: %v", err)
+	}
+	if err := repo.DeleteMake(ctx, bmw.Id); err != nil {
+		t.Fatalf("DeleteMake: %v", err)
+	}
+	if err := repo.DeleteMake(ctx, bmw.Id); !errors.Is(err, repository.ErrMakeNotFound) {
+		t.Fatalf("second DeleteMake = %v, want ErrMakeNotFound", err)
+	}
+	if _, err := repo.ResolveMake(ctx, "bmw"); !errors.Is(err, repository.ErrMakeNotFound) {
+		t.Fatalf("alias of a deleted make resolves: %v", err)
+	}
+	// Its aliases are free again.
+	mustAddMake(t, repo, "BMW", "bmw")
+	if makes, _ := repo.ListMakes(ctx); len(makes) != 1 || len(makes[0].Models) != 0 {
+		t.Fatalf("models of a deleted make survived: %+v", makes)
+	}
+}
+
+// makeSummary renders makes and their models as Name[aliases...].
+func makeSummary(makes []model.Make) []string {
+	var summary []string
+	for _, m := range makes {
+		summary = append(summary, fmt.Sprintf("%s%v", m.Name, m.Aliases))
+		for _, ref := range m.Models {
+			summary = append(summary, fmt.Sprintf("%s%v", ref.Name, ref.Aliases))
+		}
+	}
+	return summary
+}
@@ -0,0 +1,255 @@
+package repotest
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// RunEventLog is the conformance suite of repository.CarEventLog together
+// with the car repository whose outbox it reads. newStore must return an
+// empty pair for every call.
+func RunEventLog(t *testing.T, newStore func(t *testing.T) (repository.CarRepository, repository.CarEventLog)) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.CarRepository, eventLog repository.CarEventLog)
+	}{
+		{"Outbox", testOutbox},
+		{"Since", testEventsSince},
+		{"ForCars", testEventsForCars},
+		{"Listen", testListen},
+		{"Prune", testPrune},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, eventLog := newStore(t)
+			tt.fn(t, repo, eventLog)
+		})
+	}
+}
+
+func testEventsForCars(t *testing.T, repo repository.CarRepository, eventLog repository.CarEventLog) {
+	cars := mustAdd(t, repo, car("Lada", "Vesta", 2018, "A001AA77"), car("Kia", "Rio", 2020, "C003CC77"),
+		car("BMW", "X5", 2010, "B002BB77"))
+	sold := cars[0]
+	sold.OwnerSurname = "Петров"
+	if err := repo.UpdateCar(ctx, sold); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteCar(ctx, cars[1].CarId); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := eventLog.ForCars(ctx, []int{cars[1].CarId, cars[0].CarId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		eventType string
+		carId     int
+	}{
+		{model.CarCreated, cars[0].CarId},
+		{model.CarCreated, cars[1].CarId},
+		{model.CarUpdated, cars[0].CarId},
+		{model.CarDeleted, cars[1].CarId},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("ForCars = %+v, want %d events", events, len(want))
+	}
+	for i, w := range want {
+		if events[i].Type != w.eventType || events[i].CarId != w.carId {
+			t.Fatalf("event %d = %+v, want %s of car %d", i, events[i], w.eventType, w.carId)
+		}
+	}
+	if events[2].Car.OwnerSurname != "Петров" {
+		t.Fatalf("updated car: %+v", events[2].Car)
+	}
+	if events, err := eventLog.ForCars(ctx, []int{999}); err != nil || len(events) != 0 {
+		t.Fatalf("ForCars of a missing car = %+v, %v", events, err)
+	}
+}
+
+func mustSince(t *testing.T, eventLog repository.CarEventLog, afterId int64) []model.CarEvent {
+	t.Helper()
+	events, err := eventLog.Since(ctx, afterId, 100)
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	return events
+}
+
+func testOutbox(t *testing.T, repo repository.CarRepository, eventLog repository.CarEventLog) {
+	if last, err := eventLog.LastId(ctx); err != nil || last != 0 {
+		t.Fatalf("LastId of an empty log = %d, %v", last, err)
+	}
+
+	crm := auth.WithIdentity(ctx, auth.Identity{Name: "crm", Role: "editor"})
+	vesta, rio := car("Lada", "Vesta", 2018, "A001AA77"), car("Kia", "Rio", 2020, "C003CC77")
+	vesta.Vin = "XTA21099043567890"
+	if err := repo.AddCars(crm, []model.Car{vesta, rio}); err != nil {
+		t.Fatal(err)
+	}
+	cars := all(t, repo)
+
+	events := mustSince(t, eventLog, 0)
+	if len(events) != 2 {
+		t.Fatalf("AddCars recorded %d events, want 2", len(events))
+	}
+	for i, event := range events {
+		if event.Type != model.CarCreated || event.CarId != cars[i].CarId || event.Tenant != "crm" ||
+			event.Id == 0 || event.CreatedAt.IsZero() || (i > 0 && event.Id <= events[i-1].Id) {
+			t.Fatalf("created event %d: %+v", i, event)
+		}
+	}
+	if events[0].Car.Vin != vesta.Vin || events[0].Car.OwnerName != "Иван" {
+		t.Fatalf("event car: %+v", events[0].Car)
+	}
+	last := events[1].Id
+
+	// A failed write records nothing.
+	renamed := cars[0]
+	renamed.Model = "Granta"
+	if err := repo.UpdateCars(ctx, []model.Car{renamed, {CarId: 999, RegNum: "X999XX99"}}); err == nil {
+		t.Fatal("UpdateCars with a missing id succeeded")
+	}
+	if err := repo.DeleteCar(ctx, 999); err == nil {
+		t.Fatal("DeleteCar of a missing id succeeded")
+	}
+	if events := mustSince(t, eventLog, last); len(events) != 0 {
+		t.Fatalf("failed writes recorded %+v", events)
+	}
+
+	if err := repo.UpdateCar(ctx, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateCars(ctx, []model.Car{renamed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteCar(ctx, cars[0].CarId); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteCars(ctx, []int{cars[1].CarId}); err != nil {
+		t.Fatal(err)
+	}
+
+	events = mustSince(t, eventLog, last)
+	want := []struct {
+		eventType string
+		carId     int
+	}{
+		{model.CarUpdated, cars[0].CarId},
+		{model.CarUpdated, cars[0].CarId},
+		{model.CarDeleted, cars[0].CarId},
+		{model.CarDeleted, cars[1].CarId},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("recorded %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.eventType || events[i].CarId != w.carId || events[i].Tenant != "" {
+			t.Fatalf("event %d = %+v, want %s of car %d", i, events[i], w.eventType, w.carId)
+		}
+	}
+	// Deletes record the last state of the car.
+	if events[2].Car.Model != "Granta" || events[3].Car.Mark != "Kia" || events[3].Car.OwnerSurname != "Иванов" {
+		t.Fatalf("deleted cars: %+v, %+v", events[2].Car, events[3].Car)
+	}
+	if last, err := eventLog.LastId(ctx); err != nil || last != events[3].Id {
+		t.Fatalf("LastId = %d, %v, want %d", last, err, events[3].Id)
+	}
+}
+
+func testEventsSince(t *testing.T, repo repository.CarRepository, eventLog repository.CarEventLog) {
+	mustAdd(t, repo,
+		car("Lada", "Vesta", 2018, "A001AA77"),
+		car("Lada", "Granta", 2019, "B002BB77"),
+		car("Kia", "Rio", 2020, "C003CC77"),
+	)
+
+	page, err := eventLog.Since(ctx, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 {
+		t.Fatalf("Since(0, 2) returned %d events", len(page))
+	}
+	rest := mustSince(t, eventLog, page[1].Id)
+	if len(rest) != 1 || rest[0].Car.RegNum != "C003CC77" {
+		t.Fatalf("Since(%d) = %+v", page[1].Id, rest)
+	}
+	if events := mustSince(t, eventLog, rest[0].Id); len(events) != 0 {
+		t.Fatalf("Since the last id = %+v", events)
+	}
+}
+
+func testListen(t *testing.T, repo repository.CarRepository, eventLog repository.CarEventLog) {
+	listenCtx, cancel := context.WithCancel(ctx)
+	woken := make(chan struct{}, 1)
+	done := make(chan error)
+	go func() {
+		done <- eventLog.Listen(listenCtx, func() {
+			select {
+			case woken <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	// Listen may start after a write, or wake once on connect; either way a
+	// write is followed by a wake within the deadline.
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for n, woke := 0, false; !woke; {
+		select {
+		case <-woken:
+			woke = true
+		case <-ticker.C:
+			n++
+			mustAdd(t, repo, car("Lada", "Vesta", 2018, "A"+strconv.Itoa(n)))
+		case <-deadline:
+			t.Fatal("Listen was not woken by a write")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen did not return after cancel")
+	}
+}
+
+func testPrune(t *testing.T, repo repository.CarRepository, eventLog repository.CarEventLog) {
+	cars := mustAdd(t, repo, car("Lada", "Vesta", 2018, "A001AA77"))
+	// Timestamps of some logs are in milliseconds.
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := repo.DeleteCar(ctx, cars[0].CarId); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events past maxId are kept however old they are.
+	first := mustSince(t, eventLog, 0)[0].Id
+	if pruned, err := eventLog.Prune(ctx, time.Now(), first-1); err != nil || pruned != 0 {
+		t.Fatalf("Prune up to id %d = %d, %v, want 0", first-1, pruned, err)
+	}
+
+	pruned, err := eventLog.Prune(ctx, cutoff, first+1)
+	if err != nil || pruned != 1 {
+		t.Fatalf("Prune = %d, %v, want 1", pruned, err)
+	}
+	events := mustSince(t, eventLog, 0)
+	if len(events) != 1 || events[0].Type != model.CarDeleted {
+		t.Fatalf("after Prune: %+v", events)
+	}
+	// Ids are not reused after pruning.
+	if last, err := eventLog.LastId(ctx); err != nil || last != events[0].Id {
+		t.Fatalf("LastId after Prune = %d, %v", last, err)
+	}
+}
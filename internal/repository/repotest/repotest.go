@@ -0,0 +1,843 @@
+// Package repotest is the conformance suite every repository.CarRepository
+// implementation must pass, so storage backends stay interchangeable.
+package repotest
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run executes the suite. newRepo must return an empty repository for every
+// call; ids are only compared relative to each other.
+func Run(t *testing.T, newRepo func(t *testing.T) repository.CarRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.CarRepository)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"GetMissing", testGetMissing},
+		{"GetByIds", testGetByIds},
+		{"DuplicateRegNum", testDuplicateRegNum},
+		{"Vin", testVin},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"BatchUpdate", testBatchUpdate},
+		{"BatchDelete", testBatchDelete},
+		{"ModifyCars", testModifyCars},
+		{"Filters", testFilters},
+		{"Paging", testPaging},
+		{"PagingErrors", testPagingErrors},
+		{"FindExistingRegNums", testFindExistingRegNums},
+		{"StreamCars", testStreamCars},
+		{"Search", testSearch},
+		{"SearchPaging", testSearchPaging},
+		{"Suggest", testSuggest},
+		{"Stats", testStats},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"SyncTracking", testSyncTracking},
+		{"Divergences", testDivergences},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+func car(mark, carModel string, year int, regNum string) model.Car {
+	return model.Car{
+		Mark:            mark,
+		Model:           carModel,
+		Year:            year,
+		RegNum:          regNum,
+		OwnerName:       "Иван",
+		OwnerSurname:    "Иванов",
+		OwnerPatronymic: "Иванович",
+	}
+}
+
+// mustAdd inserts cars and returns them with their assigned ids, in id order.
+func mustAdd(t *testing.T, repo repository.CarRepository, cars ...model.Car) []model.Car {
+	t.Helper()
+	if err := repo.AddCars(ctx, cars); err != nil {
+		t.Fatalf("AddCars: %v", err)
+	}
+	return all(t, repo)
+}
+
+func all(t *testing.T, repo repository.CarRepository) []model.Car {
+	t.Helper()
+	var cars []model.Car
+	if err := repo.StreamCars(ctx, "", "", "", func(car model.Car) error {
+		cars = append(cars, car)
+		return nil
+	}); err != nil {
+		t.Fatalf("StreamCars: %v", err)
+	}
+	return cars
+}
+
+func ids(cars []model.Car) []int {
+	result := make([]int, len(cars))
+	for i, car := range cars {
+		result[i] = car.CarId
+	}
+	return result
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testAddAndGet(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"))
+	if len(added) != 2 || added[0].CarId >= added[1].CarId {
+		t.Fatalf("expected two cars in insertion order, got %+v", added)
+	}
+
+	got, err := repo.GetCarById(ctx, added[1].CarId)
+	if err != nil {
+		t.Fatalf("GetCarById: %v", err)
+	}
+	want := car("Lada", "Vesta", 2020, "B002BB99")
+	want.CarId = added[1].CarId
+	if got != want {
+		t.Fatalf("GetCarById = %+v, want %+v", got, want)
+	}
+
+	more := []model.Car{car("Kia", "Rio", 2015, "C003CC77"), car("Kia", "Ceed", 2016, "E004EE77")}
+	if err := repo.AddCars(ctx, more); err != nil {
+		t.Fatalf("AddCars: %v", err)
+	}
+	for _, added := range more {
+		if got, err := repo.GetCarById(ctx, added.CarId); err != nil || got.RegNum != added.RegNum {
+			t.Fatalf("AddCars set id %d for %s, which holds %+v, %v", added.CarId, added.RegNum, got, err)
+		}
+	}
+}
+
+func testGetMissing(t *testing.T, repo repository.CarRepository) {
+	if _, err := repo.GetCarById(ctx, 42); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("GetCarById on empty repository = %v, want ErrCarNotFound", err)
+	}
+}
+
+func testGetByIds(t *testing.T, repo repository.CarRepository) {
+	cars := mustAdd(t, repo,
+		car("BMW", "X5", 2010, "A001AA77"),
+		car("Lada", "Vesta", 2018, "B002BB77"),
+		car("Kia", "Rio", 2015, "C003CC77"))
+
+	got, err := repo.GetCarsByIds(ctx, []int{cars[2].CarId, 999, cars[0].CarId, cars[2].CarId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != cars[0] || got[1] != cars[2] {
+		t.Fatalf("GetCarsByIds = %+v, want cars %d and %d in id order", got, cars[0].CarId, cars[2].CarId)
+	}
+	if got, err := repo.GetCarsByIds(ctx, []int{999}); err != nil || len(got) != 0 {
+		t.Fatalf("GetCarsByIds of a missing id = %+v, %v", got, err)
+	}
+}
+
+func testDuplicateRegNum(t *testing.T, repo repository.CarRepository) {
+	mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"))
+
+	err := repo.AddCars(ctx, []model.Car{car("Kia", "Rio", 2015, "C003CC77"), car("Kia", "Rio", 2015, "A001AA77")})
+	if !errors.Is(err, repository.ErrDuplicateRegNum) {
+		t.Fatalf("AddCars with an existing plate = %v, want ErrDuplicateRegNum", err)
+	}
+	err = repo.AddCars(ctx, []model.Car{car("Kia", "Rio", 2015, "C003CC77"), car("Kia", "Rio", 2016, "C003CC77")})
+	if !errors.Is(err, repository.ErrDuplicateRegNum) {
+		t.Fatalf("AddCars with a repeated plate = %v, want ErrDuplicateRegNum", err)
+	}
+	if n := len(all(t, repo)); n != 1 {
+		t.Fatalf("failed batches must not insert anything, have %d cars", n)
+	}
+}
+
+func testVin(t *testing.T, repo repository.CarRepository) {
+	const (
+		bmwVin  = "WBAPH7C59BE123456"
+		kiaVin  = "XWEPH81ADH0012345"
+		freeVin = "Z94CT41DBER123456"
+	)
+	withVin := car("BMW", "X5", 2010, "A001AA77")
+	withVin.Vin = bmwVin
+	added := mustAdd(t, repo, withVin, car("Lada", "Vesta", 2020, "B002BB99"), car("Kia", "Rio", 2015, "C003CC77"))
+	if added[0].Vin != bmwVin || added[1].Vin != "" {
+		t.Fatalf("stored VINs = %q, %q", added[0].Vin, added[1].Vin)
+	}
+
+	got, err := repo.GetCarByVin(ctx, bmwVin)
+	if err != nil || got.CarId != added[0].CarId {
+		t.Fatalf("GetCarByVin = %+v, %v, want car %d", got, err, added[0].CarId)
+	}
+	if _, err := repo.GetCarByVin(ctx, freeVin); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("GetCarByVin of an unknown VIN = %v, want ErrCarNotFound", err)
+	}
+	if cars, _, err := repo.GetCars(ctx, 10, "BMW", "", "", dto.Cursors{}); err != nil || len(cars) != 1 || cars[0].Vin != bmwVin {
+		t.Fatalf("GetCars = %+v, %v, want the VIN listed", cars, err)
+	}
+
+	dup := car("BMW", "X3", 2011, "E005EE50")
+	dup.Vin = bmwVin
+	if err := repo.AddCars(ctx, []model.Car{dup}); !errors.Is(err, repository.ErrDuplicateVin) {
+		t.Fatalf("AddCars with a taken VIN = %v, want ErrDuplicateVin", err)
+	}
+
+	// Cars without a VIN never clash.
+	clash := added[1]
+	clash.Vin = bmwVin
+	if err := repo.UpdateCar(ctx, clash); !errors.Is(err, repository.ErrDuplicateVin) {
+		t.Fatalf("UpdateCar to a taken VIN = %v, want ErrDuplicateVin", err)
+	}
+	kia := added[2]
+	kia.Vin = kiaVin
+	if err := repo.UpdateCars(ctx, []model.Car{kia, clash}); !errors.Is(err, repository.ErrDuplicateVin) {
+		t.Fatalf("UpdateCars with a taken VIN = %v, want ErrDuplicateVin", err)
+	}
+	if got, _ := repo.GetCarById(ctx, kia.CarId); got.Vin != "" {
+		t.Fatalf("failed batch stored VIN %q", got.Vin)
+	}
+
+	// Moving a VIN within one batch is fine.
+	moved := added[0]
+	moved.Vin = kiaVin
+	kia.Vin = bmwVin
+	if err := repo.UpdateCars(ctx, []model.Car{moved, kia}); err != nil {
+		t.Fatalf("UpdateCars moving VINs: %v", err)
+	}
+	if got, _ := repo.GetCarByVin(ctx, bmwVin); got.CarId != kia.CarId {
+		t.Fatalf("after the move %s belongs to car %d, want %d", bmwVin, got.CarId, kia.CarId)
+	}
+
+	if err := repo.DeleteCar(ctx, kia.CarId); err != nil {
+		t.Fatalf("DeleteCar: %v", err)
+	}
+	reused := car("BMW", "X5", 2010, "H007HH77")
+	reused.Vin = bmwVin
+	mustAdd(t, repo, reused)
+}
+
+func testUpdate(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"))
+
+	update := added[0]
+	update.Mark, update.Model, update.Year, update.RegNum = "Toyota", "Camry", 2018, "E005EE50"
+	update.OwnerName, update.OwnerSurname, update.OwnerPatronymic = "Мария", "Петрова", ""
+	if err := repo.UpdateCar(ctx, update); err != nil {
+		t.Fatalf("UpdateCar: %v", err)
+	}
+
+	got, err := repo.GetCarById(ctx, update.CarId)
+	if err != nil {
+		t.Fatalf("GetCarById: %v", err)
+	}
+	want := car("Toyota", "Camry", 2018, "E005EE50")
+	want.CarId = update.CarId
+	want.OwnerName, want.OwnerSurname, want.OwnerPatronymic = "Мария", "Петрова", ""
+	if got != want {
+		t.Fatalf("after update = %+v, want %+v", got, want)
+	}
+
+	clash := added[1]
+	clash.RegNum = "E005EE50"
+	if err := repo.UpdateCar(ctx, clash); !errors.Is(err, repository.ErrDuplicateRegNum) {
+		t.Fatalf("UpdateCar to a taken plate = %v, want ErrDuplicateRegNum", err)
+	}
+
+	missing := update
+	missing.CarId = added[1].CarId + 100
+	if err := repo.UpdateCar(ctx, missing); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("UpdateCar on a missing car = %v, want ErrCarNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"))
+
+	if err := repo.DeleteCar(ctx, added[1].CarId); err != nil {
+		t.Fatalf("DeleteCar: %v", err)
+	}
+	if err := repo.DeleteCar(ctx, added[1].CarId); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("second DeleteCar = %v, want ErrCarNotFound", err)
+	}
+
+	// The plate is free again and ids are never reused.
+	after := mustAdd(t, repo, car("Lada", "Vesta", 2020, "B002BB99"))
+	if len(after) != 2 || after[1].CarId <= added[1].CarId {
+		t.Fatalf("expected a fresh id after delete, got %+v", ids(after))
+	}
+}
+
+func testBatchUpdate(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"), car("Kia", "Rio", 2015, "C003CC77"))
+
+	first, second := added[0], added[1]
+	first.Model, second.Model = "X6", "Granta"
+	if err := repo.UpdateCars(ctx, []model.Car{first, second}); err != nil {
+		t.Fatalf("UpdateCars: %v", err)
+	}
+	if got := all(t, repo); got[0] != first || got[1] != second || got[2] != added[2] {
+		t.Fatalf("after UpdateCars = %+v", got)
+	}
+
+	// A missing id or a taken plate rolls back the rows before it.
+	changed := added[2]
+	changed.Model = "Ceed"
+	missing := changed
+	missing.CarId = added[2].CarId + 100
+	if err := repo.UpdateCars(ctx, []model.Car{changed, missing}); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("UpdateCars with a missing car = %v, want ErrCarNotFound", err)
+	}
+	clash := first
+	clash.RegNum = "C003CC77"
+	if err := repo.UpdateCars(ctx, []model.Car{changed, clash}); !errors.Is(err, repository.ErrDuplicateRegNum) {
+		t.Fatalf("UpdateCars to a taken plate = %v, want ErrDuplicateRegNum", err)
+	}
+	if got, _ := repo.GetCarById(ctx, added[2].CarId); got.Model != "Rio" {
+		t.Fatalf("failed batch left model %q, want Rio", got.Model)
+	}
+}
+
+func testBatchDelete(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"), car("Kia", "Rio", 2015, "C003CC77"))
+
+	if err := repo.DeleteCars(ctx, []int{added[0].CarId, added[2].CarId + 100}); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("DeleteCars with a missing car = %v, want ErrCarNotFound", err)
+	}
+	if got := all(t, repo); len(got) != 3 {
+		t.Fatalf("failed batch deleted %d cars", 3-len(got))
+	}
+
+	if err := repo.DeleteCars(ctx, []int{added[0].CarId, added[2].CarId, added[0].CarId}); err != nil {
+		t.Fatalf("DeleteCars: %v", err)
+	}
+	if got := all(t, repo); len(got) != 1 || got[0].CarId != added[1].CarId {
+		t.Fatalf("after DeleteCars = %+v", ids(got))
+	}
+}
+
+func testModifyCars(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"), car("Kia", "Rio", 2015, "C003CC77"))
+
+	// Missing ids are skipped and the cars come in id order.
+	missing := added[2].CarId + 100
+	err := repo.ModifyCars(ctx, []int{missing, added[2].CarId, added[0].CarId}, func(cars []model.Car) ([]model.Car, error) {
+		if len(cars) != 2 || cars[0] != added[0] || cars[1] != added[2] {
+			t.Errorf("modify got %+v", cars)
+		}
+		cars[0].Model = "X6"
+		return cars[:1], nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyCars: %v", err)
+	}
+	if got, _ := repo.GetCarById(ctx, added[0].CarId); got.Model != "X6" {
+		t.Fatalf("ModifyCars left model %q, want X6", got.Model)
+	}
+
+	// An error from modify writes nothing.
+	failure := errors.New("refused")
+	err = repo.ModifyCars(ctx, []int{added[1].CarId}, func(cars []model.Car) ([]model.Car, error) {
+		cars[0].Model = "Granta"
+		return cars, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("ModifyCars = %v, want the error of modify", err)
+	}
+	if got, _ := repo.GetCarById(ctx, added[1].CarId); got.Model != "Vesta" {
+		t.Fatalf("failed ModifyCars left model %q, want Vesta", got.Model)
+	}
+
+	// A concurrent write waits for modify instead of being overwritten.
+	written := make(chan error, 1)
+	err = repo.ModifyCars(ctx, []int{added[1].CarId}, func(cars []model.Car) ([]model.Car, error) {
+		go func() {
+			concurrent := added[1]
+			concurrent.Year = 2021
+			written <- repo.UpdateCar(ctx, concurrent)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case err := <-written:
+			t.Errorf("UpdateCar did not wait for ModifyCars")
+			written <- err
+		default:
+		}
+		cars[0].Model = "Granta"
+		return cars, nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyCars: %v", err)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("concurrent UpdateCar: %v", err)
+	}
+	if got, _ := repo.GetCarById(ctx, added[1].CarId); got.Model != "Vesta" || got.Year != 2021 {
+		t.Fatalf("after both writes = %+v, want the later UpdateCar", got)
+	}
+}
+
+func testFilters(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo,
+		car("BMW", "X5", 2010, "A001AA77"),
+		car("BMW", "X3", 2010, "A002AA77"),
+		car("BMW", "X5", 2012, "A003AA77"),
+		car("Lada", "X5", 2010, "A004AA77"),
+	)
+
+	tests := []struct {
+		mark, model, year string
+		want              []int
+	}{
+		{"", "", "", ids(added)},
+		{"BMW", "", "", []int{added[0].CarId, added[1].CarId, added[2].CarId}},
+		{"BMW", "X5", "", []int{added[0].CarId, added[2].CarId}},
+		{"", "X5", "2010", []int{added[0].CarId, added[3].CarId}},
+		{"Audi", "", "", nil},
+	}
+	for _, tt := range tests {
+		cars, _, err := repo.GetCars(ctx, 10, tt.mark, tt.model, tt.year, dto.Cursors{})
+		if err != nil {
+			t.Fatalf("GetCars(%q, %q, %q): %v", tt.mark, tt.model, tt.year, err)
+		}
+		if !sameInts(ids(cars), tt.want) {
+			t.Errorf("GetCars(%q, %q, %q) = %v, want %v", tt.mark, tt.model, tt.year, ids(cars), tt.want)
+		}
+	}
+
+	if _, _, err := repo.GetCars(ctx, 10, "", "", "twenty", dto.Cursors{}); err == nil {
+		t.Error("GetCars with a non-numeric year must fail")
+	}
+}
+
+func testPaging(t *testing.T, repo repository.CarRepository) {
+	var cars []model.Car
+	for i := 1; i <= 5; i++ {
+		cars = append(cars, car("BMW", "X5", 2010, fmt.Sprintf("A%03dAA77", i)))
+	}
+	added := ids(mustAdd(t, repo, cars...))
+	id := func(i int) string { return fmt.Sprint(added[i]) }
+
+	tests := []struct {
+		name    string
+		cursors dto.Cursors
+		want    []int
+		result  dto.Cursors
+	}{
+		{"first page", dto.Cursors{}, added[0:2], dto.Cursors{Next: id(1)}},
+		{"middle page", dto.Cursors{Next: id(1)}, added[2:4], dto.Cursors{Prev: id(2), Next: id(3)}},
+		{"last page", dto.Cursors{Next: id(3)}, added[4:5], dto.Cursors{Prev: id(4)}},
+		{"back to first", dto.Cursors{Prev: id(2)}, added[0:2], dto.Cursors{Next: id(1)}},
+		{"back from last", dto.Cursors{Prev: id(4)}, added[2:4], dto.Cursors{Prev: id(2), Next: id(3)}},
+		{"past the end", dto.Cursors{Next: id(4)}, nil, dto.Cursors{}},
+	}
+	for _, tt := range tests {
+		got, cursors, err := repo.GetCars(ctx, 2, "", "", "", tt.cursors)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !sameInts(ids(got), tt.want) || cursors != tt.result {
+			t.Errorf("%s: got %v %+v, want %v %+v", tt.name, ids(got), cursors, tt.want, tt.result)
+		}
+	}
+}
+
+func testPagingErrors(t *testing.T, repo repository.CarRepository) {
+	if _, _, err := repo.GetCars(ctx, 0, "", "", "", dto.Cursors{}); err == nil {
+		t.Error("GetCars with a zero limit must fail")
+	}
+	if _, _, err := repo.GetCars(ctx, 1, "", "", "", dto.Cursors{Next: "1", Prev: "2"}); err == nil {
+		t.Error("GetCars with both cursors must fail")
+	}
+
+	cars, cursors, err := repo.GetCars(ctx, 10, "", "", "", dto.Cursors{})
+	if err != nil || len(cars) != 0 || cursors != (dto.Cursors{}) {
+		t.Errorf("GetCars on an empty repository = %v %+v %v, want no cars and no cursors", cars, cursors, err)
+	}
+}
+
+func testFindExistingRegNums(t *testing.T, repo repository.CarRepository) {
+	mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"))
+
+	existing, err := repo.FindExistingRegNums(ctx, []string{"B002BB99", "C003CC77"})
+	if err != nil {
+		t.Fatalf("FindExistingRegNums: %v", err)
+	}
+	if len(existing) != 1 || existing[0] != "B002BB99" {
+		t.Fatalf("FindExistingRegNums = %v, want [B002BB99]", existing)
+	}
+}
+
+func testStreamCars(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo,
+		car("BMW", "X5", 2010, "A001AA77"),
+		car("Lada", "Vesta", 2020, "B002BB99"),
+		car("BMW", "X3", 2012, "C003CC77"),
+	)
+
+	var streamed []model.Car
+	err := repo.StreamCars(ctx, "BMW", "", "", func(car model.Car) error {
+		streamed = append(streamed, car)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamCars: %v", err)
+	}
+	if !sameInts(ids(streamed), []int{added[0].CarId, added[2].CarId}) {
+		t.Fatalf("StreamCars(BMW) = %v", ids(streamed))
+	}
+	if streamed[0].OwnerSurname != "Иванов" {
+		t.Fatalf("StreamCars must include owner columns, got %+v", streamed[0])
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.StreamCars(ctx, "", "", "", func(model.Car) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("StreamCars must stop on the first callback error, got %v after %d calls", err, calls)
+	}
+}
+
+func testSearch(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo,
+		car("Toyota", "Camry", 2018, "A001AA77"),
+		car("Mercedes-Benz", "E200", 2015, "B002BB99"),
+		car("Lada", "Vesta", 2020, "C003CC77"),
+	)
+
+	tests := []struct {
+		name     string
+		variants []string
+		year     string
+		want     []int
+	}{
+		{"exact", []string{"camry"}, "", []int{added[0].CarId}},
+		{"typo", []string{"mersedes"}, "", []int{added[1].CarId}},
+		{"reg number", []string{"c003cc77"}, "", []int{added[2].CarId}},
+		{"any variant", []string{"тойота", "toyota"}, "", []int{added[0].CarId}},
+		{"filtered out", []string{"camry"}, "2020", nil},
+		{"no match", []string{"zzzz"}, "", nil},
+	}
+	for _, tt := range tests {
+		got, _, err := repo.SearchCars(ctx, tt.variants, 10, "", "", tt.year, dto.Cursors{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var gotIds []int
+		for _, m := range got {
+			if m.Score <= 0 {
+				t.Errorf("%s: car %d has score %v", tt.name, m.CarId, m.Score)
+			}
+			gotIds = append(gotIds, m.CarId)
+		}
+		if !sameInts(gotIds, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, gotIds, tt.want)
+		}
+	}
+
+	if _, _, err := repo.SearchCars(ctx, nil, 10, "", "", "", dto.Cursors{}); err == nil {
+		t.Error("SearchCars without variants must fail")
+	}
+}
+
+func testSearchPaging(t *testing.T, repo repository.CarRepository) {
+	mustAdd(t, repo,
+		car("BMW", "X5", 2010, "A001AA77"),
+		car("BMW", "X3", 2012, "B002BB99"),
+		car("Lada", "Vesta", 2020, "C003CC77"),
+		car("BMW", "M5", 2019, "D004DD77"),
+	)
+	search := func(cursors dto.Cursors) ([]model.CarMatch, dto.Cursors) {
+		t.Helper()
+		page, next, err := repo.SearchCars(ctx, []string{"bmw"}, 1, "", "", "", cursors)
+		if err != nil {
+			t.Fatalf("SearchCars %+v: %v", cursors, err)
+		}
+		return page, next
+	}
+
+	var forward []int
+	page, cursors := search(dto.Cursors{})
+	for len(page) > 0 {
+		forward = append(forward, page[0].CarId)
+		if cursors.Next == "" {
+			break
+		}
+		page, cursors = search(dto.Cursors{Next: cursors.Next})
+	}
+	if len(forward) != 3 {
+		t.Fatalf("paging forward returned %v, want the 3 BMWs once each", forward)
+	}
+
+	var backward []int
+	for cursors.Prev != "" {
+		page, cursors = search(dto.Cursors{Prev: cursors.Prev})
+		backward = append([]int{page[0].CarId}, backward...)
+	}
+	if !sameInts(backward, forward[:2]) {
+		t.Errorf("paging back returned %v, want %v", backward, forward[:2])
+	}
+}
+
+func testSuggest(t *testing.T, repo repository.CarRepository) {
+	mustAdd(t, repo,
+		car("Toyota", "Camry", 2018, "A001AA77"),
+		car("Toyota", "Corolla", 2015, "A002AA77"),
+		car("toyota", "Camry", 2016, "A003AB77"),
+		car("Tesla", "Model 3", 2021, "B004BB99"),
+		car("Лада", "Веста", 2020, "C005CC77"),
+		car("Lada_X", "Largus", 2019, "C006CC77"),
+	)
+
+	tests := []struct {
+		name    string
+		suggest func() ([]model.Suggestion, error)
+		want    string
+	}{
+		{"marks", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, nil, "TO", 10) },
+			"Toyota:2 toyota:1"},
+		{"marks limit", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, nil, "t", 2) },
+			"Toyota:2 Tesla:1"},
+		{"cyrillic marks", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, nil, "ЛА", 10) },
+			"Лада:1"},
+		{"wildcards are literal", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, nil, "lada_", 10) },
+			"Lada_X:1"},
+		{"models of a mark", func() ([]model.Suggestion, error) { return repo.SuggestModels(ctx, nil, "TOYOTA", "c", 10) },
+			"Camry:2 Corolla:1"},
+		{"models of any mark", func() ([]model.Suggestion, error) { return repo.SuggestModels(ctx, nil, "", "m", 10) },
+			"Model 3:1"},
+		{"reg numbers", func() ([]model.Suggestion, error) { return repo.SuggestRegNums(ctx, nil, "A00", 2) },
+			"A001AA77:1 A002AA77:1"},
+		{"no match", func() ([]model.Suggestion, error) { return repo.SuggestRegNums(ctx, nil, "X", 10) },
+			""},
+	}
+	for _, tt := range tests {
+		checkSuggestions(t, tt.name, tt.suggest, tt.want)
+	}
+
+	// Cars added with an API key are suggested to that key only.
+	crm := "crm"
+	other := "other"
+	crmCtx := auth.WithIdentity(ctx, auth.Identity{Name: crm, Role: "viewer"})
+	if err := repo.AddCars(crmCtx, []model.Car{car("Toyota", "Supra", 1998, "E007EE77")}); err != nil {
+		t.Fatal(err)
+	}
+	checkSuggestions(t, "tenant marks", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, &crm, "t", 10) },
+		"Toyota:1")
+	checkSuggestions(t, "tenant models", func() ([]model.Suggestion, error) { return repo.SuggestModels(ctx, &crm, "toyota", "", 10) },
+		"Supra:1")
+	checkSuggestions(t, "tenant reg numbers", func() ([]model.Suggestion, error) { return repo.SuggestRegNums(ctx, &crm, "E0", 10) },
+		"E007EE77:1")
+	checkSuggestions(t, "other tenant", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, &other, "t", 10) },
+		"")
+	checkSuggestions(t, "every tenant", func() ([]model.Suggestion, error) { return repo.SuggestMarks(ctx, nil, "TO", 10) },
+		"Toyota:3 toyota:1")
+}
+
+func checkSuggestions(t *testing.T, name string, suggest func() ([]model.Suggestion, error), want string) {
+	t.Helper()
+	got, err := suggest()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	var values []string
+	for _, s := range got {
+		values = append(values, fmt.Sprintf("%s:%d", s.Value, s.Count))
+	}
+	if strings.Join(values, " ") != want {
+		t.Errorf("%s: got %q, want %q", name, values, want)
+	}
+}
+
+func testStats(t *testing.T, repo repository.CarRepository) {
+	other := car("Lada", "Vesta", 2020, "B004BB199")
+	other.OwnerName, other.OwnerSurname, other.OwnerPatronymic = "Пётр", "Петров", ""
+	mustAdd(t, repo,
+		car("Lada", "Vesta", 2020, "A001AA77"),
+		car("Lada", "Granta", 2020, "A002AA77"),
+		car("Lada", "Vesta", 2018, "A003AA99"),
+		car("BMW", "X5", 2020, "BAD-PLATE"),
+		other,
+	)
+
+	format := func(stats model.CarStats) string {
+		var groups []string
+		for _, g := range stats.Groups {
+			groups = append(groups, fmt.Sprintf("%s/%s/%d/%s/%s:%d", g.Mark, g.Model, g.Year, g.Region, g.Owner, g.Count))
+		}
+		return fmt.Sprintf("%d cars in %d groups: %s", stats.Cars, stats.GroupCount, strings.Join(groups, " "))
+	}
+
+	tests := []struct {
+		name  string
+		query model.StatsQuery
+		want  string
+	}{
+		{"by mark", model.StatsQuery{GroupBy: []string{model.StatsByMark}, Limit: 10},
+			"5 cars in 2 groups: Lada//0//:4 BMW//0//:1"},
+		{"by year and mark", model.StatsQuery{GroupBy: []string{model.StatsByYear, model.StatsByMark}, Limit: 10},
+			"5 cars in 3 groups: Lada//2020//:3 Lada//2018//:1 BMW//2020//:1"},
+		{"limit keeps totals", model.StatsQuery{GroupBy: []string{model.StatsByModel}, Limit: 1},
+			"5 cars in 3 groups: /Vesta/0//:3"},
+		{"region", model.StatsQuery{GroupBy: []string{model.StatsByRegion}, Limit: 10},
+			"5 cars in 4 groups: //0/77/:2 //0//:1 //0/199/:1 //0/99/:1"},
+		{"owner with filters", model.StatsQuery{GroupBy: []string{model.StatsByOwner}, Mark: "Lada", Year: "2020", Limit: 10},
+			"3 cars in 2 groups: //0//Иванов Иван Иванович:2 //0//Петров Пётр:1"},
+		{"no match", model.StatsQuery{GroupBy: []string{model.StatsByMark}, Mark: "Kia", Limit: 10},
+			"0 cars in 0 groups: "},
+	}
+	for _, tt := range tests {
+		got, err := repo.CarStats(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if format(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, format(got), tt.want)
+		}
+	}
+
+	if _, err := repo.CarStats(ctx, model.StatsQuery{GroupBy: []string{"color"}, Limit: 10}); err == nil {
+		t.Error("expected an error for an unknown dimension")
+	}
+}
+
+func testConcurrentWrites(t *testing.T, repo repository.CarRepository) {
+	const writers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*2)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.AddCars(ctx, []model.Car{car("BMW", "X5", 2010, fmt.Sprintf("K%03dKK77", i))})
+			_, _, err := repo.GetCars(ctx, 5, "BMW", "", "", dto.Cursors{})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent call failed: %v", err)
+		}
+	}
+	if n := len(all(t, repo)); n != writers {
+		t.Fatalf("expected %d cars, have %d", writers, n)
+	}
+}
+
+func testSyncTracking(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo,
+		car("BMW", "X5", 2010, "A001AA77"),
+		car("Lada", "Vesta", 2020, "B002BB99"),
+		car("Kia", "Rio", 2015, "C003CC50"))
+
+	// Backends store at least millisecond precision.
+	now := time.Now().Truncate(time.Millisecond)
+	if err := repo.MarkSynced(ctx, added[0].CarId, now.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("MarkSynced: %v", err)
+	}
+	if err := repo.MarkSynced(ctx, added[1].CarId, now); err != nil {
+		t.Fatalf("MarkSynced: %v", err)
+	}
+	if err := repo.MarkSynced(ctx, added[2].CarId+100, now); !errors.Is(err, repository.ErrCarNotFound) {
+		t.Fatalf("MarkSynced on a missing car = %v, want ErrCarNotFound", err)
+	}
+
+	got, err := repo.GetCarById(ctx, added[0].CarId)
+	if err != nil {
+		t.Fatalf("GetCarById: %v", err)
+	}
+	if got.LastSyncedAt == nil || !got.LastSyncedAt.Equal(now.Add(-48*time.Hour)) {
+		t.Fatalf("LastSyncedAt = %v, want %v", got.LastSyncedAt, now.Add(-48*time.Hour))
+	}
+
+	stale, err := repo.ListStaleCars(ctx, now.Add(-24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("ListStaleCars: %v", err)
+	}
+	// Never synced first, then the oldest sync.
+	if want := []int{added[2].CarId, added[0].CarId}; !sameInts(ids(stale), want) {
+		t.Fatalf("ListStaleCars = %v, want %v", ids(stale), want)
+	}
+	if stale[0].OwnerName == "" {
+		t.Fatalf("ListStaleCars must include owners, got %+v", stale[0])
+	}
+
+	stale, err = repo.ListStaleCars(ctx, now.Add(-24*time.Hour), 1)
+	if err != nil {
+		t.Fatalf("ListStaleCars: %v", err)
+	}
+	if want := []int{added[2].CarId}; !sameInts(ids(stale), want) {
+		t.Fatalf("ListStaleCars with limit 1 = %v, want %v", ids(stale), want)
+	}
+}
+
+func testDivergences(t *testing.T, repo repository.CarRepository) {
+	added := mustAdd(t, repo, car("BMW", "X5", 2010, "A001AA77"), car("Lada", "Vesta", 2020, "B002BB99"))
+
+	now := time.Now().Truncate(time.Millisecond)
+	if err := repo.AddDivergences(ctx, []model.Divergence{
+		{CarId: added[0].CarId, Field: "model", LocalValue: "X5", RegistryValue: "X6", DetectedAt: now, Status: model.DivergenceApplied},
+		{CarId: added[1].CarId, Field: "year", LocalValue: "2020", RegistryValue: "2021", DetectedAt: now, Status: model.DivergenceApplied},
+		{CarId: added[0].CarId, Field: "owner_name", LocalValue: "Иван", RegistryValue: "Пётр", DetectedAt: now,
+			Status: model.DivergenceFailed, Error: "vin mismatch"},
+	}); err != nil {
+		t.Fatalf("AddDivergences: %v", err)
+	}
+
+	all, err := repo.ListDivergences(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("ListDivergences: %v", err)
+	}
+	if len(all) != 3 || all[0].Field != "owner_name" || all[2].Field != "model" {
+		t.Fatalf("ListDivergences = %+v, want three, newest first", all)
+	}
+	if !all[0].DetectedAt.Equal(now) || all[0].LocalValue != "Иван" || all[0].RegistryValue != "Пётр" ||
+		all[0].Status != model.DivergenceFailed || all[0].Error != "vin mismatch" || all[2].Status != model.DivergenceApplied {
+		t.Fatalf("divergence did not round-trip: %+v", all[0])
+	}
+
+	forCar, err := repo.ListDivergences(ctx, added[0].CarId, 1)
+	if err != nil {
+		t.Fatalf("ListDivergences: %v", err)
+	}
+	if len(forCar) != 1 || forCar[0].Field != "owner_name" {
+		t.Fatalf("ListDivergences for one car with limit 1 = %+v", forCar)
+	}
+
+	// Divergences are removed together with their car.
+	if err := repo.DeleteCar(ctx, added[0].CarId); err != nil {
+		t.Fatalf("DeleteCar: %v", err)
+	}
+	if left, err := repo.ListDivergences(ctx, 0, 10); err != nil || len(left) != 1 {
+		t.Fatalf("after DeleteCar ListDivergences = %+v, %v, want one", left, err)
+	}
+}
@@ -0,0 +1,266 @@
+package repotest
+
+import (
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+// RunWebhooks is the conformance suite of repository.WebhookRepository.
+// newRepo must return an empty repository with the cursor at 0 for every
+// call.
+func RunWebhooks(t *testing.T, newRepo func(t *testing.T) repository.WebhookRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.WebhookRepository)
+	}{
+		{"Subscriptions", testSubscriptions},
+		{"Enqueue", testEnqueueDeliveries},
+		{"Claim", testClaimDeliveries},
+		{"Requeue", testRequeueDead},
+		{"PruneDeliveries", testPruneDeliveries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func mustSubscribe(t *testing.T, repo repository.WebhookRepository, url string) model.WebhookSubscription {
+	t.Helper()
+	sub, err := repo.AddSubscription(ctx, model.WebhookSubscription{Url: url, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("AddSubscription: %v", err)
+	}
+	return sub
+}
+
+func delivery(subId int, eventId int64) model.WebhookDelivery {
+	return model.WebhookDelivery{
+		SubscriptionId: subId,
+		EventId:        eventId,
+		EventType:      model.CarCreated,
+		Payload:        []byte(`{"id":1}`),
+	}
+}
+
+func mustDeliveries(t *testing.T, repo repository.WebhookRepository, subId int, status string) []model.WebhookDelivery {
+	t.Helper()
+	deliveries, err := repo.ListDeliveries(ctx, subId, status, 100)
+	if err != nil {
+		t.Fatalf("ListDeliveries: %v", err)
+	}
+	return deliveries
+}
+
+func testSubscriptions(t *testing.T, repo repository.WebhookRepository) {
+	sub, err := repo.AddSubscription(ctx, model.WebhookSubscription{
+		Url:          "https://crm.example/hook",
+		Secret:       "s3cret",
+		Events:       []string{model.CarCreated, model.CarDeleted},
+		Mark:         "Lada",
+		AfterEventId: 42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Id == 0 || sub.CreatedAt.IsZero() {
+		t.Fatalf("added subscription: %+v", sub)
+	}
+	other := mustSubscribe(t, repo, "https://erp.example/hook")
+
+	got, err := repo.GetSubscription(ctx, sub.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Url != sub.Url || got.Secret != "s3cret" || len(got.Events) != 2 || got.Events[1] != model.CarDeleted ||
+		got.Mark != "Lada" || got.AfterEventId != 42 {
+		t.Fatalf("GetSubscription = %+v", got)
+	}
+	if other, err := repo.GetSubscription(ctx, other.Id); err != nil || len(other.Events) != 0 {
+		t.Fatalf("subscription to every event = %+v, %v", other, err)
+	}
+
+	subs, err := repo.ListSubscriptions(ctx)
+	if err != nil || len(subs) != 2 || subs[0].Id != sub.Id || subs[1].Id != other.Id {
+		t.Fatalf("ListSubscriptions = %+v, %v", subs, err)
+	}
+
+	// Deliveries go with their subscription.
+	if err := repo.AddDeliveries(ctx, []model.WebhookDelivery{delivery(sub.Id, 1), delivery(other.Id, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteSubscription(ctx, sub.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetSubscription(ctx, sub.Id); !errors.Is(err, repository.ErrSubscriptionNotFound) {
+		t.Fatalf("GetSubscription after delete = %v", err)
+	}
+	if err := repo.DeleteSubscription(ctx, sub.Id); !errors.Is(err, repository.ErrSubscriptionNotFound) {
+		t.Fatalf("second DeleteSubscription = %v", err)
+	}
+	if deliveries := mustDeliveries(t, repo, sub.Id, ""); len(deliveries) != 0 {
+		t.Fatalf("deliveries of a deleted subscription: %+v", deliveries)
+	}
+	if deliveries := mustDeliveries(t, repo, other.Id, ""); len(deliveries) != 1 {
+		t.Fatalf("deliveries of the other subscription: %+v", deliveries)
+	}
+}
+
+func testEnqueueDeliveries(t *testing.T, repo repository.WebhookRepository) {
+	sub := mustSubscribe(t, repo, "https://crm.example/hook")
+	if cursor, err := repo.Cursor(ctx); err != nil || cursor != 0 {
+		t.Fatalf("Cursor of an empty repository = %d, %v", cursor, err)
+	}
+
+	ok, err := repo.EnqueueDeliveries(ctx, 0, 2, []model.WebhookDelivery{delivery(sub.Id, 1), delivery(sub.Id, 2)})
+	if err != nil || !ok {
+		t.Fatalf("EnqueueDeliveries = %t, %v", ok, err)
+	}
+	// A dispatcher that read the old cursor writes nothing.
+	ok, err = repo.EnqueueDeliveries(ctx, 0, 2, []model.WebhookDelivery{delivery(sub.Id, 1)})
+	if err != nil || ok {
+		t.Fatalf("EnqueueDeliveries from a stale cursor = %t, %v", ok, err)
+	}
+	// A page without matching subscriptions still moves the cursor.
+	if ok, err := repo.EnqueueDeliveries(ctx, 2, 5, nil); err != nil || !ok {
+		t.Fatalf("EnqueueDeliveries without deliveries = %t, %v", ok, err)
+	}
+	if cursor, err := repo.Cursor(ctx); err != nil || cursor != 5 {
+		t.Fatalf("Cursor = %d, %v, want 5", cursor, err)
+	}
+
+	deliveries := mustDeliveries(t, repo, sub.Id, "")
+	if len(deliveries) != 2 {
+		t.Fatalf("queued %d deliveries, want 2", len(deliveries))
+	}
+	newest := deliveries[0]
+	if newest.EventId != 2 || newest.Id <= deliveries[1].Id || newest.Status != model.DeliveryPending ||
+		newest.Attempts != 0 || string(newest.Payload) != `{"id":1}` || newest.EventType != model.CarCreated ||
+		newest.CreatedAt.IsZero() || newest.DeliveredAt != nil {
+		t.Fatalf("queued delivery: %+v", newest)
+	}
+	if limited, err := repo.ListDeliveries(ctx, sub.Id, "", 1); err != nil || len(limited) != 1 || limited[0].Id != newest.Id {
+		t.Fatalf("ListDeliveries with limit 1 = %+v, %v", limited, err)
+	}
+}
+
+func testClaimDeliveries(t *testing.T, repo repository.WebhookRepository) {
+	sub := mustSubscribe(t, repo, "https://crm.example/hook")
+	if err := repo.AddDeliveries(ctx, []model.WebhookDelivery{delivery(sub.Id, 1), delivery(sub.Id, 2), delivery(sub.Id, 3)}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Add(time.Second)
+	claimed, err := repo.ClaimDeliveries(ctx, now, time.Minute, 2)
+	if err != nil || len(claimed) != 2 || claimed[0].EventId != 1 || claimed[1].EventId != 2 {
+		t.Fatalf("ClaimDeliveries = %+v, %v", claimed, err)
+	}
+	// Claimed deliveries are leased.
+	rest, err := repo.ClaimDeliveries(ctx, now, time.Minute, 10)
+	if err != nil || len(rest) != 1 || rest[0].EventId != 3 {
+		t.Fatalf("second ClaimDeliveries = %+v, %v", rest, err)
+	}
+
+	// The first is delivered, the second fails and is retried later.
+	delivered, failed := claimed[0], claimed[1]
+	deliveredAt := now
+	delivered.Status = model.DeliveryDelivered
+	delivered.Attempts = 1
+	delivered.DeliveredAt = &deliveredAt
+	failed.Attempts = 1
+	failed.LastStatusCode = 503
+	failed.LastError = "unexpected status 503 Service Unavailable"
+	failed.NextAttemptAt = now.Add(10 * time.Minute)
+	for _, d := range []model.WebhookDelivery{delivered, failed} {
+		if err := repo.SaveAttempt(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if claimed, err := repo.ClaimDeliveries(ctx, now.Add(5*time.Minute), time.Minute, 10); err != nil || len(claimed) != 1 || claimed[0].EventId != 3 {
+		t.Fatalf("ClaimDeliveries after the lease = %+v, %v", claimed, err)
+	}
+	retry, err := repo.ClaimDeliveries(ctx, now.Add(11*time.Minute), time.Minute, 10)
+	if err != nil || len(retry) != 2 || retry[0].EventId != 2 {
+		t.Fatalf("ClaimDeliveries after the backoff = %+v, %v", retry, err)
+	}
+	if retry[0].Attempts != 1 || retry[0].LastStatusCode != 503 || retry[0].LastError != failed.LastError {
+		t.Fatalf("retried delivery: %+v", retry[0])
+	}
+
+	done := mustDeliveries(t, repo, sub.Id, model.DeliveryDelivered)
+	if len(done) != 1 || done[0].Id != delivered.Id || done[0].DeliveredAt == nil {
+		t.Fatalf("delivered: %+v", done)
+	}
+}
+
+func testRequeueDead(t *testing.T, repo repository.WebhookRepository) {
+	sub := mustSubscribe(t, repo, "https://crm.example/hook")
+	other := mustSubscribe(t, repo, "https://erp.example/hook")
+	if err := repo.AddDeliveries(ctx, []model.WebhookDelivery{delivery(sub.Id, 1), delivery(sub.Id, 2), delivery(other.Id, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repo.ClaimDeliveries(ctx, time.Now().Add(time.Second), time.Minute, 10)
+	if err != nil || len(claimed) != 3 {
+		t.Fatalf("ClaimDeliveries = %+v, %v", claimed, err)
+	}
+	for _, d := range claimed {
+		if d.SubscriptionId == sub.Id && d.EventId == 2 {
+			continue
+		}
+		d.Status = model.DeliveryDead
+		d.Attempts = 8
+		if err := repo.SaveAttempt(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requeued, err := repo.RequeueDead(ctx, sub.Id)
+	if err != nil || requeued != 1 {
+		t.Fatalf("RequeueDead = %d, %v, want 1", requeued, err)
+	}
+	if dead := mustDeliveries(t, repo, sub.Id, model.DeliveryDead); len(dead) != 0 {
+		t.Fatalf("dead after RequeueDead: %+v", dead)
+	}
+	if dead := mustDeliveries(t, repo, other.Id, model.DeliveryDead); len(dead) != 1 {
+		t.Fatalf("RequeueDead touched another subscription: %+v", dead)
+	}
+
+	due, err := repo.ClaimDeliveries(ctx, time.Now().Add(time.Second), time.Minute, 10)
+	if err != nil || len(due) != 1 || due[0].EventId != 1 || due[0].Attempts != 0 || due[0].Status != model.DeliveryPending {
+		t.Fatalf("requeued delivery = %+v, %v", due, err)
+	}
+}
+
+func testPruneDeliveries(t *testing.T, repo repository.WebhookRepository) {
+	sub := mustSubscribe(t, repo, "https://crm.example/hook")
+	if err := repo.AddDeliveries(ctx, []model.WebhookDelivery{delivery(sub.Id, 1), delivery(sub.Id, 2), delivery(sub.Id, 3)}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repo.ClaimDeliveries(ctx, time.Now().Add(time.Second), time.Minute, 10)
+	if err != nil || len(claimed) != 3 {
+		t.Fatalf("ClaimDeliveries = %+v, %v", claimed, err)
+	}
+	old, recent := time.Now().Add(-2*time.Hour), time.Now()
+	claimed[0].Status, claimed[0].DeliveredAt = model.DeliveryDelivered, &old
+	claimed[1].Status, claimed[1].DeliveredAt = model.DeliveryDelivered, &recent
+	claimed[2].Status = model.DeliveryDead
+	for _, d := range claimed {
+		if err := repo.SaveAttempt(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := repo.PruneDeliveries(ctx, time.Now().Add(-time.Hour))
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneDeliveries = %d, %v, want 1", pruned, err)
+	}
+	kept := mustDeliveries(t, repo, sub.Id, "")
+	if len(kept) != 2 || kept[0].EventId != 3 || kept[1].EventId != 2 {
+		t.Fatalf("after PruneDeliveries: %+v", kept)
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/search"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// searchCursor is a position in a ranked result: results are ordered by score
+// descending, then id ascending.
+type searchCursor struct {
+	score float64
+	id    int
+}
+
+func parseSearchCursor(cursor string) (searchCursor, error) {
+	i := strings.LastIndexByte(cursor, ':')
+	if i < 0 {
+		return searchCursor{}, fmt.Errorf("invalid search cursor %q", cursor)
+	}
+	score, err := strconv.ParseFloat(cursor[:i], 64)
+	if err != nil {
+		return searchCursor{}, fmt.Errorf("invalid search cursor %q: %w", cursor, err)
+	}
+	id, err := strconv.Atoi(cursor[i+1:])
+	if err != nil {
+		return searchCursor{}, fmt.Errorf("invalid search cursor %q: %w", cursor, err)
+	}
+	return searchCursor{score: score, id: id}, nil
+}
+
+func (c searchCursor) String() string {
+	return strconv.FormatFloat(c.score, 'g', -1, 64) + ":" + strconv.Itoa(c.id)
+}
+
+// before reports whether m is ranked ahead of the cursor position.
+func (c searchCursor) before(m model.CarMatch) bool {
+	return m.Score > c.score || (m.Score == c.score && m.CarId < c.id)
+}
+
+func checkSearchArgs(variants []string, limit int, cursors dto.Cursors) error {
+	if len(variants) == 0 {
+		return errors.New("empty search query")
+	}
+	if limit == 0 {
+		return errors.New("limit cannot be zero")
+	}
+	if cursors.Next != "" && cursors.Prev != "" {
+		return errors.New("two cursors cannot be provided at the same time")
+	}
+	return nil
+}
+
+// searchPageCursors gives a ranked page its cursors. hasMore tells whether
+// rows exist beyond the page in the paging direction; the other direction
+// has rows whenever the request came with a cursor.
+func searchPageCursors(cursors dto.Cursors, page []model.CarMatch, hasMore bool) dto.Cursors {
+	first := SearchCursor(page[0])
+	last := SearchCursor(page[len(page)-1])
+
+	var result dto.Cursors
+	if cursors.Prev != "" {
+		result.Next = last
+		if hasMore {
+			result.Prev = first
+		}
+		return result
+	}
+	if cursors.Next != "" {
+		result.Prev = first
+	}
+	if hasMore {
+		result.Next = last
+	}
+	return result
+}
+
+// rankCars scores cars in Go for the backends without pg_trgm and pages the
+// ranked result like the Postgres query does.
+func rankCars(cars []model.Car, variants []string, limit int, cursors dto.Cursors) ([]model.CarMatch, dto.Cursors, error) {
+	var matches []model.CarMatch
+	for _, car := range cars {
+		if score := search.Score(variants, searchText(car)); score >= search.Threshold {
+			matches = append(matches, model.CarMatch{Car: listed(car), Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].CarId < matches[j].CarId
+	})
+
+	var page []model.CarMatch
+	hasMore := false
+	switch {
+	case cursors.Prev != "":
+		cursor, err := parseSearchCursor(cursors.Prev)
+		if err != nil {
+			return nil, dto.Cursors{}, err
+		}
+		end := sort.Search(len(matches), func(i int) bool { return !cursor.before(matches[i]) })
+		start := max(end-limit, 0)
+		page, hasMore = matches[start:end], start > 0
+	default:
+		start := 0
+		if cursors.Next != "" {
+			cursor, err := parseSearchCursor(cursors.Next)
+			if err != nil {
+				return nil, dto.Cursors{}, err
+			}
+			start = sort.Search(len(matches), func(i int) bool {
+				m := matches[i]
+				return !cursor.before(m) && !(m.Score == cursor.score && m.CarId == cursor.id)
+			})
+		}
+		end := min(start+limit, len(matches))
+		page, hasMore = matches[start:end], end < len(matches)
+	}
+	if len(page) == 0 {
+		return []model.CarMatch{}, dto.Cursors{}, nil
+	}
+	return page, searchPageCursors(cursors, page, hasMore), nil
+}
+
+// searchText mirrors the generated search_text column of the Postgres table.
+func searchText(car model.Car) string {
+	return search.Normalize(strings.Join([]string{
+		car.Mark, car.Model, car.RegNum, car.OwnerSurname, car.OwnerName, car.OwnerPatronymic,
+	}, " "))
+}
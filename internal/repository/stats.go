@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"car_catalog/internal/regnum"
+	"car_catalog/internal/search"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// statsColumns are the Postgres expressions of the stats dimensions over
+// cars.car. cars.plate_region mirrors regnum.Region and the owner expression
+// mirrors ownerName.
+var statsColumns = map[string]string{
+	model.StatsByMark:   "mark",
+	model.StatsByModel:  "model",
+	model.StatsByYear:   "year",
+	model.StatsByRegion: "cars.plate_region(reg_num)",
+	model.StatsByOwner: `btrim(regexp_replace(coalesce(owner_surname, '') || ' ' || coalesce(owner_name, '') || ' ' ||
+		coalesce(owner_patronymic, ''), '\s+', ' ', 'g'))`,
+}
+
+func checkStatsQuery(query model.StatsQuery) error {
+	if len(query.GroupBy) == 0 {
+		return errors.New("nothing to group by")
+	}
+	if query.Limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+	for _, dimension := range query.GroupBy {
+		if _, ok := statsColumns[dimension]; !ok {
+			return fmt.Errorf("unknown stats dimension %q", dimension)
+		}
+	}
+	return checkNumeric(query.Year)
+}
+
+func ownerName(car model.Car) string {
+	return strings.Join(strings.Fields(car.OwnerSurname+" "+car.OwnerName+" "+car.OwnerPatronymic), " ")
+}
+
+// aggregateStats computes a StatsQuery in Go over the cars stream yields, for
+// the backends without server-side grouping. The order matches the Postgres
+// query: largest groups first, then by the grouped values.
+func aggregateStats(ctx context.Context, query model.StatsQuery,
+	stream func(ctx context.Context, mark, carModel, year string, fn func(model.Car) error) error) (model.CarStats, error) {
+	if err := checkStatsQuery(query); err != nil {
+		return model.CarStats{}, err
+	}
+
+	counts := make(map[model.StatsGroup]int)
+	err := stream(ctx, query.Mark, query.Model, query.Year, func(car model.Car) error {
+		if len(query.Variants) > 0 && search.Score(query.Variants, searchText(car)) < search.Threshold {
+			return nil
+		}
+		var group model.StatsGroup
+		for _, dimension := range query.GroupBy {
+			switch dimension {
+			case model.StatsByMark:
+				group.Mark = car.Mark
+			case model.StatsByModel:
+				group.Model = car.Model
+			case model.StatsByYear:
+				group.Year = car.Year
+			case model.StatsByRegion:
+				group.Region = regnum.Region(car.RegNum)
+			case model.StatsByOwner:
+				group.Owner = ownerName(car)
+			}
+		}
+		counts[group]++
+		return nil
+	})
+	if err != nil {
+		return model.CarStats{}, err
+	}
+
+	stats := model.CarStats{Groups: make([]model.StatsGroup, 0, len(counts)), GroupCount: len(counts)}
+	for group, count := range counts {
+		group.Count = count
+		stats.Cars += count
+		stats.Groups = append(stats.Groups, group)
+	}
+	sort.Slice(stats.Groups, func(i, j int) bool {
+		return statsGroupLess(query.GroupBy, stats.Groups[i], stats.Groups[j])
+	})
+	if len(stats.Groups) > query.Limit {
+		stats.Groups = stats.Groups[:query.Limit]
+	}
+	return stats, nil
+}
+
+func statsGroupLess(groupBy []string, a, b model.StatsGroup) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	for _, dimension := range groupBy {
+		switch dimension {
+		case model.StatsByMark:
+			if a.Mark != b.Mark {
+				return a.Mark < b.Mark
+			}
+		case model.StatsByModel:
+			if a.Model != b.Model {
+				return a.Model < b.Model
+			}
+		case model.StatsByYear:
+			if a.Year != b.Year {
+				return a.Year < b.Year
+			}
+		case model.StatsByRegion:
+			if a.Region != b.Region {
+				return a.Region < b.Region
+			}
+		case model.StatsByOwner:
+			if a.Owner != b.Owner {
+				return a.Owner < b.Owner
+			}
+		}
+	}
+	return false
+}
+
+// statsStatement groups source by the query dimensions, whose expressions
+// over source are in columns, and counts the cars of each group with count.
+// Every row also carries the totals over all groups, ahead of the limit.
+func statsStatement(source string, columns map[string]string, count, where string, query model.StatsQuery, values []any) (string, []any) {
+	var selected, order []string
+	for _, dimension := range query.GroupBy {
+		column := columns[dimension]
+		selected = append(selected, column)
+		if dimension == model.StatsByYear {
+			order = append(order, column)
+		} else {
+			// Byte order, like the Go backends.
+			order = append(order, column+` COLLATE "C"`)
+		}
+	}
+	values = append(values, query.Limit)
+
+	stmt := fmt.Sprintf(`
+	SELECT %[1]s, %[2]s::bigint AS cars, SUM(%[2]s) OVER ()::bigint, COUNT(*) OVER ()
+	FROM %[3]s
+	WHERE %[4]s
+	GROUP BY %[1]s
+	ORDER BY cars DESC, %[5]s
+	LIMIT $%[6]d`,
+		strings.Join(selected, ", "), count, source, where, strings.Join(order, ", "), len(values))
+	return stmt, values
+}
+
+// queryStats runs a statsStatement.
+func queryStats(ctx context.Context, tx pgx.Tx, stmt string, values []any, groupBy []string) (model.CarStats, error) {
+	rows, err := tx.Query(ctx, stmt, values...)
+	if err != nil {
+		return model.CarStats{}, err
+	}
+	defer rows.Close()
+
+	stats := model.CarStats{Groups: []model.StatsGroup{}}
+	for rows.Next() {
+		var group model.StatsGroup
+		dest := make([]any, 0, len(groupBy)+3)
+		for _, dimension := range groupBy {
+			dest = append(dest, statsField(&group, dimension))
+		}
+		dest = append(dest, &group.Count, &stats.Cars, &stats.GroupCount)
+		if err := rows.Scan(dest...); err != nil {
+			return model.CarStats{}, err
+		}
+		stats.Groups = append(stats.Groups, group)
+	}
+	return stats, rows.Err()
+}
+
+func statsField(group *model.StatsGroup, dimension string) any {
+	switch dimension {
+	case model.StatsByMark:
+		return &group.Mark
+	case model.StatsByModel:
+		return &group.Model
+	case model.StatsByYear:
+		return &group.Year
+	case model.StatsByRegion:
+		return &group.Region
+	default:
+		return &group.Owner
+	}
+}
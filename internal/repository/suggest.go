@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"sort"
+	"strings"
+)
+
+// likePrefix turns a lower-cased prefix into a LIKE pattern, escaping the
+// wildcards with the default backslash escape.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// hasFoldPrefix reports whether value starts with lowerPrefix, ignoring case.
+func hasFoldPrefix(value, lowerPrefix string) bool {
+	return strings.HasPrefix(strings.ToLower(value), lowerPrefix)
+}
+
+// topSuggestions orders counted values like the Postgres queries do: most
+// common first, then by value bytewise, and keeps the first limit.
+func topSuggestions(counts map[string]int, limit int) []model.Suggestion {
+	suggestions := make([]model.Suggestion, 0, len(counts))
+	for value, count := range counts {
+		suggestions = append(suggestions, model.Suggestion{Value: value, Count: count})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		return suggestions[i].Value < suggestions[j].Value
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
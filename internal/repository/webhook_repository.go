@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// WebhookRepository stores webhook subscriptions and the queue of their
+// deliveries.
+type WebhookRepository interface {
+	// AddSubscription stores sub and returns it with Id and CreatedAt set.
+	AddSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error)
+	// ListSubscriptions returns every subscription, oldest first.
+	ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int) (model.WebhookSubscription, error)
+	// DeleteSubscription removes a subscription with its deliveries.
+	DeleteSubscription(ctx context.Context, id int) error
+	// Cursor is the id of the last change feed event fanned out into
+	// deliveries.
+	Cursor(ctx context.Context) (int64, error)
+	// EnqueueDeliveries queues deliveries and moves the cursor from from to
+	// to, atomically. It writes nothing and returns false when the cursor is
+	// no longer at from: another dispatcher got there first.
+	EnqueueDeliveries(ctx context.Context, from, to int64, deliveries []model.WebhookDelivery) (bool, error)
+	// AddDeliveries queues deliveries without touching the cursor, for
+	// replays.
+	AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries due at now, by
+	// id, and postpones them by lease so that no other dispatcher attempts
+	// them meanwhile.
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	// SaveAttempt stores the outcome of an attempt: Status, Attempts,
+	// NextAttemptAt, LastStatusCode, LastError and DeliveredAt.
+	SaveAttempt(ctx context.Context, delivery model.WebhookDelivery) error
+	// ListDeliveries returns up to limit deliveries of a subscription, newest
+	// first, optionally of one status.
+	ListDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error)
+	// RequeueDead makes the dead deliveries of a subscription pending again
+	// with a fresh attempt budget.
+	RequeueDead(ctx context.Context, subscriptionId int) (int64, error)
+	// PruneDeliveries deletes the deliveries delivered before the given
+	// time. Pending and dead ones are kept.
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+func sortDeliveries(deliveries []model.WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id < deliveries[j].Id })
+}
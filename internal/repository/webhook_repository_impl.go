@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookRepositoryImpl struct {
+	conn         *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+func NewWebhookRepository(conn *pgxpool.Pool, queryTimeout time.Duration) WebhookRepository {
+	return &WebhookRepositoryImpl{
+		conn:         conn,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (w *WebhookRepositoryImpl) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, w.queryTimeout)
+}
+
+const pgDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanPgDelivery(row pgx.CollectableRow) (model.WebhookDelivery, error) {
+	var (
+		d       model.WebhookDelivery
+		payload string
+	)
+	err := row.Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	d.Payload = []byte(payload)
+	return d, err
+}
+
+func (w *WebhookRepositoryImpl) AddSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `INSERT INTO cars.webhook_subscription (url, secret, events, mark, after_event_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	if err := w.conn.QueryRow(ctx, query, sub.Url, sub.Secret, sub.Events, sub.Mark, sub.AfterEventId).Scan(&sub.Id, &sub.CreatedAt); err != nil {
+		log.Printf("[ERROR] Repo - AddSubscription - Error inserting subscription: %v", err)
+		return model.WebhookSubscription{}, err
+	}
+
+	log.Printf("[INFO] Repo - AddSubscription - Added subscription %d", sub.Id)
+	return sub, nil
+}
+
+func (w *WebhookRepositoryImpl) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	rows, err := w.conn.Query(ctx, `SELECT id, url, secret, events, mark, after_event_id, created_at
+	FROM cars.webhook_subscription
+	ORDER BY id`)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListSubscriptions - Error executing select query: %v", err)
+		return nil, err
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.WebhookSubscription, error) {
+		var sub model.WebhookSubscription
+		err := row.Scan(&sub.Id, &sub.Url, &sub.Secret, &sub.Events, &sub.Mark, &sub.AfterEventId, &sub.CreatedAt)
+		return sub, err
+	})
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListSubscriptions - Error scanning row: %v", err)
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (w *WebhookRepositoryImpl) GetSubscription(ctx context.Context, id int) (model.WebhookSubscription, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	sub := model.WebhookSubscription{Id: id}
+	err := w.conn.QueryRow(ctx, `SELECT url, secret, events, mark, after_event_id, created_at
+	FROM cars.webhook_subscription
+	WHERE id = $1`, id).Scan(&sub.Url, &sub.Secret, &sub.Events, &sub.Mark, &sub.AfterEventId, &sub.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.WebhookSubscription{}, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetSubscription - Error executing select query: %v", err)
+		return model.WebhookSubscription{}, err
+	}
+	return sub, nil
+}
+
+func (w *WebhookRepositoryImpl) DeleteSubscription(ctx context.Context, id int) error {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	commandTag, err := w.conn.Exec(ctx, `DELETE FROM cars.webhook_subscription WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteSubscription - Error executing delete query: %v", err)
+		return err
+	}
+	if commandTag.RowsAffected() == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (w *WebhookRepositoryImpl) Cursor(ctx context.Context) (int64, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	var cursor int64
+	if err := w.conn.QueryRow(ctx, `SELECT last_event_id FROM cars.webhook_cursor`).Scan(&cursor); err != nil {
+		log.Printf("[ERROR] Repo - WebhookCursor - Error executing select query: %v", err)
+		return 0, err
+	}
+	return cursor, nil
+}
+
+func (w *WebhookRepositoryImpl) EnqueueDeliveries(ctx context.Context, from, to int64, deliveries []model.WebhookDelivery) (bool, error) {
+	tx, err := w.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - EnqueueDeliveries - Failed to begin transaction: %v", err)
+		return false, fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	// The row lock makes a concurrent dispatcher wait and then miss.
+	commandTag, err := tx.Exec(ctx, `UPDATE cars.webhook_cursor SET last_event_id = $2 WHERE last_event_id = $1`, from, to)
+	if err != nil {
+		log.Printf("[ERROR] Repo - EnqueueDeliveries - Error moving the cursor: %v", err)
+		return false, err
+	}
+	if commandTag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := insertPgDeliveries(ctx, tx, deliveries); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - EnqueueDeliveries - Failed to commit transaction: %v", err)
+		return false, fmt.Errorf("failed to commit transaction")
+	}
+	return true, nil
+}
+
+func (w *WebhookRepositoryImpl) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	tx, err := w.conn.Begin(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddDeliveries - Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertPgDeliveries(ctx, tx, deliveries); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("[ERROR] Repo - AddDeliveries - Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction")
+	}
+	return nil
+}
+
+func insertPgDeliveries(ctx context.Context, tx pgx.Tx, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	rows := make([][]any, len(deliveries))
+	for i, d := range deliveries {
+		rows[i] = []any{d.SubscriptionId, d.EventId, d.EventType, string(d.Payload)}
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"cars", "webhook_delivery"},
+		[]string{"subscription_id", "event_id", "event_type", "payload"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddDeliveries - Error copying deliveries: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (w *WebhookRepositoryImpl) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `UPDATE cars.webhook_delivery
+	SET next_attempt_at = $2
+	WHERE id IN (
+		SELECT id FROM cars.webhook_delivery
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at, id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + pgDeliveryColumns
+
+	rows, err := w.conn.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ClaimDeliveries - Error executing update query: %v", err)
+		return nil, err
+	}
+	deliveries, err := pgx.CollectRows(rows, scanPgDelivery)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ClaimDeliveries - Error scanning row: %v", err)
+		return nil, err
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (w *WebhookRepositoryImpl) SaveAttempt(ctx context.Context, d model.WebhookDelivery) error {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `UPDATE cars.webhook_delivery
+	SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, delivered_at = $7
+	WHERE id = $1`
+
+	if _, err := w.conn.Exec(ctx, query, d.Id, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt); err != nil {
+		log.Printf("[ERROR] Repo - SaveAttempt - Error executing update query: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (w *WebhookRepositoryImpl) ListDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT ` + pgDeliveryColumns + `
+	FROM cars.webhook_delivery
+	WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+	ORDER BY id DESC
+	LIMIT $3`
+
+	rows, err := w.conn.Query(ctx, query, subscriptionId, status, limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDeliveries - Error executing select query: %v", err)
+		return nil, err
+	}
+	deliveries, err := pgx.CollectRows(rows, scanPgDelivery)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDeliveries - Error scanning row: %v", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (w *WebhookRepositoryImpl) RequeueDead(ctx context.Context, subscriptionId int) (int64, error) {
+	ctx, cancel := w.withQueryDeadline(ctx)
+	defer cancel()
+
+	commandTag, err := w.conn.Exec(ctx, `UPDATE cars.webhook_delivery
+	SET status = 'pending', attempts = 0, next_attempt_at = now()
+	WHERE subscription_id = $1 AND status = 'dead'`, subscriptionId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - RequeueDead - Error executing update query: %v", err)
+		return 0, err
+	}
+	return commandTag.RowsAffected(), nil
+}
+
+func (w *WebhookRepositoryImpl) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	commandTag, err := w.conn.Exec(ctx, `DELETE FROM cars.webhook_delivery
+	WHERE status = 'delivered' AND delivered_at < $1`, before)
+	if err != nil {
+		log.Printf("[ERROR] Repo - PruneDeliveries - Error executing delete query: %v", err)
+		return 0, err
+	}
+	return commandTag.RowsAffected(), nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryWebhookRepository keeps subscriptions and deliveries in process
+// memory; ids are never reused, like those of the SQL tables.
+type MemoryWebhookRepository struct {
+	mu            sync.Mutex
+	subscriptions []model.WebhookSubscription
+	deliveries    []model.WebhookDelivery
+	cursor        int64
+	nextSubId     int
+	nextId        int64
+}
+
+func NewMemoryWebhookRepository() WebhookRepository {
+	return &MemoryWebhookRepository{nextSubId: 1, nextId: 1}
+}
+
+func (m *MemoryWebhookRepository) AddSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub.Id = m.nextSubId
+	sub.CreatedAt = time.Now()
+	sub.Events = append([]string{}, sub.Events...)
+	m.nextSubId++
+	m.subscriptions = append(m.subscriptions, sub)
+	return sub, nil
+}
+
+func (m *MemoryWebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]model.WebhookSubscription{}, m.subscriptions...), nil
+}
+
+func (m *MemoryWebhookRepository) GetSubscription(ctx context.Context, id int) (model.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sub := range m.subscriptions {
+		if sub.Id == id {
+			return sub, nil
+		}
+	}
+	return model.WebhookSubscription{}, ErrSubscriptionNotFound
+}
+
+func (m *MemoryWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, sub := range m.subscriptions {
+		if sub.Id != id {
+			continue
+		}
+		m.subscriptions = append(m.subscriptions[:i], m.subscriptions[i+1:]...)
+		// Deliveries go with the subscription, like ON DELETE CASCADE.
+		kept := m.deliveries[:0]
+		for _, d := range m.deliveries {
+			if d.SubscriptionId != id {
+				kept = append(kept, d)
+			}
+		}
+		m.deliveries = kept
+		return nil
+	}
+	return ErrSubscriptionNotFound
+}
+
+func (m *MemoryWebhookRepository) Cursor(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cursor, nil
+}
+
+func (m *MemoryWebhookRepository) EnqueueDeliveries(ctx context.Context, from, to int64, deliveries []model.WebhookDelivery) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cursor != from {
+		return false, nil
+	}
+	m.addLocked(deliveries)
+	m.cursor = to
+	return true, nil
+}
+
+func (m *MemoryWebhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addLocked(deliveries)
+	return nil
+}
+
+func (m *MemoryWebhookRepository) addLocked(deliveries []model.WebhookDelivery) {
+	now := time.Now()
+	for _, d := range deliveries {
+		d.Id = m.nextId
+		m.nextId++
+		d.Status = model.DeliveryPending
+		d.NextAttemptAt = now
+		d.CreatedAt = now
+		m.deliveries = append(m.deliveries, d)
+	}
+}
+
+func (m *MemoryWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed []model.WebhookDelivery
+	for i := range m.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		d := &m.deliveries[i]
+		if d.Status != model.DeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		d.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, *d)
+	}
+	return claimed, nil
+}
+
+func (m *MemoryWebhookRepository) SaveAttempt(ctx context.Context, delivery model.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.Id != delivery.Id {
+			continue
+		}
+		d.Status = delivery.Status
+		d.Attempts = delivery.Attempts
+		d.NextAttemptAt = delivery.NextAttemptAt
+		d.LastStatusCode = delivery.LastStatusCode
+		d.LastError = delivery.LastError
+		d.DeliveredAt = delivery.DeliveredAt
+		return nil
+	}
+	// The subscription was deleted meanwhile.
+	return nil
+}
+
+func (m *MemoryWebhookRepository) ListDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deliveries []model.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := m.deliveries[i]
+		if d.SubscriptionId == subscriptionId && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryWebhookRepository) RequeueDead(ctx context.Context, subscriptionId int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var requeued int64
+	now := time.Now()
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.SubscriptionId == subscriptionId && d.Status == model.DeliveryDead {
+			d.Status = model.DeliveryPending
+			d.Attempts = 0
+			d.NextAttemptAt = now
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+func (m *MemoryWebhookRepository) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pruned int64
+	kept := m.deliveries[:0]
+	for _, d := range m.deliveries {
+		if d.Status == model.DeliveryDelivered && d.DeliveredAt != nil && d.DeliveredAt.Before(before) {
+			pruned++
+			continue
+		}
+		kept = append(kept, d)
+	}
+	m.deliveries = kept
+	return pruned, nil
+}
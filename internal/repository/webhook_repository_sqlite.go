@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"car_catalog/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// SQLiteWebhookRepository stores subscriptions and deliveries next to the
+// catalog. SQLite has a single writer, so claims need no row locks.
+type SQLiteWebhookRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewSQLiteWebhookRepository(db *sql.DB, queryTimeout time.Duration) WebhookRepository {
+	return &SQLiteWebhookRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (s *SQLiteWebhookRepository) withQueryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+const sqliteDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanSQLiteDeliveries(rows *sql.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var (
+			d                      model.WebhookDelivery
+			payload                string
+			nextAttempt, createdAt int64
+			deliveredAt            sql.NullInt64
+		)
+		if err := rows.Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&nextAttempt, &d.LastStatusCode, &d.LastError, &createdAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		d.NextAttemptAt = time.UnixMilli(nextAttempt)
+		d.CreatedAt = time.UnixMilli(createdAt)
+		d.DeliveredAt = fromMillis(deliveredAt)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// splitEvents reads the comma-separated events column.
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
+
+func (s *SQLiteWebhookRepository) AddSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `INSERT INTO webhook_subscription (url, secret, events, mark, after_event_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	RETURNING id`
+
+	sub.CreatedAt = time.UnixMilli(time.Now().UnixMilli())
+	if err := s.db.QueryRowContext(ctx, query, sub.Url, sub.Secret, strings.Join(sub.Events, ","), sub.Mark,
+		sub.AfterEventId, sub.CreatedAt.UnixMilli()).Scan(&sub.Id); err != nil {
+		log.Printf("[ERROR] Repo - AddSubscription - Error inserting subscription: %v", err)
+		return model.WebhookSubscription{}, err
+	}
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+
+	log.Printf("[INFO] Repo - AddSubscription - Added subscription %d", sub.Id)
+	return sub, nil
+}
+
+func (s *SQLiteWebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, url, secret, events, mark, after_event_id, created_at
+	FROM webhook_subscription
+	ORDER BY id`)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListSubscriptions - Error executing select query: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []model.WebhookSubscription{}
+	for rows.Next() {
+		var (
+			sub       model.WebhookSubscription
+			events    string
+			createdAt int64
+		)
+		if err := rows.Scan(&sub.Id, &sub.Url, &sub.Secret, &events, &sub.Mark, &sub.AfterEventId, &createdAt); err != nil {
+			log.Printf("[ERROR] Repo - ListSubscriptions - Error scanning row: %v", err)
+			return nil, err
+		}
+		sub.Events = splitEvents(events)
+		sub.CreatedAt = time.UnixMilli(createdAt)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *SQLiteWebhookRepository) GetSubscription(ctx context.Context, id int) (model.WebhookSubscription, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	var (
+		sub       = model.WebhookSubscription{Id: id}
+		events    string
+		createdAt int64
+	)
+	err := s.db.QueryRowContext(ctx, `SELECT url, secret, events, mark, after_event_id, created_at
+	FROM webhook_subscription
+	WHERE id = ?`, id).Scan(&sub.Url, &sub.Secret, &events, &sub.Mark, &sub.AfterEventId, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.WebhookSubscription{}, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		log.Printf("[ERROR] Repo - GetSubscription - Error executing select query: %v", err)
+		return model.WebhookSubscription{}, err
+	}
+	sub.Events = splitEvents(events)
+	sub.CreatedAt = time.UnixMilli(createdAt)
+	return sub, nil
+}
+
+func (s *SQLiteWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhook_subscription WHERE id = ?`, id)
+	if err != nil {
+		log.Printf("[ERROR] Repo - DeleteSubscription - Error executing delete query: %v", err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteWebhookRepository) Cursor(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	var cursor int64
+	if err := s.db.QueryRowContext(ctx, `SELECT last_event_id FROM webhook_cursor`).Scan(&cursor); err != nil {
+		log.Printf("[ERROR] Repo - WebhookCursor - Error executing select query: %v", err)
+		return 0, err
+	}
+	return cursor, nil
+}
+
+func (s *SQLiteWebhookRepository) EnqueueDeliveries(ctx context.Context, from, to int64, deliveries []model.WebhookDelivery) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - EnqueueDeliveries - Failed to begin transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE webhook_cursor SET last_event_id = ? WHERE last_event_id = ?`, to, from)
+	if err != nil {
+		log.Printf("[ERROR] Repo - EnqueueDeliveries - Error moving the cursor: %v", err)
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+	if err := insertSQLiteDeliveries(ctx, tx, deliveries); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - EnqueueDeliveries - Failed to commit transaction: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLiteWebhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Repo - AddDeliveries - Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertSQLiteDeliveries(ctx, tx, deliveries); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Repo - AddDeliveries - Failed to commit transaction: %v", err)
+		return err
+	}
+	return nil
+}
+
+func insertSQLiteDeliveries(ctx context.Context, tx *sql.Tx, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO webhook_delivery
+	(subscription_id, event_id, event_type, payload, next_attempt_at, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UnixMilli()
+	for _, d := range deliveries {
+		if _, err := stmt.ExecContext(ctx, d.SubscriptionId, d.EventId, d.EventType, string(d.Payload), now, now); err != nil {
+			log.Printf("[ERROR] Repo - AddDeliveries - Error inserting delivery: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `UPDATE webhook_delivery
+	SET next_attempt_at = ?
+	WHERE id IN (
+		SELECT id FROM webhook_delivery
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?
+	)
+	RETURNING ` + sqliteDeliveryColumns
+
+	rows, err := s.db.QueryContext(ctx, query, now.Add(lease).UnixMilli(), now.UnixMilli(), limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ClaimDeliveries - Error executing update query: %v", err)
+		return nil, err
+	}
+	deliveries, err := scanSQLiteDeliveries(rows)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ClaimDeliveries - Error scanning row: %v", err)
+		return nil, err
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (s *SQLiteWebhookRepository) SaveAttempt(ctx context.Context, d model.WebhookDelivery) error {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `UPDATE webhook_delivery
+	SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?
+	WHERE id = ?`
+
+	if _, err := s.db.ExecContext(ctx, query, d.Status, d.Attempts, d.NextAttemptAt.UnixMilli(),
+		d.LastStatusCode, d.LastError, toMillis(d.DeliveredAt), d.Id); err != nil {
+		log.Printf("[ERROR] Repo - SaveAttempt - Error executing update query: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteWebhookRepository) ListDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]model.WebhookDelivery, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	query := `SELECT ` + sqliteDeliveryColumns + `
+	FROM webhook_delivery
+	WHERE subscription_id = ? AND (? = '' OR status = ?)
+	ORDER BY id DESC
+	LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, query, subscriptionId, status, status, limit)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDeliveries - Error executing select query: %v", err)
+		return nil, err
+	}
+	deliveries, err := scanSQLiteDeliveries(rows)
+	if err != nil {
+		log.Printf("[ERROR] Repo - ListDeliveries - Error scanning row: %v", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *SQLiteWebhookRepository) RequeueDead(ctx context.Context, subscriptionId int) (int64, error) {
+	ctx, cancel := s.withQueryDeadline(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE webhook_delivery
+	SET status = 'pending', attempts = 0, next_attempt_at = ?
+	WHERE subscription_id = ? AND status = 'dead'`, time.Now().UnixMilli(), subscriptionId)
+	if err != nil {
+		log.Printf("[ERROR] Repo - RequeueDead - Error executing update query: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLiteWebhookRepository) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhook_delivery
+	WHERE status = 'delivered' AND delivered_at < ?`, before.UnixMilli())
+	if err != nil {
+		log.Printf("[ERROR] Repo - PruneDeliveries - Error executing delete query: %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
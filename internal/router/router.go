@@ -4,6 +4,7 @@ import (
 	_ "car_catalog/docs"
 	"car_catalog/internal/handler"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -16,9 +17,80 @@ func NewRouter(carHandler *handler.CarHandler) *httprouter.Router {
 	router.POST("/api/addCars", carHandler.AddCars)
 	router.PATCH("/api/updateCar/:id", carHandler.UpdateCar)
 	router.DELETE("/api/delete/:id", carHandler.DeleteCar)
+	// httprouter does not allow a static segment where /api/cars/:id/...
+	// has a parameter, so the collection endpoints are dispatched from :id.
+	router.GET("/api/cars/:id", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		switch p.ByName("id") {
+		case "export":
+			carHandler.ExportCars(w, r, p)
+		case "events":
+			carHandler.StreamEvents(w, r, p)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	router.POST("/api/cars/:id", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if p.ByName("id") == "import" {
+			carHandler.ImportCars(w, r, p)
+			return
+		}
+		http.NotFound(w, r)
+	})
+	router.POST("/api/cars/:id/resync", carHandler.ResyncCar)
+	router.GET("/api/cars/:id/attachments", carHandler.ListAttachments)
+	router.POST("/api/cars/:id/attachments", carHandler.UploadAttachment)
+	router.GET("/api/cars/:id/attachments/:attachmentId", carHandler.GetAttachment)
+	router.DELETE("/api/cars/:id/attachments/:attachmentId", carHandler.DeleteAttachment)
+	router.GET("/api/sync/divergences", carHandler.GetDivergences)
+	router.PATCH("/api/cars", carHandler.BatchUpdateCars)
+	router.GET("/api/suggest/marks", carHandler.SuggestMarks)
+	router.GET("/api/suggest/models", carHandler.SuggestModels)
+	router.GET("/api/suggest/regnums", carHandler.SuggestRegNums)
+	router.GET("/api/stats", carHandler.GetStats)
+	router.GET("/api/admin/makes", carHandler.ListMakes)
+	router.POST("/api/admin/makes", carHandler.AddMake)
+	router.DELETE("/api/admin/makes/:id", carHandler.DeleteMake)
+	router.POST("/api/admin/makes/:id/aliases", carHandler.AddMakeAlias)
+	router.DELETE("/api/admin/makes/:id/aliases/:alias", carHandler.DeleteMakeAlias)
+	router.POST("/api/admin/makes/:id/models", carHandler.AddModel)
+	router.DELETE("/api/admin/models/:id", carHandler.DeleteModel)
+	router.POST("/api/admin/models/:id/aliases", carHandler.AddModelAlias)
+	router.DELETE("/api/admin/models/:id/aliases/:alias", carHandler.DeleteModelAlias)
+	router.GET("/api/admin/cache-stats", carHandler.GetCacheStats)
+	router.GET("/api/admin/webhooks", carHandler.ListWebhooks)
+	router.POST("/api/admin/webhooks", carHandler.AddWebhook)
+	router.DELETE("/api/admin/webhooks/:id", carHandler.DeleteWebhook)
+	router.GET("/api/admin/webhooks/:id/deliveries", carHandler.ListWebhookDeliveries)
+	router.POST("/api/admin/webhooks/:id/replay", carHandler.ReplayWebhook)
+	if carHandler.GraphQL != nil {
+		router.GET("/graphql", carHandler.GetGraphQL)
+		router.POST("/graphql", carHandler.PostGraphQL)
+	}
+	// A colon starts a parameter in httprouter, so the batch delete verb is
+	// matched before routing instead of being registered.
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/cars:batchDelete" {
+			carHandler.BatchDeleteCars(w, r, nil)
+			return
+		}
+		http.NotFound(w, r)
+	})
 
 	router.GET("/swagger/*any", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		httpSwagger.WrapHandler(w, r)
 	})
 	return router
 }
+
+// IsImport reports whether r draws from the import rate-limit budget: it
+// calls the external registry or writes in bulk.
+func IsImport(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPatch:
+		return r.URL.Path == "/api/cars"
+	case http.MethodPost:
+		return r.URL.Path == "/api/addCars" || r.URL.Path == "/api/cars/import" ||
+			r.URL.Path == "/api/cars:batchDelete" || strings.HasSuffix(r.URL.Path, "/resync")
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package router_test
+
+import (
+	"car_catalog/internal/handler"
+	"car_catalog/internal/router"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsImport(t *testing.T) {
+	for _, tt := range []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodPost, "/api/addCars", true},
+		{http.MethodPost, "/api/cars/import", true},
+		{http.MethodPatch, "/api/cars", true},
+		{http.MethodPost, "/api/cars:batchDelete", true},
+		{http.MethodPost, "/api/cars/7/resync", true},
+		{http.MethodGet, "/api/cars/export", false},
+		{http.MethodGet, "/api/cars/events", false},
+		{http.MethodGet, "/api/getCars/", false},
+		{http.MethodPost, "/api/cars/7/attachments", false},
+	} {
+		if got := router.IsImport(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("IsImport(%s %s) = %t, want %t", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCollectionRoutes(t *testing.T) {
+	routes := router.NewRouter(handler.NewCarHandler(nil, nil))
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/cars/7", http.StatusNotFound},
+		{http.MethodPost, "/api/cars/7", http.StatusNotFound},
+		{http.MethodPost, "/api/cars/import?format=xml", http.StatusBadRequest},
+		{http.MethodPost, "/api/cars:batchDelete", http.StatusBadRequest},
+		{http.MethodGet, "/api/cars:batchDelete", http.StatusNotFound},
+		{http.MethodGet, "/api/cars/export?format=xml", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
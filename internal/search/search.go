@@ -0,0 +1,162 @@
+// Package search holds the transliteration-aware fuzzy matcher behind the
+// q= parameter of /api/getCars. Postgres does the matching with pg_trgm; the
+// Go scorer here mirrors it for the SQLite and in-memory backends.
+package search
+
+import (
+	"car_catalog/internal/regnum"
+	"strings"
+	"unicode"
+)
+
+// Threshold is the lowest score a car needs to be returned. It is also set
+// as pg_trgm.word_similarity_threshold for the Postgres search.
+const Threshold = 0.3
+
+// MaxQueryLength bounds q, in characters.
+const MaxQueryLength = 100
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n",
+	'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y",
+	'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// latinToCyrillic is tried longest spelling first.
+var latinToCyrillic = []struct{ latin, cyrillic string }{
+	{"shch", "щ"}, {"sch", "щ"},
+	{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yu", "ю"}, {"ya", "я"}, {"yo", "е"},
+	{"a", "а"}, {"b", "б"}, {"c", "к"}, {"d", "д"}, {"e", "е"}, {"f", "ф"},
+	{"g", "г"}, {"h", "х"}, {"i", "и"}, {"j", "дж"}, {"k", "к"}, {"l", "л"},
+	{"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"}, {"q", "к"}, {"r", "р"},
+	{"s", "с"}, {"t", "т"}, {"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "кс"},
+	{"y", "й"}, {"z", "з"},
+}
+
+// Normalize lower-cases s and folds ё into е, the same way the search_text
+// column is built.
+func Normalize(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "ё", "е")
+}
+
+// Variants returns the spellings q is searched under: as typed, transliterated
+// to Latin, transliterated to Cyrillic and, for plate-like input, the
+// canonical plate. Duplicates are dropped.
+func Variants(q string) []string {
+	normalized := Normalize(q)
+	candidates := []string{
+		normalized,
+		ToLatin(normalized),
+		ToCyrillic(normalized),
+		strings.ToLower(regnum.Normalize(q)),
+	}
+
+	var variants []string
+	seen := make(map[string]bool, len(candidates))
+	for _, v := range candidates {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// ToLatin transliterates the Cyrillic letters of a normalized string.
+func ToLatin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ToCyrillic transliterates the Latin letters of a normalized string.
+func ToCyrillic(s string) string {
+	var b strings.Builder
+outer:
+	for len(s) > 0 {
+		for _, t := range latinToCyrillic {
+			if strings.HasPrefix(s, t.latin) {
+				b.WriteString(t.cyrillic)
+				s = s[len(t.latin):]
+				continue outer
+			}
+		}
+		r := []rune(s)[0]
+		b.WriteRune(r)
+		s = s[len(string(r)):]
+	}
+	return b.String()
+}
+
+// Score rates how well text, a normalized search_text, matches the best of
+// the query variants: 1 when a variant occurs in it verbatim, otherwise the
+// mean over the variant's words of their best trigram similarity with a word
+// of text, which is close to pg_trgm word_similarity.
+func Score(variants []string, text string) float64 {
+	textWords := words(text)
+	best := 0.0
+	for _, v := range variants {
+		if strings.Contains(text, v) {
+			return 1
+		}
+		queryWords := words(v)
+		if len(queryWords) == 0 {
+			continue
+		}
+		sum := 0.0
+		for _, qw := range queryWords {
+			wordBest := 0.0
+			for _, tw := range textWords {
+				if s := similarity(qw, tw); s > wordBest {
+					wordBest = s
+				}
+			}
+			sum += wordBest
+		}
+		if score := sum / float64(len(queryWords)); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// similarity is the pg_trgm similarity of two words: shared trigrams over all
+// trigrams, each word padded with two leading spaces and one trailing space.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	union := len(ta) + len(tb) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+func trigrams(word string) map[string]bool {
+	r := []rune("  " + word + " ")
+	set := make(map[string]bool, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		set[string(r[i:i+3])] = true
+	}
+	return set
+}
@@ -0,0 +1,49 @@
+package search_test
+
+import (
+	"car_catalog/internal/search"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"Тойота", []string{"тойота", "toyota"}},
+		{"Ivanov", []string{"ivanov", "иванов"}},
+		{"Семён", []string{"семен", "semen"}},
+	}
+	for _, tt := range tests {
+		got := search.Variants(tt.q)
+		if len(got) < len(tt.want) {
+			t.Errorf("Variants(%q) = %q, want at least %q", tt.q, got, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if got[i] != want {
+				t.Errorf("Variants(%q) = %q, want prefix %q", tt.q, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	const text = "mercedes-benz e200 a123bc77 иванов иван иванович"
+	tests := []struct {
+		q    string
+		want func(float64) bool
+	}{
+		{"benz", func(s float64) bool { return s == 1 }},
+		{"Mersedes", func(s float64) bool { return s >= search.Threshold && s < 1 }},
+		{"Ivanov", func(s float64) bool { return s == 1 }},
+		{"А123ВС77", func(s float64) bool { return s == 1 }},
+		{"toyota", func(s float64) bool { return s < search.Threshold }},
+	}
+	for _, tt := range tests {
+		if got := search.Score(search.Variants(tt.q), text); !tt.want(got) {
+			t.Errorf("Score(%q) = %v", tt.q, got)
+		}
+	}
+}
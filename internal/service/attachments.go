@@ -0,0 +1,304 @@
+package service
+
+import (
+	"bytes"
+	"car_catalog/internal/blobstore"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// sniffLen is how much of an upload http.DetectContentType looks at.
+	sniffLen = 512
+	// maxFileNameBytes caps stored file names, like most file systems do.
+	maxFileNameBytes = 255
+)
+
+var (
+	ErrInvalidAttachment     = errors.New("invalid attachment")
+	ErrAttachmentTooLarge    = errors.New("attachment too large")
+	ErrUnsupportedAttachment = errors.New("unsupported attachment type")
+)
+
+// AttachmentOptions bound what can be attached to a car.
+type AttachmentOptions struct {
+	// MaxSize caps the content of one attachment, in bytes.
+	MaxSize int64
+	// AllowedTypes lists the media types accepted, as detected from the
+	// content; what the client declares is ignored.
+	AllowedTypes []string
+}
+
+// AttachmentService manages the photos and documents attached to cars.
+type AttachmentService interface {
+	// Upload attaches content to a car under fileName. When the car already
+	// has the same content, that attachment is returned with created false
+	// and nothing is stored.
+	Upload(ctx context.Context, carId, fileName string, content io.Reader) (attachment dto.AttachmentDto, created bool, err error)
+	// List returns the attachments of a car, oldest first.
+	List(ctx context.Context, carId string) ([]dto.AttachmentDto, error)
+	// Open returns an attachment with its content; the caller closes it.
+	Open(ctx context.Context, carId, id string) (dto.AttachmentDto, io.ReadSeekCloser, error)
+	Delete(ctx context.Context, carId, id string) error
+	// SweepBlobs deletes the stored contents that no attachment refers to
+	// and that were not put within grace, so uploads in flight keep theirs.
+	SweepBlobs(ctx context.Context, grace time.Duration) (int, error)
+}
+
+type AttachmentServiceImpl struct {
+	Repo  repository.AttachmentRepository
+	Cars  repository.CarRepository
+	Blobs blobstore.Store
+	Opts  AttachmentOptions
+
+	allowed map[string]bool
+}
+
+func NewAttachmentService(repo repository.AttachmentRepository, cars repository.CarRepository, blobs blobstore.Store, opts AttachmentOptions) AttachmentService {
+	allowed := make(map[string]bool, len(opts.AllowedTypes))
+	for _, t := range opts.AllowedTypes {
+		allowed[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+	return &AttachmentServiceImpl{
+		Repo:    repo,
+		Cars:    cars,
+		Blobs:   blobs,
+		Opts:    opts,
+		allowed: allowed,
+	}
+}
+
+func toAttachmentDto(a model.Attachment) dto.AttachmentDto {
+	return dto.AttachmentDto{
+		Id:          a.Id,
+		CarId:       a.CarId,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Sha256:      a.Sha256,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+func (s *AttachmentServiceImpl) Upload(ctx context.Context, carId, fileName string, content io.Reader) (dto.AttachmentDto, bool, error) {
+	carID, err := strconv.Atoi(carId)
+	if err != nil {
+		log.Printf("[ERROR] Service - Upload - Invalid car id %q: %v", carId, err)
+		return dto.AttachmentDto{}, false, err
+	}
+	// Checked before anything is stored; the foreign key still catches a
+	// car deleted meanwhile.
+	if _, err := s.Cars.GetCarById(ctx, carID); err != nil {
+		return dto.AttachmentDto{}, false, err
+	}
+
+	upload := &uploadReader{r: content, left: s.Opts.MaxSize, max: s.Opts.MaxSize}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(upload, head)
+	if upload.err != nil {
+		return dto.AttachmentDto{}, false, upload.err
+	}
+	if n == 0 {
+		return dto.AttachmentDto{}, false, fmt.Errorf("%w: the file is empty", ErrInvalidAttachment)
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return dto.AttachmentDto{}, false, err
+	}
+	head = head[:n]
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !s.allowed[contentType] {
+		log.Printf("[INFO] Service - Upload - Rejected %q of type %s", fileName, contentType)
+		return dto.AttachmentDto{}, false, fmt.Errorf("%w: %s, want one of %s",
+			ErrUnsupportedAttachment, contentType, strings.Join(s.Opts.AllowedTypes, ", "))
+	}
+
+	blob, err := s.Blobs.Put(ctx, io.MultiReader(bytes.NewReader(head), upload))
+	if upload.err != nil {
+		log.Printf("[INFO] Service - Upload - Upload of %q stopped: %v", fileName, upload.err)
+		return dto.AttachmentDto{}, false, upload.err
+	}
+	if err != nil {
+		log.Printf("[ERROR] Service - Upload - Unable to store %q: %v", fileName, err)
+		return dto.AttachmentDto{}, false, err
+	}
+
+	// A blob whose attachment fails to be added is left to the sweep.
+	attachment, created, err := s.Repo.AddAttachment(ctx, model.Attachment{
+		CarId:       carID,
+		FileName:    cleanFileName(fileName),
+		ContentType: contentType,
+		Size:        blob.Size,
+		Sha256:      blob.Key,
+	})
+	if err != nil {
+		return dto.AttachmentDto{}, false, err
+	}
+
+	log.Printf("[INFO] Service - Upload - Car %d: attachment %d, created %t", carID, attachment.Id, created)
+	return toAttachmentDto(attachment), created, nil
+}
+
+// uploadReader reads an upload, failing with ErrAttachmentTooLarge past max
+// bytes. err keeps why reading failed, to tell the client's fault from that
+// of the store the content is copied to.
+type uploadReader struct {
+	r    io.Reader
+	left int64
+	max  int64
+	err  error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	// One byte past the limit is enough to know the upload is too large.
+	if int64(len(p)) > u.left+1 {
+		p = p[:u.left+1]
+	}
+	n, err := u.r.Read(p)
+	u.left -= int64(n)
+	switch {
+	case u.left < 0:
+		u.err = fmt.Errorf("%w: over %d bytes", ErrAttachmentTooLarge, u.max)
+	case err != nil && err != io.EOF:
+		u.err = fmt.Errorf("%w: %w", ErrInvalidAttachment, err)
+	default:
+		return n, err
+	}
+	return n, u.err
+}
+
+// cleanFileName keeps the last element of a client-supplied name, without
+// control characters and at most maxFileNameBytes long.
+func cleanFileName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name))
+	for len(name) > maxFileNameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+func (s *AttachmentServiceImpl) List(ctx context.Context, carId string) ([]dto.AttachmentDto, error) {
+	carID, err := strconv.Atoi(carId)
+	if err != nil {
+		log.Printf("[ERROR] Service - List - Invalid car id %q: %v", carId, err)
+		return nil, err
+	}
+	if _, err := s.Cars.GetCarById(ctx, carID); err != nil {
+		return nil, err
+	}
+	attachments, err := s.Repo.ListAttachments(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.AttachmentDto, len(attachments))
+	for i, a := range attachments {
+		result[i] = toAttachmentDto(a)
+	}
+	return result, nil
+}
+
+func (s *AttachmentServiceImpl) Open(ctx context.Context, carId, id string) (dto.AttachmentDto, io.ReadSeekCloser, error) {
+	carID, attachmentID, err := attachmentIds(carId, id)
+	if err != nil {
+		return dto.AttachmentDto{}, nil, err
+	}
+	attachment, err := s.Repo.GetAttachment(ctx, carID, attachmentID)
+	if err != nil {
+		return dto.AttachmentDto{}, nil, err
+	}
+	content, _, err := s.Blobs.Open(ctx, attachment.Sha256)
+	if err != nil {
+		log.Printf("[ERROR] Service - Open - Content of attachment %d is unavailable: %v", attachment.Id, err)
+		return dto.AttachmentDto{}, nil, err
+	}
+	return toAttachmentDto(attachment), content, nil
+}
+
+func (s *AttachmentServiceImpl) Delete(ctx context.Context, carId, id string) error {
+	carID, attachmentID, err := attachmentIds(carId, id)
+	if err != nil {
+		return err
+	}
+	// The content may be shared with other attachments; the sweep deletes
+	// it once none refers to it.
+	if _, err := s.Repo.DeleteAttachment(ctx, carID, attachmentID); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Service - Delete - Deleted attachment %d of car %d", attachmentID, carID)
+	return nil
+}
+
+func attachmentIds(carId, id string) (int, int, error) {
+	carID, err := strconv.Atoi(carId)
+	if err != nil {
+		log.Printf("[ERROR] Service - Attachments - Invalid car id %q: %v", carId, err)
+		return 0, 0, err
+	}
+	attachmentID, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("[ERROR] Service - Attachments - Invalid attachment id %q: %v", id, err)
+		return 0, 0, err
+	}
+	return carID, attachmentID, nil
+}
+
+func (s *AttachmentServiceImpl) SweepBlobs(ctx context.Context, grace time.Duration) (int, error) {
+	blobs, err := s.Blobs.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-grace)
+	deleted := 0
+	for _, blob := range blobs {
+		if blob.ModTime.After(cutoff) {
+			continue
+		}
+		referenced, err := s.Repo.BlobReferenced(ctx, blob.Key)
+		if err != nil {
+			return deleted, err
+		}
+		if referenced {
+			continue
+		}
+		// An upload of the same content may have refreshed the blob since
+		// it was listed; its attachment is then about to refer to it.
+		removed, err := s.Blobs.DeleteIfOlder(ctx, blob.Key, cutoff)
+		if err != nil {
+			return deleted, err
+		}
+		if removed {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+const (
+	BatchModeTransaction = "transaction"
+	BatchModePerItem     = "per_item"
+)
+
+var (
+	ErrInvalidBatch  = errors.New("invalid batch request")
+	ErrBatchTooLarge = errors.New("batch selects too many cars")
+	// ErrBatchAborted means a transactional batch failed on some items and
+	// nothing was written; the result lists the failures.
+	ErrBatchAborted = errors.New("batch aborted, nothing was changed")
+)
+
+// errStopMatching ends a StreamCars walk once the batch is known to be too
+// large.
+var errStopMatching = errors.New("stop matching")
+
+// batchItem is the change set of one car of a batch update.
+type batchItem struct {
+	id      int
+	changes dto.UpdateCarDto
+}
+
+func (c *CarServiceImpl) BatchUpdateCars(ctx context.Context, req dto.BatchUpdateRequest, maxItems int) (dto.BatchResult, error) {
+	mode, err := batchMode(req.Mode)
+	if err != nil {
+		return dto.BatchResult{}, err
+	}
+	result := dto.BatchResult{Mode: mode, DryRun: req.DryRun}
+
+	var items []batchItem
+	switch {
+	case len(req.Items) > 0 && req.Filter == nil && req.Changes == nil:
+		if maxItems > 0 && len(req.Items) > maxItems {
+			return dto.BatchResult{}, fmt.Errorf("%w: %d items, at most %d", ErrBatchTooLarge, len(req.Items), maxItems)
+		}
+		seen := make(map[int]bool, len(req.Items))
+		for _, item := range req.Items {
+			if seen[item.Id] {
+				return dto.BatchResult{}, fmt.Errorf("%w: car %d appears more than once", ErrInvalidBatch, item.Id)
+			}
+			seen[item.Id] = true
+			items = append(items, batchItem{id: item.Id, changes: item.Changes})
+		}
+
+	case req.Filter != nil && req.Changes != nil && len(req.Items) == 0:
+		// The change set is the same for every car, so check it once.
+		if err := applyUpdate(&model.Car{}, *req.Changes); err != nil {
+			return dto.BatchResult{}, fmt.Errorf("%w: invalid year %q", ErrInvalidBatch, req.Changes.Year)
+		}
+		if *req.Changes == (dto.UpdateCarDto{}) {
+			return dto.BatchResult{}, fmt.Errorf("%w: changes are empty", ErrInvalidBatch)
+		}
+		cars, matched, err := c.matchCars(ctx, *req.Filter, maxItems, req.DryRun)
+		if err != nil {
+			return dto.BatchResult{}, err
+		}
+		if req.DryRun {
+			result.Matched = matched
+			return result, nil
+		}
+		for _, car := range cars {
+			items = append(items, batchItem{id: car.CarId, changes: *req.Changes})
+		}
+
+	default:
+		return dto.BatchResult{}, fmt.Errorf("%w: send either items or a filter with changes", ErrInvalidBatch)
+	}
+
+	// The cars are read and written back in one transaction of the
+	// repository, so a concurrent update is not lost to a stale copy.
+	resolver := newCanonicalizer(c.Dictionary)
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.id
+	}
+
+	if mode == BatchModeTransaction {
+		var attempt dto.BatchResult
+		err := c.CarRepo.ModifyCars(ctx, ids, func(cars []model.Car) ([]model.Car, error) {
+			attempt = result
+			targets, err := c.applyBatchUpdate(ctx, resolver, items, cars, &attempt)
+			switch {
+			case err != nil:
+				return nil, err
+			case attempt.Failed > 0:
+				return nil, ErrBatchAborted
+			case req.DryRun:
+				return nil, nil
+			}
+			attempt.Affected = len(targets)
+			return targets, nil
+		})
+		result = attempt
+		switch {
+		case errors.Is(err, ErrBatchAborted):
+			return result, ErrBatchAborted
+		case errors.Is(err, repository.ErrCarNotFound) || errors.Is(err, repository.ErrDuplicateRegNum) ||
+			errors.Is(err, repository.ErrDuplicateVin):
+			result.Affected = 0
+			result.Failed++
+			result.Errors = append(result.Errors, dto.BatchItemError{Error: err.Error()})
+			return result, ErrBatchAborted
+		case err != nil:
+			log.Printf("[ERROR] Service - BatchUpdateCars - Error updating cars: %v", err)
+			return dto.BatchResult{}, err
+		}
+	} else {
+		for _, item := range items {
+			var (
+				written bool
+				hardErr error
+			)
+			err := c.CarRepo.ModifyCars(ctx, []int{item.id}, func(cars []model.Car) ([]model.Car, error) {
+				written = false
+				targets, err := c.applyBatchUpdate(ctx, resolver, []batchItem{item}, cars, &result)
+				if err != nil {
+					hardErr = err
+					return nil, err
+				}
+				if req.DryRun || len(targets) == 0 {
+					return nil, nil
+				}
+				written = true
+				return targets, nil
+			})
+			if hardErr != nil {
+				log.Printf("[ERROR] Service - BatchUpdateCars - Error updating car %d: %v", item.id, hardErr)
+				return dto.BatchResult{}, hardErr
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				result.Failed++
+				result.Errors = append(result.Errors, dto.BatchItemError{Id: item.id, Error: err.Error()})
+				continue
+			}
+			if written {
+				result.Affected++
+			}
+		}
+	}
+
+	log.Printf("[INFO] Service - BatchUpdateCars - %+v", result)
+	return result, nil
+}
+
+// applyBatchUpdate applies items to cars, the rows the repository holds for
+// them, and returns the cars to write. Items that cannot be applied are
+// recorded in result; other errors are returned.
+func (c *CarServiceImpl) applyBatchUpdate(ctx context.Context, resolver *canonicalizer, items []batchItem, cars []model.Car, result *dto.BatchResult) ([]model.Car, error) {
+	fail := func(id int, err error) {
+		result.Failed++
+		result.Errors = append(result.Errors, dto.BatchItemError{Id: id, Error: err.Error()})
+	}
+	byId := make(map[int]model.Car, len(cars))
+	for _, car := range cars {
+		byId[car.CarId] = car
+	}
+
+	var targets []model.Car
+	for _, item := range items {
+		result.Matched++
+		car, ok := byId[item.id]
+		if !ok {
+			fail(item.id, repository.ErrCarNotFound)
+			continue
+		}
+		if err := applyUpdate(&car, item.changes); err != nil {
+			fail(item.id, fmt.Errorf("invalid year %q", item.changes.Year))
+			continue
+		}
+		if item.changes.Mark != "" || item.changes.Model != "" {
+			if err := resolver.canonicalizeCar(ctx, &car); err != nil {
+				log.Printf("[ERROR] Service - applyBatchUpdate - Error canonicalizing car %d: %v", item.id, err)
+				return nil, err
+			}
+		}
+		if vinAffected(item.changes) {
+			if err := checkVin(ctx, resolver, &car); err != nil {
+				fail(item.id, err)
+				continue
+			}
+		}
+		targets = append(targets, car)
+	}
+	return targets, nil
+}
+
+func (c *CarServiceImpl) BatchDeleteCars(ctx context.Context, req dto.BatchDeleteRequest, maxItems int) (dto.BatchResult, error) {
+	mode, err := batchMode(req.Mode)
+	if err != nil {
+		return dto.BatchResult{}, err
+	}
+	result := dto.BatchResult{Mode: mode, DryRun: req.DryRun}
+	fail := func(id int, err error) {
+		result.Failed++
+		result.Errors = append(result.Errors, dto.BatchItemError{Id: id, Error: err.Error()})
+	}
+
+	var ids []int
+	switch {
+	case len(req.Ids) > 0 && req.Filter == nil:
+		seen := make(map[int]bool, len(req.Ids))
+		for _, id := range req.Ids {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if maxItems > 0 && len(ids) > maxItems {
+			return dto.BatchResult{}, fmt.Errorf("%w: %d ids, at most %d", ErrBatchTooLarge, len(ids), maxItems)
+		}
+		// Missing ids are reported up front, so a dry run sees them too.
+		var existing []int
+		for _, id := range ids {
+			if _, err := c.CarRepo.GetCarById(ctx, id); err != nil {
+				if !errors.Is(err, repository.ErrCarNotFound) {
+					log.Printf("[ERROR] Service - BatchDeleteCars - Error getting car with id %d: %v", id, err)
+					return dto.BatchResult{}, err
+				}
+				fail(id, err)
+				continue
+			}
+			existing = append(existing, id)
+		}
+		ids = existing
+
+	case req.Filter != nil && len(req.Ids) == 0:
+		cars, matched, err := c.matchCars(ctx, *req.Filter, maxItems, req.DryRun)
+		if err != nil {
+			return dto.BatchResult{}, err
+		}
+		if req.DryRun {
+			result.Matched = matched
+			return result, nil
+		}
+		for _, car := range cars {
+			ids = append(ids, car.CarId)
+		}
+
+	default:
+		return dto.BatchResult{}, fmt.Errorf("%w: send either ids or a filter", ErrInvalidBatch)
+	}
+
+	result.Matched = len(ids) + result.Failed
+	if req.DryRun {
+		return result, nil
+	}
+
+	if mode == BatchModeTransaction {
+		if result.Failed > 0 {
+			return result, ErrBatchAborted
+		}
+		if err := c.CarRepo.DeleteCars(ctx, ids); err != nil {
+			if errors.Is(err, repository.ErrCarNotFound) {
+				fail(0, err)
+				return result, ErrBatchAborted
+			}
+			log.Printf("[ERROR] Service - BatchDeleteCars - Error deleting cars: %v", err)
+			return dto.BatchResult{}, err
+		}
+		result.Affected = len(ids)
+	} else {
+		for _, id := range ids {
+			if err := c.CarRepo.DeleteCar(ctx, id); err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				fail(id, err)
+				continue
+			}
+			result.Affected++
+		}
+	}
+
+	log.Printf("[INFO] Service - BatchDeleteCars - %+v", result)
+	return result, nil
+}
+
+// matchCars returns the cars selected by filter. A dry run only counts them,
+// without the maxItems cap; otherwise more than maxItems matches is an error.
+func (c *CarServiceImpl) matchCars(ctx context.Context, filter dto.BatchFilter, maxItems int, countOnly bool) ([]model.Car, int, error) {
+	if filter == (dto.BatchFilter{}) {
+		return nil, 0, fmt.Errorf("%w: the filter must set at least one field", ErrInvalidBatch)
+	}
+	if filter.Year != "" {
+		if _, err := strconv.Atoi(filter.Year); err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid year %q", ErrInvalidBatch, filter.Year)
+		}
+	}
+
+	var (
+		cars    []model.Car
+		matched int
+	)
+	err := c.CarRepo.StreamCars(ctx, filter.Mark, filter.Model, filter.Year, func(car model.Car) error {
+		matched++
+		if countOnly {
+			return nil
+		}
+		if maxItems > 0 && matched > maxItems {
+			return errStopMatching
+		}
+		cars = append(cars, car)
+		return nil
+	})
+	if errors.Is(err, errStopMatching) {
+		return nil, 0, fmt.Errorf("%w: the filter matches more than %d cars", ErrBatchTooLarge, maxItems)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Service - matchCars - Error streaming cars: %v", err)
+		return nil, 0, err
+	}
+	return cars, matched, nil
+}
+
+func batchMode(mode string) (string, error) {
+	switch mode {
+	case "", BatchModeTransaction:
+		return BatchModeTransaction, nil
+	case BatchModePerItem:
+		return BatchModePerItem, nil
+	}
+	return "", fmt.Errorf("%w: mode %q must be %s or %s", ErrInvalidBatch, mode, BatchModeTransaction, BatchModePerItem)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/regnum"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/vin"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	minCarYear = 1885
+	// maxFieldLength matches the VARCHAR(100) text columns of the car table.
+	maxFieldLength = 100
+)
+
+func (c *CarServiceImpl) ImportCars(ctx context.Context, rows []dto.ImportRow, dryRun bool) (dto.ImportReport, error) {
+	report := dto.ImportReport{DryRun: dryRun, Total: len(rows)}
+	reject := func(row dto.ImportRow, reason string) {
+		report.Errors = append(report.Errors, dto.ImportRowError{
+			Line:   row.Line,
+			RegNum: row.Car.RegNum,
+			Error:  reason,
+		})
+	}
+
+	var (
+		valid     []dto.ImportRow
+		seen      = make(map[string]int, len(rows))
+		seenVins  = make(map[string]int)
+		regNumSet = make([]string, 0, len(rows))
+	)
+	for _, row := range rows {
+		if row.Error != "" {
+			reject(row, row.Error)
+			continue
+		}
+
+		row.Car.RegNum = regnum.Normalize(row.Car.RegNum)
+		row.Car.Vin = vin.Normalize(row.Car.Vin)
+		if err := validateImportCar(row.Car); err != nil {
+			reject(row, err.Error())
+			continue
+		}
+		if line, ok := seen[row.Car.RegNum]; ok {
+			reject(row, fmt.Sprintf("duplicate of line %d", line))
+			continue
+		}
+		if line, ok := seenVins[row.Car.Vin]; ok {
+			reject(row, fmt.Sprintf("VIN duplicates line %d", line))
+			continue
+		}
+		seen[row.Car.RegNum] = row.Line
+		if row.Car.Vin != "" {
+			seenVins[row.Car.Vin] = row.Line
+		}
+
+		valid = append(valid, row)
+		regNumSet = append(regNumSet, row.Car.RegNum)
+	}
+
+	var existing []string
+	if len(regNumSet) > 0 {
+		var err error
+		existing, err = c.CarRepo.FindExistingRegNums(ctx, regNumSet)
+		if err != nil {
+			log.Printf("[ERROR] Service - ImportCars - Error checking existing registration numbers: %v", err)
+			return dto.ImportReport{}, err
+		}
+	}
+	existingSet := make(map[string]bool, len(existing))
+	for _, regNum := range existing {
+		existingSet[regNum] = true
+	}
+
+	var carsToAdd []model.Car
+	resolver := newCanonicalizer(c.Dictionary)
+	for _, row := range valid {
+		if existingSet[row.Car.RegNum] {
+			reject(row, "registration number already exists")
+			continue
+		}
+		car := model.Car{
+			Mark:            row.Car.Mark,
+			Model:           row.Car.Model,
+			Year:            row.Car.Year,
+			RegNum:          row.Car.RegNum,
+			Vin:             row.Car.Vin,
+			OwnerName:       row.Car.Owner.Name,
+			OwnerSurname:    row.Car.Owner.Surname,
+			OwnerPatronymic: row.Car.Owner.Patronymic,
+		}
+		if err := resolver.canonicalizeCar(ctx, &car); err != nil {
+			log.Printf("[ERROR] Service - ImportCars - Error canonicalizing car: %v", err)
+			return dto.ImportReport{}, err
+		}
+		if err := checkVin(ctx, resolver, &car); err != nil {
+			if !errors.Is(err, ErrVinMismatch) {
+				log.Printf("[ERROR] Service - ImportCars - Error checking VIN: %v", err)
+				return dto.ImportReport{}, err
+			}
+			reject(row, err.Error())
+			continue
+		}
+		if car.Vin != "" {
+			if _, err := c.CarRepo.GetCarByVin(ctx, car.Vin); err == nil {
+				reject(row, "VIN already exists")
+				continue
+			} else if !errors.Is(err, repository.ErrCarNotFound) {
+				log.Printf("[ERROR] Service - ImportCars - Error checking existing VIN: %v", err)
+				return dto.ImportReport{}, err
+			}
+		}
+		carsToAdd = append(carsToAdd, car)
+	}
+	report.Valid = len(carsToAdd)
+	report.Rejected = len(report.Errors)
+	log.Printf("[DEBUG] Service - ImportCars - %d valid rows, %d rejected", report.Valid, report.Rejected)
+
+	if dryRun || len(carsToAdd) == 0 {
+		log.Println("[INFO] Service - ImportCars - Nothing to write")
+		return report, nil
+	}
+
+	if err := c.CarRepo.AddCars(ctx, carsToAdd); err != nil {
+		log.Printf("[ERROR] Service - ImportCars - Error adding cars: %v", err)
+		return dto.ImportReport{}, err
+	}
+	report.Imported = len(carsToAdd)
+
+	log.Printf("[INFO] Service - ImportCars - Imported %d cars", report.Imported)
+	return report, nil
+}
+
+func validateImportCar(car dto.ImportCarDto) error {
+	switch {
+	case car.Mark == "":
+		return fmt.Errorf("mark is required")
+	case car.Model == "":
+		return fmt.Errorf("model is required")
+	case utf8.RuneCountInString(car.Mark) > maxFieldLength:
+		return fmt.Errorf("mark is longer than %d characters", maxFieldLength)
+	case utf8.RuneCountInString(car.Model) > maxFieldLength:
+		return fmt.Errorf("model is longer than %d characters", maxFieldLength)
+	case car.Year < minCarYear || car.Year > time.Now().Year()+1:
+		return fmt.Errorf("year %d is out of range", car.Year)
+	}
+
+	if err := regnum.Validate(car.RegNum); err != nil {
+		return fmt.Errorf("%w %q", err, car.RegNum)
+	}
+	if car.Vin != "" {
+		if err := vin.Validate(car.Vin); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{car.Owner.Name, car.Owner.Surname, car.Owner.Patronymic} {
+		if utf8.RuneCountInString(name) > maxFieldLength {
+			return fmt.Errorf("owner name is longer than %d characters", maxFieldLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package service_test
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/service"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func importRow(line int, mark, model string, year int, regNum, vin string) dto.ImportRow {
+	return dto.ImportRow{Line: line, Car: dto.ImportCarDto{Mark: mark, Model: model, Year: year, RegNum: regNum, Vin: vin}}
+}
+
+func TestImportCars(t *testing.T) {
+	tests := []struct {
+		name string
+		// existingVin is the VIN of the car already in the catalog,
+		// A000AA77.
+		existingVin string
+		rows        []dto.ImportRow
+		dryRun      bool
+		want        dto.ImportReport
+		wantErrors  []string
+		// stored lists the imported plates found in the catalog afterwards.
+		stored []string
+	}{
+		{
+			name: "valid rows are normalized and imported",
+			rows: []dto.ImportRow{
+				importRow(2, "Lada", "Vesta", 2020, "а 123 вс 77", ""),
+				importRow(3, "Tesla", "Model 3", 2018, "B456CE77", "5yj3e1ea2jf000316"),
+			},
+			want:   dto.ImportReport{Total: 2, Valid: 2, Imported: 2},
+			stored: []string{"A123BC77", "B456CE77"},
+		},
+		{
+			name: "dry run writes nothing",
+			rows: []dto.ImportRow{
+				importRow(2, "Lada", "Vesta", 2020, "A123BC77", ""),
+			},
+			dryRun: true,
+			want:   dto.ImportReport{DryRun: true, Total: 1, Valid: 1},
+		},
+		{
+			name: "invalid rows",
+			rows: []dto.ImportRow{
+				{Line: 2, Error: `invalid year "twenty"`},
+				importRow(3, "", "Vesta", 2020, "A123BC77", ""),
+				importRow(4, "Lada", "", 2020, "A123BC77", ""),
+				importRow(5, strings.Repeat("x", 101), "Vesta", 2020, "A123BC77", ""),
+				importRow(6, "Lada", "Vesta", 1700, "A123BC77", ""),
+				importRow(7, "Lada", "Vesta", 2020, "123", ""),
+				importRow(8, "Lada", "Vesta", 2020, "A123BC77", "not-a-vin"),
+				importRow(9, "Lada", "Vesta", 2020, "A123BC77", "5YJ3E1EA2JF000316"),
+			},
+			want: dto.ImportReport{Total: 8, Rejected: 8},
+			wantErrors: []string{
+				`2: invalid year "twenty"`,
+				"3: mark is required",
+				"4: model is required",
+				"5: mark is longer than 100 characters",
+				"6: year 1700 is out of range",
+				"7: invalid registration number",
+				"8: ",
+				"9: ",
+			},
+		},
+		{
+			name: "duplicates within the file",
+			rows: []dto.ImportRow{
+				importRow(2, "Lada", "Vesta", 2020, "A123BC77", ""),
+				importRow(3, "Lada", "Niva", 2021, "a 123 bc 77", ""),
+				importRow(4, "Tesla", "Model 3", 2018, "B456CE77", "5YJ3E1EA2JF000316"),
+				importRow(5, "Tesla", "Model 3", 2018, "C789EE77", "5YJ3E1EA2JF000316"),
+			},
+			want: dto.ImportReport{Total: 4, Valid: 2, Imported: 2, Rejected: 2},
+			wantErrors: []string{
+				"3: duplicate of line 2",
+				"5: VIN duplicates line 4",
+			},
+			stored: []string{"A123BC77", "B456CE77"},
+		},
+		{
+			name:        "duplicates of existing cars",
+			existingVin: "5YJ3E1EA2JF000316",
+			rows: []dto.ImportRow{
+				importRow(2, "Lada", "Vesta", 2020, "A000AA77", ""),
+				importRow(3, "Tesla", "Model 3", 2018, "B456CE77", "5YJ3E1EA2JF000316"),
+				importRow(4, "Lada", "Niva", 2021, "C789EE77", ""),
+			},
+			want: dto.ImportReport{Total: 3, Valid: 1, Imported: 1, Rejected: 2},
+			wantErrors: []string{
+				"2: registration number already exists",
+				"3: VIN already exists",
+			},
+			stored: []string{"C789EE77"},
+		},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		repo := repository.NewMemoryCarRepository()
+		existing := model.Car{Mark: "Tesla", Model: "Model 3", Year: 2018, RegNum: "A000AA77", Vin: tt.existingVin}
+		if err := repo.AddCars(ctx, []model.Car{existing}); err != nil {
+			t.Fatal(err)
+		}
+		cars := service.NewCarService(repo, nil, carinfo.NewChain())
+
+		report, err := cars.ImportCars(ctx, tt.rows, tt.dryRun)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		var gotErrors []string
+		for i, rowErr := range report.Errors {
+			got := fmt.Sprintf("%d: %s", rowErr.Line, rowErr.Error)
+			gotErrors = append(gotErrors, got)
+			if i >= len(tt.wantErrors) || !strings.HasPrefix(got, tt.wantErrors[i]) {
+				t.Errorf("%s: error %d = %q, want %q", tt.name, i, got, tt.wantErrors)
+			}
+		}
+		if len(gotErrors) != len(tt.wantErrors) {
+			t.Errorf("%s: errors = %q, want %q", tt.name, gotErrors, tt.wantErrors)
+		}
+		report.Errors = nil
+		if !reflect.DeepEqual(report, tt.want) {
+			t.Errorf("%s: report = %+v, want %+v", tt.name, report, tt.want)
+		}
+
+		stored, err := repo.FindExistingRegNums(ctx, []string{"A123BC77", "B456CE77", "C789EE77"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(stored)
+		if strings.Join(stored, " ") != strings.Join(tt.stored, " ") {
+			t.Errorf("%s: stored %v, want %v", tt.name, stored, tt.stored)
+		}
+	}
+}
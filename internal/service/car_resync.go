@@ -0,0 +1,244 @@
+package service
+
+import (
+	"car_catalog/internal/carinfo"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/vin"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ResyncService compares catalog entries with the registry and applies what
+// changed there.
+type ResyncService interface {
+	ResyncCar(ctx context.Context, carId string) (dto.ResyncResult, error)
+	// ResyncStale resyncs up to limit cars not synced within staleAfter.
+	ResyncStale(ctx context.Context, staleAfter time.Duration, limit int) (dto.ResyncSummary, error)
+	GetDivergences(ctx context.Context, carId string, limit int) ([]dto.DivergenceDto, error)
+}
+
+type ResyncServiceImpl struct {
+	CarService CarService
+	CarRepo    repository.CarRepository
+	CarInfo    carinfo.CarInfoProvider
+}
+
+func NewResyncService(carService CarService, carRepo repository.CarRepository, carInfo carinfo.CarInfoProvider) ResyncService {
+	return &ResyncServiceImpl{
+		CarService: carService,
+		CarRepo:    carRepo,
+		CarInfo:    carInfo,
+	}
+}
+
+func (s *ResyncServiceImpl) ResyncCar(ctx context.Context, carId string) (dto.ResyncResult, error) {
+	carID, err := strconv.Atoi(carId)
+	if err != nil {
+		log.Printf("[ERROR] Service - ResyncCar - Unable to parse car id, error: %v", err)
+		return dto.ResyncResult{}, err
+	}
+
+	car, err := s.CarRepo.GetCarById(ctx, carID)
+	if err != nil {
+		log.Printf("[ERROR] Service - ResyncCar - Error getting car with id %d: %v", carID, err)
+		return dto.ResyncResult{}, err
+	}
+	return s.resync(ctx, car)
+}
+
+func (s *ResyncServiceImpl) ResyncStale(ctx context.Context, staleAfter time.Duration, limit int) (dto.ResyncSummary, error) {
+	cars, err := s.CarRepo.ListStaleCars(ctx, time.Now().Add(-staleAfter), limit)
+	if err != nil {
+		log.Printf("[ERROR] Service - ResyncStale - Error listing stale cars: %v", err)
+		return dto.ResyncSummary{}, err
+	}
+
+	var summary dto.ResyncSummary
+	for _, car := range cars {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		summary.Checked++
+
+		result, err := s.resync(ctx, car)
+		switch {
+		case err != nil:
+			log.Printf("[ERROR] Service - ResyncStale - Car %d: %v", car.CarId, err)
+			summary.Failed++
+		case result.NotFound:
+			summary.NotFound++
+		case len(result.Divergences) > 0 && result.Divergences[0].Status == model.DivergenceFailed:
+			summary.Failed++
+		case len(result.Divergences) > 0:
+			summary.Updated++
+		default:
+			summary.Unchanged++
+		}
+	}
+
+	log.Printf("[INFO] Service - ResyncStale - %+v", summary)
+	return summary, nil
+}
+
+// resync looks the car up, applies the registry values with UpdateCar
+// semantics (empty registry values never overwrite local data) and records
+// every divergence with whether it was applied. A plate the registry no
+// longer knows is recorded but left as is. Lookup failures leave the car
+// unsynced, so it is retried next time.
+func (s *ResyncServiceImpl) resync(ctx context.Context, car model.Car) (dto.ResyncResult, error) {
+	registry, err := s.CarInfo.Lookup(ctx, car.RegNum)
+	notFound := errors.Is(err, carinfo.ErrNotFound)
+	if err != nil && !notFound {
+		return dto.ResyncResult{}, err
+	}
+
+	now := time.Now()
+	var divergences []model.Divergence
+	if notFound {
+		divergences = append(divergences, model.Divergence{
+			CarId: car.CarId, Field: "registry", LocalValue: car.RegNum, RegistryValue: "not found", DetectedAt: now,
+		})
+	} else {
+		// The registry spells marks its own way; compare canonical values so
+		// "MERCEDES-BENZ" against "Mercedes-Benz" is not a divergence.
+		if registry.Mark, registry.Model, err = s.CarService.Canonicalize(ctx, registry.Mark, registry.Model); err != nil {
+			return dto.ResyncResult{}, err
+		}
+		// A VIN the registry got wrong would fail the update on every run.
+		registry.Vin = vin.Normalize(registry.Vin)
+		if err := vin.Validate(registry.Vin); registry.Vin != "" && err != nil {
+			log.Printf("[INFO] Service - Resync - Ignoring registry VIN of car %d: %v", car.CarId, err)
+			registry.Vin = ""
+		}
+		divergences = diffWithRegistry(car, registry, now)
+	}
+
+	// The update goes first so every divergence is recorded once, with its
+	// outcome, and the car is marked synced either way: registry values the
+	// catalog refuses are not tried again on every run.
+	status, applyErr := model.DivergenceSkipped, ""
+	if len(divergences) > 0 && !notFound {
+		status = model.DivergenceApplied
+		if err := s.CarService.UpdateCar(ctx, strconv.Itoa(car.CarId), updateFromDivergences(divergences)); err != nil {
+			if ctx.Err() != nil {
+				return dto.ResyncResult{}, ctx.Err()
+			}
+			log.Printf("[INFO] Service - Resync - Registry values of car %d not applied: %v", car.CarId, err)
+			status, applyErr = model.DivergenceFailed, err.Error()
+		}
+	}
+	for i := range divergences {
+		divergences[i].Status, divergences[i].Error = status, applyErr
+	}
+
+	if len(divergences) > 0 {
+		if err := s.CarRepo.AddDivergences(ctx, divergences); err != nil {
+			log.Printf("[ERROR] Service - Resync - Error recording divergences of car %d: %v", car.CarId, err)
+			return dto.ResyncResult{}, err
+		}
+	}
+
+	if err := s.CarRepo.MarkSynced(ctx, car.CarId, now); err != nil {
+		log.Printf("[ERROR] Service - Resync - Error marking car %d synced: %v", car.CarId, err)
+		return dto.ResyncResult{}, err
+	}
+
+	log.Printf("[INFO] Service - Resync - Car %d synced, %d divergences", car.CarId, len(divergences))
+	return dto.ResyncResult{
+		CarId:       car.CarId,
+		SyncedAt:    now,
+		NotFound:    notFound,
+		Divergences: toDivergenceDtos(divergences),
+	}, nil
+}
+
+func (s *ResyncServiceImpl) GetDivergences(ctx context.Context, carId string, limit int) ([]dto.DivergenceDto, error) {
+	carID := 0
+	if carId != "" {
+		var err error
+		if carID, err = strconv.Atoi(carId); err != nil {
+			log.Printf("[ERROR] Service - GetDivergences - Unable to parse car id, error: %v", err)
+			return nil, err
+		}
+	}
+
+	divergences, err := s.CarRepo.ListDivergences(ctx, carID, limit)
+	if err != nil {
+		log.Printf("[ERROR] Service - GetDivergences - Error listing divergences: %v", err)
+		return nil, err
+	}
+	return toDivergenceDtos(divergences), nil
+}
+
+func diffWithRegistry(car model.Car, registry dto.AddCarsDto, now time.Time) []model.Divergence {
+	var divergences []model.Divergence
+	compare := func(field, local, remote string) {
+		if remote != "" && remote != local {
+			divergences = append(divergences, model.Divergence{
+				CarId: car.CarId, Field: field, LocalValue: local, RegistryValue: remote, DetectedAt: now,
+			})
+		}
+	}
+
+	compare("mark", car.Mark, registry.Mark)
+	compare("model", car.Model, registry.Model)
+	if registry.Year != 0 {
+		compare("year", strconv.Itoa(car.Year), strconv.Itoa(registry.Year))
+	}
+	compare("vin", car.Vin, registry.Vin)
+	compare("owner_name", car.OwnerName, registry.Owner.Name)
+	compare("owner_surname", car.OwnerSurname, registry.Owner.Surname)
+	compare("owner_patronymic", car.OwnerPatronymic, registry.Owner.Patronymic)
+	return divergences
+}
+
+func updateFromDivergences(divergences []model.Divergence) dto.UpdateCarDto {
+	var (
+		update dto.UpdateCarDto
+		owner  dto.People
+	)
+	for _, d := range divergences {
+		switch d.Field {
+		case "mark":
+			update.Mark = d.RegistryValue
+		case "model":
+			update.Model = d.RegistryValue
+		case "year":
+			update.Year = d.RegistryValue
+		case "vin":
+			update.Vin = d.RegistryValue
+		case "owner_name":
+			owner.Name = d.RegistryValue
+		case "owner_surname":
+			owner.Surname = d.RegistryValue
+		case "owner_patronymic":
+			owner.Patronymic = d.RegistryValue
+		}
+	}
+	if owner != (dto.People{}) {
+		update.Owner = &owner
+	}
+	return update
+}
+
+func toDivergenceDtos(divergences []model.Divergence) []dto.DivergenceDto {
+	result := make([]dto.DivergenceDto, 0, len(divergences))
+	for _, d := range divergences {
+		result = append(result, dto.DivergenceDto{
+			Id:            d.Id,
+			CarId:         d.CarId,
+			Field:         d.Field,
+			LocalValue:    d.LocalValue,
+			RegistryValue: d.RegistryValue,
+			DetectedAt:    d.DetectedAt,
+			Status:        d.Status,
+			Error:         d.Error,
+		})
+	}
+	return result
+}
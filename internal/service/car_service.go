@@ -7,7 +7,28 @@ import (
 
 type CarService interface {
 	GetFilteredCars(ctx context.Context, filters dto.Filters, cursors dto.Cursors) ([]dto.GetFilteredCarsDto, dto.Cursors, error)
+	// GetCar returns one car; Owner is set only when includeOwner is.
+	GetCar(ctx context.Context, carId string, includeOwner bool) (dto.ExportCarDto, error)
+	// GetCars returns the cars with the given ids in id order, skipping the
+	// missing ones, in a single repository call.
+	GetCars(ctx context.Context, carIds []int, includeOwner bool) ([]dto.ExportCarDto, error)
 	DeleteCar(ctx context.Context, carId string) error
 	UpdateCar(ctx context.Context, carId string, car dto.UpdateCarDto) error
 	AddCars(ctx context.Context, cars []dto.AddCarsDto) error
+	// AddCarsByRegNum looks the registration numbers up in the registry and
+	// adds the cars found in one batch. Numbers the registry does not know
+	// are skipped and reported; any other lookup error adds nothing.
+	AddCarsByRegNum(ctx context.Context, regNums []string) (dto.AddCarsResult, error)
+	ImportCars(ctx context.Context, rows []dto.ImportRow, dryRun bool) (dto.ImportReport, error)
+	ExportCars(ctx context.Context, filters dto.Filters, includeOwner bool, fn func(dto.ExportCarDto) error) error
+	// BatchUpdateCars and BatchDeleteCars refuse to touch more than maxItems
+	// cars; zero means no limit.
+	BatchUpdateCars(ctx context.Context, req dto.BatchUpdateRequest, maxItems int) (dto.BatchResult, error)
+	BatchDeleteCars(ctx context.Context, req dto.BatchDeleteRequest, maxItems int) (dto.BatchResult, error)
+	SuggestMarks(ctx context.Context, prefix string, limit int) ([]dto.SuggestionDto, error)
+	SuggestModels(ctx context.Context, mark, prefix string, limit int) ([]dto.SuggestionDto, error)
+	SuggestRegNums(ctx context.Context, prefix string, limit int) ([]dto.SuggestionDto, error)
+	// Canonicalize maps a mark and model onto the dictionary spelling; unknown
+	// values are returned as given.
+	Canonicalize(ctx context.Context, mark, carModel string) (string, string, error)
 }
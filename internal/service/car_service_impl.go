@@ -1,25 +1,66 @@
 package service
 
 import (
+	"car_catalog/internal/carinfo"
 	"car_catalog/internal/dto"
 	"car_catalog/internal/model"
 	"car_catalog/internal/repository"
+	"car_catalog/internal/search"
+	"car_catalog/internal/vin"
 	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"strconv"
+	"time"
+	"unicode/utf8"
 )
 
 type CarServiceImpl struct {
 	CarRepo repository.CarRepository
+	// Dictionary canonicalizes marks and models on write; nil stores them
+	// as given.
+	Dictionary repository.DictionaryRepository
+	// CarInfo looks registration numbers up for AddCarsByRegNum.
+	CarInfo carinfo.CarInfoProvider
 }
 
-func NewCarService(carRepo repository.CarRepository) CarService {
-	return &CarServiceImpl{CarRepo: carRepo}
+func NewCarService(carRepo repository.CarRepository, dictionary repository.DictionaryRepository, carInfo carinfo.CarInfoProvider) CarService {
+	return &CarServiceImpl{CarRepo: carRepo, Dictionary: dictionary, CarInfo: carInfo}
+}
+
+func (c *CarServiceImpl) AddCarsByRegNum(ctx context.Context, regNums []string) (dto.AddCarsResult, error) {
+	result := dto.AddCarsResult{NotFound: []string{}}
+	cars := []dto.AddCarsDto{}
+	for _, regNum := range regNums {
+		car, err := c.CarInfo.Lookup(ctx, regNum)
+		if errors.Is(err, carinfo.ErrNotFound) {
+			log.Printf("[INFO] Service - AddCarsByRegNum - No car information for %s, skipped: %v", regNum, err)
+			result.NotFound = append(result.NotFound, regNum)
+			continue
+		}
+		if err != nil {
+			log.Printf("[ERROR] Service - AddCarsByRegNum - Lookup of %s failed: %v", regNum, err)
+			return dto.AddCarsResult{}, err
+		}
+		cars = append(cars, car)
+	}
+
+	if len(cars) > 0 {
+		if err := c.AddCars(ctx, cars); err != nil {
+			return dto.AddCarsResult{}, err
+		}
+	}
+	result.Added = len(cars)
+	return result, nil
 }
 
 func (c *CarServiceImpl) AddCars(ctx context.Context, cars []dto.AddCarsDto) error {
 	var carsToAdd []model.Car
+	// The data has just come from the registry.
+	syncedAt := time.Now()
+	resolver := newCanonicalizer(c.Dictionary)
 
 	for _, car := range cars {
 		log.Printf("[DEBUG] Service - AddCars - Adding car: %+v", car)
@@ -28,9 +69,19 @@ func (c *CarServiceImpl) AddCars(ctx context.Context, cars []dto.AddCarsDto) err
 			Model:           car.Model,
 			Year:            car.Year,
 			RegNum:          car.RegNum,
+			Vin:             car.Vin,
 			OwnerName:       car.Owner.Name,
 			OwnerSurname:    car.Owner.Surname,
 			OwnerPatronymic: car.Owner.Patronymic,
+			LastSyncedAt:    &syncedAt,
+		}
+		if err := resolver.canonicalizeCar(ctx, &carToAdd); err != nil {
+			log.Printf("[ERROR] Service - AddCars - Error canonicalizing car: %v", err)
+			return err
+		}
+		if err := checkVin(ctx, resolver, &carToAdd); err != nil {
+			log.Printf("[ERROR] Service - AddCars - Rejected VIN of %s: %v", carToAdd.RegNum, err)
+			return err
 		}
 		carsToAdd = append(carsToAdd, carToAdd)
 	}
@@ -44,6 +95,36 @@ func (c *CarServiceImpl) AddCars(ctx context.Context, cars []dto.AddCarsDto) err
 	return nil
 }
 
+func (c *CarServiceImpl) GetCar(ctx context.Context, carId string, includeOwner bool) (dto.ExportCarDto, error) {
+	carID, err := strconv.Atoi(carId)
+	if err != nil {
+		log.Printf("[ERROR] Service - GetCar - Unable to parse car id, error: %v", err)
+		return dto.ExportCarDto{}, err
+	}
+
+	car, err := c.CarRepo.GetCarById(ctx, carID)
+	if err != nil {
+		log.Printf("[ERROR] Service - GetCar - Error getting car with id %s: %v", carId, err)
+		return dto.ExportCarDto{}, err
+	}
+	return toExportCarDto(car, includeOwner), nil
+}
+
+func (c *CarServiceImpl) GetCars(ctx context.Context, carIds []int, includeOwner bool) ([]dto.ExportCarDto, error) {
+	log.Printf("[DEBUG] Service - GetCars - Car IDs: %v", carIds)
+
+	cars, err := c.CarRepo.GetCarsByIds(ctx, carIds)
+	if err != nil {
+		log.Printf("[ERROR] Service - GetCars - Error getting cars: %v", err)
+		return nil, err
+	}
+	exportCars := make([]dto.ExportCarDto, len(cars))
+	for i, car := range cars {
+		exportCars[i] = toExportCarDto(car, includeOwner)
+	}
+	return exportCars, nil
+}
+
 func (c *CarServiceImpl) DeleteCar(ctx context.Context, carId string) error {
 	carID, err := strconv.Atoi(carId)
 	if err != nil {
@@ -85,8 +166,12 @@ func DecodeCursor(cursors *dto.Cursors) error {
 }
 
 func EncodeCursor(cursors *dto.Cursors) {
-	(*cursors).Next = base64.StdEncoding.EncodeToString([]byte(cursors.Next))
-	(*cursors).Prev = base64.StdEncoding.EncodeToString([]byte(cursors.Prev))
+	(*cursors).Next = encodeCursor(cursors.Next)
+	(*cursors).Prev = encodeCursor(cursors.Prev)
+}
+
+func encodeCursor(cursor string) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursor))
 }
 
 func (c *CarServiceImpl) GetFilteredCars(ctx context.Context, filters dto.Filters, cursors dto.Cursors) ([]dto.GetFilteredCarsDto, dto.Cursors, error) {
@@ -101,6 +186,17 @@ func (c *CarServiceImpl) GetFilteredCars(ctx context.Context, filters dto.Filter
 		return []dto.GetFilteredCarsDto{}, dto.Cursors{}, err
 	}
 
+	if filters.Vin != "" {
+		if filters.Query != "" {
+			log.Println("[ERROR] Service - GetFilteredCars - Both vin and q are set")
+			return []dto.GetFilteredCarsDto{}, dto.Cursors{}, fmt.Errorf("vin and q cannot be combined")
+		}
+		return c.getCarByVin(ctx, filters)
+	}
+	if filters.Query != "" {
+		return c.searchCars(ctx, filters, limit, cursors)
+	}
+
 	carsToFilter, cursors, err := c.CarRepo.GetCars(ctx, limit, filters.Mark, filters.Model, filters.Year, cursors)
 	if err != nil {
 		log.Printf("[ERROR] Service - GetFilteredCars - Error getting all cars: %v", err)
@@ -111,10 +207,12 @@ func (c *CarServiceImpl) GetFilteredCars(ctx context.Context, filters dto.Filter
 	for _, car := range carsToFilter {
 		log.Printf("[DEBUG] Service - GetFilteredCars - Adding car: %+v", car)
 		filteredCar := dto.GetFilteredCarsDto{
-			CarId: car.CarId,
-			Mark:  car.Mark,
-			Model: car.Model,
-			Year:  strconv.Itoa(car.Year),
+			CarId:  car.CarId,
+			Mark:   car.Mark,
+			Model:  car.Model,
+			Year:   strconv.Itoa(car.Year),
+			Vin:    car.Vin,
+			Cursor: encodeCursor(repository.CarCursor(car)),
 		}
 		filteredCars = append(filteredCars, filteredCar)
 	}
@@ -125,6 +223,75 @@ func (c *CarServiceImpl) GetFilteredCars(ctx context.Context, filters dto.Filter
 	return filteredCars, cursors, nil
 }
 
+// searchCars serves GetFilteredCars when q is set. Cursors have been decoded
+// already.
+func (c *CarServiceImpl) searchCars(ctx context.Context, filters dto.Filters, limit int, cursors dto.Cursors) ([]dto.GetFilteredCarsDto, dto.Cursors, error) {
+	if n := utf8.RuneCountInString(filters.Query); n > search.MaxQueryLength {
+		log.Printf("[ERROR] Service - GetFilteredCars - Search query is %d characters long", n)
+		return []dto.GetFilteredCarsDto{}, dto.Cursors{}, fmt.Errorf("search query must be at most %d characters", search.MaxQueryLength)
+	}
+	variants := search.Variants(filters.Query)
+	log.Printf("[DEBUG] Service - GetFilteredCars - Search variants: %q", variants)
+
+	matches, cursors, err := c.CarRepo.SearchCars(ctx, variants, limit, filters.Mark, filters.Model, filters.Year, cursors)
+	if err != nil {
+		log.Printf("[ERROR] Service - GetFilteredCars - Error searching cars: %v", err)
+		return []dto.GetFilteredCarsDto{}, dto.Cursors{}, err
+	}
+
+	var found []dto.GetFilteredCarsDto
+	for _, match := range matches {
+		found = append(found, dto.GetFilteredCarsDto{
+			CarId:  match.CarId,
+			Mark:   match.Mark,
+			Model:  match.Model,
+			Year:   strconv.Itoa(match.Year),
+			Vin:    match.Vin,
+			Score:  match.Score,
+			Cursor: encodeCursor(repository.SearchCursor(match)),
+		})
+	}
+
+	EncodeCursor(&cursors)
+
+	log.Printf("[INFO] Service - GetFilteredCars - Found %d cars for %q", len(found), filters.Query)
+	return found, cursors, nil
+}
+
+// getCarByVin serves GetFilteredCars when vin is set: at most one car, no
+// cursors. The other filters narrow it down like they do a listing.
+func (c *CarServiceImpl) getCarByVin(ctx context.Context, filters dto.Filters) ([]dto.GetFilteredCarsDto, dto.Cursors, error) {
+	number := vin.Normalize(filters.Vin)
+	if err := vin.Validate(number); err != nil {
+		log.Printf("[ERROR] Service - GetFilteredCars - Invalid VIN filter: %v", err)
+		return []dto.GetFilteredCarsDto{}, dto.Cursors{}, err
+	}
+
+	car, err := c.CarRepo.GetCarByVin(ctx, number)
+	if errors.Is(err, repository.ErrCarNotFound) {
+		return nil, dto.Cursors{}, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Service - GetFilteredCars - Error getting car by VIN: %v", err)
+		return []dto.GetFilteredCarsDto{}, dto.Cursors{}, err
+	}
+	if (filters.Mark != "" && car.Mark != filters.Mark) ||
+		(filters.Model != "" && car.Model != filters.Model) ||
+		(filters.Year != "" && strconv.Itoa(car.Year) != filters.Year) {
+		return nil, dto.Cursors{}, nil
+	}
+
+	log.Printf("[INFO] Service - GetFilteredCars - Found car %d by VIN", car.CarId)
+	return []dto.GetFilteredCarsDto{{
+		CarId:  car.CarId,
+		Mark:   car.Mark,
+		Model:  car.Model,
+		Year:   strconv.Itoa(car.Year),
+		Vin:    car.Vin,
+		Cursor: encodeCursor(repository.CarCursor(car)),
+	}}, dto.Cursors{}, nil
+}
+
 func (c *CarServiceImpl) UpdateCar(ctx context.Context, carId string, car dto.UpdateCarDto) error {
 	carID, err := strconv.Atoi(carId)
 	if err != nil {
@@ -139,26 +306,21 @@ func (c *CarServiceImpl) UpdateCar(ctx context.Context, carId string, car dto.Up
 		return err
 	}
 
-	if car.Mark != "" {
-		log.Printf("[DEBUG] Service - UpdateCar - Updating car mark to: %s", car.Mark)
-		carToUpdate.Mark = car.Mark
-	}
-	if car.Model != "" {
-		log.Printf("[DEBUG] Service - UpdateCar - Updating car model to: %s", car.Model)
-		carToUpdate.Model = car.Model
+	if err := applyUpdate(&carToUpdate, car); err != nil {
+		log.Printf("[ERROR] Service - UpdateCar - Unable to parse car year, error: %v", err)
 	}
-	if car.Year != "" {
-		log.Printf("[DEBUG] Service - UpdateCar - Updating car year to: %s", car.Year)
-		year, err := strconv.Atoi(car.Year)
-		if err != nil {
-			log.Printf("[ERROR] Service - UpdateCar - Unable to parse car year, error: %v", err)
-		} else {
-			carToUpdate.Year = year
+	resolver := newCanonicalizer(c.Dictionary)
+	if car.Mark != "" || car.Model != "" {
+		if err := resolver.canonicalizeCar(ctx, &carToUpdate); err != nil {
+			log.Printf("[ERROR] Service - UpdateCar - Error canonicalizing car: %v", err)
+			return err
 		}
 	}
-	if car.RegNum != "" {
-		log.Printf("[DEBUG] Service - UpdateCar - Updating car regNum to: %s", car.RegNum)
-		carToUpdate.RegNum = car.RegNum
+	if vinAffected(car) {
+		if err := checkVin(ctx, resolver, &carToUpdate); err != nil {
+			log.Printf("[ERROR] Service - UpdateCar - Rejected VIN: %v", err)
+			return err
+		}
 	}
 
 	if err := c.CarRepo.UpdateCar(ctx, carToUpdate); err != nil {
@@ -169,3 +331,88 @@ func (c *CarServiceImpl) UpdateCar(ctx context.Context, carId string, car dto.Up
 	log.Println("[INFO] Service - UpdateCar - Car updated successfully")
 	return nil
 }
+
+func (c *CarServiceImpl) ExportCars(ctx context.Context, filters dto.Filters, includeOwner bool, fn func(dto.ExportCarDto) error) error {
+	if filters.Year != "" {
+		if _, err := strconv.Atoi(filters.Year); err != nil {
+			log.Printf("[ERROR] Service - ExportCars - Unable to parse year filter, error: %v", err)
+			return err
+		}
+	}
+
+	exported := 0
+	err := c.CarRepo.StreamCars(ctx, filters.Mark, filters.Model, filters.Year, func(car model.Car) error {
+		exported++
+		return fn(toExportCarDto(car, includeOwner))
+	})
+	if err != nil {
+		log.Printf("[ERROR] Service - ExportCars - Error streaming cars: %v", err)
+		return err
+	}
+
+	log.Printf("[INFO] Service - ExportCars - Exported %d cars", exported)
+	return nil
+}
+
+func toExportCarDto(car model.Car, includeOwner bool) dto.ExportCarDto {
+	exportCar := dto.ExportCarDto{
+		CarId:  car.CarId,
+		Mark:   car.Mark,
+		Model:  car.Model,
+		Year:   car.Year,
+		RegNum: car.RegNum,
+		Vin:    car.Vin,
+	}
+	if includeOwner {
+		exportCar.Owner = &dto.People{
+			Name:       car.OwnerName,
+			Surname:    car.OwnerSurname,
+			Patronymic: car.OwnerPatronymic,
+		}
+	}
+	return exportCar
+}
+
+// applyUpdate merges changes into car: empty values are left unchanged. An
+// unparsable year is skipped and reported after the other fields are applied.
+func applyUpdate(car *model.Car, changes dto.UpdateCarDto) error {
+	var yearErr error
+	if changes.Mark != "" {
+		log.Printf("[DEBUG] Service - applyUpdate - Updating car mark to: %s", changes.Mark)
+		car.Mark = changes.Mark
+	}
+	if changes.Model != "" {
+		log.Printf("[DEBUG] Service - applyUpdate - Updating car model to: %s", changes.Model)
+		car.Model = changes.Model
+	}
+	if changes.Year != "" {
+		log.Printf("[DEBUG] Service - applyUpdate - Updating car year to: %s", changes.Year)
+		year, err := strconv.Atoi(changes.Year)
+		if err != nil {
+			yearErr = err
+		} else {
+			car.Year = year
+		}
+	}
+	if changes.RegNum != "" {
+		log.Printf("[DEBUG] Service - applyUpdate - Updating car regNum to: %s", changes.RegNum)
+		car.RegNum = changes.RegNum
+	}
+	if changes.Vin != "" {
+		log.Printf("[DEBUG] Service - applyUpdate - Updating car VIN to: %s", changes.Vin)
+		car.Vin = changes.Vin
+	}
+	if changes.Owner != nil {
+		log.Printf("[DEBUG] Service - applyUpdate - Updating car owner to: %+v", *changes.Owner)
+		if changes.Owner.Name != "" {
+			car.OwnerName = changes.Owner.Name
+		}
+		if changes.Owner.Surname != "" {
+			car.OwnerSurname = changes.Owner.Surname
+		}
+		if changes.Owner.Patronymic != "" {
+			car.OwnerPatronymic = changes.Owner.Patronymic
+		}
+	}
+	return yearErr
+}
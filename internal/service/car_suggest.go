@@ -0,0 +1,100 @@
+package service
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/regnum"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxSuggestPrefix = 50
+	// minRegNumPrefix keeps the plate suggestions from listing the whole
+	// catalog.
+	minRegNumPrefix = 2
+	// minNamePrefix keeps the mark and model suggestions from grouping the
+	// whole catalog; models of one mark need no prefix.
+	minNamePrefix = 1
+	// adminRole sees the cars and changes of every API key.
+	adminRole = "admin"
+)
+
+var ErrInvalidSuggest = errors.New("invalid suggest request")
+
+func (c *CarServiceImpl) SuggestMarks(ctx context.Context, prefix string, limit int) ([]dto.SuggestionDto, error) {
+	prefix, err := suggestPrefix(prefix, minNamePrefix)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := c.CarRepo.SuggestMarks(ctx, suggestTenant(ctx), prefix, limit)
+	if err != nil {
+		log.Printf("[ERROR] Service - SuggestMarks - Error getting suggestions: %v", err)
+		return nil, err
+	}
+	return suggestionDtos(suggestions), nil
+}
+
+func (c *CarServiceImpl) SuggestModels(ctx context.Context, mark, prefix string, limit int) ([]dto.SuggestionDto, error) {
+	mark = strings.TrimSpace(mark)
+	minLength := minNamePrefix
+	if mark != "" {
+		minLength = 0
+	}
+	prefix, err := suggestPrefix(prefix, minLength)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := c.CarRepo.SuggestModels(ctx, suggestTenant(ctx), mark, prefix, limit)
+	if err != nil {
+		log.Printf("[ERROR] Service - SuggestModels - Error getting suggestions: %v", err)
+		return nil, err
+	}
+	return suggestionDtos(suggestions), nil
+}
+
+// SuggestRegNums normalizes the prefix the way stored plates are, so "а 12"
+// finds the plates starting with A12.
+func (c *CarServiceImpl) SuggestRegNums(ctx context.Context, prefix string, limit int) ([]dto.SuggestionDto, error) {
+	prefix, err := suggestPrefix(regnum.Normalize(prefix), minRegNumPrefix)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := c.CarRepo.SuggestRegNums(ctx, suggestTenant(ctx), prefix, limit)
+	if err != nil {
+		log.Printf("[ERROR] Service - SuggestRegNums - Error getting suggestions: %v", err)
+		return nil, err
+	}
+	return suggestionDtos(suggestions), nil
+}
+
+// suggestTenant limits suggestions to the cars added with the caller's API
+// key; admins and unauthenticated deployments see the whole catalog.
+func suggestTenant(ctx context.Context) *string {
+	identity, ok := auth.FromContext(ctx)
+	if !ok || identity.Role == adminRole {
+		return nil
+	}
+	return &identity.Name
+}
+
+func suggestPrefix(prefix string, minLength int) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if n := utf8.RuneCountInString(prefix); n < minLength || n > maxSuggestPrefix {
+		return "", fmt.Errorf("%w: prefix must be %d to %d characters long", ErrInvalidSuggest, minLength, maxSuggestPrefix)
+	}
+	return prefix, nil
+}
+
+func suggestionDtos(suggestions []model.Suggestion) []dto.SuggestionDto {
+	result := make([]dto.SuggestionDto, 0, len(suggestions))
+	for _, s := range suggestions {
+		result = append(result, dto.SuggestionDto{Value: s.Value, Count: s.Count})
+	}
+	return result
+}
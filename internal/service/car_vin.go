@@ -0,0 +1,59 @@
+package service
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/vin"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrVinMismatch means a valid VIN decodes to a different mark or model year
+// than the car it is given for.
+var ErrVinMismatch = errors.New("VIN does not match the car")
+
+// checkVin normalizes car.Vin and checks it against the car. An empty VIN is
+// fine. The decoded manufacturer goes through the dictionary like the mark
+// does, so "VW" in the catalog matches a Volkswagen WMI; a WMI missing from
+// the built-in table is not checked. The model year may be one ahead of the
+// year the car was built, so both are accepted.
+func checkVin(ctx context.Context, resolver *canonicalizer, car *model.Car) error {
+	if car.Vin == "" {
+		return nil
+	}
+	car.Vin = vin.Normalize(car.Vin)
+	if err := vin.Validate(car.Vin); err != nil {
+		return err
+	}
+
+	info := vin.Decode(car.Vin)
+	if info.Manufacturer != "" {
+		decoded, err := resolver.canonicalize(ctx, info.Manufacturer, "")
+		if err != nil {
+			return err
+		}
+		if DictionaryKey(decoded.Mark) != DictionaryKey(car.Mark) {
+			return fmt.Errorf("%w: %s is made by %s, not %s", ErrVinMismatch, car.Vin, decoded.Mark, car.Mark)
+		}
+	}
+	if car.Year != 0 && !vinYearMatches(info.Years, car.Year) {
+		return fmt.Errorf("%w: %s has model year %v, the car is from %d", ErrVinMismatch, car.Vin, info.Years, car.Year)
+	}
+	return nil
+}
+
+func vinYearMatches(modelYears []int, year int) bool {
+	for _, modelYear := range modelYears {
+		if year == modelYear || year == modelYear-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// vinAffected reports whether changes may break the match between a stored
+// VIN and the car.
+func vinAffected(changes dto.UpdateCarDto) bool {
+	return changes.Vin != "" || changes.Mark != "" || changes.Year != ""
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// mapBatchSize is how many rewritten cars MapCars saves per transaction.
+const mapBatchSize = 500
+
+// maxDictionaryNameLength caps names and aliases, in characters.
+const maxDictionaryNameLength = 100
+
+var ErrInvalidDictionaryEntry = errors.New("invalid dictionary entry")
+
+// DictionaryService curates the reference dictionary of marks and models and
+// maps the catalog onto it.
+type DictionaryService interface {
+	ListMakes(ctx context.Context) ([]dto.MakeDto, error)
+	AddMake(ctx context.Context, req dto.DictionaryEntryRequest) (dto.MakeDto, error)
+	DeleteMake(ctx context.Context, makeId string) error
+	AddMakeAlias(ctx context.Context, makeId, alias string) error
+	DeleteMakeAlias(ctx context.Context, makeId, alias string) error
+	AddModel(ctx context.Context, makeId string, req dto.DictionaryEntryRequest) (dto.ModelRefDto, error)
+	DeleteModel(ctx context.Context, modelId string) error
+	AddModelAlias(ctx context.Context, modelId, alias string) error
+	DeleteModelAlias(ctx context.Context, modelId, alias string) error
+	// MapCars rewrites every car to the canonical mark and model and reports
+	// the values the dictionary does not know. A dry run only reports.
+	MapCars(ctx context.Context, dryRun bool) (dto.DictionaryMapReport, error)
+}
+
+type DictionaryServiceImpl struct {
+	Dictionary repository.DictionaryRepository
+	CarRepo    repository.CarRepository
+}
+
+func NewDictionaryService(dictionary repository.DictionaryRepository, carRepo repository.CarRepository) DictionaryService {
+	return &DictionaryServiceImpl{
+		Dictionary: dictionary,
+		CarRepo:    carRepo,
+	}
+}
+
+// DictionaryKey is the normalized spelling aliases are stored and looked up
+// under: lower case, ё folded into е, letters and digits only. "Mercedes-Benz"
+// and "mercedes benz" share a key; "БМВ" and "BMW" do not and need an alias.
+func DictionaryKey(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == 'ё':
+			b.WriteRune('е')
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// canonicalizer resolves marks and models against the dictionary, caching
+// lookups for the duration of one operation.
+type canonicalizer struct {
+	dictionary repository.DictionaryRepository
+	makes      map[string]*model.Make
+	models     map[string]*model.ModelRef
+}
+
+func newCanonicalizer(dictionary repository.DictionaryRepository) *canonicalizer {
+	return &canonicalizer{
+		dictionary: dictionary,
+		makes:      make(map[string]*model.Make),
+		models:     make(map[string]*model.ModelRef),
+	}
+}
+
+// canonical is a mark and model after canonicalization. Unknown values are
+// kept as given.
+type canonical struct {
+	Mark       string
+	Model      string
+	MarkFound  bool
+	ModelFound bool
+}
+
+func (c *canonicalizer) canonicalize(ctx context.Context, mark, carModel string) (canonical, error) {
+	result := canonical{Mark: mark, Model: carModel}
+	if c == nil || c.dictionary == nil {
+		return result, nil
+	}
+
+	markKey := DictionaryKey(mark)
+	found, cached := c.makes[markKey]
+	if !cached {
+		m, err := c.dictionary.ResolveMake(ctx, markKey)
+		switch {
+		case err == nil:
+			found = &m
+		case !errors.Is(err, repository.ErrMakeNotFound):
+			return canonical{}, err
+		}
+		c.makes[markKey] = found
+	}
+	if found == nil {
+		return result, nil
+	}
+	result.Mark, result.MarkFound = found.Name, true
+
+	modelKey := DictionaryKey(carModel)
+	cacheKey := strconv.Itoa(found.Id) + "/" + modelKey
+	ref, cached := c.models[cacheKey]
+	if !cached {
+		m, err := c.dictionary.ResolveModel(ctx, found.Id, modelKey)
+		switch {
+		case err == nil:
+			ref = &m
+		case !errors.Is(err, repository.ErrModelNotFound):
+			return canonical{}, err
+		}
+		c.models[cacheKey] = ref
+	}
+	if ref != nil {
+		result.Model, result.ModelFound = ref.Name, true
+	}
+	return result, nil
+}
+
+// canonicalizeCar rewrites the mark and model of car in place; unknown values
+// are left alone.
+func (c *canonicalizer) canonicalizeCar(ctx context.Context, car *model.Car) error {
+	result, err := c.canonicalize(ctx, car.Mark, car.Model)
+	if err != nil {
+		return err
+	}
+	if result.Mark != car.Mark || result.Model != car.Model {
+		log.Printf("[DEBUG] Service - canonicalize - %q %q -> %q %q", car.Mark, car.Model, result.Mark, result.Model)
+	}
+	car.Mark, car.Model = result.Mark, result.Model
+	return nil
+}
+
+func (c *CarServiceImpl) Canonicalize(ctx context.Context, mark, carModel string) (string, string, error) {
+	result, err := newCanonicalizer(c.Dictionary).canonicalize(ctx, mark, carModel)
+	if err != nil {
+		log.Printf("[ERROR] Service - Canonicalize - Error resolving %q %q: %v", mark, carModel, err)
+		return "", "", err
+	}
+	return result.Mark, result.Model, nil
+}
+
+func (d *DictionaryServiceImpl) ListMakes(ctx context.Context) ([]dto.MakeDto, error) {
+	makes, err := d.Dictionary.ListMakes(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Service - ListMakes - Error listing makes: %v", err)
+		return nil, err
+	}
+	result := make([]dto.MakeDto, 0, len(makes))
+	for _, m := range makes {
+		result = append(result, toMakeDto(m))
+	}
+	return result, nil
+}
+
+func (d *DictionaryServiceImpl) AddMake(ctx context.Context, req dto.DictionaryEntryRequest) (dto.MakeDto, error) {
+	name, aliases, err := dictionaryEntry(req)
+	if err != nil {
+		return dto.MakeDto{}, err
+	}
+	created, err := d.Dictionary.AddMake(ctx, name, aliases)
+	if err != nil {
+		log.Printf("[ERROR] Service - AddMake - Error adding make %q: %v", name, err)
+		return dto.MakeDto{}, err
+	}
+	log.Printf("[INFO] Service - AddMake - Added make %q", name)
+	return toMakeDto(created), nil
+}
+
+func (d *DictionaryServiceImpl) DeleteMake(ctx context.Context, makeId string) error {
+	id, err := strconv.Atoi(makeId)
+	if err != nil {
+		return err
+	}
+	return d.Dictionary.DeleteMake(ctx, id)
+}
+
+func (d *DictionaryServiceImpl) AddMakeAlias(ctx context.Context, makeId, alias string) error {
+	id, err := strconv.Atoi(makeId)
+	if err != nil {
+		return err
+	}
+	key, err := aliasKey(alias)
+	if err != nil {
+		return err
+	}
+	return d.Dictionary.AddMakeAlias(ctx, id, key)
+}
+
+func (d *DictionaryServiceImpl) DeleteMakeAlias(ctx context.Context, makeId, alias string) error {
+	id, err := strconv.Atoi(makeId)
+	if err != nil {
+		return err
+	}
+	return d.Dictionary.DeleteMakeAlias(ctx, id, DictionaryKey(alias))
+}
+
+func (d *DictionaryServiceImpl) AddModel(ctx context.Context, makeId string, req dto.DictionaryEntryRequest) (dto.ModelRefDto, error) {
+	id, err := strconv.Atoi(makeId)
+	if err != nil {
+		return dto.ModelRefDto{}, err
+	}
+	name, aliases, err := dictionaryEntry(req)
+	if err != nil {
+		return dto.ModelRefDto{}, err
+	}
+	created, err := d.Dictionary.AddModel(ctx, id, name, aliases)
+	if err != nil {
+		log.Printf("[ERROR] Service - AddModel - Error adding model %q: %v", name, err)
+		return dto.ModelRefDto{}, err
+	}
+	log.Printf("[INFO] Service - AddModel - Added model %q to make %d", name, id)
+	return toModelRefDto(created), nil
+}
+
+func (d *DictionaryServiceImpl) DeleteModel(ctx context.Context, modelId string) error {
+	id, err := strconv.Atoi(modelId)
+	if err != nil {
+		return err
+	}
+	return d.Dictionary.DeleteModel(ctx, id)
+}
+
+func (d *DictionaryServiceImpl) AddModelAlias(ctx context.Context, modelId, alias string) error {
+	id, err := strconv.Atoi(modelId)
+	if err != nil {
+		return err
+	}
+	key, err := aliasKey(alias)
+	if err != nil {
+		return err
+	}
+	return d.Dictionary.AddModelAlias(ctx, id, key)
+}
+
+func (d *DictionaryServiceImpl) DeleteModelAlias(ctx context.Context, modelId, alias string) error {
+	id, err := strconv.Atoi(modelId)
+	if err != nil {
+		return err
+	}
+	return d.Dictionary.DeleteModelAlias(ctx, id, DictionaryKey(alias))
+}
+
+func (d *DictionaryServiceImpl) MapCars(ctx context.Context, dryRun bool) (dto.DictionaryMapReport, error) {
+	report := dto.DictionaryMapReport{DryRun: dryRun}
+	resolver := newCanonicalizer(d.Dictionary)
+	unmatchedMarks := make(map[string]int)
+	unmatchedModels := make(map[[2]string]int)
+
+	var changed []model.Car
+	err := d.CarRepo.StreamCars(ctx, "", "", "", func(car model.Car) error {
+		report.Scanned++
+		result, err := resolver.canonicalize(ctx, car.Mark, car.Model)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !result.MarkFound:
+			unmatchedMarks[car.Mark]++
+		case !result.ModelFound:
+			unmatchedModels[[2]string{result.Mark, car.Model}]++
+		}
+		if result.Mark != car.Mark || result.Model != car.Model {
+			car.Mark, car.Model = result.Mark, result.Model
+			changed = append(changed, car)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ERROR] Service - MapCars - Error reading the catalog: %v", err)
+		return dto.DictionaryMapReport{}, err
+	}
+	report.Changed = len(changed)
+
+	for mark, count := range unmatchedMarks {
+		report.UnmatchedMarks = append(report.UnmatchedMarks, dto.UnmatchedValue{Mark: mark, Count: count})
+	}
+	for key, count := range unmatchedModels {
+		report.UnmatchedModels = append(report.UnmatchedModels, dto.UnmatchedValue{Mark: key[0], Model: key[1], Count: count})
+	}
+	sortUnmatched(report.UnmatchedMarks)
+	sortUnmatched(report.UnmatchedModels)
+
+	if !dryRun {
+		for start := 0; start < len(changed); start += mapBatchSize {
+			batch := changed[start:min(start+mapBatchSize, len(changed))]
+			if err := d.CarRepo.UpdateCars(ctx, batch); err != nil {
+				log.Printf("[ERROR] Service - MapCars - Error updating cars: %v", err)
+				return report, err
+			}
+			report.Updated += len(batch)
+		}
+	}
+
+	log.Printf("[INFO] Service - MapCars - Scanned %d cars, %d changed, %d updated, %d unmatched marks, %d unmatched models",
+		report.Scanned, report.Changed, report.Updated, len(report.UnmatchedMarks), len(report.UnmatchedModels))
+	return report, nil
+}
+
+// sortUnmatched puts the most common values first.
+func sortUnmatched(values []dto.UnmatchedValue) {
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		if values[i].Mark != values[j].Mark {
+			return values[i].Mark < values[j].Mark
+		}
+		return values[i].Model < values[j].Model
+	})
+}
+
+// dictionaryEntry validates a new make or model and returns its alias keys,
+// the canonical name's first, without duplicates.
+func dictionaryEntry(req dto.DictionaryEntryRequest) (string, []string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxDictionaryNameLength {
+		return "", nil, fmt.Errorf("%w: name must be 1 to %d characters long", ErrInvalidDictionaryEntry, maxDictionaryNameLength)
+	}
+
+	var aliases []string
+	seen := make(map[string]bool, len(req.Aliases)+1)
+	for _, alias := range append([]string{name}, req.Aliases...) {
+		key, err := aliasKey(alias)
+		if err != nil {
+			return "", nil, err
+		}
+		if !seen[key] {
+			seen[key] = true
+			aliases = append(aliases, key)
+		}
+	}
+	return name, aliases, nil
+}
+
+func aliasKey(alias string) (string, error) {
+	key := DictionaryKey(alias)
+	if key == "" || utf8.RuneCountInString(key) > maxDictionaryNameLength {
+		return "", fmt.Errorf("%w: alias %q must have 1 to %d letters or digits", ErrInvalidDictionaryEntry, alias, maxDictionaryNameLength)
+	}
+	return key, nil
+}
+
+func toMakeDto(m model.Make) dto.MakeDto {
+	result := dto.MakeDto{Id: m.Id, Name: m.Name, Aliases: m.Aliases, Models: []dto.ModelRefDto{}}
+	if result.Aliases == nil {
+		result.Aliases = []string{}
+	}
+	for _, ref := range m.Models {
+		result.Models = append(result.Models, toModelRefDto(ref))
+	}
+	return result
+}
+
+func toModelRefDto(m model.ModelRef) dto.ModelRefDto {
+	result := dto.ModelRefDto{Id: m.Id, MakeId: m.MakeId, Name: m.Name, Aliases: m.Aliases}
+	if result.Aliases == nil {
+		result.Aliases = []string{}
+	}
+	return result
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"car_catalog/internal/auth"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// eventPageSize is how many events one log read returns.
+	eventPageSize = 500
+	// eventBuffer is how far a subscriber may fall behind the live feed
+	// before it is dropped; it resumes from the log on reconnect.
+	eventBuffer = 256
+	// eventPollInterval catches up with writers that cannot wake this
+	// instance, like the CLI on SQLite, and with missed notifications.
+	eventPollInterval = 5 * time.Second
+)
+
+// EventService streams the catalog change feed recorded by the car
+// repository.
+type EventService interface {
+	// Subscribe delivers the events after lastId that match filter: those
+	// already in the log first, then new ones as they are recorded. A
+	// negative lastId starts at the newest event. The channel is closed when
+	// ctx is done or the subscriber falls too far behind; it may resume from
+	// the last id it received. An authenticated caller other than an admin
+	// only sees its own changes, whatever filter.Tenant says.
+	Subscribe(ctx context.Context, lastId int64, filter dto.EventFilter) (<-chan dto.CarEventDto, error)
+	// Follow reads new events from the log and hands them to subscribers
+	// until ctx is done.
+	Follow(ctx context.Context)
+	// Prune drops the events older than retention up to maxId; later ones
+	// are kept for a consumer that has yet to read them.
+	Prune(ctx context.Context, retention time.Duration, maxId int64) (int64, error)
+	// OwnerHistory returns the owners each of the given cars had, oldest
+	// first, as far back as the retained events reach. Cars without events
+	// are left out.
+	OwnerHistory(ctx context.Context, carIds []int) (map[int][]dto.OwnershipDto, error)
+}
+
+type EventServiceImpl struct {
+	Log repository.CarEventLog
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	// last is the newest event handed to subscribers; ready is closed once
+	// Follow has read it from the log.
+	last  int64
+	ready chan struct{}
+	wake  chan struct{}
+}
+
+type subscriber struct {
+	filter dto.EventFilter
+	events chan model.CarEvent
+}
+
+func NewEventService(eventLog repository.CarEventLog) EventService {
+	return &EventServiceImpl{
+		Log:         eventLog,
+		subscribers: make(map[*subscriber]struct{}),
+		ready:       make(chan struct{}),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func (e *EventServiceImpl) Subscribe(ctx context.Context, lastId int64, filter dto.EventFilter) (<-chan dto.CarEventDto, error) {
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if identity, ok := auth.FromContext(ctx); ok && identity.Role != adminRole {
+		filter.Tenant = identity.Name
+	}
+
+	// Subscribe before reading the log, so nothing recorded in between is
+	// missed; ids already replayed are skipped when the live feed repeats
+	// them.
+	sub := &subscriber{filter: filter, events: make(chan model.CarEvent, eventBuffer)}
+	e.mu.Lock()
+	e.subscribers[sub] = struct{}{}
+	live := e.last
+	e.mu.Unlock()
+	// An id from the future means the log was reset; follow it from here.
+	if lastId < 0 || lastId > live {
+		lastId = live
+	}
+
+	out := make(chan dto.CarEventDto)
+	go func() {
+		defer close(out)
+		defer e.unsubscribe(sub)
+
+		sent := lastId
+		send := func(event model.CarEvent) bool {
+			if event.Id <= sent || !eventMatches(filter, event) {
+				return true
+			}
+			select {
+			case out <- toCarEventDto(event):
+				sent = event.Id
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Replay from the log up to where the live feed starts. A long
+		// replay may overflow the live buffer; the subscriber is then
+		// dropped and resumes from where it got.
+		for after := lastId; after < live; {
+			page, err := e.Log.Since(ctx, after, eventPageSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[ERROR] Service - Subscribe - Error reading events after %d: %v", after, err)
+				}
+				return
+			}
+			if len(page) == 0 {
+				break
+			}
+			for _, event := range page {
+				if !send(event) {
+					return
+				}
+			}
+			after = page[len(page)-1].Id
+		}
+
+		for {
+			select {
+			case event, ok := <-sub.events:
+				if !ok {
+					log.Printf("[INFO] Service - Subscribe - Subscriber dropped at event %d", sent)
+					return
+				}
+				if !send(event) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *EventServiceImpl) unsubscribe(sub *subscriber) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.subscribers[sub]; ok {
+		delete(e.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (e *EventServiceImpl) Follow(ctx context.Context) {
+	last, err := e.Log.LastId(ctx)
+	for err != nil {
+		log.Printf("[ERROR] Service - Follow - Unable to read the event log: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventPollInterval):
+		}
+		last, err = e.Log.LastId(ctx)
+	}
+	e.mu.Lock()
+	e.last = last
+	e.mu.Unlock()
+	close(e.ready)
+	log.Printf("[INFO] Service - Follow - Following the event log from %d", last)
+
+	go e.Log.Listen(ctx, func() {
+		select {
+		case e.wake <- struct{}{}:
+		default:
+		}
+	})
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.mu.Lock()
+			for sub := range e.subscribers {
+				delete(e.subscribers, sub)
+				close(sub.events)
+			}
+			e.mu.Unlock()
+			return
+		case <-e.wake:
+		case <-ticker.C:
+		}
+		e.catchUp(ctx)
+	}
+}
+
+// catchUp hands the events recorded since the last call to subscribers.
+func (e *EventServiceImpl) catchUp(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		last := e.last
+		e.mu.Unlock()
+
+		page, err := e.Log.Since(ctx, last, eventPageSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[ERROR] Service - Follow - Error reading events after %d: %v", last, err)
+			}
+			return
+		}
+
+		e.mu.Lock()
+		for _, event := range page {
+			for sub := range e.subscribers {
+				if !eventMatches(sub.filter, event) {
+					continue
+				}
+				select {
+				case sub.events <- event:
+				default:
+					delete(e.subscribers, sub)
+					close(sub.events)
+				}
+			}
+			e.last = event.Id
+		}
+		e.mu.Unlock()
+
+		if len(page) < eventPageSize {
+			return
+		}
+	}
+}
+
+func (e *EventServiceImpl) Prune(ctx context.Context, retention time.Duration, maxId int64) (int64, error) {
+	pruned, err := e.Log.Prune(ctx, time.Now().Add(-retention), maxId)
+	if err != nil {
+		log.Printf("[ERROR] Service - Prune - Error pruning events: %v", err)
+		return 0, err
+	}
+	return pruned, nil
+}
+
+func (e *EventServiceImpl) OwnerHistory(ctx context.Context, carIds []int) (map[int][]dto.OwnershipDto, error) {
+	events, err := e.Log.ForCars(ctx, carIds)
+	if err != nil {
+		log.Printf("[ERROR] Service - OwnerHistory - Error reading events: %v", err)
+		return nil, err
+	}
+
+	history := make(map[int][]dto.OwnershipDto)
+	for _, event := range events {
+		owner := dto.People{
+			Name:       event.Car.OwnerName,
+			Surname:    event.Car.OwnerSurname,
+			Patronymic: event.Car.OwnerPatronymic,
+		}
+		owners := history[event.CarId]
+		if n := len(owners); n > 0 {
+			if owners[n-1].Owner == owner {
+				continue
+			}
+			until := event.CreatedAt
+			owners[n-1].Until = &until
+		}
+		history[event.CarId] = append(owners, dto.OwnershipDto{Owner: owner, Since: event.CreatedAt})
+	}
+	return history, nil
+}
+
+// eventMatches applies the filters of /api/cars/events, exact like those of
+// the listing.
+func eventMatches(filter dto.EventFilter, event model.CarEvent) bool {
+	return (filter.Mark == "" || event.Car.Mark == filter.Mark) &&
+		(filter.Tenant == "" || event.Tenant == filter.Tenant)
+}
+
+func toCarEventDto(event model.CarEvent) dto.CarEventDto {
+	return dto.CarEventDto{
+		Id:     event.Id,
+		Type:   event.Type,
+		CarId:  event.CarId,
+		Tenant: event.Tenant,
+		Car: dto.ExportCarDto{
+			CarId:  event.Car.CarId,
+			Mark:   event.Car.Mark,
+			Model:  event.Car.Model,
+			Year:   event.Car.Year,
+			RegNum: event.Car.RegNum,
+			Vin:    event.Car.Vin,
+			Owner: &dto.People{
+				Name:       event.Car.OwnerName,
+				Surname:    event.Car.OwnerSurname,
+				Patronymic: event.Car.OwnerPatronymic,
+			},
+		},
+		CreatedAt: event.CreatedAt,
+	}
+}
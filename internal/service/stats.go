@@ -0,0 +1,131 @@
+package service
+
+import (
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/search"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	defaultStatsLimit = 100
+	maxStatsLimit     = 1000
+)
+
+var ErrInvalidStats = errors.New("invalid stats request")
+
+// StatsService aggregates the catalog for /api/stats.
+type StatsService interface {
+	// CarStats counts the cars matching filters per distinct combination of
+	// the groupBy dimensions: mark, model, year, region or owner. Filters
+	// take the same values as GetFilteredCars; Limit caps the groups.
+	CarStats(ctx context.Context, groupBy []string, filters dto.Filters) (dto.StatsDto, error)
+}
+
+type StatsServiceImpl struct {
+	CarRepo repository.CarRepository
+	// View serves the queries it covers; nil always reads the live table.
+	View repository.CarStatsView
+}
+
+func NewStatsService(carRepo repository.CarRepository, view repository.CarStatsView) StatsService {
+	return &StatsServiceImpl{
+		CarRepo: carRepo,
+		View:    view,
+	}
+}
+
+func (s *StatsServiceImpl) CarStats(ctx context.Context, groupBy []string, filters dto.Filters) (dto.StatsDto, error) {
+	query, err := statsQuery(groupBy, filters)
+	if err != nil {
+		log.Printf("[ERROR] Service - CarStats - %v", err)
+		return dto.StatsDto{}, err
+	}
+
+	source := "live"
+	var stats model.CarStats
+	if s.View != nil && s.View.Covers(query) {
+		source = "materialized"
+		stats, err = s.View.CarStats(ctx, query)
+	} else {
+		stats, err = s.CarRepo.CarStats(ctx, query)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Service - CarStats - Error aggregating cars: %v", err)
+		return dto.StatsDto{}, err
+	}
+
+	result := dto.StatsDto{
+		GroupBy:     query.GroupBy,
+		Source:      source,
+		RefreshedAt: stats.RefreshedAt,
+		TotalCars:   stats.Cars,
+		TotalGroups: stats.GroupCount,
+		Groups:      make([]dto.StatsGroupDto, 0, len(stats.Groups)),
+	}
+	for _, group := range stats.Groups {
+		result.Groups = append(result.Groups, dto.StatsGroupDto{
+			Mark:   group.Mark,
+			Model:  group.Model,
+			Year:   group.Year,
+			Region: group.Region,
+			Owner:  group.Owner,
+			Count:  group.Count,
+		})
+	}
+
+	log.Printf("[INFO] Service - CarStats - %d of %d groups by %v from the %s data", len(result.Groups), result.TotalGroups, query.GroupBy, source)
+	return result, nil
+}
+
+func statsQuery(groupBy []string, filters dto.Filters) (model.StatsQuery, error) {
+	query := model.StatsQuery{
+		Mark:  filters.Mark,
+		Model: filters.Model,
+		Year:  filters.Year,
+		Limit: defaultStatsLimit,
+	}
+
+	if len(groupBy) == 0 {
+		return model.StatsQuery{}, fmt.Errorf("%w: groupBy is required", ErrInvalidStats)
+	}
+	seen := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case model.StatsByMark, model.StatsByModel, model.StatsByYear, model.StatsByRegion, model.StatsByOwner:
+		default:
+			return model.StatsQuery{}, fmt.Errorf("%w: cannot group by %q", ErrInvalidStats, dimension)
+		}
+		if seen[dimension] {
+			return model.StatsQuery{}, fmt.Errorf("%w: %q is listed twice", ErrInvalidStats, dimension)
+		}
+		seen[dimension] = true
+		query.GroupBy = append(query.GroupBy, dimension)
+	}
+
+	if filters.Year != "" {
+		if _, err := strconv.Atoi(filters.Year); err != nil {
+			return model.StatsQuery{}, fmt.Errorf("%w: invalid year %q", ErrInvalidStats, filters.Year)
+		}
+	}
+	if filters.Limit != "" {
+		limit, err := strconv.Atoi(filters.Limit)
+		if err != nil || limit < 1 || limit > maxStatsLimit {
+			return model.StatsQuery{}, fmt.Errorf("%w: limit must be 1 to %d", ErrInvalidStats, maxStatsLimit)
+		}
+		query.Limit = limit
+	}
+	if filters.Query != "" {
+		if n := utf8.RuneCountInString(filters.Query); n > search.MaxQueryLength {
+			return model.StatsQuery{}, fmt.Errorf("%w: search query must be at most %d characters", ErrInvalidStats, search.MaxQueryLength)
+		}
+		query.Variants = search.Variants(filters.Query)
+	}
+	return query, nil
+}
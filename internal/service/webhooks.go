@@ -0,0 +1,564 @@
+package service
+
+import (
+	"bytes"
+	"car_catalog/internal/dto"
+	"car_catalog/internal/model"
+	"car_catalog/internal/repository"
+	"car_catalog/internal/webhook"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookPollInterval is how often the dispatcher looks for due retries
+	// and for events it was not woken for.
+	webhookPollInterval = time.Second
+	// webhookLeaseMargin is added to the attempt timeout to get the time a
+	// claimed delivery is hidden from other dispatchers.
+	webhookLeaseMargin = 30 * time.Second
+	// webhookSecretBytes is the size of generated secrets.
+	webhookSecretBytes = 32
+	// webhookResponseLimit is how much of a response body is read so the
+	// connection can be reused.
+	webhookResponseLimit = 64 << 10
+
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// WebhookOptions tune the delivery of webhooks.
+type WebhookOptions struct {
+	// Timeout bounds one attempt.
+	Timeout time.Duration
+	// MaxAttempts is how many attempts a delivery gets before it is dead.
+	MaxAttempts int
+	// Backoff is the pause after the first failed attempt; it doubles after
+	// every further one, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Workers is how many deliveries are attempted at once.
+	Workers int
+	// AllowPrivate lets subscriptions reach loopback and private addresses,
+	// for development; otherwise they are refused on subscription and when
+	// dialing.
+	AllowPrivate bool
+}
+
+// WebhookService manages webhook subscriptions and delivers the change feed
+// to them.
+type WebhookService interface {
+	// Subscribe creates a subscription to the events recorded from now on.
+	// The returned subscription carries the secret deliveries are signed
+	// with; it is not shown again.
+	Subscribe(ctx context.Context, req dto.WebhookSubscriptionRequest) (dto.WebhookSubscriptionDto, error)
+	ListSubscriptions(ctx context.Context) ([]dto.WebhookSubscriptionDto, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	// ListDeliveries returns the latest deliveries of a subscription, newest
+	// first, optionally of one status.
+	ListDeliveries(ctx context.Context, id, status, limit string) ([]dto.WebhookDeliveryDto, error)
+	// Replay queues the dead deliveries of a subscription again, or with
+	// FromEventId every retained event after it that the subscription
+	// matches.
+	Replay(ctx context.Context, id string, req dto.WebhookReplayRequest) (dto.WebhookReplayResult, error)
+	// Dispatch turns new events into deliveries and attempts the due ones
+	// until ctx is done. Several instances may dispatch at once.
+	Dispatch(ctx context.Context)
+	// Prune drops the deliveries delivered longer than retention ago.
+	Prune(ctx context.Context, retention time.Duration) (int64, error)
+	// Cursor is the id of the last event turned into deliveries. Later
+	// events must stay in the log until Dispatch has read them.
+	Cursor(ctx context.Context) (int64, error)
+}
+
+type WebhookServiceImpl struct {
+	Repo   repository.WebhookRepository
+	Log    repository.CarEventLog
+	Client *http.Client
+	Opts   WebhookOptions
+
+	wake chan struct{}
+}
+
+func NewWebhookService(repo repository.WebhookRepository, eventLog repository.CarEventLog, opts WebhookOptions) WebhookService {
+	client := webhook.NewClient(opts.Timeout)
+	if opts.AllowPrivate {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+	return &WebhookServiceImpl{
+		Repo:   repo,
+		Log:    eventLog,
+		Client: client,
+		Opts:   opts,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func (s *WebhookServiceImpl) Subscribe(ctx context.Context, req dto.WebhookSubscriptionRequest) (dto.WebhookSubscriptionDto, error) {
+	req.Url = strings.TrimSpace(req.Url)
+	u, err := url.Parse(req.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return dto.WebhookSubscriptionDto{}, fmt.Errorf("%w: url %q is not an absolute http(s) URL", ErrInvalidWebhook, req.Url)
+	}
+	if !s.Opts.AllowPrivate {
+		if err := webhook.CheckHost(ctx, u.Hostname()); errors.Is(err, webhook.ErrPrivateAddress) {
+			return dto.WebhookSubscriptionDto{}, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+		} else if err != nil {
+			return dto.WebhookSubscriptionDto{}, fmt.Errorf("%w: unable to resolve %q: %v", ErrInvalidWebhook, u.Hostname(), err)
+		}
+	}
+	events := []string{}
+	seen := make(map[string]bool)
+	for _, eventType := range req.Events {
+		switch eventType {
+		case model.CarCreated, model.CarUpdated, model.CarDeleted:
+		default:
+			return dto.WebhookSubscriptionDto{}, fmt.Errorf("%w: unknown event %q, want %s, %s or %s",
+				ErrInvalidWebhook, eventType, model.CarCreated, model.CarUpdated, model.CarDeleted)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			events = append(events, eventType)
+		}
+	}
+	if req.Secret == "" {
+		secret := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			log.Printf("[ERROR] Service - Subscribe - Unable to generate a secret: %v", err)
+			return dto.WebhookSubscriptionDto{}, err
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+
+	last, err := s.Log.LastId(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Service - Subscribe - Unable to read the event log: %v", err)
+		return dto.WebhookSubscriptionDto{}, err
+	}
+	sub, err := s.Repo.AddSubscription(ctx, model.WebhookSubscription{
+		Url:          req.Url,
+		Secret:       req.Secret,
+		Events:       events,
+		Mark:         strings.TrimSpace(req.Mark),
+		AfterEventId: last,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Service - Subscribe - Error adding subscription: %v", err)
+		return dto.WebhookSubscriptionDto{}, err
+	}
+
+	log.Printf("[INFO] Service - Subscribe - Subscribed %s after event %d", sub.Url, last)
+	subDto := toWebhookSubscriptionDto(sub)
+	subDto.Secret = sub.Secret
+	return subDto, nil
+}
+
+func (s *WebhookServiceImpl) ListSubscriptions(ctx context.Context) ([]dto.WebhookSubscriptionDto, error) {
+	subs, err := s.Repo.ListSubscriptions(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Service - ListSubscriptions - Error listing subscriptions: %v", err)
+		return nil, err
+	}
+	subDtos := make([]dto.WebhookSubscriptionDto, len(subs))
+	for i, sub := range subs {
+		subDtos[i] = toWebhookSubscriptionDto(sub)
+	}
+	return subDtos, nil
+}
+
+func (s *WebhookServiceImpl) DeleteSubscription(ctx context.Context, id string) error {
+	subId, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.DeleteSubscription(ctx, subId); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Service - DeleteSubscription - Deleted subscription %d", subId)
+	return nil
+}
+
+func (s *WebhookServiceImpl) ListDeliveries(ctx context.Context, id, status, limit string) ([]dto.WebhookDeliveryDto, error) {
+	subId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: status %q must be %s, %s or %s",
+			ErrInvalidWebhook, status, model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead)
+	}
+	n := defaultDeliveryLimit
+	if limit != "" {
+		if n, err = strconv.Atoi(limit); err != nil || n <= 0 || n > maxDeliveryLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidWebhook, maxDeliveryLimit)
+		}
+	}
+	if _, err := s.Repo.GetSubscription(ctx, subId); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.Repo.ListDeliveries(ctx, subId, status, n)
+	if err != nil {
+		log.Printf("[ERROR] Service - ListDeliveries - Error listing deliveries of %d: %v", subId, err)
+		return nil, err
+	}
+	deliveryDtos := make([]dto.WebhookDeliveryDto, len(deliveries))
+	for i, d := range deliveries {
+		deliveryDtos[i] = toWebhookDeliveryDto(d)
+	}
+	return deliveryDtos, nil
+}
+
+func (s *WebhookServiceImpl) Replay(ctx context.Context, id string, req dto.WebhookReplayRequest) (dto.WebhookReplayResult, error) {
+	subId, err := strconv.Atoi(id)
+	if err != nil {
+		return dto.WebhookReplayResult{}, err
+	}
+	if req.FromEventId != nil && *req.FromEventId < 0 {
+		return dto.WebhookReplayResult{}, fmt.Errorf("%w: fromEventId must not be negative", ErrInvalidWebhook)
+	}
+	sub, err := s.Repo.GetSubscription(ctx, subId)
+	if err != nil {
+		return dto.WebhookReplayResult{}, err
+	}
+
+	var queued int64
+	if req.FromEventId == nil {
+		if queued, err = s.Repo.RequeueDead(ctx, subId); err != nil {
+			log.Printf("[ERROR] Service - Replay - Error requeueing dead deliveries of %d: %v", subId, err)
+			return dto.WebhookReplayResult{}, err
+		}
+	} else if queued, err = s.replayEvents(ctx, sub, *req.FromEventId); err != nil {
+		return dto.WebhookReplayResult{}, err
+	}
+
+	log.Printf("[INFO] Service - Replay - Queued %d deliveries of subscription %d", queued, subId)
+	s.signal()
+	return dto.WebhookReplayResult{Queued: queued}, nil
+}
+
+// replayEvents queues the events after fromId that sub matches, whether or
+// not they were delivered before. Events past the cursor are left to the
+// dispatcher, which queues them anyway.
+func (s *WebhookServiceImpl) replayEvents(ctx context.Context, sub model.WebhookSubscription, fromId int64) (int64, error) {
+	cursor, err := s.Repo.Cursor(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Service - Replay - Unable to read the webhook cursor: %v", err)
+		return 0, err
+	}
+	sub.AfterEventId = fromId
+
+	var queued int64
+	for after := fromId; after < cursor; {
+		page, err := s.Log.Since(ctx, after, eventPageSize)
+		if err != nil {
+			log.Printf("[ERROR] Service - Replay - Error reading events after %d: %v", after, err)
+			return queued, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		var deliveries []model.WebhookDelivery
+		for _, event := range page {
+			if event.Id > cursor {
+				break
+			}
+			if webhookMatches(sub, event) {
+				d, err := newDelivery(sub, event)
+				if err != nil {
+					return queued, err
+				}
+				deliveries = append(deliveries, d)
+			}
+		}
+		if err := s.Repo.AddDeliveries(ctx, deliveries); err != nil {
+			log.Printf("[ERROR] Service - Replay - Error queueing deliveries: %v", err)
+			return queued, err
+		}
+		queued += int64(len(deliveries))
+		after = page[len(page)-1].Id
+	}
+	return queued, nil
+}
+
+// signal wakes the dispatcher of this instance.
+func (s *WebhookServiceImpl) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *WebhookServiceImpl) Dispatch(ctx context.Context) {
+	log.Printf("[INFO] Service - Dispatch - Delivering webhooks with %d workers", s.Opts.Workers)
+	go s.Log.Listen(ctx, s.signal)
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		s.fanOut(ctx)
+		s.deliver(ctx)
+		select {
+		case <-ctx.Done():
+			log.Println("[INFO] Service - Dispatch - Webhook dispatcher stopped")
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// fanOut queues a delivery for every event past the cursor and every
+// subscription matching it.
+func (s *WebhookServiceImpl) fanOut(ctx context.Context) {
+	for ctx.Err() == nil {
+		cursor, err := s.Repo.Cursor(ctx)
+		if err != nil {
+			log.Printf("[ERROR] Service - Dispatch - Unable to read the webhook cursor: %v", err)
+			return
+		}
+		page, err := s.Log.Since(ctx, cursor, eventPageSize)
+		if err != nil {
+			log.Printf("[ERROR] Service - Dispatch - Error reading events after %d: %v", cursor, err)
+			return
+		}
+		if len(page) == 0 {
+			return
+		}
+		subs, err := s.Repo.ListSubscriptions(ctx)
+		if err != nil {
+			log.Printf("[ERROR] Service - Dispatch - Error listing subscriptions: %v", err)
+			return
+		}
+
+		var deliveries []model.WebhookDelivery
+		for _, event := range page {
+			for _, sub := range subs {
+				if !webhookMatches(sub, event) {
+					continue
+				}
+				d, err := newDelivery(sub, event)
+				if err != nil {
+					return
+				}
+				deliveries = append(deliveries, d)
+			}
+		}
+		// Another dispatcher may have queued the page meanwhile; the cursor
+		// is then read again.
+		last := page[len(page)-1].Id
+		moved, err := s.Repo.EnqueueDeliveries(ctx, cursor, last, deliveries)
+		if err != nil {
+			log.Printf("[ERROR] Service - Dispatch - Error queueing deliveries: %v", err)
+			return
+		}
+		if moved {
+			log.Printf("[DEBUG] Service - Dispatch - Queued %d deliveries for events up to %d", len(deliveries), last)
+			if len(page) < eventPageSize {
+				return
+			}
+		}
+	}
+}
+
+// deliver attempts the due deliveries, Workers at a time, until none is due.
+func (s *WebhookServiceImpl) deliver(ctx context.Context) {
+	lease := s.Opts.Timeout + webhookLeaseMargin
+	for ctx.Err() == nil {
+		claimed, err := s.Repo.ClaimDeliveries(ctx, time.Now(), lease, s.Opts.Workers)
+		if err != nil {
+			log.Printf("[ERROR] Service - Dispatch - Error claiming deliveries: %v", err)
+			return
+		}
+		if len(claimed) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, d := range claimed {
+			wg.Add(1)
+			go func(d model.WebhookDelivery) {
+				defer wg.Done()
+				s.attempt(ctx, d)
+			}(d)
+		}
+		wg.Wait()
+
+		if len(claimed) < s.Opts.Workers {
+			return
+		}
+	}
+}
+
+// attempt POSTs one delivery and records the outcome. An attempt cut short
+// by shutdown is not recorded; the delivery is retried once its lease ends.
+func (s *WebhookServiceImpl) attempt(ctx context.Context, d model.WebhookDelivery) {
+	sub, err := s.Repo.GetSubscription(ctx, d.SubscriptionId)
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Service - Dispatch - Unable to read subscription %d: %v", d.SubscriptionId, err)
+		return
+	}
+
+	statusCode, err := s.post(ctx, sub, d)
+	if ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	d.Attempts++
+	if err == nil {
+		d.Status = model.DeliveryDelivered
+		d.DeliveredAt = &now
+		log.Printf("[DEBUG] Service - Dispatch - Delivered %d to %s", d.Id, sub.Url)
+	} else {
+		d.LastStatusCode = statusCode
+		d.LastError = err.Error()
+		if d.Attempts >= s.Opts.MaxAttempts {
+			d.Status = model.DeliveryDead
+			log.Printf("[ERROR] Service - Dispatch - Delivery %d to %s is dead after %d attempts: %v", d.Id, sub.Url, d.Attempts, err)
+		} else {
+			d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+			log.Printf("[INFO] Service - Dispatch - Delivery %d to %s failed, retrying at %s: %v",
+				d.Id, sub.Url, d.NextAttemptAt.Format(time.RFC3339), err)
+		}
+	}
+
+	if err := s.Repo.SaveAttempt(context.WithoutCancel(ctx), d); err != nil {
+		log.Printf("[ERROR] Service - Dispatch - Unable to save attempt of %d: %v", d.Id, err)
+	}
+}
+
+// post sends d to sub and returns the response status; any status but 2xx
+// fails the attempt.
+func (s *WebhookServiceImpl) post(ctx context.Context, sub model.WebhookSubscription, d model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "car_catalog-webhooks")
+	req.Header.Set(webhook.IdHeader, strconv.FormatInt(d.Id, 10))
+	req.Header.Set(webhook.EventHeader, d.EventType)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(sub.Secret, time.Now(), d.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookResponseLimit))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the pause after the given number of failed attempts.
+func (s *WebhookServiceImpl) backoff(attempts int) time.Duration {
+	pause := s.Opts.Backoff
+	for i := 1; i < attempts && pause < s.Opts.MaxBackoff; i++ {
+		pause *= 2
+	}
+	return min(pause, s.Opts.MaxBackoff)
+}
+
+func (s *WebhookServiceImpl) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	pruned, err := s.Repo.PruneDeliveries(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("[ERROR] Service - Prune - Error pruning webhook deliveries: %v", err)
+		return 0, err
+	}
+	return pruned, nil
+}
+
+func (s *WebhookServiceImpl) Cursor(ctx context.Context) (int64, error) {
+	cursor, err := s.Repo.Cursor(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Service - Cursor - Unable to read the webhook cursor: %v", err)
+		return 0, err
+	}
+	return cursor, nil
+}
+
+// webhookMatches reports whether sub wants event: one recorded after the
+// subscription of a type and mark it asked for.
+func webhookMatches(sub model.WebhookSubscription, event model.CarEvent) bool {
+	if event.Id <= sub.AfterEventId || (sub.Mark != "" && event.Car.Mark != sub.Mark) {
+		return false
+	}
+	if len(sub.Events) == 0 {
+		return true
+	}
+	for _, eventType := range sub.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// newDelivery renders event for sub. Subscriptions are managed by admins, so
+// the payload includes the owner.
+func newDelivery(sub model.WebhookSubscription, event model.CarEvent) (model.WebhookDelivery, error) {
+	payload, err := json.Marshal(toCarEventDto(event))
+	if err != nil {
+		log.Printf("[ERROR] Service - Dispatch - Error encoding event %d: %v", event.Id, err)
+		return model.WebhookDelivery{}, err
+	}
+	return model.WebhookDelivery{
+		SubscriptionId: sub.Id,
+		EventId:        event.Id,
+		EventType:      event.Type,
+		Payload:        payload,
+	}, nil
+}
+
+func toWebhookSubscriptionDto(sub model.WebhookSubscription) dto.WebhookSubscriptionDto {
+	return dto.WebhookSubscriptionDto{
+		Id:           sub.Id,
+		Url:          sub.Url,
+		Events:       sub.Events,
+		Mark:         sub.Mark,
+		AfterEventId: sub.AfterEventId,
+		CreatedAt:    sub.CreatedAt,
+	}
+}
+
+func toWebhookDeliveryDto(d model.WebhookDelivery) dto.WebhookDeliveryDto {
+	deliveryDto := dto.WebhookDeliveryDto{
+		Id:             d.Id,
+		EventId:        d.EventId,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+		Payload:        json.RawMessage(d.Payload),
+	}
+	if d.Status == model.DeliveryPending {
+		next := d.NextAttemptAt
+		deliveryDto.NextAttemptAt = &next
+	}
+	return deliveryDto
+}
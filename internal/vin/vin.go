@@ -0,0 +1,123 @@
+// Package vin validates and decodes vehicle identification numbers (ISO 3779)
+// without calling out to any service.
+package vin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Length is the length of every VIN issued since 1981.
+const Length = 17
+
+var ErrInvalid = errors.New("invalid VIN")
+
+// yearCodes are the model-year characters at position 10, repeating every 30
+// years from 1980.
+const yearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// weights of the positions in the North American check digit sum; position 9
+// holds the check digit itself.
+var weights = [Length]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// Info is what a VIN tells without a database of vehicle specs.
+type Info struct {
+	// Manufacturer is empty when the WMI is not in the built-in table.
+	Manufacturer string
+	// Years are the model years position 10 may stand for, oldest first.
+	// North American VINs tell the 30-year cycle apart, so they have one.
+	Years []int
+}
+
+// Normalize upper-cases a VIN and drops spaces and dashes.
+func Normalize(vin string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(vin)) {
+		if r == ' ' || r == '-' || r == '\t' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Validate checks an already normalized VIN: 17 characters out of digits and
+// Latin letters except I, O and Q, and a valid check digit for VINs issued in
+// North America, where it is mandatory. Elsewhere position 9 is free.
+func Validate(vin string) error {
+	if len(vin) != Length {
+		return fmt.Errorf("%w: %d characters instead of %d", ErrInvalid, len(vin), Length)
+	}
+	for i := 0; i < Length; i++ {
+		if _, ok := transliterate(vin[i]); !ok {
+			return fmt.Errorf("%w: character %q at position %d", ErrInvalid, vin[i], i+1)
+		}
+	}
+	if !strings.ContainsRune(yearCodes, rune(vin[9])) {
+		return fmt.Errorf("%w: model year character %q", ErrInvalid, vin[9])
+	}
+	if northAmerican(vin) {
+		if want := CheckDigit(vin); vin[8] != want {
+			return fmt.Errorf("%w: check digit is %q, expected %q", ErrInvalid, vin[8], want)
+		}
+	}
+	return nil
+}
+
+// CheckDigit computes position 9 of a VIN with valid characters.
+func CheckDigit(vin string) byte {
+	sum := 0
+	for i := 0; i < Length; i++ {
+		value, _ := transliterate(vin[i])
+		sum += value * weights[i]
+	}
+	if sum%11 == 10 {
+		return 'X'
+	}
+	return byte('0' + sum%11)
+}
+
+// Decode reads the manufacturer and model year of a valid VIN.
+func Decode(vin string) Info {
+	info := Info{Manufacturer: manufacturer(vin)}
+
+	i := strings.IndexByte(yearCodes, vin[9])
+	first, second := 1980+i, 2010+i
+	switch {
+	case !northAmerican(vin):
+		info.Years = []int{first, second}
+	case vin[6] >= '0' && vin[6] <= '9':
+		// Since 2010 North American VINs have a letter at position 7.
+		info.Years = []int{first}
+	default:
+		info.Years = []int{second}
+	}
+	return info
+}
+
+// northAmerican reports whether the VIN was issued in the United States,
+// Canada or Mexico.
+func northAmerican(vin string) bool {
+	return vin[0] >= '1' && vin[0] <= '5'
+}
+
+// transliterate gives the check digit value of a VIN character; I, O and Q
+// are not allowed, as they read like 1 and 0.
+func transliterate(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'H':
+		return int(c-'A') + 1, true
+	case c >= 'J' && c <= 'N':
+		return int(c-'J') + 1, true
+	case c == 'P':
+		return 7, true
+	case c == 'R':
+		return 9, true
+	case c >= 'S' && c <= 'Z':
+		return int(c-'S') + 2, true
+	}
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package vin_test
+
+import (
+	"car_catalog/internal/vin"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		vin   string
+		valid bool
+	}{
+		{"1M8GDM9AXKP042788", true},
+		{"1G1ZT53826F109149", true},
+		// Position 9 is free outside North America.
+		{"XTA21703080123456", true},
+		{"WBA3A5C50CF256551", true},
+		{"5YJ3E1EA0JF000316", false},
+		{"1M8GDM9AXKP04278", false},
+		{"1M8GDM9AXKP04278O", false},
+		{"1HGCM82630A004352", false},
+	}
+	for _, tt := range tests {
+		err := vin.Validate(tt.vin)
+		if (err == nil) != tt.valid {
+			t.Errorf("Validate(%q) = %v, want valid %t", tt.vin, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, vin.ErrInvalid) {
+			t.Errorf("Validate(%q) = %v, want ErrInvalid", tt.vin, err)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		vin  string
+		want string
+	}{
+		{"1M8GDM9AXKP042788", "[1989]"},
+		{"5YJ3E1EA2JF000316", "Tesla [2018]"},
+		{"1G1ZT53826F109149", "Chevrolet [2006]"},
+		{"XTA21703080123456", "Lada [2008 2038]"},
+		{"JTHBK1GG0E2123456", "Lexus [1984 2014]"},
+		{"JTDKN3DU5A0123456", "Toyota [1980 2010]"},
+	}
+	for _, tt := range tests {
+		info := vin.Decode(tt.vin)
+		got := fmt.Sprint(info.Years)
+		if info.Manufacturer != "" {
+			got = info.Manufacturer + " " + got
+		}
+		if got != tt.want {
+			t.Errorf("Decode(%q) = %s, want %s", tt.vin, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := vin.Normalize(" 1m8gdm9a-xkp 042788 "); got != "1M8GDM9AXKP042788" {
+		t.Errorf("Normalize = %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+package vin
+
+// wmis maps world manufacturer identifiers, the first three VIN characters,
+// to the mark they are sold under. Entries of two characters cover every
+// third character not listed. The table holds the makes common in the
+// catalog, not the full SAE register.
+var wmis = map[string]string{
+	// Russia
+	"XTA": "Lada",
+	"XTT": "UAZ",
+	"X96": "GAZ",
+	"X9F": "Ford",
+	"XW8": "Volkswagen",
+	"XWE": "Kia",
+	"X7L": "Renault",
+	"Z94": "Hyundai",
+	"Z8N": "Nissan",
+
+	// Japan
+	"JT":  "Toyota",
+	"JTH": "Lexus",
+	"JTJ": "Lexus",
+	"JHM": "Honda",
+	"JH4": "Acura",
+	"JN":  "Nissan",
+	"JM":  "Mazda",
+	"JF":  "Subaru",
+	"JA":  "Mitsubishi",
+	"JS":  "Suzuki",
+
+	// Korea
+	"KMH": "Hyundai",
+	"KNA": "Kia",
+	"KNC": "Kia",
+	"KND": "Kia",
+	"KNE": "Kia",
+	"KL":  "Chevrolet",
+
+	// China
+	"LVV": "Chery",
+	"L6T": "Geely",
+	"LGW": "Haval",
+	"LSV": "Volkswagen",
+	"LFV": "Volkswagen",
+	"LBV": "BMW",
+
+	// Europe
+	"WBA": "BMW",
+	"WBS": "BMW",
+	"WBY": "BMW",
+	"WDB": "Mercedes-Benz",
+	"WDC": "Mercedes-Benz",
+	"WDD": "Mercedes-Benz",
+	"W1K": "Mercedes-Benz",
+	"W1N": "Mercedes-Benz",
+	"WVW": "Volkswagen",
+	"WVG": "Volkswagen",
+	"WV1": "Volkswagen",
+	"WV2": "Volkswagen",
+	"WAU": "Audi",
+	"WUA": "Audi",
+	"WP0": "Porsche",
+	"WP1": "Porsche",
+	"W0L": "Opel",
+	"WF0": "Ford",
+	"WME": "Smart",
+	"VF1": "Renault",
+	"VF3": "Peugeot",
+	"VF7": "Citroen",
+	"TMB": "Skoda",
+	"YV1": "Volvo",
+	"YS3": "Saab",
+	"ZFA": "Fiat",
+	"ZAR": "Alfa Romeo",
+	"ZFF": "Ferrari",
+	"ZHW": "Lamborghini",
+	"SAL": "Land Rover",
+	"SAJ": "Jaguar",
+
+	// North America
+	"1F":  "Ford",
+	"3FA": "Ford",
+	"1G1": "Chevrolet",
+	"1GC": "Chevrolet",
+	"1HG": "Honda",
+	"2HG": "Honda",
+	"1N4": "Nissan",
+	"2T1": "Toyota",
+	"4T1": "Toyota",
+	"5YJ": "Tesla",
+	"5XY": "Kia",
+	"5NP": "Hyundai",
+	"3VW": "Volkswagen",
+}
+
+func manufacturer(vin string) string {
+	if name, ok := wmis[vin[:3]]; ok {
+		return name
+	}
+	return wmis[vin[:2]]
+}
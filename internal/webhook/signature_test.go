@@ -0,0 +1,36 @@
+package webhook_test
+
+import (
+	"car_catalog/internal/webhook"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1,"type":"car.created"}`)
+	now := time.Now()
+	header := webhook.Sign("s3cret", now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{"valid", "s3cret", header, body, time.Minute, nil},
+		{"wrong secret", "other", header, body, time.Minute, webhook.ErrSignatureMismatch},
+		{"tampered body", "s3cret", header, []byte(`{"id":2}`), time.Minute, webhook.ErrSignatureMismatch},
+		{"expired", "s3cret", webhook.Sign("s3cret", now.Add(-time.Hour), body), body, time.Minute, webhook.ErrSignatureExpired},
+		{"any age", "s3cret", webhook.Sign("s3cret", now.Add(-time.Hour), body), body, 0, nil},
+		{"no timestamp", "s3cret", "v1=abcd", body, time.Minute, webhook.ErrMalformedSignature},
+		{"no signature", "s3cret", "t=1", body, time.Minute, webhook.ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		if err := webhook.Verify(tt.secret, tt.header, tt.body, tt.tolerance); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
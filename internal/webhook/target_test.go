@@ -0,0 +1,66 @@
+package webhook_test
+
+import (
+	"car_catalog/internal/webhook"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		private bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"224.0.0.1", true},
+	}
+	for _, tt := range tests {
+		err := webhook.CheckAddr(netip.MustParseAddr(tt.addr))
+		if got := errors.Is(err, webhook.ErrPrivateAddress); got != tt.private {
+			t.Errorf("CheckAddr(%s) = %v, want private %v", tt.addr, err, tt.private)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	if err := webhook.CheckHost(ctx, "169.254.169.254"); !errors.Is(err, webhook.ErrPrivateAddress) {
+		t.Fatalf("CheckHost(metadata) = %v, want ErrPrivateAddress", err)
+	}
+	if err := webhook.CheckHost(ctx, "localhost"); !errors.Is(err, webhook.ErrPrivateAddress) {
+		t.Fatalf("CheckHost(localhost) = %v, want ErrPrivateAddress", err)
+	}
+	if err := webhook.CheckHost(ctx, "93.184.216.34"); err != nil {
+		t.Fatalf("CheckHost(public) = %v", err)
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := webhook.NewClient(time.Second).Post(server.URL, "application/json", nil)
+	if !errors.Is(err, webhook.ErrPrivateAddress) || called {
+		t.Fatalf("Post to %s = %v, called %v; want ErrPrivateAddress", server.URL, err, called)
+	}
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
-	"car_catalog/internal/pkg/app"
+	"car_catalog/internal/cli"
 	"log"
+	"os"
 )
 
 // @title Cars Catalog API
@@ -13,13 +14,7 @@ import (
 // @BasePath /
 
 func main() {
-	a, err := app.New()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = a.Run()
-	if err != nil {
+	if err := cli.Run(os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 }
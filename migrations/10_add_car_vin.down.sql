@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS cars.car_vin_idx;
+ALTER TABLE cars.car DROP COLUMN IF EXISTS vin;
@@ -0,0 +1,5 @@
+-- VIN (ISO 3779), normalized and validated by the application. Cars added
+-- before VINs were collected have NULL, which the unique index ignores.
+ALTER TABLE cars.car ADD COLUMN vin VARCHAR(17);
+
+CREATE UNIQUE INDEX car_vin_idx ON cars.car (vin);
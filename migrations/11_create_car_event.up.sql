@@ -0,0 +1,13 @@
+-- Change feed behind GET /api/cars/events. Events outlive their cars, so
+-- car_id has no foreign key; car is the state after the change as JSON.
+-- Appends notify the car_events channel, see PostgresCarEventLog.
+CREATE TABLE cars.car_event (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(16) NOT NULL,
+    car_id INTEGER NOT NULL,
+    tenant VARCHAR(100) NOT NULL DEFAULT '',
+    car JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX car_event_created_at_idx ON cars.car_event (created_at);
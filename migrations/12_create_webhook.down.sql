@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS cars.webhook_cursor;
+DROP TABLE IF EXISTS cars.webhook_delivery;
+DROP TABLE IF EXISTS cars.webhook_subscription;
@@ -0,0 +1,42 @@
+-- Webhook subscriptions and their deliveries. The dispatcher fans the
+-- car_event outbox out into webhook_delivery rows and moves
+-- webhook_cursor in the same transaction, so each event is queued once even
+-- with several instances. A subscription only receives the events after
+-- after_event_id, the newest event when it was created.
+CREATE TABLE cars.webhook_subscription (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    mark VARCHAR(100) NOT NULL DEFAULT '',
+    after_event_id BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- payload is the exact body that is signed and sent, so it is TEXT rather
+-- than JSONB, which would reformat it.
+CREATE TABLE cars.webhook_delivery (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES cars.webhook_subscription (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    event_type VARCHAR(16) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_delivery_due_idx ON cars.webhook_delivery (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_delivery_subscription_idx ON cars.webhook_delivery (subscription_id, id);
+
+CREATE TABLE cars.webhook_cursor (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_event_id BIGINT NOT NULL
+);
+
+INSERT INTO cars.webhook_cursor (last_event_id)
+SELECT COALESCE(MAX(id), 0) FROM cars.car_event;
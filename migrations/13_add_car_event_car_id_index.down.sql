@@ -0,0 +1 @@
+DROP INDEX IF EXISTS cars.car_event_car_id_idx;
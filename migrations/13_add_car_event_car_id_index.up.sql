@@ -0,0 +1,2 @@
+-- Ownership history reads the events of a page of cars at once.
+CREATE INDEX car_event_car_id_idx ON cars.car_event (car_id, id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cars.car_attachment;
@@ -0,0 +1,15 @@
+-- Files attached to cars. The content lives in the blob store under its
+-- SHA-256, so attachments with equal content share one blob; a car has each
+-- content at most once.
+CREATE TABLE cars.car_attachment (
+    id SERIAL PRIMARY KEY,
+    car_id INTEGER NOT NULL REFERENCES cars.car (id) ON DELETE CASCADE,
+    file_name TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (car_id, sha256)
+);
+
+CREATE INDEX car_attachment_sha256_idx ON cars.car_attachment (sha256);
@@ -0,0 +1,2 @@
+ALTER TABLE cars.car_divergence DROP COLUMN IF EXISTS error;
+ALTER TABLE cars.car_divergence DROP COLUMN IF EXISTS status;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS car_event_assign_id ON cars.car_event;
+DROP FUNCTION IF EXISTS cars.assign_car_event_id();
+ALTER TABLE cars.car_event ALTER COLUMN id SET DEFAULT nextval('cars.car_event_id_seq');
+DROP SEQUENCE IF EXISTS cars.car_event_pending_id_seq;
@@ -0,0 +1,21 @@
+-- Event ids are taken at commit, under a transaction-level advisory lock, so
+-- they become visible in id order and a reader following id > last never
+-- skips a late commit. The lock is held from the deferred trigger to the end
+-- of the commit rather than for the whole write. Until then a row carries a
+-- negative id of its own, which no other transaction sees.
+CREATE SEQUENCE cars.car_event_pending_id_seq;
+
+ALTER TABLE cars.car_event ALTER COLUMN id SET DEFAULT -nextval('cars.car_event_pending_id_seq');
+
+CREATE FUNCTION cars.assign_car_event_id() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(7311046);
+    UPDATE cars.car_event SET id = nextval('cars.car_event_id_seq') WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER car_event_assign_id
+    AFTER INSERT ON cars.car_event
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION cars.assign_car_event_id();
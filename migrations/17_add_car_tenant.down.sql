@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS cars.car_tenant_reg_num_prefix_idx;
+DROP INDEX IF EXISTS cars.car_tenant_model_prefix_idx;
+DROP INDEX IF EXISTS cars.car_tenant_mark_prefix_idx;
+ALTER TABLE cars.car DROP COLUMN IF EXISTS tenant;
@@ -0,0 +1,7 @@
+-- The API key each car was added with; empty for cars added without
+-- authentication. Suggestions only count the caller's own cars.
+ALTER TABLE cars.car ADD COLUMN tenant VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX car_tenant_mark_prefix_idx ON cars.car (tenant, lower(mark) text_pattern_ops);
+CREATE INDEX car_tenant_model_prefix_idx ON cars.car (tenant, lower(model) text_pattern_ops);
+CREATE INDEX car_tenant_reg_num_prefix_idx ON cars.car (tenant, reg_num text_pattern_ops);
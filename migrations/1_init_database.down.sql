@@ -1 +1,2 @@
-DROP TABLE IF EXISTS cars.car;
\ No newline at end of file
+DROP TABLE IF EXISTS car;
+DROP SCHEMA IF EXISTS cars;
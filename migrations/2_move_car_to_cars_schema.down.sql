@@ -0,0 +1 @@
+ALTER TABLE cars.car SET SCHEMA public;
@@ -0,0 +1,2 @@
+-- 1_init_database created the cars schema but put the car table into public.
+ALTER TABLE public.car SET SCHEMA cars;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cars.registry_cache;
@@ -0,0 +1,8 @@
+-- Persistent second level of the registry lookup cache. not_found rows are
+-- negative entries: the registry answered 404 for the plate.
+CREATE TABLE cars.registry_cache (
+    reg_num VARCHAR(20) PRIMARY KEY,
+    car JSONB,
+    not_found BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
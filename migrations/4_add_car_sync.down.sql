@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS cars.car_divergence;
+DROP INDEX IF EXISTS cars.car_last_synced_at_idx;
+ALTER TABLE cars.car DROP COLUMN IF EXISTS last_synced_at;
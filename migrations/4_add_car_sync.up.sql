@@ -0,0 +1,15 @@
+-- NULL means the car has never been compared with the registry.
+ALTER TABLE cars.car ADD COLUMN last_synced_at TIMESTAMPTZ;
+
+CREATE INDEX car_last_synced_at_idx ON cars.car (last_synced_at NULLS FIRST, id);
+
+CREATE TABLE cars.car_divergence (
+    id SERIAL PRIMARY KEY,
+    car_id INTEGER NOT NULL REFERENCES cars.car (id) ON DELETE CASCADE,
+    field VARCHAR(32) NOT NULL,
+    local_value TEXT NOT NULL,
+    registry_value TEXT NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX car_divergence_car_id_idx ON cars.car_divergence (car_id);
@@ -0,0 +1,16 @@
+-- Responses to requests sent with an Idempotency-Key header. A NULL status
+-- marks a request that is still being processed; its expires_at is the lock
+-- timeout rather than the replay TTL.
+CREATE TABLE cars.idempotency_key (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status INTEGER,
+    header JSONB,
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_key_expires_at_idx ON cars.idempotency_key (expires_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS cars.car_search_tsv_idx;
+DROP INDEX IF EXISTS cars.car_search_text_trgm_idx;
+
+ALTER TABLE cars.car DROP COLUMN IF EXISTS search_tsv;
+ALTER TABLE cars.car DROP COLUMN IF EXISTS search_text;
+
+-- pg_trgm is left installed: it may have existed before this migration.
@@ -0,0 +1,22 @@
+-- Fuzzy search for the q= parameter of /api/getCars. pg_trgm needs to be
+-- available to the database; creating it may require elevated privileges.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- search_text is what both indexes see: every searchable column, lower-cased,
+-- with ё folded into е. The Go matcher (package search) builds the same text.
+ALTER TABLE cars.car ADD COLUMN search_text TEXT GENERATED ALWAYS AS (
+    replace(lower(
+        mark || ' ' || model || ' ' || reg_num || ' ' ||
+        coalesce(owner_surname, '') || ' ' || coalesce(owner_name, '') || ' ' || coalesce(owner_patronymic, '')
+    ), 'ё', 'е')
+) STORED;
+
+ALTER TABLE cars.car ADD COLUMN search_tsv TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple'::regconfig, replace(lower(
+        mark || ' ' || model || ' ' || reg_num || ' ' ||
+        coalesce(owner_surname, '') || ' ' || coalesce(owner_name, '') || ' ' || coalesce(owner_patronymic, '')
+    ), 'ё', 'е'))
+) STORED;
+
+CREATE INDEX car_search_text_trgm_idx ON cars.car USING GIN (search_text gin_trgm_ops);
+CREATE INDEX car_search_tsv_idx ON cars.car USING GIN (search_tsv);
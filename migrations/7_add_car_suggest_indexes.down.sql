@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS cars.car_reg_num_prefix_idx;
+DROP INDEX IF EXISTS cars.car_model_prefix_idx;
+DROP INDEX IF EXISTS cars.car_mark_model_prefix_idx;
+DROP INDEX IF EXISTS cars.car_mark_prefix_idx;
@@ -0,0 +1,6 @@
+-- Prefix indexes for /api/suggest. text_pattern_ops lets LIKE 'abc%' use the
+-- index whatever the database collation is.
+CREATE INDEX car_mark_prefix_idx ON cars.car (lower(mark) text_pattern_ops);
+CREATE INDEX car_mark_model_prefix_idx ON cars.car (lower(mark), lower(model) text_pattern_ops);
+CREATE INDEX car_model_prefix_idx ON cars.car (lower(model) text_pattern_ops);
+CREATE INDEX car_reg_num_prefix_idx ON cars.car (reg_num text_pattern_ops);
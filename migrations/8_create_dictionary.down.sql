@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS cars.model_alias;
+DROP TABLE IF EXISTS cars.model_ref;
+DROP TABLE IF EXISTS cars.make_alias;
+DROP TABLE IF EXISTS cars.make;
@@ -0,0 +1,34 @@
+-- Reference dictionary of marks and models. Aliases hold normalized spellings
+-- (see service.DictionaryKey); car.mark and car.model keep the canonical name
+-- as text, so existing queries and exports are unaffected.
+CREATE TABLE cars.make (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE cars.make_alias (
+    alias VARCHAR(100) PRIMARY KEY,
+    make_id INTEGER NOT NULL REFERENCES cars.make (id) ON DELETE CASCADE
+);
+
+CREATE INDEX make_alias_make_id_idx ON cars.make_alias (make_id);
+
+CREATE TABLE cars.model_ref (
+    id SERIAL PRIMARY KEY,
+    make_id INTEGER NOT NULL REFERENCES cars.make (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    UNIQUE (make_id, name),
+    -- Lets model_alias check that its make is the model's make.
+    UNIQUE (id, make_id)
+);
+
+-- Model aliases are unique per make: "x5" means one model of BMW only.
+CREATE TABLE cars.model_alias (
+    make_id INTEGER NOT NULL,
+    alias VARCHAR(100) NOT NULL,
+    model_id INTEGER NOT NULL,
+    PRIMARY KEY (make_id, alias),
+    FOREIGN KEY (model_id, make_id) REFERENCES cars.model_ref (id, make_id) ON DELETE CASCADE
+);
+
+CREATE INDEX model_alias_model_id_idx ON cars.model_alias (model_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS cars.car_stats_refresh;
+DROP MATERIALIZED VIEW IF EXISTS cars.car_stats;
+DROP FUNCTION IF EXISTS cars.plate_region(TEXT);
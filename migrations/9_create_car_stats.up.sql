@@ -0,0 +1,24 @@
+-- Catalog statistics for /api/stats. plate_region mirrors regnum.Region: the
+-- region code of a well-formed plate, '' for anything else.
+CREATE FUNCTION cars.plate_region(reg_num TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT CASE WHEN reg_num ~ '^[ABEKMHOPCTYX][0-9]{3}[ABEKMHOPCTYX]{2}[0-9]{2,3}$'
+        THEN substr(reg_num, 7) ELSE '' END
+$$;
+
+-- car_stats pre-aggregates the dimensions that stay small per group. With
+-- stats.materialized the application refreshes it every
+-- stats.refresh_interval; the unique index allows REFRESH ... CONCURRENTLY.
+CREATE MATERIALIZED VIEW cars.car_stats AS
+SELECT mark, model, year, cars.plate_region(reg_num) AS region, COUNT(*) AS cars
+FROM cars.car
+GROUP BY 1, 2, 3, 4;
+
+CREATE UNIQUE INDEX car_stats_group_idx ON cars.car_stats (mark, model, year, region);
+
+-- Postgres does not record when a materialized view was refreshed.
+CREATE TABLE cars.car_stats_refresh (
+    refreshed_at TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO cars.car_stats_refresh (refreshed_at) VALUES (now());
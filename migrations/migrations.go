@@ -0,0 +1,15 @@
+// Package migrations embeds the schema migrations, so the binary does not
+// depend on the working directory it is started from.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLite holds the migrations of the embedded SQLite backend, under "sqlite".
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
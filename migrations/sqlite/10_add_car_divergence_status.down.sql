@@ -0,0 +1,2 @@
+ALTER TABLE car_divergence DROP COLUMN error;
+ALTER TABLE car_divergence DROP COLUMN status;
@@ -0,0 +1,6 @@
+-- Whether resync wrote the registry value to the car: applied, failed (error
+-- says why) or skipped, like a plate the registry no longer knows.
+ALTER TABLE car_divergence ADD COLUMN status TEXT NOT NULL DEFAULT 'applied';
+ALTER TABLE car_divergence ADD COLUMN error TEXT NOT NULL DEFAULT '';
+
+UPDATE car_divergence SET status = 'skipped' WHERE field = 'registry';
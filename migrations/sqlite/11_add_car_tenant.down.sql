@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS car_tenant_mark_model_idx;
+ALTER TABLE car DROP COLUMN tenant;
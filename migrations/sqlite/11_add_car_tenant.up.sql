@@ -0,0 +1,5 @@
+-- The API key each car was added with; empty for cars added without
+-- authentication. Suggestions only count the caller's own cars.
+ALTER TABLE car ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX car_tenant_mark_model_idx ON car (tenant, mark, model);
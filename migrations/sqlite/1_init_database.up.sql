@@ -0,0 +1,12 @@
+-- AUTOINCREMENT keeps ids from being reused after deletes, matching the
+-- Postgres SERIAL column.
+CREATE TABLE car (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    mark TEXT NOT NULL,
+    model TEXT NOT NULL,
+    year INTEGER NOT NULL,
+    reg_num TEXT NOT NULL UNIQUE,
+    owner_name TEXT,
+    owner_surname TEXT,
+    owner_patronymic TEXT
+);
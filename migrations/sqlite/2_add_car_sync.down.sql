@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS car_divergence;
+DROP INDEX IF EXISTS car_last_synced_at_idx;
+ALTER TABLE car DROP COLUMN last_synced_at;
@@ -0,0 +1,15 @@
+-- Times are Unix milliseconds, so they compare and sort as numbers.
+ALTER TABLE car ADD COLUMN last_synced_at INTEGER;
+
+CREATE INDEX car_last_synced_at_idx ON car (last_synced_at, id);
+
+CREATE TABLE car_divergence (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    car_id INTEGER NOT NULL REFERENCES car (id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    local_value TEXT NOT NULL,
+    registry_value TEXT NOT NULL,
+    detected_at INTEGER NOT NULL
+);
+
+CREATE INDEX car_divergence_car_id_idx ON car_divergence (car_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS car_mark_model_idx;
@@ -0,0 +1,2 @@
+-- Serves the GROUP BY of the suggest queries.
+CREATE INDEX car_mark_model_idx ON car (mark, model);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS model_alias;
+DROP TABLE IF EXISTS model_ref;
+DROP TABLE IF EXISTS make_alias;
+DROP TABLE IF EXISTS make;
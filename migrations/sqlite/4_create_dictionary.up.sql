@@ -0,0 +1,29 @@
+CREATE TABLE make (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE make_alias (
+    alias TEXT PRIMARY KEY,
+    make_id INTEGER NOT NULL REFERENCES make (id) ON DELETE CASCADE
+);
+
+CREATE INDEX make_alias_make_id_idx ON make_alias (make_id);
+
+CREATE TABLE model_ref (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    make_id INTEGER NOT NULL REFERENCES make (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (make_id, name),
+    UNIQUE (id, make_id)
+);
+
+CREATE TABLE model_alias (
+    make_id INTEGER NOT NULL,
+    alias TEXT NOT NULL,
+    model_id INTEGER NOT NULL,
+    PRIMARY KEY (make_id, alias),
+    FOREIGN KEY (model_id, make_id) REFERENCES model_ref (id, make_id) ON DELETE CASCADE
+);
+
+CREATE INDEX model_alias_model_id_idx ON model_alias (model_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS car_vin_idx;
+ALTER TABLE car DROP COLUMN vin;
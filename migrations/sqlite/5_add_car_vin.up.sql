@@ -0,0 +1,4 @@
+-- VIN, see the Postgres migration 10. NULLs are distinct in unique indexes.
+ALTER TABLE car ADD COLUMN vin TEXT;
+
+CREATE UNIQUE INDEX car_vin_idx ON car (vin);
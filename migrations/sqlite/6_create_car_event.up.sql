@@ -0,0 +1,12 @@
+-- AUTOINCREMENT keeps ids of pruned events from being reused, so a resumed
+-- stream never sees an id twice.
+CREATE TABLE car_event (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    car_id INTEGER NOT NULL,
+    tenant TEXT NOT NULL DEFAULT '',
+    car TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX car_event_created_at_idx ON car_event (created_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS car_event_car_id_idx;
@@ -0,0 +1,14 @@
+-- See the Postgres migration 14. Times are Unix milliseconds like elsewhere
+-- in this schema.
+CREATE TABLE car_attachment (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    car_id INTEGER NOT NULL REFERENCES car (id) ON DELETE CASCADE,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    UNIQUE (car_id, sha256)
+);
+
+CREATE INDEX car_attachment_sha256_idx ON car_attachment (sha256);